@@ -6,7 +6,10 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/configs"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/jobs"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/routers"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
 )
 
 // @title           Ticktiz Ticketing
@@ -26,6 +29,13 @@ func main() {
 		return
 	}
 
+	// JWT keys are loaded once, rotating keys only needs a restart with the new env
+	if err := pkg.InitKeySet(); err != nil {
+		log.Println("failed to load JWT keys\nCause: ", err.Error())
+		return
+	}
+	log.Println("✅ JWT keys loaded.")
+
 	// PostgreSQL DB Initialization
 	db, err := configs.InitDB()
 	if err != nil {
@@ -45,15 +55,47 @@ func main() {
 	rdb := configs.InitRDB()
 	defer rdb.Close()
 
-	// Test Redis Connection
+	// Test Redis Connection, without Redis the API still serves from Postgres (see GET /health)
 	if _, err := rdb.Ping(context.Background()).Result(); err != nil {
-		log.Println("failed to ping redis database\nCause: ", err.Error())
+		log.Println("⚠️ failed to ping redis database, running in degraded mode (no cache)\nCause: ", err.Error())
+	} else {
+		log.Println("✅ Successfully connect & ping to rdb!")
+	}
+
+	// Background job: watchlist notifications
+	notifier, err := configs.InitNotifier()
+	if err != nil {
+		log.Println("failed to init notifier\nCause: ", err.Error())
+		return
+	}
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobs.NewNotificationJob(repositories.NewNotificationRepository(db), notifier, configs.NotificationInterval()).Start(jobCtx)
+	log.Println("✅ Notification job started.")
+
+	// Background job: scheduled publish / archive of movies
+	jobs.NewMovieStatusJob(repositories.NewMovieRepository(db, rdb), configs.MovieStatusInterval()).Start(jobCtx)
+	log.Println("✅ Movie status job started.")
+
+	// Background job: personal data exports
+	jobs.NewDataExportJob(
+		repositories.NewExportRepository(db),
+		repositories.NewUserRepository(db, rdb),
+		repositories.NewOrderRepository(db, rdb),
+		configs.DataExportInterval(),
+		configs.DataExportTTL(),
+	).Start(jobCtx)
+	log.Println("✅ Data export job started.")
+
+	// Storage of uploaded images (local disk or S3 compatible)
+	storage, err := configs.InitStorage()
+	if err != nil {
+		log.Println("failed to init storage\nCause: ", err.Error())
 		return
 	}
-	log.Println("✅ Successfully connect & ping to rdb!")
 
 	// Engine Gin Initialization
-	router := routers.InitRouter(db, rdb)
+	router := routers.InitRouter(db, rdb, storage)
 	router.Run(":3000")
 
 	// Flow of the program
@@ -0,0 +1 @@
+DROP TABLE public.user_totp;
\ No newline at end of file
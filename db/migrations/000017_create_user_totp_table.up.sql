@@ -0,0 +1,20 @@
+-- public.user_totp definition
+
+-- Drop table
+
+-- DROP TABLE public.user_totp;
+
+CREATE TABLE public.user_totp (
+	user_id uuid NOT NULL,
+	secret text NOT NULL,
+	enabled_at timestamptz NULL,
+	last_used_step int8 NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	updated_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT user_totp_pkey PRIMARY KEY (user_id)
+);
+
+
+-- public.user_totp foreign keys
+
+ALTER TABLE public.user_totp ADD CONSTRAINT user_totp_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
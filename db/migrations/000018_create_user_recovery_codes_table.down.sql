@@ -0,0 +1 @@
+DROP TABLE public.user_recovery_codes;
\ No newline at end of file
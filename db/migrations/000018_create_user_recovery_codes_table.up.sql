@@ -0,0 +1,20 @@
+-- public.user_recovery_codes definition
+
+-- Drop table
+
+-- DROP TABLE public.user_recovery_codes;
+
+CREATE TABLE public.user_recovery_codes (
+	id int4 GENERATED ALWAYS AS IDENTITY( INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START 1 CACHE 1 NO CYCLE) NOT NULL,
+	user_id uuid NOT NULL,
+	code_hash text NOT NULL,
+	used_at timestamptz NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT user_recovery_codes_pkey PRIMARY KEY (id),
+	CONSTRAINT user_recovery_codes_user_id_code_hash_key UNIQUE (user_id, code_hash)
+);
+
+
+-- public.user_recovery_codes foreign keys
+
+ALTER TABLE public.user_recovery_codes ADD CONSTRAINT user_recovery_codes_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
@@ -0,0 +1,3 @@
+UPDATE public.users SET "password" = '' WHERE "password" IS NULL;
+ALTER TABLE public.users ALTER COLUMN "password" SET NOT NULL;
+DROP TABLE public.user_identities;
\ No newline at end of file
@@ -0,0 +1,27 @@
+-- public.user_identities definition
+
+-- Drop table
+
+-- DROP TABLE public.user_identities;
+
+CREATE TABLE public.user_identities (
+	id int4 GENERATED ALWAYS AS IDENTITY( INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START 1 CACHE 1 NO CYCLE) NOT NULL,
+	user_id uuid NOT NULL,
+	provider text NOT NULL,
+	subject text NOT NULL,
+	email text NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	last_login_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT user_identities_pkey PRIMARY KEY (id),
+	CONSTRAINT user_identities_provider_subject_key UNIQUE (provider, subject)
+);
+
+
+-- public.user_identities foreign keys
+
+ALTER TABLE public.user_identities ADD CONSTRAINT user_identities_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
+
+
+-- Users created through social login have no password
+
+ALTER TABLE public.users ALTER COLUMN "password" DROP NOT NULL;
@@ -0,0 +1 @@
+DROP TABLE public.permissions;
\ No newline at end of file
@@ -0,0 +1,21 @@
+-- public.permissions definition
+
+-- Drop table
+
+-- DROP TABLE public.permissions;
+
+CREATE TABLE public.permissions (
+	code text NOT NULL,
+	description text NULL,
+	CONSTRAINT permissions_pkey PRIMARY KEY (code)
+);
+
+
+-- The application checks these codes, so they are part of the schema (not db/seeds)
+
+INSERT INTO public.permissions (code,description) VALUES
+	 ('movies:write','Create and edit movies'),
+	 ('movies:archive','Archive movies'),
+	 ('tickets:scan','Scan tickets at the cinema entrance'),
+	 ('revenue:read','See sales and revenue'),
+	 ('roles:manage','Assign and revoke roles of users');
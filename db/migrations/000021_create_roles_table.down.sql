@@ -0,0 +1 @@
+DROP TABLE public.roles;
\ No newline at end of file
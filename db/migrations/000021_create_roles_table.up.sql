@@ -0,0 +1,22 @@
+-- public.roles definition
+
+-- Drop table
+
+-- DROP TABLE public.roles;
+
+CREATE TABLE public.roles (
+	id int4 GENERATED ALWAYS AS IDENTITY( INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START 1 CACHE 1 NO CYCLE) NOT NULL,
+	"name" text NOT NULL,
+	description text NULL,
+	CONSTRAINT roles_name_key UNIQUE (name),
+	CONSTRAINT roles_pkey PRIMARY KEY (id)
+);
+
+
+-- "user" and "admin" mirror users.role, every user implicitly has the role of that column
+
+INSERT INTO public.roles ("name",description) VALUES
+	 ('user','Customer'),
+	 ('admin','Full access'),
+	 ('cinema_staff','Scans tickets, usually scoped to one cinema'),
+	 ('content_editor','Edits movies, no access to revenue');
@@ -0,0 +1 @@
+DROP TABLE public.role_permissions;
\ No newline at end of file
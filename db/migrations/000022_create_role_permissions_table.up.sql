@@ -0,0 +1,29 @@
+-- public.role_permissions definition
+
+-- Drop table
+
+-- DROP TABLE public.role_permissions;
+
+CREATE TABLE public.role_permissions (
+	role_id int4 NOT NULL,
+	permission_code text NOT NULL,
+	CONSTRAINT role_permissions_pkey PRIMARY KEY (role_id, permission_code)
+);
+
+
+-- public.role_permissions foreign keys
+
+ALTER TABLE public.role_permissions ADD CONSTRAINT role_permissions_role_id_fkey FOREIGN KEY (role_id) REFERENCES public.roles(id) ON DELETE CASCADE;
+ALTER TABLE public.role_permissions ADD CONSTRAINT role_permissions_permission_code_fkey FOREIGN KEY (permission_code) REFERENCES public.permissions(code) ON DELETE CASCADE;
+
+
+INSERT INTO public.role_permissions (role_id,permission_code)
+SELECT r.id, p.code FROM public.roles r CROSS JOIN public.permissions p WHERE r."name" = 'admin';
+
+INSERT INTO public.role_permissions (role_id,permission_code)
+SELECT r.id, v.code FROM public.roles r
+JOIN (VALUES
+	 ('cinema_staff','tickets:scan'),
+	 ('content_editor','movies:write'),
+	 ('content_editor','movies:archive')
+) AS v(role_name, code) ON v.role_name = r."name";
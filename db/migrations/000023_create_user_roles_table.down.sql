@@ -0,0 +1 @@
+DROP TABLE public.user_roles;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- public.user_roles definition
+
+-- Drop table
+
+-- DROP TABLE public.user_roles;
+
+CREATE TABLE public.user_roles (
+	id int4 GENERATED ALWAYS AS IDENTITY( INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START 1 CACHE 1 NO CYCLE) NOT NULL,
+	user_id uuid NOT NULL,
+	role_id int4 NOT NULL,
+	cinema_id int4 NULL, -- NULL = the role applies to every cinema
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL,
+	CONSTRAINT user_roles_pkey PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX user_roles_user_role_cinema_key ON public.user_roles USING btree (user_id, role_id, COALESCE(cinema_id, 0));
+
+
+-- public.user_roles foreign keys
+
+ALTER TABLE public.user_roles ADD CONSTRAINT user_roles_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
+ALTER TABLE public.user_roles ADD CONSTRAINT user_roles_role_id_fkey FOREIGN KEY (role_id) REFERENCES public.roles(id) ON DELETE CASCADE;
+ALTER TABLE public.user_roles ADD CONSTRAINT user_roles_cinema_id_fkey FOREIGN KEY (cinema_id) REFERENCES public.cinemas(id);
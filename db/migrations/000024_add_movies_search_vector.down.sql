@@ -0,0 +1,18 @@
+DROP INDEX public.people_name_trgm_idx;
+DROP INDEX public.movies_title_trgm_idx;
+DROP INDEX public.movies_search_vector_idx;
+
+DROP TRIGGER genres_search_vector_update ON public.genres;
+DROP TRIGGER people_search_vector_update ON public.people;
+DROP TRIGGER movie_actors_search_vector_update ON public.movie_actors;
+DROP TRIGGER movie_genres_search_vector_update ON public.movie_genres;
+DROP TRIGGER movies_search_vector_update ON public.movies;
+
+DROP FUNCTION public.genres_search_vector_trigger();
+DROP FUNCTION public.people_search_vector_trigger();
+DROP FUNCTION public.movie_relation_search_vector_trigger();
+DROP FUNCTION public.movies_search_vector_trigger();
+DROP FUNCTION public.refresh_movie_search_vector(int4[]);
+DROP FUNCTION public.build_movie_search_vector(int4, text, text, int4);
+
+ALTER TABLE public.movies DROP COLUMN search_vector;
\ No newline at end of file
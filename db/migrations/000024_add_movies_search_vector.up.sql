@@ -0,0 +1,131 @@
+-- Full-text search over title, synopsis, genres, director and cast
+-- plus trigram indexes for typo tolerant matching
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE public.movies ADD search_vector tsvector NULL;
+
+
+-- Title & synopsis use the english config (stemming), names and genres use simple (no stemming)
+
+CREATE OR REPLACE FUNCTION public.build_movie_search_vector(p_movie_id int4, p_title text, p_synopsis text, p_director_id int4)
+RETURNS tsvector
+LANGUAGE sql
+STABLE
+AS $$
+	SELECT
+		setweight(to_tsvector('english', COALESCE(p_title, '')), 'A') ||
+		setweight(to_tsvector('simple', COALESCE(p_title, '')), 'A') ||
+		setweight(to_tsvector('simple', COALESCE((SELECT p."name" FROM public.people p WHERE p.id = p_director_id), '')), 'B') ||
+		setweight(to_tsvector('simple', COALESCE((
+			SELECT string_agg(p."name", ' ')
+			FROM public.movie_actors ma
+			JOIN public.people p ON p.id = ma.actor_id
+			WHERE ma.movie_id = p_movie_id
+		), '')), 'B') ||
+		setweight(to_tsvector('simple', COALESCE((
+			SELECT string_agg(g."name", ' ')
+			FROM public.movie_genres mg
+			JOIN public.genres g ON g.id = mg.genre_id
+			WHERE mg.movie_id = p_movie_id
+		), '')), 'C') ||
+		setweight(to_tsvector('english', COALESCE(p_synopsis, '')), 'D')
+$$;
+
+CREATE OR REPLACE FUNCTION public.refresh_movie_search_vector(p_movie_ids int4[])
+RETURNS void
+LANGUAGE sql
+AS $$
+	UPDATE public.movies m
+	SET search_vector = public.build_movie_search_vector(m.id, m.title, m.synopsis, m.director_id)
+	WHERE m.id = ANY(p_movie_ids)
+$$;
+
+
+-- public.movies: rebuilt when its own columns change
+
+CREATE OR REPLACE FUNCTION public.movies_search_vector_trigger()
+RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	NEW.search_vector := public.build_movie_search_vector(NEW.id, NEW.title, NEW.synopsis, NEW.director_id);
+	RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER movies_search_vector_update
+BEFORE INSERT OR UPDATE OF title, synopsis, director_id ON public.movies
+FOR EACH ROW EXECUTE FUNCTION public.movies_search_vector_trigger();
+
+
+-- public.movie_genres / public.movie_actors: rebuild the movie of the changed row
+
+CREATE OR REPLACE FUNCTION public.movie_relation_search_vector_trigger()
+RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM public.refresh_movie_search_vector(ARRAY[OLD.movie_id]);
+	ELSE
+		PERFORM public.refresh_movie_search_vector(ARRAY[NEW.movie_id]);
+	END IF;
+	RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER movie_genres_search_vector_update
+AFTER INSERT OR DELETE ON public.movie_genres
+FOR EACH ROW EXECUTE FUNCTION public.movie_relation_search_vector_trigger();
+
+CREATE TRIGGER movie_actors_search_vector_update
+AFTER INSERT OR DELETE ON public.movie_actors
+FOR EACH ROW EXECUTE FUNCTION public.movie_relation_search_vector_trigger();
+
+
+-- public.people / public.genres: a renamed person or genre changes every movie it appears in
+
+CREATE OR REPLACE FUNCTION public.people_search_vector_trigger()
+RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	PERFORM public.refresh_movie_search_vector(ARRAY(
+		SELECT m.id FROM public.movies m WHERE m.director_id = NEW.id
+		UNION
+		SELECT ma.movie_id FROM public.movie_actors ma WHERE ma.actor_id = NEW.id
+	));
+	RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER people_search_vector_update
+AFTER UPDATE OF "name" ON public.people
+FOR EACH ROW EXECUTE FUNCTION public.people_search_vector_trigger();
+
+CREATE OR REPLACE FUNCTION public.genres_search_vector_trigger()
+RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	PERFORM public.refresh_movie_search_vector(ARRAY(
+		SELECT mg.movie_id FROM public.movie_genres mg WHERE mg.genre_id = NEW.id
+	));
+	RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER genres_search_vector_update
+AFTER UPDATE OF "name" ON public.genres
+FOR EACH ROW EXECUTE FUNCTION public.genres_search_vector_trigger();
+
+
+-- Backfill & indexes
+
+UPDATE public.movies m
+SET search_vector = public.build_movie_search_vector(m.id, m.title, m.synopsis, m.director_id);
+
+CREATE INDEX movies_search_vector_idx ON public.movies USING gin (search_vector);
+CREATE INDEX movies_title_trgm_idx ON public.movies USING gin (title gin_trgm_ops);
+CREATE INDEX people_name_trgm_idx ON public.people USING gin ("name" gin_trgm_ops);
@@ -0,0 +1,2 @@
+DELETE FROM public.permissions WHERE code = 'reviews:moderate';
+DROP TABLE public.reviews;
\ No newline at end of file
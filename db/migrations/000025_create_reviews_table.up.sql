@@ -0,0 +1,38 @@
+-- public.reviews definition
+
+-- Drop table
+
+-- DROP TABLE public.reviews;
+
+CREATE TABLE public.reviews (
+	id int4 GENERATED ALWAYS AS IDENTITY( INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START 1 CACHE 1 NO CYCLE) NOT NULL,
+	movie_id int4 NOT NULL,
+	user_id uuid NOT NULL,
+	rating int2 NOT NULL,
+	body text DEFAULT ''::text NOT NULL,
+	hidden_at timestamptz NULL, -- hidden by a moderator, not shown and not counted in the average
+	flagged_at timestamptz NULL, -- marked for a moderator to look at
+	flag_reason text NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	updated_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT reviews_pkey PRIMARY KEY (id),
+	CONSTRAINT reviews_movie_id_user_id_key UNIQUE (movie_id, user_id),
+	CONSTRAINT reviews_rating_check CHECK ((rating >= 1) AND (rating <= 5))
+);
+CREATE INDEX reviews_movie_id_created_at_idx ON public.reviews USING btree (movie_id, created_at DESC, id DESC) WHERE (hidden_at IS NULL);
+CREATE INDEX reviews_flagged_at_idx ON public.reviews USING btree (flagged_at) WHERE (flagged_at IS NOT NULL);
+
+
+-- public.reviews foreign keys
+
+ALTER TABLE public.reviews ADD CONSTRAINT reviews_movie_id_fkey FOREIGN KEY (movie_id) REFERENCES public.movies(id);
+ALTER TABLE public.reviews ADD CONSTRAINT reviews_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+
+
+-- Moderation permission, admin gets it like every other permission, content editors too
+
+INSERT INTO public.permissions (code,description) VALUES
+	 ('reviews:moderate','Hide and flag reviews');
+
+INSERT INTO public.role_permissions (role_id,permission_code)
+SELECT r.id, 'reviews:moderate' FROM public.roles r WHERE r."name" IN ('admin', 'content_editor');
@@ -0,0 +1,5 @@
+DROP TRIGGER reviews_rating_update ON public.reviews;
+DROP FUNCTION public.reviews_rating_trigger();
+DROP FUNCTION public.refresh_movie_rating(int4);
+ALTER TABLE public.movies DROP COLUMN rating_count;
+ALTER TABLE public.movies DROP COLUMN rating_avg;
\ No newline at end of file
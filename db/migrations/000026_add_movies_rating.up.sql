@@ -0,0 +1,40 @@
+-- Rating aggregate of the visible reviews, stored on the movie so every movie list can show
+-- it without joining public.reviews
+
+ALTER TABLE public.movies ADD rating_avg numeric(3, 2) NULL; -- NULL = no reviews yet
+ALTER TABLE public.movies ADD rating_count int4 DEFAULT 0 NOT NULL;
+
+
+CREATE OR REPLACE FUNCTION public.refresh_movie_rating(p_movie_id int4)
+RETURNS void
+LANGUAGE sql
+AS $$
+	UPDATE public.movies m
+	SET
+		rating_avg = r.avg_rating,
+		rating_count = r.total
+	FROM (
+		SELECT ROUND(AVG(rating), 2) AS avg_rating, COUNT(*) AS total
+		FROM public.reviews
+		WHERE movie_id = p_movie_id AND hidden_at IS NULL
+	) r
+	WHERE m.id = p_movie_id
+$$;
+
+CREATE OR REPLACE FUNCTION public.reviews_rating_trigger()
+RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		PERFORM public.refresh_movie_rating(OLD.movie_id);
+	ELSE
+		PERFORM public.refresh_movie_rating(NEW.movie_id);
+	END IF;
+	RETURN NULL;
+END
+$$;
+
+CREATE TRIGGER reviews_rating_update
+AFTER INSERT OR DELETE OR UPDATE OF rating, hidden_at ON public.reviews
+FOR EACH ROW EXECUTE FUNCTION public.reviews_rating_trigger();
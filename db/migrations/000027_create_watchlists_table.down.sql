@@ -0,0 +1 @@
+DROP TABLE public.watchlists;
\ No newline at end of file
@@ -0,0 +1,20 @@
+-- public.watchlists definition
+
+-- Drop table
+
+-- DROP TABLE public.watchlists;
+
+CREATE TABLE public.watchlists (
+	user_id uuid NOT NULL,
+	movie_id int4 NOT NULL,
+	notified_at timestamptz NULL, -- set once the "tickets available" notification is queued
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT watchlists_pkey PRIMARY KEY (user_id, movie_id)
+);
+CREATE INDEX watchlists_pending_idx ON public.watchlists USING btree (movie_id) WHERE (notified_at IS NULL);
+
+
+-- public.watchlists foreign keys
+
+ALTER TABLE public.watchlists ADD CONSTRAINT watchlists_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
+ALTER TABLE public.watchlists ADD CONSTRAINT watchlists_movie_id_fkey FOREIGN KEY (movie_id) REFERENCES public.movies(id);
@@ -0,0 +1 @@
+DROP TABLE public.notifications;
\ No newline at end of file
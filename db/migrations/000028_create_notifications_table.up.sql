@@ -0,0 +1,28 @@
+-- public.notifications definition
+-- Outbox: rows are written in the same transaction as the event, a job delivers them afterwards
+
+-- Drop table
+
+-- DROP TABLE public.notifications;
+
+CREATE TABLE public.notifications (
+	id int8 GENERATED ALWAYS AS IDENTITY( INCREMENT BY 1 MINVALUE 1 MAXVALUE 9223372036854775807 START 1 CACHE 1 NO CYCLE) NOT NULL,
+	user_id uuid NOT NULL,
+	kind text NOT NULL, -- e.g. movie_scheduled
+	dedupe_key text NOT NULL, -- the same event is queued only once
+	title text NOT NULL,
+	message text NOT NULL,
+	payload jsonb DEFAULT '{}'::jsonb NOT NULL,
+	attempts int4 DEFAULT 0 NOT NULL,
+	last_error text NULL,
+	sent_at timestamptz NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT notifications_pkey PRIMARY KEY (id),
+	CONSTRAINT notifications_dedupe_key_key UNIQUE (dedupe_key)
+);
+CREATE INDEX notifications_pending_idx ON public.notifications USING btree (created_at) WHERE (sent_at IS NULL);
+
+
+-- public.notifications foreign keys
+
+ALTER TABLE public.notifications ADD CONSTRAINT notifications_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
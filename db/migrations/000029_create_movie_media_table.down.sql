@@ -0,0 +1 @@
+DROP TABLE public.movie_media;
\ No newline at end of file
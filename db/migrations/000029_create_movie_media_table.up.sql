@@ -0,0 +1,29 @@
+-- public.movie_media definition
+-- Trailers are stored as provider + video id, stills as a file in public/stills
+
+-- Drop table
+
+-- DROP TABLE public.movie_media;
+
+CREATE TABLE public.movie_media (
+	id int4 GENERATED ALWAYS AS IDENTITY( INCREMENT BY 1 MINVALUE 1 MAXVALUE 2147483647 START 1 CACHE 1 NO CYCLE) NOT NULL,
+	movie_id int4 NOT NULL,
+	kind text NOT NULL, -- trailer, image
+	provider text NULL, -- youtube, vimeo (trailer)
+	external_id text NULL, -- video id at the provider (trailer)
+	file text NULL, -- filename in public/stills (image)
+	caption text DEFAULT ''::text NOT NULL,
+	"position" int4 DEFAULT 0 NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT movie_media_pkey PRIMARY KEY (id),
+	CONSTRAINT movie_media_kind_check CHECK (
+		((kind = 'trailer') AND (provider IN ('youtube', 'vimeo')) AND (external_id IS NOT NULL) AND (file IS NULL))
+		OR ((kind = 'image') AND (file IS NOT NULL) AND (provider IS NULL) AND (external_id IS NULL))
+	)
+);
+CREATE INDEX movie_media_movie_id_position_idx ON public.movie_media USING btree (movie_id, "position", id);
+
+
+-- public.movie_media foreign keys
+
+ALTER TABLE public.movie_media ADD CONSTRAINT movie_media_movie_id_fkey FOREIGN KEY (movie_id) REFERENCES public.movies(id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+DROP TABLE public.people_aliases;
+DROP INDEX public.people_name_lower_idx;
+
+ALTER TABLE public.people DROP COLUMN updated_at;
+ALTER TABLE public.people DROP COLUMN birth_date;
+ALTER TABLE public.people DROP COLUMN photo_img;
+ALTER TABLE public.people DROP COLUMN biography;
\ No newline at end of file
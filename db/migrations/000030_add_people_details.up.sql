@@ -0,0 +1,31 @@
+-- Person pages: biography, photo and birth date
+
+ALTER TABLE public.people ADD biography text DEFAULT ''::text NOT NULL;
+ALTER TABLE public.people ADD photo_img text NULL;
+ALTER TABLE public.people ADD birth_date date NULL;
+ALTER TABLE public.people ADD updated_at timestamptz DEFAULT CURRENT_TIMESTAMP NULL;
+
+
+-- public.people_aliases definition
+-- Names of people that were merged into another person. Creating a movie with an old
+-- spelling in Cast / Director links the merged person instead of creating the duplicate again.
+
+-- Drop table
+
+-- DROP TABLE public.people_aliases;
+
+CREATE TABLE public.people_aliases (
+	person_id int4 NOT NULL,
+	alias text NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+CREATE UNIQUE INDEX people_aliases_alias_key ON public.people_aliases USING btree (lower(alias));
+CREATE INDEX people_aliases_person_id_idx ON public.people_aliases USING btree (person_id);
+
+-- lookups by name ignore case
+CREATE INDEX people_name_lower_idx ON public.people USING btree (lower("name"));
+
+
+-- public.people_aliases foreign keys
+
+ALTER TABLE public.people_aliases ADD CONSTRAINT people_aliases_person_id_fkey FOREIGN KEY (person_id) REFERENCES public.people(id) ON DELETE CASCADE;
@@ -0,0 +1,10 @@
+DROP INDEX public.movies_archive_at_idx;
+DROP INDEX public.movies_publish_at_idx;
+DROP INDEX public.movies_status_idx;
+
+ALTER TABLE public.movies DROP CONSTRAINT movies_archived_at_check;
+ALTER TABLE public.movies DROP CONSTRAINT movies_status_check;
+
+ALTER TABLE public.movies DROP COLUMN archive_at;
+ALTER TABLE public.movies DROP COLUMN publish_at;
+ALTER TABLE public.movies DROP COLUMN status;
\ No newline at end of file
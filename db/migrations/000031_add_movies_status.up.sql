@@ -0,0 +1,16 @@
+-- Movie lifecycle: draft -> published -> archived
+-- publish_at / archive_at are applied by the movie status job. archived_at stays the moment the
+-- movie was archived, the check keeps it in line with status.
+
+ALTER TABLE public.movies ADD status text DEFAULT 'published'::text NOT NULL;
+ALTER TABLE public.movies ADD publish_at timestamptz NULL;
+ALTER TABLE public.movies ADD archive_at timestamptz NULL;
+
+UPDATE public.movies SET status = 'archived' WHERE archived_at IS NOT NULL;
+
+ALTER TABLE public.movies ADD CONSTRAINT movies_status_check CHECK ((status = ANY (ARRAY['draft'::text, 'published'::text, 'archived'::text])));
+ALTER TABLE public.movies ADD CONSTRAINT movies_archived_at_check CHECK (((status = 'archived'::text) = (archived_at IS NOT NULL)));
+
+CREATE INDEX movies_status_idx ON public.movies USING btree (status);
+CREATE INDEX movies_publish_at_idx ON public.movies USING btree (publish_at) WHERE (status = 'draft'::text);
+CREATE INDEX movies_archive_at_idx ON public.movies USING btree (archive_at) WHERE (status <> 'archived'::text);
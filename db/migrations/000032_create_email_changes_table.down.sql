@@ -0,0 +1 @@
+DROP TABLE public.email_changes;
\ No newline at end of file
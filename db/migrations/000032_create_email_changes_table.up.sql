@@ -0,0 +1,22 @@
+-- public.email_changes definition
+-- A requested change of the login email, it is applied once the link sent to the new address is opened.
+-- Only the sha256 of the token is stored.
+
+-- Drop table
+
+-- DROP TABLE public.email_changes;
+
+CREATE TABLE public.email_changes (
+	user_id uuid NOT NULL,
+	new_email text NOT NULL,
+	token_hash text NOT NULL,
+	expires_at timestamptz NOT NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT email_changes_pkey PRIMARY KEY (user_id),
+	CONSTRAINT email_changes_token_hash_key UNIQUE (token_hash)
+);
+
+
+-- public.email_changes foreign keys
+
+ALTER TABLE public.email_changes ADD CONSTRAINT email_changes_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
@@ -0,0 +1 @@
+DROP TABLE public.login_events;
\ No newline at end of file
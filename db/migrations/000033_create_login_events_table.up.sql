@@ -0,0 +1,22 @@
+-- public.login_events definition
+-- Successful logins (a token was issued), shown in the data export
+
+-- Drop table
+
+-- DROP TABLE public.login_events;
+
+CREATE TABLE public.login_events (
+	id int8 GENERATED ALWAYS AS IDENTITY( INCREMENT BY 1 MINVALUE 1 MAXVALUE 9223372036854775807 START 1 CACHE 1 NO CYCLE) NOT NULL,
+	user_id uuid NOT NULL,
+	"method" text NOT NULL, -- password, oidc:<provider> or 2fa
+	ip_address text NULL,
+	user_agent text NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT login_events_pkey PRIMARY KEY (id)
+);
+CREATE INDEX login_events_user_id_created_at_idx ON public.login_events USING btree (user_id, created_at DESC);
+
+
+-- public.login_events foreign keys
+
+ALTER TABLE public.login_events ADD CONSTRAINT login_events_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
@@ -0,0 +1 @@
+DROP TABLE public.data_exports;
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- public.data_exports definition
+-- Personal data exports, the ZIP is built by a background job and kept until expires_at
+
+-- Drop table
+
+-- DROP TABLE public.data_exports;
+
+CREATE TABLE public.data_exports (
+	id uuid DEFAULT gen_random_uuid() NOT NULL,
+	user_id uuid NOT NULL,
+	status text DEFAULT 'pending'::text NOT NULL,
+	archive bytea NULL,
+	size_bytes int4 NULL,
+	attempts int4 DEFAULT 0 NOT NULL,
+	last_error text NULL,
+	started_at timestamptz NULL, -- a processing export that started long ago is picked up again
+	completed_at timestamptz NULL,
+	expires_at timestamptz NULL,
+	created_at timestamptz DEFAULT CURRENT_TIMESTAMP NOT NULL,
+	CONSTRAINT data_exports_pkey PRIMARY KEY (id),
+	CONSTRAINT data_exports_status_check CHECK ((status = ANY (ARRAY['pending'::text, 'processing'::text, 'ready'::text, 'failed'::text])))
+);
+CREATE INDEX data_exports_user_id_created_at_idx ON public.data_exports USING btree (user_id, created_at DESC);
+-- only one export of a user is prepared at a time
+CREATE UNIQUE INDEX data_exports_user_id_active_key ON public.data_exports USING btree (user_id) WHERE (status = ANY (ARRAY['pending'::text, 'processing'::text]));
+
+
+-- public.data_exports foreign keys
+
+ALTER TABLE public.data_exports ADD CONSTRAINT data_exports_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE CASCADE;
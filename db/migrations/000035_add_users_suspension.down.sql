@@ -0,0 +1,5 @@
+DELETE FROM public.permissions WHERE code = 'users:manage';
+
+ALTER TABLE public.users DROP COLUMN password_reset_required;
+ALTER TABLE public.users DROP COLUMN suspended_reason;
+ALTER TABLE public.users DROP COLUMN suspended_at;
\ No newline at end of file
@@ -0,0 +1,13 @@
+-- Admin user management: suspended users can't login and their tokens are rejected
+-- (user blacklist key in redis), password_reset_required makes the next password login set a new password
+
+ALTER TABLE public.users ADD suspended_at timestamptz NULL;
+ALTER TABLE public.users ADD suspended_reason text NULL;
+ALTER TABLE public.users ADD password_reset_required bool DEFAULT false NOT NULL;
+
+
+INSERT INTO public.permissions (code,description) VALUES
+	 ('users:manage','See users, change their role, suspend them and force a password reset');
+
+INSERT INTO public.role_permissions (role_id,permission_code)
+SELECT r.id, 'users:manage' FROM public.roles r WHERE r."name" = 'admin';
@@ -0,0 +1,13 @@
+-- Orders of deleted accounts have no user anymore and can't get the NOT NULL back. They are revenue data,
+-- so instead of deleting them this step stops: remove or reassign them by hand, then migrate down again.
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM public.transactions WHERE user_id IS NULL) THEN
+		RAISE EXCEPTION 'transactions of deleted accounts exist (user_id IS NULL), remove or reassign them before migrating down';
+	END IF;
+END
+$$;
+
+ALTER TABLE public.transactions DROP CONSTRAINT transactions_user_id_fkey;
+ALTER TABLE public.transactions ADD CONSTRAINT transactions_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id);
+ALTER TABLE public.transactions ALTER COLUMN user_id SET NOT NULL;
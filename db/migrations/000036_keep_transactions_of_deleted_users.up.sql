@@ -0,0 +1,5 @@
+-- Account deletion: orders stay for the revenue reports, without the user and the contact data
+
+ALTER TABLE public.transactions ALTER COLUMN user_id DROP NOT NULL;
+ALTER TABLE public.transactions DROP CONSTRAINT transactions_user_id_fkey;
+ALTER TABLE public.transactions ADD CONSTRAINT transactions_user_id_fkey FOREIGN KEY (user_id) REFERENCES public.users(id) ON DELETE SET NULL;
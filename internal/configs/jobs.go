@@ -0,0 +1,33 @@
+package configs
+
+import (
+	"os"
+	"time"
+)
+
+// MovieStatusInterval is how often scheduled publish / archive dates are applied (MOVIE_STATUS_INTERVAL), default 1 minute
+func MovieStatusInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("MOVIE_STATUS_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// DataExportInterval is how often requested data exports are built (DATA_EXPORT_INTERVAL), default 1 minute
+func DataExportInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("DATA_EXPORT_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
+
+// DataExportTTL is how long a ready data export can be downloaded (DATA_EXPORT_TTL), default 72 hours
+func DataExportTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("DATA_EXPORT_TTL"))
+	if err != nil || ttl <= 0 {
+		return 72 * time.Hour
+	}
+	return ttl
+}
@@ -0,0 +1,34 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// InitNotifier picks the notifier from NOTIFIER (log or file), log is the default
+func InitNotifier() (pkg.Notifier, error) {
+	switch os.Getenv("NOTIFIER") {
+	case "", "log":
+		return pkg.LogNotifier{}, nil
+	case "file":
+		path := os.Getenv("NOTIFIER_FILE")
+		if path == "" {
+			path = "notifications.log"
+		}
+		return pkg.NewFileNotifier(path), nil
+	default:
+		return nil, fmt.Errorf("unknown NOTIFIER %q, use log or file", os.Getenv("NOTIFIER"))
+	}
+}
+
+// NotificationInterval is how often the notification job runs (NOTIFICATION_INTERVAL, e.g. 30s), default 1 minute
+func NotificationInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("NOTIFICATION_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+	return interval
+}
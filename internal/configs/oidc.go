@@ -0,0 +1,66 @@
+package configs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// oidcProviderConfig is one entry of the OIDC_PROVIDERS_FILE json array.
+// The secret can be given directly or through another env var (client_secret_env).
+type oidcProviderConfig struct {
+	Name            string   `json:"name"`
+	Issuer          string   `json:"issuer"`
+	ClientID        string   `json:"client_id"`
+	ClientSecret    string   `json:"client_secret"`
+	ClientSecretEnv string   `json:"client_secret_env"`
+	RedirectURL     string   `json:"redirect_url"`
+	Scopes          []string `json:"scopes"`
+}
+
+// InitOIDCProviders reads the provider list, no file configured means social login is disabled
+func InitOIDCProviders() (map[string]*pkg.OIDCProvider, error) {
+	providers := map[string]*pkg.OIDCProvider{}
+
+	path := os.Getenv("OIDC_PROVIDERS_FILE")
+	if path == "" {
+		return providers, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return providers, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	var cfgs []oidcProviderConfig
+	if err := json.Unmarshal(raw, &cfgs); err != nil {
+		return providers, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	for _, cfg := range cfgs {
+		if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return map[string]*pkg.OIDCProvider{}, fmt.Errorf("provider %q: name, issuer, client_id and redirect_url are required", cfg.Name)
+		}
+		if _, exists := providers[cfg.Name]; exists {
+			return map[string]*pkg.OIDCProvider{}, fmt.Errorf("provider %q is configured twice", cfg.Name)
+		}
+
+		secret := cfg.ClientSecret
+		if cfg.ClientSecretEnv != "" {
+			secret = os.Getenv(cfg.ClientSecretEnv)
+		}
+
+		providers[cfg.Name] = &pkg.OIDCProvider{
+			Name:         cfg.Name,
+			Issuer:       cfg.Issuer,
+			ClientID:     cfg.ClientID,
+			ClientSecret: secret,
+			RedirectURL:  cfg.RedirectURL,
+			Scopes:       cfg.Scopes,
+		}
+	}
+
+	return providers, nil
+}
@@ -3,20 +3,47 @@ package configs
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
 	"github.com/redis/go-redis/v9"
 )
 
+// InitRDB creates the client, it does not connect yet. Every command goes through utils.RedisBreaker:
+// after REDIS_BREAKER_THRESHOLD connection failures in a row (default 5) Redis is not asked for
+// REDIS_BREAKER_COOLDOWN (default 30s) and the callers fall back to Postgres.
 func InitRDB() *redis.Client {
 	// redisUser := os.Getenv("REDIS_USER")
 	// redisPass := os.Getenv("REDIS_PASSWORD")
 	redisHost := os.Getenv("REDIS_HOST")
 	redisPort := os.Getenv("REDIS_PORT")
 
-	return redis.NewClient(&redis.Options{
+	rdb := redis.NewClient(&redis.Options{
 		Addr: fmt.Sprintf("%s:%s", redisHost, redisPort),
 		// Username: redisUser,
 		// Password: redisPass,
+		DialTimeout: 2 * time.Second,
 	})
 
+	threshold, err := strconv.Atoi(os.Getenv("REDIS_BREAKER_THRESHOLD"))
+	if err != nil || threshold <= 0 {
+		threshold = 5
+	}
+	cooldown, err := time.ParseDuration(os.Getenv("REDIS_BREAKER_COOLDOWN"))
+	if err != nil || cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	utils.RedisBreaker = utils.NewCircuitBreaker(threshold, cooldown)
+	rdb.AddHook(utils.RedisBreakerHook{Breaker: utils.RedisBreaker})
+
+	return rdb
+}
+
+// AuthRedisFailOpen tells what the token middlewares do when the blacklist can't be read (AUTH_REDIS_FAILURE).
+// "closed" (default) rejects the request with 503, "open" lets it through so a revoked token stays valid
+// until Redis is back or the token expires.
+func AuthRedisFailOpen() bool {
+	return strings.EqualFold(os.Getenv("AUTH_REDIS_FAILURE"), "open")
 }
@@ -0,0 +1,38 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// InitStorage picks where uploaded images are kept from STORAGE_DRIVER (local or s3), local is the default.
+// local writes to STORAGE_LOCAL_DIR (public) which is served under /api/v1/img.
+func InitStorage() (pkg.Storage, error) {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "public"
+		}
+		return pkg.NewLocalStorage(dir, utils.ImageBaseURL), nil
+	case "s3":
+		// S3_URL_EXPIRY is the lifetime of presigned links (e.g. 30m), only used without S3_PUBLIC_URL
+		expiry, _ := time.ParseDuration(os.Getenv("S3_URL_EXPIRY"))
+		return pkg.NewS3Storage(pkg.S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PathStyle: os.Getenv("S3_PATH_STYLE") == "true",
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+			URLExpiry: expiry,
+		})
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_DRIVER %q, use local or s3", os.Getenv("STORAGE_DRIVER"))
+	}
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+	"github.com/redis/go-redis/v9"
+)
+
+// ur : user repository, or : order repository, pr : permission repository (extra roles)
+type AdminUserHandler struct {
+	ur *repositories.UserRepository
+	or *repositories.OrderRepository
+	pr *repositories.PermissionRepository
+	ac *utils.AuthCacheManager
+}
+
+func NewAdminUserHandler(ur *repositories.UserRepository, or *repositories.OrderRepository, pr *repositories.PermissionRepository, rdb *redis.Client) *AdminUserHandler {
+	return &AdminUserHandler{
+		ur: ur,
+		or: or,
+		pr: pr,
+		ac: utils.NewAuthCacheManager(rdb),
+	}
+}
+
+// @Summary List users
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   q        query string false "Search in email and name"
+// @Param   role     query string false "admin or user"
+// @Param   status   query string false "active or suspended"
+// @Param   page     query int    false "Page number"
+// @Param   per_page query int    false "Users per page (default 20, max 100)"
+// @Param   cursor   query string false "next_cursor of the previous page, replaces page"
+// @Success 200 {object} models.SuccessResponse{data=pagination.Page[models.AdminUser]}
+// @Router  /api/v1/admin/users [get]
+func (a *AdminUserHandler) ListUsers(ctx *gin.Context) {
+	var filter models.AdminUserFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	if filter.Role != "" && !slices.Contains([]string{"admin", "user"}, filter.Role) {
+		utils.HandleError(ctx, http.StatusBadRequest, "role must be admin or user", "invalid role filter")
+		return
+	}
+	if filter.Status != "" && !slices.Contains([]string{"active", "suspended"}, filter.Status) {
+		utils.HandleError(ctx, http.StatusBadRequest, "status must be active or suspended", "invalid status filter")
+		return
+	}
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 20, 100)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
+
+	users, err := a.ur.ListUsers(ctx.Request.Context(), filter, params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    users,
+	})
+}
+
+// @Summary Get a user
+// @Description Account, profile, extra roles, 2FA and activity of the user
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path string true "User ID"
+// @Success 200 {object} models.AdminUserDetail
+// @Failure 404 {object} models.ErrorResponse
+// @Router  /api/v1/admin/users/{id} [get]
+func (a *AdminUserHandler) GetUser(ctx *gin.Context) {
+	user, err := a.ur.GetUserDetail(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		a.handleUserError(ctx, err)
+		return
+	}
+
+	user.ExtraRoles, err = a.pr.ListUserRoles(ctx.Request.Context(), user.ID)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if user.ExtraRoles == nil {
+		user.ExtraRoles = []models.UserRole{}
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    user,
+	})
+}
+
+// @Summary Order history of a user
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id       path  string true  "User ID"
+// @Param   page     query int    false "Page number"
+// @Param   per_page query int    false "Transactions per page (default 10, max 50)"
+// @Param   cursor   query string false "next_cursor of the previous page, replaces page"
+// @Success 200 {object} models.SuccessResponse{data=pagination.Page[models.TransactionHistory]}
+// @Failure 404 {object} models.ErrorResponse
+// @Router  /api/v1/admin/users/{id}/orders [get]
+func (a *AdminUserHandler) ListUserOrders(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 10, 50)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
+
+	exists, err := a.ur.UserExists(ctx.Request.Context(), userID)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if !exists {
+		utils.HandleError(ctx, http.StatusNotFound, repositories.ErrUserNotFound.Error(), "unknown user id")
+		return
+	}
+
+	orders, err := a.or.ListTransaction(ctx.Request.Context(), userID, params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    orders,
+	})
+}
+
+// @Summary Change the role of a user
+// @Description Sets users.role, the tokens of the user stop working because their permissions are outdated.
+// @Tags    Admin
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path string                       true "User ID"
+// @Param   body body models.ChangeUserRoleRequest true "New role"
+// @Success 200 {object} map[string]string "Role changed"
+// @Router  /api/v1/admin/users/{id}/role [patch]
+func (a *AdminUserHandler) ChangeRole(ctx *gin.Context) {
+	var req models.ChangeUserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	userID, ok := a.otherUserID(ctx, "change your own role")
+	if !ok {
+		return
+	}
+
+	if err := a.ur.ChangeRole(ctx.Request.Context(), userID, req.Role); err != nil {
+		a.handleUserError(ctx, err)
+		return
+	}
+	a.invalidateTokens(ctx, userID)
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    map[string]string{"message": "Role changed"},
+	})
+}
+
+// @Summary Suspend a user
+// @Description The user can't login anymore and every token of the user is rejected with 403.
+// @Tags    Admin
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path string                    true "User ID"
+// @Param   body body models.SuspendUserRequest true "Reason"
+// @Success 200 {object} map[string]string "User suspended"
+// @Router  /api/v1/admin/users/{id}/suspend [post]
+func (a *AdminUserHandler) SuspendUser(ctx *gin.Context) {
+	var req models.SuspendUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	userID, ok := a.otherUserID(ctx, "suspend yourself")
+	if !ok {
+		return
+	}
+
+	if err := a.ur.SuspendUser(ctx.Request.Context(), userID, req.Reason); err != nil {
+		a.handleUserError(ctx, err)
+		return
+	}
+	// the login checks the database, the tokens that are already out are stopped by the suspension key
+	if err := a.ac.SuspendUserTokens(ctx.Request.Context(), userID); err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "user suspended, but the tokens could not be revoked", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    map[string]string{"message": "User suspended"},
+	})
+}
+
+// @Summary Reactivate a suspended user
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path string true "User ID"
+// @Success 200 {object} map[string]string "User reactivated"
+// @Router  /api/v1/admin/users/{id}/reactivate [post]
+func (a *AdminUserHandler) ReactivateUser(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if err := a.ur.ReactivateUser(ctx.Request.Context(), userID); err != nil {
+		a.handleUserError(ctx, err)
+		return
+	}
+	if err := a.ac.ReactivateUserTokens(ctx.Request.Context(), userID, accessTokenLifetime); err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "user reactivated, but the tokens could not be restored", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    map[string]string{"message": "User reactivated"},
+	})
+}
+
+// @Summary Force a password reset
+// @Description Logs the user out everywhere, the next password login returns a reset token instead of a JWT
+// @Description (see /auth/password/reset). Accounts from a social login have no password and get 409.
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path string true "User ID"
+// @Success 200 {object} map[string]string "Password reset required"
+// @Failure 409 {object} models.ErrorResponse
+// @Router  /api/v1/admin/users/{id}/password-reset [post]
+func (a *AdminUserHandler) ForcePasswordReset(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if err := a.ur.RequirePasswordReset(ctx.Request.Context(), userID); err != nil {
+		a.handleUserError(ctx, err)
+		return
+	}
+	a.invalidateTokens(ctx, userID)
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    map[string]string{"message": "Password reset required"},
+	})
+}
+
+// otherUserID returns the :id of the route, admins can't do action to their own account
+// (they would lock themselves out). On failure the error response is already written.
+func (a *AdminUserHandler) otherUserID(ctx *gin.Context, action string) (string, bool) {
+	admin, ok := claimsFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	userID := ctx.Param("id")
+	if userID == admin.UserId {
+		utils.HandleError(ctx, http.StatusBadRequest, "you can't "+action, "admin action on own account")
+		return "", false
+	}
+	return userID, true
+}
+
+func (a *AdminUserHandler) handleUserError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repositories.ErrUserNotFound):
+		utils.HandleError(ctx, http.StatusNotFound, err.Error(), err.Error())
+	case errors.Is(err, repositories.ErrUserWithoutPassword):
+		utils.HandleError(ctx, http.StatusConflict, err.Error(), err.Error())
+	default:
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+	}
+}
+
+// invalidateTokens forces a new login, like after a change of the extra roles
+func (a *AdminUserHandler) invalidateTokens(ctx *gin.Context, userID string) {
+	if err := a.ac.BlacklistUserTokens(ctx.Request.Context(), userID, accessTokenLifetime); err != nil {
+		log.Println("failed to invalidate tokens of the user.\nCause: ", err.Error())
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+)
+
+// er : export repository
+type ExportHandler struct {
+	er *repositories.ExportRepository
+}
+
+func NewExportHandler(er *repositories.ExportRepository) *ExportHandler {
+	return &ExportHandler{er: er}
+}
+
+// @Summary Request a copy of the personal data
+// @Description The ZIP (profile, transactions with seats, reviews and login history as JSON) is built in the
+// @Description background. A notification is sent when it is ready, GET /users/me/export shows the status.
+// @Tags    Users
+// @Produce json
+// @Security BearerAuth
+// @Success 202 {object} models.DataExport
+// @Failure 409 {object} models.ErrorResponse
+// @Router  /api/v1/users/me/export [post]
+func (e *ExportHandler) RequestExport(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	export, err := e.er.CreateExport(ctx.Request.Context(), user.UserId)
+	if err != nil {
+		if errors.Is(err, repositories.ErrExportInProgress) {
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), err.Error())
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusAccepted, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusAccepted,
+		Data:    export,
+	})
+}
+
+// @Summary Status of the latest data export
+// @Description download_url is set when the export is ready, until expires_at
+// @Tags    Users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.DataExport
+// @Failure 404 {object} models.ErrorResponse
+// @Router  /api/v1/users/me/export [get]
+func (e *ExportHandler) GetExport(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	export, err := e.er.LatestExport(ctx.Request.Context(), user.UserId)
+	if err != nil {
+		if errors.Is(err, repositories.ErrExportNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), err.Error())
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if export.Status == "ready" {
+		export.DownloadURL = utils.ExportDownloadURL(export.ID)
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    export,
+	})
+}
+
+// @Summary Download a data export
+// @Tags    Users
+// @Produce application/zip
+// @Security BearerAuth
+// @Param   id path string true "Export ID"
+// @Success 200 {file} file "ZIP archive"
+// @Failure 404 {object} models.ErrorResponse
+// @Router  /api/v1/users/me/export/{id}/download [get]
+func (e *ExportHandler) DownloadExport(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+
+	archive, completedAt, err := e.er.GetArchive(ctx.Request.Context(), user.UserId, ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, repositories.ErrExportNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), err.Error())
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	ctx.Header("Content-Disposition", `attachment; filename="tickitz-data-`+completedAt.Format("20060102")+`.zip"`)
+	ctx.Header("Cache-Control", "private, no-store")
+	ctx.Data(http.StatusOK, "application/zip", archive)
+}
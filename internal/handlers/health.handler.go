@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/configs"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+const healthCheckTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	db  *pgxpool.Pool
+	rdb *redis.Client
+}
+
+func NewHealthHandler(db *pgxpool.Pool, rdb *redis.Client) *HealthHandler {
+	return &HealthHandler{db: db, rdb: rdb}
+}
+
+// @Summary     Health of the API and its dependencies
+// @Description Without Redis the API still answers (degraded): the cache is skipped and the token blacklist
+// @Description follows auth_redis_failure. Only a Postgres outage answers 503.
+// @Tags        Health
+// @Produce     json
+// @Success     200 {object} models.HealthStatus
+// @Failure     503 {object} models.HealthStatus
+// @Router      /health [get]
+func (h *HealthHandler) Check(ctx *gin.Context) {
+	reqCtx, cancel := context.WithTimeout(ctx.Request.Context(), healthCheckTimeout)
+	defer cancel()
+
+	health := models.HealthStatus{
+		Status:           "ok",
+		Postgres:         "up",
+		Redis:            "up",
+		AuthRedisFailure: "closed",
+	}
+	if configs.AuthRedisFailOpen() {
+		health.AuthRedisFailure = "open"
+	}
+
+	if err := h.rdb.Ping(reqCtx).Err(); err != nil {
+		health.Status = "degraded"
+		health.Redis = "down"
+		health.RedisError = err.Error()
+	}
+	// read after the ping, it can open or close the circuit
+	health.RedisCircuit = utils.RedisBreaker.State()
+
+	status := http.StatusOK
+	if err := h.db.Ping(reqCtx); err != nil {
+		health.Status = "down"
+		health.Postgres = "down"
+		health.PostgresError = err.Error()
+		status = http.StatusServiceUnavailable
+	}
+
+	// load balancers read the status code, the body is not wrapped in SuccessResponse
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(status, health)
+}
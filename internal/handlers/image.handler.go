@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// st : storage of the uploaded images
+type ImageHandler struct {
+	st pkg.Storage
+}
+
+func NewImageHandler(st pkg.Storage) *ImageHandler {
+	return &ImageHandler{st: st}
+}
+
+// @Summary Image from the object storage
+// @Description Redirects to the (signed) URL of the image, used when the images are not on the local disk
+// @Tags    Images
+// @Param   filepath path string true "e.g. posters/1727000000_images_.png"
+// @Success 302
+// @Router  /api/v1/img/{filepath} [get]
+func (h *ImageHandler) ServeImage(ctx *gin.Context) {
+	url, err := h.st.URL(ctx.Request.Context(), ctx.Param("filepath"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusNotFound, "image not found", err.Error())
+		return
+	}
+
+	// presigned links expire, so the redirect itself is not cached for long
+	ctx.Header("Cache-Control", "public, max-age=60")
+	ctx.Redirect(http.StatusFound, url)
+}
+
+// saveUploadedImage checks the content of the upload, renders the variants (none for nil) and puts everything
+// into <dir> of the storage under a new name. field is the form field named in the 422 response.
+// It returns the filename, on failure the error response is already written.
+func saveUploadedImage(ctx *gin.Context, st pkg.Storage, field string, file *multipart.FileHeader, dir string, variants []pkg.ImageVariant) (string, bool) {
+	src, err := file.Open()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return "", false
+	}
+	defer src.Close()
+
+	img, err := pkg.ProcessImage(src, pkg.ImageLimitsFromEnv(), variants)
+	if err != nil {
+		var invalid pkg.ErrInvalidImage
+		if errors.As(err, &invalid) {
+			utils.HandleValidationError(ctx, models.FieldErrors{{Field: field, Message: invalid.Reason}})
+			return "", false
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "upload failed", err.Error())
+		return "", false
+	}
+
+	// the extension comes from the content, not from the name the client sent
+	filename := fmt.Sprintf("%d_images_%s", time.Now().UnixNano(), img.Ext)
+	if len(variants) > 0 {
+		filename = pkg.OriginalImageName(fmt.Sprint(time.Now().UnixNano()), img.Ext)
+	}
+
+	// the variants first, the original is what the database points to
+	files := []pkg.EncodedImage{}
+	for _, v := range img.Variants {
+		v.Name = pkg.ImageVariantName(filename, v.Name, v.Ext)
+		files = append(files, v)
+	}
+	files = append(files, pkg.EncodedImage{Name: filename, ContentType: img.ContentType, Data: img.Data})
+
+	for i, f := range files {
+		if err := st.Put(ctx.Request.Context(), path.Join(dir, f.Name), bytes.NewReader(f.Data), f.ContentType); err != nil {
+			for _, written := range files[:i] {
+				if err := st.Delete(context.WithoutCancel(ctx), path.Join(dir, written.Name)); err != nil {
+					log.Println("failed to remove uploaded image\nCause: ", err.Error())
+				}
+			}
+			utils.HandleError(ctx, http.StatusInternalServerError, "upload failed", err.Error())
+			return "", false
+		}
+	}
+	return filename, true
+}
+
+// removeUploadedImage deletes a file saved by saveUploadedImage and its variants, a leftover file is only logged.
+// It also runs when the client is already gone, so the request context is not cancelling it.
+func removeUploadedImage(ctx context.Context, st pkg.Storage, dir, filename string) {
+	filename = filepath.Base(filename)
+	for _, name := range append(pkg.ImageVariantFiles(filename), filename) {
+		if err := st.Delete(context.WithoutCancel(ctx), path.Join(dir, name)); err != nil {
+			log.Println("failed to remove uploaded image\nCause: ", err.Error())
+		}
+	}
+}
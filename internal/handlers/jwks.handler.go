@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// @Summary Public keys used to sign access tokens
+// @Tags    Auth
+// @Produce json
+// @Success 200 {object} pkg.JWKSet
+// @Router  /.well-known/jwks.json [get]
+func GetJWKS(ctx *gin.Context) {
+	ks, err := pkg.CurrentKeySet()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	// Other services cache this, a rotated key is published before it signs anything
+	ctx.Header("Cache-Control", "public, max-age=300")
+	// JWKS has its own format (RFC 7517), so it is not wrapped in SuccessResponse
+	ctx.JSON(http.StatusOK, ks.JWKS())
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// stills are stored next to posters and backdrops, served under /api/v1/img/stills
+const stillsDir = "stills"
+
+// mr : media repository, st : storage of the stills
+type MediaHandler struct {
+	mr *repositories.MediaRepository
+	st pkg.Storage
+}
+
+func NewMediaHandler(mr *repositories.MediaRepository, st pkg.Storage) *MediaHandler {
+	return &MediaHandler{mr: mr, st: st}
+}
+
+// @Summary List trailers and stills of a movie (admin)
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path int true "Movie ID"
+// @Success 200 {array} models.MovieMedia
+// @Router  /api/v1/admin/movies/{id}/media [get]
+func (h *MediaHandler) ListMedia(ctx *gin.Context) {
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "movie id must be integer")
+		return
+	}
+
+	media, err := h.mr.ListMovieMedia(ctx.Request.Context(), movieID)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    media,
+	})
+}
+
+// @Summary Add a trailer (admin)
+// @Tags    Admin
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path int                      true "Movie ID"
+// @Param   body body models.AddTrailerRequest true "YouTube / Vimeo url, or provider + video_id"
+// @Success 201 {object} models.MovieMedia
+// @Router  /api/v1/admin/movies/{id}/media/trailers [post]
+func (h *MediaHandler) AddTrailer(ctx *gin.Context) {
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "movie id must be integer")
+		return
+	}
+
+	var req models.AddTrailerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	provider, videoID, err := utils.ParseTrailer(req)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid trailer")
+		return
+	}
+
+	media, err := h.mr.AddTrailer(ctx.Request.Context(), movieID, provider, videoID, req.Caption)
+	if err != nil {
+		if errors.Is(err, repositories.ErrMediaMovieNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "add trailer failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusCreated,
+		Data:    media,
+	})
+}
+
+// @Summary Upload a still image (admin)
+// @Tags    Admin
+// @Accept  multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param   id      path     int    true  "Movie ID"
+// @Param   image   formData file   true  "png, jpg, jpeg or webp"
+// @Param   caption formData string false "Caption"
+// @Success 201 {object} models.MovieMedia
+// @Router  /api/v1/admin/movies/{id}/media/images [post]
+func (h *MediaHandler) AddImage(ctx *gin.Context) {
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "movie id must be integer")
+		return
+	}
+
+	var req models.AddImageRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	filename, ok := saveUploadedImage(ctx, h.st, "image", req.Image, stillsDir, nil)
+	if !ok {
+		return
+	}
+
+	media, err := h.mr.AddImage(ctx.Request.Context(), movieID, filename, req.Caption)
+	if err != nil {
+		removeUploadedImage(ctx, h.st, stillsDir, filename)
+		if errors.Is(err, repositories.ErrMediaMovieNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "add image failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusCreated,
+		Data:    media,
+	})
+}
+
+// @Summary Reorder trailers and stills (admin)
+// @Tags    Admin
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path int                        true "Movie ID"
+// @Param   body body models.ReorderMediaRequest true "Every media id of the movie in the new order"
+// @Success 200 {array} models.MovieMedia
+// @Router  /api/v1/admin/movies/{id}/media/order [put]
+func (h *MediaHandler) ReorderMedia(ctx *gin.Context) {
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "movie id must be integer")
+		return
+	}
+
+	var req models.ReorderMediaRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	if err := h.mr.ReorderMedia(ctx.Request.Context(), movieID, req.IDs); err != nil {
+		if errors.Is(err, repositories.ErrMediaOrderMismatch) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "reorder media failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	media, err := h.mr.ListMovieMedia(ctx.Request.Context(), movieID)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    media,
+	})
+}
+
+// @Summary Delete a trailer or still (admin)
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id       path int true "Movie ID"
+// @Param   media_id path int true "Media ID"
+// @Success 200 {object} models.SuccessResponse
+// @Router  /api/v1/admin/movies/{id}/media/{media_id} [delete]
+func (h *MediaHandler) DeleteMedia(ctx *gin.Context) {
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "movie id must be integer")
+		return
+	}
+	mediaID, err := strconv.Atoi(ctx.Param("media_id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid media_id", "media id must be integer")
+		return
+	}
+
+	media, err := h.mr.DeleteMedia(ctx.Request.Context(), movieID, mediaID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrMediaNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "delete media failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if media.Image != "" {
+		removeUploadedImage(ctx, h.st, stillsDir, media.Image)
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data: map[string]string{
+			"message": "Media deleted",
+		},
+	})
+}
@@ -1,28 +1,32 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"path/filepath"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
 )
 
-// mr : movie repository
+// mr : movie repository, st : storage of posters and backdrops
 type MovieHandler struct {
 	mr *repositories.MovieRepository
+	st pkg.Storage
 }
 
-func NewMovieHandler(mr *repositories.MovieRepository) *MovieHandler {
-	return &MovieHandler{mr: mr}
+func NewMovieHandler(mr *repositories.MovieRepository, st pkg.Storage) *MovieHandler {
+	return &MovieHandler{mr: mr, st: st}
 }
 
 // @Summary Get upcoming movies
@@ -33,7 +37,7 @@ func NewMovieHandler(mr *repositories.MovieRepository) *MovieHandler {
 func (m *MovieHandler) ListUpcomingMovies(ctx *gin.Context) {
 	upcomingMovies, err := m.mr.ListUpcomingMovies(ctx)
 	if err != nil {
-		utils.HandleError(ctx, http.StatusOK, err.Error(), "failed to list upcoming movies")
+		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), "failed to list upcoming movies")
 		return
 	}
 
@@ -66,65 +70,220 @@ func (m *MovieHandler) ListPopularMovies(ctx *gin.Context) {
 // @Summary Get filtered movies
 // @Tags    Movies
 // @Produce json
-// @Param   keywords query string false "Comma-separated keywords"
-// @Param   genres   query string false "Comma-separated genre IDs"
-// @Param   page     query int    false "Page number"
-// @Success 200 {object} models.SuccessResponse
+// @Param   keywords     query string false "Comma-separated keywords"
+// @Param   genres       query string false "Comma-separated genre IDs"
+// @Param   age_ratings  query string false "Comma-separated age rating IDs"
+// @Param   release_from query string false "Released on or after (YYYY-MM-DD)"
+// @Param   release_to   query string false "Released on or before (YYYY-MM-DD)"
+// @Param   min_duration query int    false "Minimum duration in minutes"
+// @Param   max_duration query int    false "Maximum duration in minutes"
+// @Param   status       query string false "now_showing or upcoming"
+// @Param   city_id      query int    false "Only movies with an upcoming schedule in this city"
+// @Param   cinema_id    query int    false "Only movies with an upcoming schedule in this cinema"
+// @Param   sort         query string false "title, release_date (default), popularity or rating"
+// @Param   order        query string false "asc or desc"
+// @Param   page         query int    false "Page number"
+// @Param   per_page     query int    false "Movies per page (default 20, max 50)"
+// @Param   cursor       query string false "next_cursor of the previous page, replaces page"
+// @Success 200 {object} models.SuccessResponse{data=pagination.Page[models.Movie]}
+// @Failure 400 {object} models.ErrorResponse
 // @Router  /api/v1/movies/ [get]
 func (m *MovieHandler) ListFilteredMovies(ctx *gin.Context) {
-	keywordParam := ctx.Query("keywords")
-	genreParam := ctx.Query("genres")
-	pageParam := ctx.Query("page")
+	filter, err := parseMovieFilter(ctx)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid movie filter")
+		return
+	}
+	if err := utils.ValidateMovieFilter(filter); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid movie filter")
+		return
+	}
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 20, 50)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
+
+	// Call repo
+	movies, err := m.mr.ListMovieFiltered(ctx.Request.Context(), filter, params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), "cannot get filtered movies")
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    movies,
+	})
+}
+
+// parseMovieFilter reads the query string of GET /movies, malformed values are an error instead of being ignored
+func parseMovieFilter(ctx *gin.Context) (models.MovieFilter, error) {
+	filter := models.MovieFilter{
+		Keywords: []string{},
+		Status:   ctx.Query("status"),
+		Sort:     ctx.Query("sort"),
+		Order:    strings.ToLower(ctx.Query("order")),
+	}
 
 	// Convert to []string
-	keywords := []string{}
-	if keywordParam != "" {
-		keywords = strings.Split(keywordParam, ",")
+	if keywordParam := ctx.Query("keywords"); keywordParam != "" {
+		for _, kw := range strings.Split(keywordParam, ",") {
+			if kw = strings.TrimSpace(kw); kw != "" {
+				filter.Keywords = append(filter.Keywords, kw)
+			}
+		}
 	}
 
 	// Convert to []int
-	genres := []int{}
-	if genreParam != "" {
-		genreStrings := strings.Split(genreParam, ",")
-		for _, g := range genreStrings {
-			if id, err := strconv.Atoi(g); err == nil {
-				genres = append(genres, id)
-			}
+	var err error
+	if filter.Genres, err = parseIntList(ctx.Query("genres"), "genres"); err != nil {
+		return filter, err
+	}
+	if filter.AgeRatings, err = parseIntList(ctx.Query("age_ratings"), "age_ratings"); err != nil {
+		return filter, err
+	}
+
+	if filter.ReleasedFrom, err = parseDateParam(ctx.Query("release_from"), "release_from"); err != nil {
+		return filter, err
+	}
+	if filter.ReleasedTo, err = parseDateParam(ctx.Query("release_to"), "release_to"); err != nil {
+		return filter, err
+	}
+
+	if filter.MinDuration, err = parseIntParam(ctx.Query("min_duration"), "min_duration"); err != nil {
+		return filter, err
+	}
+	if filter.MaxDuration, err = parseIntParam(ctx.Query("max_duration"), "max_duration"); err != nil {
+		return filter, err
+	}
+	if filter.CityID, err = parseIntParam(ctx.Query("city_id"), "city_id"); err != nil {
+		return filter, err
+	}
+	if filter.CinemaID, err = parseIntParam(ctx.Query("cinema_id"), "cinema_id"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+func parseIntList(value, name string) ([]int, error) {
+	ids := []int{}
+	if value == "" {
+		return ids, nil
+	}
+	for _, part := range strings.Split(value, ",") {
+		id, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a comma-separated list of numbers", name)
 		}
+		ids = append(ids, id)
 	}
+	return ids, nil
+}
 
-	// Convert page
-	// If no page in param, then page = 0
-	page, _ := strconv.Atoi(pageParam)
+func parseIntParam(value, name string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a number", name)
+	}
+	return &n, nil
+}
 
-	// Set default
-	if page <= 0 {
-		page = 1
+func parseDateParam(value, name string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
 	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date in format YYYY-MM-DD", name)
+	}
+	return &date, nil
+}
 
-	// Calculate Limit & Offset based on page
-	limit := 20
-	offset := (page - 1) * limit
+// @Summary     Full-text movie search
+// @Description Results are ranked and can only be paged with page, there is no next_cursor.
+// @Tags        Movies
+// @Produce     json
+// @Param       q        query string true  "Title, person, genre or a phrase of the synopsis"
+// @Param       page     query int    false "Page number"
+// @Param       per_page query int    false "Results per page (default 20, max 50)"
+// @Success     200 {object} models.SuccessResponse{data=pagination.Page[models.MovieSearchResult]}
+// @Router      /api/v1/movies/search [get]
+func (m *MovieHandler) SearchMovies(ctx *gin.Context) {
+	q := strings.TrimSpace(ctx.Query("q"))
+	if q == "" {
+		utils.HandleError(ctx, http.StatusBadRequest, "q is required", "empty search query")
+		return
+	}
+	if len(q) > 100 {
+		utils.HandleError(ctx, http.StatusBadRequest, "q is too long", "search query longer than 100 characters")
+		return
+	}
 
-	// Build filter struct
-	filter := models.MovieFilter{
-		Keywords: keywords,
-		Genres:   genres,
-		Offset:   offset,
-		Limit:    limit,
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 20, 50)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
 	}
 
-	// Call repo
-	movies, err := m.mr.ListMovieFiltered(ctx, filter)
+	results, err := m.mr.SearchMovies(ctx.Request.Context(), q, params)
 	if err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), "cannot get filtered movies")
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
 		return
 	}
 
 	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Status:  http.StatusOK,
-		Data:    movies,
+		Data:    results,
+	})
+}
+
+// @Summary Autocomplete for the search box
+// @Tags    Movies
+// @Produce json
+// @Param   q     query string true  "What the user typed so far"
+// @Param   limit query int    false "Max suggestions (default 8, max 20)"
+// @Success 200 {array} models.Suggestion
+// @Router  /api/v1/movies/suggest [get]
+func (m *MovieHandler) SuggestMovies(ctx *gin.Context) {
+	q := strings.TrimSpace(ctx.Query("q"))
+	if len(q) > 100 {
+		utils.HandleError(ctx, http.StatusBadRequest, "q is too long", "suggest query longer than 100 characters")
+		return
+	}
+
+	limit, _ := strconv.Atoi(ctx.Query("limit"))
+	if limit <= 0 {
+		limit = 8
+	}
+	if limit > 20 {
+		limit = 20
+	}
+
+	suggestions, err := m.mr.Suggest(ctx.Request.Context(), q, limit)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    suggestions,
 	})
 }
 
@@ -137,8 +296,12 @@ func (m *MovieHandler) ListFilteredMovies(ctx *gin.Context) {
 func (m *MovieHandler) GetMovieDetails(ctx *gin.Context) {
 	movieID := ctx.Param("id")
 
-	movie, err := m.mr.GetMovieDetails(ctx, movieID)
+	movie, err := m.mr.GetCachedMovieDetails(ctx, movieID)
 	if err != nil {
+		if errors.Is(err, repositories.ErrMovieNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "get movie details failed")
+			return
+		}
 		log.Println("error : ", err)
 		utils.HandleResponse(ctx, http.StatusInternalServerError, models.ErrorResponse{
 			Success: false,
@@ -147,7 +310,47 @@ func (m *MovieHandler) GetMovieDetails(ctx *gin.Context) {
 		})
 		return
 	}
+	// drafts are only visible in the admin
+	if movie.Status == "draft" {
+		utils.HandleError(ctx, http.StatusNotFound, repositories.ErrMovieNotFound.Error(), "movie is a draft")
+		return
+	}
+	ctx.Header("ETag", movieETag(movie.UpdatedAt))
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    movie,
+	})
+}
+
+// @Summary     Get a movie (admin)
+// @Description Also drafts and archived movies, never from the cache. The ETag header is the If-Match of the edit.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path int true "Movie ID"
+// @Success     200 {object} models.Movie
+// @Failure     404 {object} models.ErrorResponse
+// @Router      /api/v1/admin/movies/{id} [get]
+func (m *MovieHandler) GetAdminMovie(ctx *gin.Context) {
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "movie id must be integer")
+		return
+	}
 
+	movie, err := m.mr.GetMovieDetails(ctx, strconv.Itoa(movieID))
+	if err != nil {
+		if errors.Is(err, repositories.ErrMovieNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "get admin movie failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	ctx.Header("ETag", movieETag(movie.UpdatedAt))
 	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Status:  http.StatusOK,
@@ -156,29 +359,59 @@ func (m *MovieHandler) GetMovieDetails(ctx *gin.Context) {
 }
 
 // @Summary List all movies (admin)
+// @Description Every movie comes with its schedule count. The tickets sold (seats of paid transactions) are only
+// @Description returned and sortable with the revenue:read permission, sort=tickets_sold without it is 403.
+// @Description status_counts counts the movies per status with every filter except status.
 // @Tags    Admin
 // @Produce json
 // @Security BearerAuth
-// @Success 200 {object} models.SuccessResponse
+// @Param   q            query string false "Part of the title"
+// @Param   genres       query string false "Comma-separated genre IDs"
+// @Param   status       query string false "draft, published or archived (default: everything but archived)"
+// @Param   release_from query string false "Released on or after (YYYY-MM-DD)"
+// @Param   release_to   query string false "Released on or before (YYYY-MM-DD)"
+// @Param   sort         query string false "updated_at (default), created_at, title, release_date, schedule_count or tickets_sold"
+// @Param   order        query string false "asc or desc"
+// @Param   page         query int    false "Page number"
+// @Param   per_page     query int    false "Movies per page (default 20, max 100)"
+// @Param   cursor       query string false "next_cursor of the previous page, replaces page"
+// @Success 200 {object} models.SuccessResponse{data=models.AdminMoviePage}
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Router  /api/v1/admin/movies [get]
 func (m *MovieHandler) ListAllMovies(ctx *gin.Context) {
-	pageParam := ctx.Query("page")
-
-	// Convert page
-	// If not page in param, then page = 0
-	page, _ := strconv.Atoi(pageParam)
-
-	// Set default
-	if page <= 0 {
-		page = 1
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+	filter, err := parseAdminMovieFilter(ctx)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid movie filter")
+		return
+	}
+	if err := utils.ValidateAdminMovieFilter(filter); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid movie filter")
+		return
 	}
 
-	// Calculate limit & offset based on page
-	limit := 10
-	offset := (page - 1) * limit
+	// content editors can use the list too, but the sales figures are for revenue:read only
+	filter.WithSales = user.HasPermission("revenue:read", "")
+	if filter.Sort == "tickets_sold" && !filter.WithSales {
+		utils.HandleError(ctx, http.StatusForbidden, "Anda tidak punya hak akses untuk resource ini", "Missing permission revenue:read")
+		return
+	}
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 20, 100)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
 
-	allMovies, err := m.mr.ListAllMovies(ctx, offset)
+	allMovies, err := m.mr.ListAllMovies(ctx.Request.Context(), filter, params)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
 		utils.HandleResponse(ctx, http.StatusInternalServerError, models.ErrorResponse{
 			Success: false,
 			Status:  http.StatusInternalServerError,
@@ -194,105 +427,206 @@ func (m *MovieHandler) ListAllMovies(ctx *gin.Context) {
 	})
 }
 
+// parseAdminMovieFilter reads the query string of GET /admin/movies, like parseMovieFilter
+func parseAdminMovieFilter(ctx *gin.Context) (models.AdminMovieFilter, error) {
+	filter := models.AdminMovieFilter{
+		Query:  strings.TrimSpace(ctx.Query("q")),
+		Status: ctx.Query("status"),
+		Sort:   ctx.Query("sort"),
+		Order:  strings.ToLower(ctx.Query("order")),
+	}
+
+	var err error
+	if filter.Genres, err = parseIntList(ctx.Query("genres"), "genres"); err != nil {
+		return filter, err
+	}
+	if filter.ReleasedFrom, err = parseDateParam(ctx.Query("release_from"), "release_from"); err != nil {
+		return filter, err
+	}
+	if filter.ReleasedTo, err = parseDateParam(ctx.Query("release_to"), "release_to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
 // @Summary Archive movie by ID (admin)
+// @Description Future schedules without bookings are removed. With paid bookings for future schedules the answer is 409, unless force=true.
 // @Tags    Admin
 // @Produce json
 // @Security BearerAuth
-// @Param   id path string true "Movie ID"
-// @Success 200 {object} models.SuccessResponse
+// @Param   id    path  int  true  "Movie ID"
+// @Param   force query bool false "Archive even with paid future bookings"
+// @Success 200 {object} models.ArchiveMovieRespond
+// @Failure 409 {object} models.ErrorResponse
 // @Router  /api/v1/admin/movies/{id}/archive [delete]
 func (m *MovieHandler) ArchiveMovieByID(ctx *gin.Context) {
-	movieId := ctx.Param("id")
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "movie id must be integer")
+		return
+	}
+	force := ctx.Query("force") == "true"
 
-	archievedMovieId, err := m.mr.ArchiveMovieByID(ctx, movieId)
+	archivedMovie, err := m.mr.ArchiveMovieByID(ctx, movieID, force)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"body":    0,
-		})
+		switch {
+		case errors.Is(err, repositories.ErrMovieNotFound):
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "archive movie failed")
+		case errors.Is(err, repositories.ErrMovieAlreadyArchived):
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), "archive movie failed")
+		case errors.Is(err, repositories.ErrMovieHasBookings):
+			msg := fmt.Sprintf("movie has %d paid bookings for future schedules, archive with force=true to keep those schedules", archivedMovie.PaidBookings)
+			utils.HandleError(ctx, http.StatusConflict, msg, "archive movie failed")
+		default:
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		}
+		return
 	}
 
 	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Status:  http.StatusOK,
-		Data:    archievedMovieId,
+		Data:    archivedMovie,
 	})
 }
 
-// @Summary Create a new movie (admin)
+// @Summary Restore an archived movie (admin)
+// @Description Schedules that were removed by archiving are not restored.
 // @Tags    Admin
-// @Accept  json
 // @Produce json
 // @Security BearerAuth
-// @Param   body body models.CreateMovie true "Movie data"
-// @Success 200 {object} models.SuccessResponse
-// @Router  /api/v1/admin/movies [post]
-func (m *MovieHandler) CreateMovie(ctx *gin.Context) {
-	var body models.CreateMovie
-	if err := ctx.ShouldBind(&body); err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+// @Param   id     path  int    true  "Movie ID"
+// @Param   status query string false "published (default) or draft"
+// @Success 200 {object} models.Movie
+// @Failure 409 {object} models.ErrorResponse
+// @Router  /api/v1/admin/movies/{id}/unarchive [post]
+func (m *MovieHandler) UnarchiveMovie(ctx *gin.Context) {
+	status := ctx.DefaultQuery("status", "published")
+	if status != "published" && status != "draft" {
+		utils.HandleError(ctx, http.StatusBadRequest, "status must be published or draft", "invalid status")
 		return
 	}
-	log.Println("Body : ")
-	log.Println(body)
-
-	// From postman must upload a new poster
-	filePoster := body.PosterImg
-	filenamePoster := ""
-	var locationPoster string
+	m.changeMovieStatus(ctx, func(movieID int) error {
+		return m.mr.UnarchiveMovie(ctx, movieID, status)
+	})
+}
 
-	if filePoster != nil {
-		ext := filepath.Ext(filePoster.Filename)
-		re := regexp.MustCompile(`(?i)\.(png|jpg|jpeg|webp)$`)
-		if !re.MatchString(ext) {
-			utils.HandleError(ctx, http.StatusBadRequest, "invalid file type", "only png, jpg, jpeg, webp allowed")
-			return
-		}
+// @Summary Publish a draft now (admin)
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path int true "Movie ID"
+// @Success 200 {object} models.Movie
+// @Failure 409 {object} models.ErrorResponse
+// @Router  /api/v1/admin/movies/{id}/publish [post]
+func (m *MovieHandler) PublishMovie(ctx *gin.Context) {
+	m.changeMovieStatus(ctx, func(movieID int) error {
+		return m.mr.PublishMovie(ctx, movieID)
+	})
+}
 
-		filenamePoster = fmt.Sprintf("%d_images_%s", time.Now().UnixNano(), ext) // replace "userID"
-		locationPoster = filepath.Join("public/posters", filenamePoster)
+// changeMovieStatus runs change for the movie of the url and answers with the updated movie
+func (m *MovieHandler) changeMovieStatus(ctx *gin.Context, change func(movieID int) error) {
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "movie id must be integer")
+		return
+	}
 
-		if err := ctx.SaveUploadedFile(filePoster, locationPoster); err != nil {
-			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "failed to upload")
-			return
+	if err := change(movieID); err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrMovieNotFound):
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "change movie status failed")
+		case errors.Is(err, repositories.ErrMovieNotArchived), errors.Is(err, repositories.ErrMovieNotDraft):
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), "change movie status failed")
+		default:
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
 		}
+		return
 	}
 
-	fileBackdrop := body.BackdropImg
-	filenameBackdrop := ""
-	var locationBackdrop string
+	movie, err := m.mr.GetMovieDetails(ctx, strconv.Itoa(movieID))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
 
-	if fileBackdrop != nil {
-		ext := filepath.Ext(fileBackdrop.Filename)
-		re := regexp.MustCompile(`(?i)\.(png|jpg|jpeg|webp)$`)
-		if !re.MatchString(ext) {
-			utils.HandleError(ctx, http.StatusBadRequest, "invalid file type", "only png, jpg, jpeg, webp allowed")
-			return
-		}
+	ctx.Header("ETag", movieETag(movie.UpdatedAt))
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    movie,
+	})
+}
 
-		filenameBackdrop = fmt.Sprintf("%d_images_%s", time.Now().UnixNano(), ext) // replace "userID"
-		locationBackdrop = filepath.Join("public/backdrops", filenameBackdrop)
+// @Summary Create a new movie (admin)
+// @Description Send the movie as JSON body, or as multipart form with the JSON in "metadata" and the images as files.
+// @Description Every invalid field is listed in the 422 response.
+// @Tags    Admin
+// @Accept  json,mpfd
+// @Produce json
+// @Security BearerAuth
+// @Param   body         body     models.MovieInput true  "Movie data (JSON body)"
+// @Param   metadata     formData string            false "Movie data as JSON (multipart)"
+// @Param   poster_img   formData file              false "png, jpg, jpeg or webp"
+// @Param   backdrop_img formData file              false "png, jpg, jpeg or webp"
+// @Success 201 {object} models.Movie
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Router  /api/v1/admin/movies [post]
+func (m *MovieHandler) CreateMovie(ctx *gin.Context) {
+	var body models.MovieInput
+	upload, ok := bindMoviePayload(ctx, &body, func(models.MovieUpload) models.FieldErrors { return utils.ValidateMovieInput(body) })
+	if !ok {
+		return
+	}
 
-		if err := ctx.SaveUploadedFile(fileBackdrop, locationBackdrop); err != nil {
-			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "failed to upload")
-			return
-		}
+	filenamePoster, filenameBackdrop, ok := m.saveMovieImages(ctx, upload)
+	if !ok {
+		return
 	}
 
-	newM, err := m.mr.CreateMovie(ctx, body, filenamePoster, filenameBackdrop)
+	newMovieID, err := m.mr.CreateMovie(ctx, body, filenamePoster, filenameBackdrop)
+	if err != nil {
+		m.removeMovieImages(ctx, filenamePoster, filenameBackdrop)
+		handleMovieWriteError(ctx, err, "create movie failed")
+		return
+	}
+
+	newM, err := m.mr.GetMovieDetails(ctx, strconv.Itoa(newMovieID))
 	if err != nil {
 		utils.HandleError(ctx, http.StatusInternalServerError, "status internal server error", err.Error())
 		return
 	}
 
-	log.Println("Newly created movie : ", newM)
-	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+	log.Println("Newly created movie : ", newM.ID)
+	ctx.Header("ETag", movieETag(newM.UpdatedAt))
+	utils.HandleResponse(ctx, http.StatusCreated, models.SuccessResponse{
 		Success: true,
-		Status:  http.StatusOK,
+		Status:  http.StatusCreated,
 		Data:    newM,
 	})
 }
 
+// @Summary Edit a movie (admin)
+// @Description Only the fields that are sent change. genre_ids and cast replace the lists, schedule replaces the
+// @Description schedules (schedules with sold tickets are kept), without it the schedules are not touched.
+// @Description If-Match must be the ETag of GET /admin/movies/{id} (or of the create / edit response), 412 when
+// @Description the movie was changed in the meantime.
+// @Tags    Admin
+// @Accept  json,mpfd
+// @Produce json
+// @Security BearerAuth
+// @Param   id           path     int               true  "Movie ID"
+// @Param   If-Match     header   string            true  "ETag of the movie"
+// @Param   body         body     models.MoviePatch true  "Changed fields (JSON body)"
+// @Param   metadata     formData string            false "Changed fields as JSON (multipart)"
+// @Param   poster_img   formData file              false "png, jpg, jpeg or webp"
+// @Param   backdrop_img formData file              false "png, jpg, jpeg or webp"
+// @Success 200 {object} models.Movie
+// @Failure 412 {object} models.ErrorResponse
+// @Failure 422 {object} models.ValidationErrorResponse
+// @Failure 428 {object} models.ErrorResponse
+// @Router  /api/v1/admin/movies/{id} [patch]
 func (m *MovieHandler) EditMovie(ctx *gin.Context) {
 	// Parse movie ID
 	idParam := ctx.Param("id")
@@ -302,66 +636,156 @@ func (m *MovieHandler) EditMovie(ctx *gin.Context) {
 		return
 	}
 
-	// Parse form
-	var body models.CreateMovie
-	if err := ctx.ShouldBind(&body); err != nil {
-		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+	// Version the client has seen
+	ifMatch := ctx.GetHeader("If-Match")
+	if ifMatch == "" {
+		utils.HandleError(ctx, http.StatusPreconditionRequired, "If-Match header with the ETag of the movie is required", "missing If-Match")
 		return
 	}
-
-	// Handle poster upload (optional)
-	filePoster := body.PosterImg
-	filenamePoster := ""
-	var locationPoster string
-	if filePoster != nil {
-		ext := filepath.Ext(filePoster.Filename)
-		re := regexp.MustCompile(`(?i)\.(png|jpg|jpeg|webp)$`)
-		if !re.MatchString(ext) {
-			utils.HandleError(ctx, http.StatusBadRequest, "invalid file type", "only png, jpg, jpeg, webp allowed")
-			return
-		}
-
-		filenamePoster = fmt.Sprintf("%d_images_%s", time.Now().UnixNano(), ext)
-		locationPoster = filepath.Join("public/posters", filenamePoster)
-
-		if err := ctx.SaveUploadedFile(filePoster, locationPoster); err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "upload failed", err.Error())
-			return
-		}
+	version, ok := parseMovieETag(ifMatch)
+	if !ok {
+		utils.HandleError(ctx, http.StatusPreconditionFailed, repositories.ErrMovieModified.Error(), "invalid If-Match")
+		return
 	}
 
-	// Handle backdrop upload (optional)
-	fileBackdrop := body.BackdropImg
-	filenameBackdrop := ""
-	var locationBackdrop string
-	if fileBackdrop != nil {
-		ext := filepath.Ext(fileBackdrop.Filename)
-		re := regexp.MustCompile(`(?i)\.(png|jpg|jpeg|webp)$`)
-		if !re.MatchString(ext) {
-			utils.HandleError(ctx, http.StatusBadRequest, "invalid file type", "only png, jpg, jpeg, webp allowed")
-			return
+	// Parse body
+	var patch models.MoviePatch
+	upload, ok := bindMoviePayload(ctx, &patch, func(upload models.MovieUpload) models.FieldErrors {
+		if patch == (models.MoviePatch{}) && upload.PosterImg == nil && upload.BackdropImg == nil {
+			return models.FieldErrors{{Field: "body", Message: "nothing to update"}}
 		}
+		return utils.ValidateMoviePatch(patch)
+	})
+	if !ok {
+		return
+	}
 
-		filenameBackdrop = fmt.Sprintf("%d_images_%s", time.Now().UnixNano(), ext)
-		locationBackdrop = filepath.Join("public/backdrops", filenameBackdrop)
-
-		if err := ctx.SaveUploadedFile(fileBackdrop, locationBackdrop); err != nil {
-			utils.HandleError(ctx, http.StatusInternalServerError, "upload failed", err.Error())
-			return
-		}
+	// Handle poster and backdrop upload (optional)
+	filenamePoster, filenameBackdrop, ok := m.saveMovieImages(ctx, upload)
+	if !ok {
+		return
 	}
 
 	// Call repository
-	updatedMovie, err := m.mr.EditMovie(ctx, movieID, body, filenamePoster, filenameBackdrop)
+	_, oldPoster, oldBackdrop, err := m.mr.EditMovie(ctx, movieID, patch, filenamePoster, filenameBackdrop, version)
+	if err != nil {
+		m.removeMovieImages(ctx, filenamePoster, filenameBackdrop)
+		handleMovieWriteError(ctx, err, "failed to update movie")
+		return
+	}
+	// the replaced images are not referenced anymore
+	m.removeMovieImages(ctx, oldPoster, oldBackdrop)
+
+	updatedMovie, err := m.mr.GetMovieDetails(ctx, idParam)
 	if err != nil {
 		utils.HandleError(ctx, http.StatusInternalServerError, "failed to update movie", err.Error())
 		return
 	}
 
 	// Response
+	ctx.Header("ETag", movieETag(updatedMovie.UpdatedAt))
 	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Status:  http.StatusOK,
 		Data:    updatedMovie,
 	})
 }
+
+// movieETag is the version of a movie for If-Match, taken from updated_at
+func movieETag(updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d"`, updatedAt.UnixMicro())
+}
+
+// parseMovieETag reads the If-Match header, "*" matches any version (nil)
+func parseMovieETag(header string) (*time.Time, bool) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return nil, true
+	}
+	micros, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	version := time.UnixMicro(micros)
+	return &version, true
+}
+
+// bindMoviePayload decodes the JSON body, or the "metadata" part of a multipart form, into body and
+// checks it with validate, which also gets the uploaded files. On failure the error response is already written.
+func bindMoviePayload(ctx *gin.Context, body any, validate func(upload models.MovieUpload) models.FieldErrors) (upload models.MovieUpload, ok bool) {
+	var fieldErrs models.FieldErrors
+
+	if ctx.ContentType() == binding.MIMEJSON {
+		data, err := ctx.GetRawData()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+			return upload, false
+		}
+		fieldErrs = utils.DecodeStrictJSON(data, body)
+	} else {
+		if err := ctx.ShouldBind(&upload); err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+			return upload, false
+		}
+		if upload.Metadata != "" {
+			for _, e := range utils.DecodeStrictJSON([]byte(upload.Metadata), body) {
+				field := "metadata"
+				if e.Field != "body" {
+					field += "." + e.Field
+				}
+				fieldErrs = append(fieldErrs, models.FieldError{Field: field, Message: e.Message})
+			}
+		}
+	}
+
+	if fieldErrs == nil {
+		fieldErrs = validate(upload)
+	}
+	if len(fieldErrs) > 0 {
+		utils.HandleValidationError(ctx, fieldErrs)
+		return upload, false
+	}
+	return upload, true
+}
+
+// saveMovieImages stores the uploaded poster and backdrop, a missing file gives an empty filename
+func (m *MovieHandler) saveMovieImages(ctx *gin.Context, upload models.MovieUpload) (poster string, backdrop string, ok bool) {
+	if upload.PosterImg != nil {
+		if poster, ok = saveUploadedImage(ctx, m.st, "poster_img", upload.PosterImg, "posters", pkg.PosterVariants); !ok {
+			return "", "", false
+		}
+	}
+	if upload.BackdropImg != nil {
+		if backdrop, ok = saveUploadedImage(ctx, m.st, "backdrop_img", upload.BackdropImg, "backdrops", pkg.BackdropVariants); !ok {
+			m.removeMovieImages(ctx, poster, "")
+			return "", "", false
+		}
+	}
+	return poster, backdrop, true
+}
+
+// removeMovieImages cleans up the images of a create / edit that failed, or the ones that were replaced
+func (m *MovieHandler) removeMovieImages(ctx context.Context, poster, backdrop string) {
+	if poster != "" {
+		removeUploadedImage(ctx, m.st, "posters", poster)
+	}
+	if backdrop != "" {
+		removeUploadedImage(ctx, m.st, "backdrops", backdrop)
+	}
+}
+
+// handleMovieWriteError answers 422 for ids that don't exist, 404 for an unknown movie, 412 for an
+// outdated If-Match and 500 otherwise
+func handleMovieWriteError(ctx *gin.Context, err error, logMsg string) {
+	var fieldErrs models.FieldErrors
+	switch {
+	case errors.As(err, &fieldErrs):
+		utils.HandleValidationError(ctx, fieldErrs)
+	case errors.Is(err, repositories.ErrMovieNotFound):
+		utils.HandleError(ctx, http.StatusNotFound, err.Error(), logMsg)
+	case errors.Is(err, repositories.ErrMovieModified):
+		utils.HandleError(ctx, http.StatusPreconditionFailed, err.Error(), logMsg)
+	default:
+		utils.HandleError(ctx, http.StatusInternalServerError, "status internal server error", err.Error())
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMovieETagRoundTrip(t *testing.T) {
+	// updated_at comes from Postgres with microseconds, the ETag must keep all of them
+	updatedAt := time.Date(2025, 3, 14, 9, 26, 53, 589793000, time.UTC)
+	etag := movieETag(updatedAt)
+	if etag != `"1741944413589793"` {
+		t.Fatalf("movieETag = %s", etag)
+	}
+
+	version, ok := parseMovieETag(etag)
+	if !ok || version == nil || !version.Equal(updatedAt) {
+		t.Fatalf("parseMovieETag(%s) = %v, %v, want %v", etag, version, ok, updatedAt)
+	}
+
+	// a time from Go has nanoseconds, the ETag is the version as stored
+	withNanos := updatedAt.Add(123 * time.Nanosecond)
+	if version, _ := parseMovieETag(movieETag(withNanos)); !version.Equal(updatedAt) {
+		t.Errorf("round trip of %v = %v, want %v", withNanos, version, updatedAt)
+	}
+}
+
+func TestParseMovieETag(t *testing.T) {
+	version := time.UnixMicro(1741944413589793)
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+		any    bool
+	}{
+		{name: "any version", header: "*", ok: true, any: true},
+		{name: "any version with spaces", header: " * ", ok: true, any: true},
+		{name: "quoted", header: `"1741944413589793"`, ok: true},
+		{name: "unquoted", header: `1741944413589793`, ok: true},
+		{name: "weak", header: `W/"1741944413589793"`, ok: true},
+		{name: "weak unquoted", header: `W/1741944413589793`, ok: true},
+		{name: "surrounding spaces", header: `  "1741944413589793" `, ok: true},
+		{name: "empty quotes", header: `""`},
+		{name: "not a number", header: `"abc"`},
+		{name: "date", header: `"2025-03-14T09:26:53Z"`},
+		{name: "several etags", header: `"1741944413589793", "1741944413589794"`},
+		{name: "lowercase weak prefix", header: `w/"1741944413589793"`},
+		{name: "overflow", header: `"99999999999999999999"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseMovieETag(tt.header)
+			if ok != tt.ok {
+				t.Fatalf("parseMovieETag(%q) ok = %v, want %v", tt.header, ok, tt.ok)
+			}
+			switch {
+			case !ok:
+			case tt.any && got != nil:
+				t.Errorf("parseMovieETag(%q) = %v, want nil (any version)", tt.header, got)
+			case !tt.any && (got == nil || !got.Equal(version)):
+				t.Errorf("parseMovieETag(%q) = %v, want %v", tt.header, got, version)
+			}
+		})
+	}
+}
+
+func TestEditMovieIfMatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    int
+	}{
+		{name: "missing", ifMatch: "", want: http.StatusPreconditionRequired},
+		{name: "garbage", ifMatch: `"not-a-version"`, want: http.StatusPreconditionFailed},
+		{name: "list of etags", ifMatch: `"1", "2"`, want: http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Params = gin.Params{{Key: "id", Value: "1"}}
+			ctx.Request = httptest.NewRequest(http.MethodPatch, "/api/v1/admin/movies/1", nil)
+			if tt.ifMatch != "" {
+				ctx.Request.Header.Set("If-Match", tt.ifMatch)
+			}
+
+			// the header is checked before the repository is used
+			NewMovieHandler(nil, nil).EditMovie(ctx)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
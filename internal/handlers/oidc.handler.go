@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+	"github.com/redis/go-redis/v9"
+)
+
+// state, nonce & PKCE verifier are only valid for this long
+const oidcStateTTL = 10 * time.Minute
+
+// providers : configured OIDC providers by name, ur : user repository, tr : two factor repository
+type OIDCHandler struct {
+	providers map[string]*pkg.OIDCProvider
+	ur        *repositories.UserRepository
+	tr        *repositories.TwoFactorRepository
+	ac        *utils.AuthCacheManager
+}
+
+func NewOIDCHandler(providers map[string]*pkg.OIDCProvider, ur *repositories.UserRepository, tr *repositories.TwoFactorRepository, rdb *redis.Client) *OIDCHandler {
+	return &OIDCHandler{
+		providers: providers,
+		ur:        ur,
+		tr:        tr,
+		ac:        utils.NewAuthCacheManager(rdb),
+	}
+}
+
+// @Summary List social login providers
+// @Tags    Auth
+// @Produce json
+// @Success 200 {object} models.OIDCProvidersResponse
+// @Router  /api/v1/auth/oidc/providers [get]
+func (o *OIDCHandler) ListProviders(ctx *gin.Context) {
+	names := make([]string, 0, len(o.providers))
+	for name := range o.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    models.OIDCProvidersResponse{Providers: names},
+	})
+}
+
+// @Summary Start social login
+// @Tags    Auth
+// @Param   provider path string true "Provider name, e.g. google"
+// @Success 302 "Redirect to the provider"
+// @Router  /api/v1/auth/oidc/{provider}/login [get]
+func (o *OIDCHandler) Login(ctx *gin.Context) {
+	provider, ok := o.providers[ctx.Param("provider")]
+	if !ok {
+		utils.HandleError(ctx, http.StatusNotFound, "unknown login provider", "oidc provider not configured: "+ctx.Param("provider"))
+		return
+	}
+
+	state, err := pkg.GenRandomString(32)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	nonce, err := pkg.GenRandomString(32)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	verifier, challenge, err := pkg.GenPKCE()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx.Request.Context(), state, nonce, challenge)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadGateway, "login provider unavailable", err.Error())
+		return
+	}
+
+	loginState := models.OIDCLoginState{
+		Provider:     provider.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	}
+	if err := o.ac.SaveOIDCState(ctx.Request.Context(), state, loginState, oidcStateTTL); err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary Finish social login
+// @Tags    Auth
+// @Produce json
+// @Param   provider path  string true "Provider name, e.g. google"
+// @Param   code     query string true "Authorization code"
+// @Param   state    query string true "State from the login redirect"
+// @Success 200 {object} models.SuccessLoginResponse "JWT token, or a challenge token when 2FA is enabled"
+// @Router  /api/v1/auth/oidc/{provider}/callback [get]
+func (o *OIDCHandler) Callback(ctx *gin.Context) {
+	provider, ok := o.providers[ctx.Param("provider")]
+	if !ok {
+		utils.HandleError(ctx, http.StatusNotFound, "unknown login provider", "oidc provider not configured: "+ctx.Param("provider"))
+		return
+	}
+
+	if errParam := ctx.Query("error"); errParam != "" {
+		utils.HandleError(ctx, http.StatusUnauthorized, "login cancelled", "oidc provider returned error: "+errParam)
+		return
+	}
+
+	code, state := ctx.Query("code"), ctx.Query("state")
+	if code == "" || state == "" {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", "code and state are required")
+		return
+	}
+
+	loginState, err := o.ac.PopOIDCState(ctx.Request.Context(), state)
+	if err != nil {
+		if errors.Is(err, utils.ErrOIDCStateNotFound) {
+			utils.HandleError(ctx, http.StatusUnauthorized, "login expired, please try again", err.Error())
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if loginState.Provider != provider.Name {
+		utils.HandleError(ctx, http.StatusUnauthorized, "login expired, please try again", "oidc state belongs to another provider")
+		return
+	}
+
+	tokens, err := provider.Exchange(ctx.Request.Context(), code, loginState.CodeVerifier)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusUnauthorized, "login failed", err.Error())
+		return
+	}
+
+	identity, err := provider.VerifyIDToken(ctx.Request.Context(), tokens.IDToken, loginState.Nonce)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusUnauthorized, "login failed", err.Error())
+		return
+	}
+
+	user, err := o.ur.FindOrCreateUserByIdentity(ctx.Request.Context(), models.ExternalIdentity{
+		Provider:      provider.Name,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		FirstName:     identity.GivenName,
+		LastName:      identity.FamilyName,
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrUnverifiedIdentityEmail) {
+			utils.HandleError(ctx, http.StatusForbidden, err.Error(), "oidc login without verified email")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	respondLogin(ctx, o.ur, o.tr, user.Id, user.Role, "oidc:"+provider.Name)
+}
@@ -1,14 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
 	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
 )
 
 // or : order repository
@@ -51,6 +54,10 @@ func (o *OrderHandler) AddTransaction(ctx *gin.Context) {
 
 	transaction, err := o.or.AddNewTransactionsAndSeatCodes(ctx, body, user.UserId)
 	if err != nil {
+		if errors.Is(err, repositories.ErrScheduleNotBookable) {
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), "add transaction failed")
+			return
+		}
 		log.Println("error : ", err.Error())
 		utils.HandleResponse(ctx, http.StatusInternalServerError, models.ErrorResponse{
 			Success: false,
@@ -97,6 +104,10 @@ func (o *OrderHandler) PayTransaction(ctx *gin.Context) {
 // @Summary Get user transaction histories
 // @Tags Orders
 // @Produce json
+// @Param page query int false "Page number"
+// @Param per_page query int false "Transactions per page (default 10, max 50)"
+// @Param cursor query string false "next_cursor of the previous page, replaces page"
+// @Success 200 {object} models.SuccessResponse{data=pagination.Page[models.TransactionHistory]}
 // @Router /orders/histories [get]
 // @Security BearerAuth
 func (o *OrderHandler) ListTransaction(ctx *gin.Context) {
@@ -108,8 +119,18 @@ func (o *OrderHandler) ListTransaction(ctx *gin.Context) {
 		return
 	}
 
-	tHistories, err := o.or.ListTransaction(ctx, user.UserId)
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 10, 50)
 	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
+
+	tHistories, err := o.or.ListTransaction(ctx.Request.Context(), user.UserId, params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
 		utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), "cannot get list of transaction")
 		return
 	}
@@ -120,3 +141,40 @@ func (o *OrderHandler) ListTransaction(ctx *gin.Context) {
 		Data:    tHistories,
 	})
 }
+
+// ScanTicket godoc
+// @Summary Scan a ticket at the cinema entrance
+// @Tags Staff
+// @Produce json
+// @Param cinema_id path int true "Cinema ID"
+// @Param id path string true "Transaction ID"
+// @Router /staff/cinemas/{cinema_id}/tickets/{id}/scan [patch]
+// @Security BearerAuth
+func (o *OrderHandler) ScanTicket(ctx *gin.Context) {
+	cinemaID, err := strconv.Atoi(ctx.Param("cinema_id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid cinema_id", err.Error())
+		return
+	}
+
+	ticket, err := o.or.ScanTicket(ctx.Request.Context(), ctx.Param("id"), cinemaID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrTicketNotFound):
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "scan ticket failed")
+		case errors.Is(err, repositories.ErrTicketNotPaid):
+			utils.HandleError(ctx, http.StatusUnprocessableEntity, err.Error(), "scan ticket failed")
+		case errors.Is(err, repositories.ErrTicketAlreadyScanned):
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), "scan ticket failed")
+		default:
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		}
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    ticket,
+	})
+}
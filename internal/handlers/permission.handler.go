@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+// tokens live 60 minutes, after that every old token is expired anyway
+const accessTokenLifetime = time.Hour
+
+// pr : permission repository
+type PermissionHandler struct {
+	pr *repositories.PermissionRepository
+	ac *utils.AuthCacheManager
+}
+
+func NewPermissionHandler(pr *repositories.PermissionRepository, rdb *redis.Client) *PermissionHandler {
+	return &PermissionHandler{
+		pr: pr,
+		ac: utils.NewAuthCacheManager(rdb),
+	}
+}
+
+// @Summary List roles and their permissions
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Role
+// @Router  /api/v1/admin/roles [get]
+func (p *PermissionHandler) ListRoles(ctx *gin.Context) {
+	roles, err := p.pr.ListRoles(ctx.Request.Context())
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    roles,
+	})
+}
+
+// @Summary List the extra roles of a user
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id path string true "User ID"
+// @Success 200 {array} models.UserRole
+// @Router  /api/v1/admin/users/{id}/roles [get]
+func (p *PermissionHandler) ListUserRoles(ctx *gin.Context) {
+	userRoles, err := p.pr.ListUserRoles(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserOrCinemaMissing) {
+			utils.HandleError(ctx, http.StatusNotFound, "user not found", err.Error())
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    userRoles,
+	})
+}
+
+// @Summary Assign a role to a user
+// @Tags    Admin
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path string                   true "User ID"
+// @Param   body body models.AssignRoleRequest true "Role, optionally limited to one cinema"
+// @Success 201 {object} models.UserRole
+// @Router  /api/v1/admin/users/{id}/roles [post]
+func (p *PermissionHandler) AssignRole(ctx *gin.Context) {
+	var req models.AssignRoleRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	userID := ctx.Param("id")
+	userRole, err := p.pr.AssignRole(ctx.Request.Context(), userID, req.Role, req.CinemaID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrRoleNotFound), errors.Is(err, repositories.ErrBaseRole):
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "assign role failed")
+		case errors.Is(err, repositories.ErrUserOrCinemaMissing):
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "assign role failed")
+		case errors.Is(err, repositories.ErrRoleAlreadyAssigned):
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), "assign role failed")
+		default:
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		}
+		return
+	}
+
+	p.invalidateTokens(ctx, userID)
+
+	utils.HandleResponse(ctx, http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusCreated,
+		Data:    userRole,
+	})
+}
+
+// @Summary Revoke a role of a user
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   id      path string true "User ID"
+// @Param   role_id path int    true "ID of the role assignment"
+// @Success 200 {object} models.SuccessResponse
+// @Router  /api/v1/admin/users/{id}/roles/{role_id} [delete]
+func (p *PermissionHandler) RevokeRole(ctx *gin.Context) {
+	userRoleID, err := strconv.Atoi(ctx.Param("role_id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid role_id", err.Error())
+		return
+	}
+
+	userID := ctx.Param("id")
+	if err := p.pr.RevokeRole(ctx.Request.Context(), userID, userRoleID); err != nil {
+		if errors.Is(err, repositories.ErrUserRoleNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "revoke role failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	p.invalidateTokens(ctx, userID)
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data: map[string]string{
+			"message": "Role revoked",
+		},
+	})
+}
+
+// invalidateTokens forces a new login, the permissions inside the old tokens are outdated
+func (p *PermissionHandler) invalidateTokens(ctx *gin.Context, userID string) {
+	if err := p.ac.BlacklistUserTokens(ctx.Request.Context(), userID, accessTokenLifetime); err != nil {
+		log.Println("failed to invalidate tokens after role change.\nCause: ", err.Error())
+	}
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+)
+
+// photos of people, served under /api/v1/img/people
+const peopleDir = "people"
+
+// pr : people repository, st : storage of the photos
+type PersonHandler struct {
+	pr *repositories.PeopleRepository
+	st pkg.Storage
+}
+
+func NewPersonHandler(pr *repositories.PeopleRepository, st pkg.Storage) *PersonHandler {
+	return &PersonHandler{pr: pr, st: st}
+}
+
+// @Summary Search directors and cast
+// @Description Without q every person is listed by name. Search results are ranked and can only be paged with page.
+// @Tags    People
+// @Produce json
+// @Param   q        query string false "Name or part of the name, typos are tolerated"
+// @Param   page     query int    false "Page number"
+// @Param   per_page query int    false "People per page (default 20, max 50)"
+// @Param   cursor   query string false "next_cursor of the previous page, only without q"
+// @Success 200 {object} models.SuccessResponse{data=pagination.Page[models.Person]}
+// @Router  /api/v1/people [get]
+func (h *PersonHandler) SearchPeople(ctx *gin.Context) {
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 20, 50)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
+
+	people, err := h.pr.SearchPeople(ctx.Request.Context(), strings.TrimSpace(ctx.Query("q")), params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    people,
+	})
+}
+
+// @Summary Person page with filmography
+// @Tags    People
+// @Produce json
+// @Param   id path int true "Person ID"
+// @Success 200 {object} models.PersonDetail
+// @Router  /api/v1/people/{id} [get]
+func (h *PersonHandler) GetPerson(ctx *gin.Context) {
+	personID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "person id must be integer")
+		return
+	}
+
+	person, err := h.pr.GetPerson(ctx.Request.Context(), personID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrPersonNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "get person failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    person,
+	})
+}
+
+// @Summary Edit a person (admin)
+// @Description Fields that are not sent stay unchanged, an empty birth_date clears it
+// @Tags    Admin
+// @Accept  multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param   id         path     int    true  "Person ID"
+// @Param   name       formData string false "Name"
+// @Param   biography  formData string false "Biography"
+// @Param   birth_date formData string false "Birth date (YYYY-MM-DD)"
+// @Param   photo      formData file   false "png, jpg, jpeg or webp"
+// @Success 200 {object} models.Person
+// @Router  /api/v1/admin/people/{id} [patch]
+func (h *PersonHandler) EditPerson(ctx *gin.Context) {
+	personID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "person id must be integer")
+		return
+	}
+
+	var req models.EditPersonRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	if req.Name != nil {
+		name := strings.Join(strings.Fields(*req.Name), " ")
+		if name == "" {
+			utils.HandleError(ctx, http.StatusBadRequest, "name can't be empty", "invalid name")
+			return
+		}
+		req.Name = &name
+	}
+
+	var birthDate *time.Time
+	if req.BirthDate != nil && *req.BirthDate != "" {
+		date, err := time.Parse("2006-01-02", *req.BirthDate)
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "birth_date must be YYYY-MM-DD", err.Error())
+			return
+		}
+		birthDate = &date
+	}
+
+	photo := ""
+	if req.Photo != nil {
+		filename, ok := saveUploadedImage(ctx, h.st, "photo", req.Photo, peopleDir, nil)
+		if !ok {
+			return
+		}
+		photo = filename
+	}
+
+	person, oldPhoto, err := h.pr.EditPerson(ctx.Request.Context(), personID, req, birthDate, photo)
+	if err != nil {
+		if photo != "" {
+			removeUploadedImage(ctx, h.st, peopleDir, photo)
+		}
+		switch {
+		case errors.Is(err, repositories.ErrPersonNotFound):
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "edit person failed")
+		case errors.Is(err, repositories.ErrPersonNameTaken):
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), "edit person failed")
+		default:
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		}
+		return
+	}
+	if oldPhoto != "" {
+		removeUploadedImage(ctx, h.st, peopleDir, oldPhoto)
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    person,
+	})
+}
+
+// @Summary Possible duplicate people (admin)
+// @Description Pairs whose names are equal apart from case, spaces and punctuation, or look alike
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   min_score query number false "Minimum name similarity 0-1 (default 0.6)"
+// @Param   limit     query int    false "Max pairs (default 50, max 200)"
+// @Success 200 {array} models.DuplicatePeople
+// @Router  /api/v1/admin/people/duplicates [get]
+func (h *PersonHandler) FindDuplicates(ctx *gin.Context) {
+	minScore := 0.6
+	if v := ctx.Query("min_score"); v != "" {
+		score, err := strconv.ParseFloat(v, 64)
+		if err != nil || score <= 0 || score > 1 {
+			utils.HandleError(ctx, http.StatusBadRequest, "min_score must be a number between 0 and 1", "invalid min_score")
+			return
+		}
+		minScore = score
+	}
+	limit := 50
+	if v := ctx.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			utils.HandleError(ctx, http.StatusBadRequest, "limit must be a positive number", "invalid limit")
+			return
+		}
+		limit = min(n, 200)
+	}
+
+	duplicates, err := h.pr.FindDuplicates(ctx.Request.Context(), minScore, limit)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    duplicates,
+	})
+}
+
+// @Summary Merge duplicate people into this person (admin)
+// @Description Movies of the sources are moved to this person, their names become aliases
+// @Tags    Admin
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path int                       true "Person ID that is kept"
+// @Param   body body models.MergePeopleRequest true "IDs of the people merged into it"
+// @Success 200 {object} models.Person
+// @Router  /api/v1/admin/people/{id}/merge [post]
+func (h *PersonHandler) MergePeople(ctx *gin.Context) {
+	personID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid id", "person id must be integer")
+		return
+	}
+
+	var req models.MergePeopleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	person, err := h.pr.MergePeople(ctx.Request.Context(), personID, req.SourceIDs)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrPersonNotFound):
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "merge people failed")
+		case errors.Is(err, repositories.ErrInvalidMergeSource):
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "merge people failed")
+		default:
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		}
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    person,
+	})
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+)
+
+// rr : review repository
+type ReviewHandler struct {
+	rr *repositories.ReviewRepository
+}
+
+func NewReviewHandler(rr *repositories.ReviewRepository) *ReviewHandler {
+	return &ReviewHandler{rr: rr}
+}
+
+// @Summary List the reviews of a movie
+// @Tags    Reviews
+// @Produce json
+// @Param   id       path  int    true  "Movie ID"
+// @Param   page     query int    false "Page number"
+// @Param   per_page query int    false "Reviews per page (default 10, max 50)"
+// @Param   cursor   query string false "next_cursor of the previous page, replaces page"
+// @Success 200 {object} models.SuccessResponse{data=pagination.Page[models.Review]}
+// @Router  /api/v1/movies/{id}/reviews [get]
+func (r *ReviewHandler) ListMovieReviews(ctx *gin.Context) {
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid movie id", err.Error())
+		return
+	}
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 10, 50)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
+
+	reviews, err := r.rr.ListMovieReviews(ctx.Request.Context(), movieID, params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    reviews,
+	})
+}
+
+// @Summary Review a movie
+// @Description Only possible with a paid ticket for the movie, one review per movie
+// @Tags    Reviews
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path int                  true "Movie ID"
+// @Param   body body models.ReviewRequest true "Rating 1-5 and review text"
+// @Success 201 {object} models.Review
+// @Router  /api/v1/movies/{id}/reviews [post]
+func (r *ReviewHandler) CreateReview(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+	movieID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid movie id", err.Error())
+		return
+	}
+
+	var req models.ReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	review, err := r.rr.CreateReview(ctx.Request.Context(), movieID, user.UserId, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrReviewNotAllowed):
+			utils.HandleError(ctx, http.StatusForbidden, err.Error(), "create review failed")
+		case errors.Is(err, repositories.ErrReviewAlreadyExists):
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), "create review failed")
+		default:
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		}
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusCreated, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusCreated,
+		Data:    review,
+	})
+}
+
+// @Summary Edit own review
+// @Tags    Reviews
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path int                  true "Review ID"
+// @Param   body body models.ReviewRequest true "Rating 1-5 and review text"
+// @Success 200 {object} models.Review
+// @Router  /api/v1/reviews/{id} [patch]
+func (r *ReviewHandler) UpdateReview(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+	reviewID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid review id", err.Error())
+		return
+	}
+
+	var req models.ReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	review, err := r.rr.UpdateOwnReview(ctx.Request.Context(), reviewID, user.UserId, req)
+	if err != nil {
+		if errors.Is(err, repositories.ErrReviewNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "update review failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    review,
+	})
+}
+
+// @Summary Delete own review
+// @Tags    Reviews
+// @Produce json
+// @Security BearerAuth
+// @Param   id path int true "Review ID"
+// @Success 200 {object} models.SuccessResponse
+// @Router  /api/v1/reviews/{id} [delete]
+func (r *ReviewHandler) DeleteReview(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+	reviewID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid review id", err.Error())
+		return
+	}
+
+	if err := r.rr.DeleteOwnReview(ctx.Request.Context(), reviewID, user.UserId); err != nil {
+		if errors.Is(err, repositories.ErrReviewNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "delete review failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data: map[string]string{
+			"message": "Review deleted",
+		},
+	})
+}
+
+// @Summary List reviews for moderation
+// @Tags    Admin
+// @Produce json
+// @Security BearerAuth
+// @Param   status   query string false "flagged (default), hidden or all"
+// @Param   page     query int    false "Page number"
+// @Param   per_page query int    false "Reviews per page (default 20, max 50)"
+// @Param   cursor   query string false "next_cursor of the previous page, replaces page"
+// @Success 200 {object} models.SuccessResponse{data=pagination.Page[models.Review]}
+// @Router  /api/v1/admin/reviews [get]
+func (r *ReviewHandler) ListReviewsForModeration(ctx *gin.Context) {
+	status := ctx.DefaultQuery("status", "flagged")
+	if !slices.Contains(repositories.ReviewStatuses, status) {
+		utils.HandleError(ctx, http.StatusBadRequest, "status must be flagged, hidden or all", "invalid review status")
+		return
+	}
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 20, 50)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
+
+	reviews, err := r.rr.ListReviewsForModeration(ctx.Request.Context(), status, params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    reviews,
+	})
+}
+
+// @Summary Hide, unhide, flag or unflag a review
+// @Tags    Admin
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   id   path int                          true "Review ID"
+// @Param   body body models.ModerateReviewRequest true "Moderation action"
+// @Success 200 {object} models.Review
+// @Router  /api/v1/admin/reviews/{id}/moderation [patch]
+func (r *ReviewHandler) ModerateReview(ctx *gin.Context) {
+	reviewID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid review id", err.Error())
+		return
+	}
+
+	var req models.ModerateReviewRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	review, err := r.rr.ModerateReview(ctx.Request.Context(), reviewID, req)
+	if err != nil {
+		if errors.Is(err, repositories.ErrReviewNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "moderate review failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    review,
+	})
+}
+
+// claimsFromContext reads the claims set by VerifyToken, on failure the error response is already written
+func claimsFromContext(ctx *gin.Context) (pkg.Claims, bool) {
+	claims, _ := ctx.Get("claims")
+	user, ok := claims.(pkg.Claims)
+	if !ok {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot cast into pkg.claims")
+	}
+	return user, ok
+}
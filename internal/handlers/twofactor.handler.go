@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/configs"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+	"github.com/redis/go-redis/v9"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const recoveryCodeCount = 10
+
+// tr : two factor repository, ur : user repository, ac : failed codes and used challenges
+type TwoFactorHandler struct {
+	tr       *repositories.TwoFactorRepository
+	ur       *repositories.UserRepository
+	ac       *utils.AuthCacheManager
+	failOpen bool
+}
+
+func NewTwoFactorHandler(tr *repositories.TwoFactorRepository, ur *repositories.UserRepository, rdb *redis.Client) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		tr:       tr,
+		ur:       ur,
+		ac:       utils.NewAuthCacheManager(rdb),
+		failOpen: configs.AuthRedisFailOpen(),
+	}
+}
+
+func newTOTPConfig() *pkg.TOTPConfig {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "Tickitz"
+	}
+	return pkg.NewTOTPConfig(issuer)
+}
+
+// @Summary Start two-factor enrolment
+// @Tags    Users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.TwoFactorSetupResponse
+// @Router  /api/v1/users/2fa/setup [post]
+func (t *TwoFactorHandler) Setup(ctx *gin.Context) {
+	claims, _ := ctx.Get("claims")
+	user, ok := claims.(pkg.Claims)
+	if !ok {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot cast into pkg.claims")
+		return
+	}
+
+	profile, err := t.ur.GetProfile(ctx.Request.Context(), user.UserId)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	totpCfg := newTOTPConfig()
+	secret, err := totpCfg.GenSecret()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	if err := t.tr.SavePendingSecret(ctx.Request.Context(), user.UserId, secret); err != nil {
+		if errors.Is(err, repositories.ErrTwoFactorAlreadyEnabled) {
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), "2fa setup requested while enabled")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	uri := totpCfg.URI(profile.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data: models.TwoFactorSetupResponse{
+			Secret:     secret,
+			OtpauthURI: uri,
+			QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+		},
+	})
+}
+
+// @Summary Confirm two-factor enrolment
+// @Tags    Users
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body models.TwoFactorCodeRequest true "Code from the authenticator app"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Router  /api/v1/users/2fa/confirm [post]
+func (t *TwoFactorHandler) Confirm(ctx *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	claims, _ := ctx.Get("claims")
+	user, ok := claims.(pkg.Claims)
+	if !ok {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot cast into pkg.claims")
+		return
+	}
+
+	totp, err := t.tr.GetTOTP(ctx.Request.Context(), user.UserId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			utils.HandleError(ctx, http.StatusBadRequest, "two-factor setup has not been started", err.Error())
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if totp.EnabledAt != nil {
+		utils.HandleError(ctx, http.StatusConflict, repositories.ErrTwoFactorAlreadyEnabled.Error(), "2fa confirm requested while enabled")
+		return
+	}
+
+	step, valid := newTOTPConfig().Validate(req.Code, totp.Secret, time.Now())
+	if !valid {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid two-factor code", "2fa confirm failed")
+		return
+	}
+
+	codes, hashes, err := genRecoveryCodes()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	if err := t.tr.EnableTOTP(ctx.Request.Context(), user.UserId, step, hashes); err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	// Recovery codes are only shown once, the database only keeps the hashes
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    models.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// @Summary Disable two-factor authentication
+// @Tags    Users
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body models.TwoFactorCodeRequest true "Current code or a recovery code"
+// @Success 200 {object} models.SuccessResponse
+// @Router  /api/v1/users/2fa [delete]
+func (t *TwoFactorHandler) Disable(ctx *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	claims, _ := ctx.Get("claims")
+	user, ok := claims.(pkg.Claims)
+	if !ok {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot cast into pkg.claims")
+		return
+	}
+
+	valid, err := t.checkSecondFactor(ctx, user.UserId, req.Code, req.Code)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if !valid {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid two-factor code", "2fa disable failed")
+		return
+	}
+
+	if err := t.tr.DisableTOTP(ctx.Request.Context(), user.UserId); err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data: map[string]string{
+			"message": "Two-factor authentication disabled",
+		},
+	})
+}
+
+// @Summary Regenerate two-factor recovery codes
+// @Tags    Users
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body models.TwoFactorCodeRequest true "Current code from the authenticator app"
+// @Success 200 {object} models.RecoveryCodesResponse
+// @Router  /api/v1/users/2fa/recovery-codes [post]
+func (t *TwoFactorHandler) RegenerateRecoveryCodes(ctx *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	claims, _ := ctx.Get("claims")
+	user, ok := claims.(pkg.Claims)
+	if !ok {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot cast into pkg.claims")
+		return
+	}
+
+	valid, err := t.checkSecondFactor(ctx, user.UserId, req.Code, "")
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if !valid {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid two-factor code", "2fa recovery code regeneration failed")
+		return
+	}
+
+	codes, hashes, err := genRecoveryCodes()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if err := t.tr.ReplaceRecoveryCodes(ctx.Request.Context(), user.UserId, hashes); err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    models.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// @Summary     Finish login with a two-factor code
+// @Description A challenge token works once. After 5 wrong codes the second factor of the user is locked for 15 minutes.
+// @Tags        Auth
+// @Accept      json
+// @Produce     json
+// @Param       body body models.TwoFactorLoginRequest true "Challenge token from login and a TOTP or recovery code"
+// @Success     200 {object} models.SuccessLoginResponse
+// @Failure     429 {object} models.ErrorResponse
+// @Router      /api/v1/auth/2fa/verify [post]
+func (t *TwoFactorHandler) VerifyLogin(ctx *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		utils.HandleError(ctx, http.StatusBadRequest, "code or recovery_code is required", "2fa verify without code")
+		return
+	}
+
+	var challenge pkg.Claims
+	if err := challenge.VerifyChallengeToken(req.ChallengeToken); err != nil {
+		utils.HandleError(ctx, http.StatusUnauthorized, "silahkan login kembali", err.Error())
+		return
+	}
+	if challenge.ID == "" {
+		utils.HandleError(ctx, http.StatusUnauthorized, "silahkan login kembali", "2fa challenge without jti")
+		return
+	}
+
+	locked, err := t.ac.IsTwoFactorLocked(ctx.Request.Context(), challenge.UserId)
+	if err != nil && t.limitUnavailable(ctx, err) {
+		return
+	}
+	if locked {
+		utils.HandleError(ctx, http.StatusTooManyRequests, "terlalu banyak kode yang salah, coba lagi nanti", "2fa locked")
+		return
+	}
+
+	valid, err := t.checkSecondFactor(ctx, challenge.UserId, req.Code, req.RecoveryCode)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if !valid {
+		if _, err := t.ac.RecordTwoFactorFailure(ctx.Request.Context(), challenge.UserId); err != nil && t.limitUnavailable(ctx, err) {
+			return
+		}
+		utils.HandleError(ctx, http.StatusUnauthorized, "invalid two-factor code", "2fa verify failed")
+		return
+	}
+
+	// a challenge that was already used can't log in again, even with a new code
+	fresh, err := t.ac.ConsumeChallenge(ctx.Request.Context(), challenge.ID, max(time.Until(challenge.ExpiresAt.Time), time.Second))
+	if err != nil && t.limitUnavailable(ctx, err) {
+		return
+	}
+	if err == nil && !fresh {
+		utils.HandleError(ctx, http.StatusUnauthorized, "silahkan login kembali", "2fa challenge already used")
+		return
+	}
+	if err := t.ac.ResetTwoFactorFailures(ctx.Request.Context(), challenge.UserId); err != nil {
+		log.Println(err.Error())
+	}
+
+	// the account could be suspended between the password and the code
+	if _, ok := checkAccountActive(ctx, t.ur, challenge.UserId); !ok {
+		return
+	}
+
+	login, err := issueLoginToken(ctx.Request.Context(), t.ur, challenge.UserId, challenge.Role, true)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	recordLogin(ctx, t.ur, challenge.UserId, "2fa")
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    login,
+	})
+}
+
+// limitUnavailable applies AUTH_REDIS_FAILURE when the failure counter or the used challenges can't be
+// reached. It returns true when the request was rejected.
+func (t *TwoFactorHandler) limitUnavailable(ctx *gin.Context, err error) bool {
+	if t.failOpen {
+		log.Println("2fa limits unavailable, request allowed (fail open)\nCause: ", err.Error())
+		return false
+	}
+	utils.HandleError(ctx, http.StatusServiceUnavailable, "layanan sedang tidak tersedia, coba lagi nanti", err.Error())
+	return true
+}
+
+// checkSecondFactor accepts either a TOTP code (not replayable) or an unused recovery code
+func (t *TwoFactorHandler) checkSecondFactor(ctx *gin.Context, userID, code, recoveryCode string) (bool, error) {
+	totp, err := t.tr.GetTOTP(ctx.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	if totp.EnabledAt == nil {
+		return false, nil
+	}
+
+	if code != "" {
+		if step, valid := newTOTPConfig().Validate(code, totp.Secret, time.Now()); valid {
+			return t.tr.MarkStepUsed(ctx.Request.Context(), userID, step)
+		}
+	}
+
+	if recoveryCode != "" {
+		used, err := t.tr.UseRecoveryCode(ctx.Request.Context(), userID, pkg.HashRecoveryCode(recoveryCode))
+		if err != nil {
+			return false, err
+		}
+		if used {
+			log.Printf("recovery code used by user %s", userID)
+		}
+		return used, nil
+	}
+
+	return false, nil
+}
+
+func genRecoveryCodes() ([]string, []string, error) {
+	codes, err := pkg.GenRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = pkg.HashRecoveryCode(c)
+	}
+	return codes, hashes, nil
+}
@@ -2,15 +2,20 @@
 package handlers
 
 import (
-	"fmt"
+	"context"
+	"errors"
+	"io"
 	"log"
 	"net/http"
-	"path/filepath"
+	"net/mail"
+	"net/url"
+	"os"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
@@ -18,16 +23,23 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// ur : user repositories
+// profile pictures, served under /api/v1/img/profile_pics
+const profilePicsDir = "profile_pics"
+
+// ur : user repositories, tr : two factor repository, st : storage of the profile pictures
 type UserHandler struct {
 	ur *repositories.UserRepository
+	tr *repositories.TwoFactorRepository
 	ac *utils.AuthCacheManager
+	st pkg.Storage
 }
 
-func NewUserHandler(ur *repositories.UserRepository, rdb *redis.Client) *UserHandler {
+func NewUserHandler(ur *repositories.UserRepository, tr *repositories.TwoFactorRepository, rdb *redis.Client, st pkg.Storage) *UserHandler {
 	return &UserHandler{
 		ur: ur,
+		tr: tr,
 		ac: utils.NewAuthCacheManager(rdb),
+		st: st,
 	}
 }
 
@@ -51,8 +63,7 @@ func (u *UserHandler) Register(ctx *gin.Context) {
 	// Hash password
 	// "password": "ceganssangar123(DF&&"
 	// format : email + sangar123(DF&&
-	hashCfg := pkg.NewHashConfig()
-	hashCfg.UseRecommended()
+	hashCfg := newHashConfig()
 	hashedPassword, err := hashCfg.GenHash(user.Password)
 	if err != nil {
 		utils.HandleError(ctx, http.StatusInternalServerError, "failed to hash password", err.Error())
@@ -81,7 +92,8 @@ func (u *UserHandler) Register(ctx *gin.Context) {
 // @Accept  json
 // @Produce json
 // @Param   body body models.User true "Login credentials"
-// @Success 200 {object} map[string]string "JWT token"
+// @Success 200 {object} map[string]string "JWT token, a challenge token when 2FA is enabled or a reset token after a forced password reset"
+// @Failure 403 {object} models.ErrorResponse "Account is suspended"
 // @Router  /api/v1/auth/login [post]
 func (u *UserHandler) Login(ctx *gin.Context) {
 	var user models.User
@@ -91,7 +103,6 @@ func (u *UserHandler) Login(ctx *gin.Context) {
 	}
 
 	log.Println("email: ", user.Email)
-	log.Println("password: ", user.Password)
 
 	// GetID from Database
 	infoUser, err := u.ur.GetIDFromEmail(ctx, user.Email)
@@ -113,10 +124,18 @@ func (u *UserHandler) Login(ctx *gin.Context) {
 	}
 
 	log.Println("role : ", userCred.Role)
-	log.Println("hashedPassword : ", userCred.Password)
+
+	// Akun dari social login tidak punya password, login hanya lewat provider
+	if userCred.Password == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Nama atau Password salah",
+		})
+		return
+	}
 
 	// Bandingkan password
-	hashCfg := pkg.NewHashConfig()
+	hashCfg := newHashConfig()
 	isMatched, err := hashCfg.CompareHashAndPassword(user.Password, userCred.Password)
 	if err != nil {
 		log.Println("Internal Server Error.\nCause: ", err.Error())
@@ -139,9 +158,93 @@ func (u *UserHandler) Login(ctx *gin.Context) {
 		return
 	}
 
+	// Hash yang dibuat dengan parameter lama di-upgrade saat password masih ada di tangan
+	u.upgradePasswordHash(ctx, infoUser.Id, user.Password, userCred.Password, hashCfg)
+
+	respondLogin(ctx, u.ur, u.tr, infoUser.Id, userCred.Role, "password")
+}
+
+// upgradePasswordHash rehashes the password when the stored hash uses outdated parameters.
+// A failure only gets logged, the user can still login with the old hash.
+func (u *UserHandler) upgradePasswordHash(ctx *gin.Context, userID, password, storedHash string, hashCfg *pkg.HashConfig) {
+	needsRehash, err := hashCfg.NeedsRehash(storedHash)
+	if err != nil || !needsRehash {
+		return
+	}
+
+	newHash, err := hashCfg.GenHash(password)
+	if err != nil {
+		log.Println("failed to rehash password.\nCause: ", err.Error())
+		return
+	}
+	if err := u.ur.RehashPassword(ctx.Request.Context(), userID, storedHash, newHash); err != nil {
+		log.Println("failed to store rehashed password.\nCause: ", err.Error())
+		return
+	}
+	log.Printf("password hash of user %s upgraded", userID)
+}
+
+// newHashConfig returns the target hash parameters (env overrides the recommended values)
+func newHashConfig() *pkg.HashConfig {
+	hashCfg := pkg.NewHashConfig()
+	if err := hashCfg.UseEnv(); err != nil {
+		log.Println("invalid hash config, using recommended.\nCause: ", err.Error())
+	}
+	return hashCfg
+}
+
+// respondLogin finishes a successful first factor (password or social login), method goes into the login history.
+// Kalau 2FA aktif, jangan kirim jwt dulu, kirim challenge token untuk /auth/2fa/verify
+func respondLogin(ctx *gin.Context, ur *repositories.UserRepository, tr *repositories.TwoFactorRepository, userID, role, method string) {
+	status, ok := checkAccountActive(ctx, ur, userID)
+	if !ok {
+		return
+	}
+	// Password reset dipaksa admin, token hanya bisa dipakai di /auth/password/reset.
+	// Akun social login tidak pernah sampai sini, mereka tidak punya password untuk di-reset.
+	if status.PasswordResetRequired {
+		resetToken, err := pkg.NewPasswordResetClaims(userID, role).GenToken()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+			return
+		}
+
+		utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+			Success: true,
+			Status:  http.StatusOK,
+			Data: models.PasswordResetRequiredResponse{
+				PasswordResetRequired: true,
+				ResetToken:            resetToken,
+			},
+		})
+		return
+	}
+
+	twoFactorEnabled, err := tr.IsEnabled(ctx.Request.Context(), userID)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if twoFactorEnabled {
+		challengeToken, err := pkg.NewChallengeClaims(userID, role).GenToken()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+			return
+		}
+
+		utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+			Success: true,
+			Status:  http.StatusOK,
+			Data: models.TwoFactorChallengeResponse{
+				TwoFactorRequired: true,
+				ChallengeToken:    challengeToken,
+			},
+		})
+		return
+	}
+
 	// Jika match, maka buatkan jwt dan kirim via response
-	claims := pkg.NewJWTClaims(infoUser.Id, userCred.Role)
-	jwtToken, err := claims.GenToken()
+	login, err := issueLoginToken(ctx.Request.Context(), ur, userID, role, false)
 	if err != nil {
 		log.Println("Internal Server Error.\nCause: ", err.Error())
 		ctx.JSON(http.StatusInternalServerError, gin.H{
@@ -151,16 +254,58 @@ func (u *UserHandler) Login(ctx *gin.Context) {
 		return
 	}
 
+	recordLogin(ctx, ur, userID, method)
+
 	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
 		Success: true,
 		Status:  http.StatusOK,
-		Data: models.SuccessLoginResponse{
-			Role:  userCred.Role,
-			Token: jwtToken,
-		},
+		Data:    login,
 	})
 }
 
+// checkAccountActive rejects suspended accounts with 403. On failure the error response is already written.
+func checkAccountActive(ctx *gin.Context, ur *repositories.UserRepository, userID string) (models.AccountStatus, bool) {
+	status, err := ur.GetAccountStatus(ctx.Request.Context(), userID)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return models.AccountStatus{}, false
+	}
+	if status.SuspendedAt != nil {
+		utils.HandleError(ctx, http.StatusForbidden, "akun kamu dinonaktifkan", "login of suspended user")
+		return models.AccountStatus{}, false
+	}
+	return status, true
+}
+
+// recordLogin writes the login history, a failure only gets logged and does not block the login
+func recordLogin(ctx *gin.Context, ur *repositories.UserRepository, userID, method string) {
+	if err := ur.RecordLogin(ctx.Request.Context(), userID, method, ctx.ClientIP(), ctx.Request.UserAgent()); err != nil {
+		log.Println(err.Error())
+	}
+}
+
+// issueLoginToken creates the access token that is returned after a successful login.
+// Permissions are embedded, a role change therefore invalidates the tokens of that user.
+func issueLoginToken(ctx context.Context, ur *repositories.UserRepository, userID, role string, mfa bool) (models.SuccessLoginResponse, error) {
+	permissions, err := ur.GetPermissions(ctx, userID)
+	if err != nil {
+		return models.SuccessLoginResponse{}, err
+	}
+
+	claims := pkg.NewJWTClaims(userID, role)
+	claims.MFA = mfa
+	claims.Permissions = permissions
+	jwtToken, err := claims.GenToken()
+	if err != nil {
+		return models.SuccessLoginResponse{}, err
+	}
+
+	return models.SuccessLoginResponse{
+		Role:  role,
+		Token: jwtToken,
+	}, nil
+}
+
 // @Summary User logout
 // @Tags    Auth
 // @Produce json
@@ -249,21 +394,40 @@ func (u *UserHandler) GetProfile(ctx *gin.Context) {
 }
 
 // @Summary Edit user profile
+// @Description Only the fields that are sent are changed, null (an empty value in a form) clears a field.
+// @Description Spaces, dashes and parentheses are removed from the phone number.
 // @Tags    Users
+// @Accept  json
 // @Accept  multipart/form-data
 // @Produce json
 // @Security BearerAuth
-// @Param   first_name formData string false "First name"
-// @Param   last_name formData string false "Last name"
-// @Param   phone_number formData string false "Phone number"
-// @Param   img formData file false "Profile image"
+// @Param   body body models.EditUserProfile false "Profile fields (JSON)"
+// @Param   first_name formData string false "First name, at most 30 characters"
+// @Param   last_name formData string false "Last name, at most 30 characters"
+// @Param   phone_number formData string false "Phone number, 8 to 12 digits with an optional +"
+// @Param   img formData file false "Profile image (png, jpg or webp), img_urls has the generated sizes"
 // @Success 200 {object} models.UserProfile
+// @Failure 422 {object} models.ValidationErrorResponse
 // @Router  /api/v1/users/profile [patch]
 func (u *UserHandler) EditProfile(ctx *gin.Context) {
-	// Get image from form-data
 	var body models.EditUserProfile
-	if err := ctx.ShouldBind(&body); err != nil {
-		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+	if ctx.ContentType() == binding.MIMEJSON {
+		data, err := ctx.GetRawData()
+		if err != nil {
+			utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+			return
+		}
+		if fieldErrs := utils.DecodeStrictJSON(data, &body); fieldErrs != nil {
+			utils.HandleValidationError(ctx, fieldErrs)
+			return
+		}
+	} else if err := ctx.ShouldBind(&body); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	if fieldErrs := utils.ValidateProfileEdit(&body); len(fieldErrs) > 0 {
+		utils.HandleValidationError(ctx, fieldErrs)
 		return
 	}
 
@@ -278,34 +442,28 @@ func (u *UserHandler) EditProfile(ctx *gin.Context) {
 	// Dari postman harus ambil gambar baru
 	file := body.Img
 	if file != nil {
-		ext := filepath.Ext(file.Filename)
-		re := regexp.MustCompile(`(?i)\.(png|jpg|jpeg|webp)$`)
-		if !re.MatchString(ext) {
-			utils.HandleError(ctx, http.StatusBadRequest, "invalid file type", "only png, jpg, jpeg, webp allowed")
+		filename, ok := saveUploadedImage(ctx, u.st, "img", file, profilePicsDir, pkg.AvatarVariants)
+		if !ok {
 			return
 		}
 
-		filename := fmt.Sprintf("%d_images_%s%s", time.Now().UnixNano(), user.UserId, ext)
-		location := filepath.Join("public/profile_pics", filename)
-
-		if err := ctx.SaveUploadedFile(file, location); err != nil {
-			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "failed to upload")
-			return
-		}
-
-		// Update profile with new image
-		editedProfile, err := u.ur.EditProfile(ctx.Request.Context(), user.UserId, body, filename)
+		// Update profile with new image, the previous one is not used anymore
+		editedProfile, oldImg, err := u.ur.EditProfile(ctx.Request.Context(), user.UserId, body, filename)
 		if err != nil {
+			removeUploadedImage(ctx, u.st, profilePicsDir, filename)
 			utils.HandleError(ctx, http.StatusInternalServerError, err.Error(), "cannot edit user profile")
 			return
 		}
+		if oldImg != "" && oldImg != filename {
+			removeUploadedImage(ctx, u.st, profilePicsDir, oldImg)
+		}
 
 		utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{Success: true, Status: http.StatusOK, Data: editedProfile})
 		return
 	}
 
 	// If no image uploaded, just update profile without image
-	editedProfile, err := u.ur.EditProfile(ctx.Request.Context(), user.UserId, body, "")
+	editedProfile, _, err := u.ur.EditProfile(ctx.Request.Context(), user.UserId, body, "")
 	if err != nil {
 		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot edit user profile")
 		return
@@ -315,6 +473,36 @@ func (u *UserHandler) EditProfile(ctx *gin.Context) {
 
 }
 
+// @Summary Remove the profile picture
+// @Tags    Users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string "Profile picture removed"
+// @Router  /api/v1/users/profile/img [delete]
+func (u *UserHandler) RemoveAvatar(ctx *gin.Context) {
+	claims, _ := ctx.Get("claims")
+	user, ok := claims.(pkg.Claims)
+	if !ok {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot cast into pkg.claims")
+		return
+	}
+
+	oldImg, err := u.ur.RemoveAvatar(ctx.Request.Context(), user.UserId)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if oldImg != "" {
+		removeUploadedImage(ctx, u.st, profilePicsDir, oldImg)
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    map[string]string{"message": "Profile picture removed"},
+	})
+}
+
 // @Summary Change user password
 // @Tags    Users
 // @Accept  json
@@ -353,7 +541,7 @@ func (u *UserHandler) ChangePassword(ctx *gin.Context) {
 	}
 
 	// Compare old password
-	hashCfg := pkg.NewHashConfig()
+	hashCfg := newHashConfig()
 	isMatched, err := hashCfg.CompareHashAndPassword(req.OldPassword, userCred.Password)
 	if err != nil || !isMatched {
 		utils.HandleError(ctx, http.StatusUnauthorized, "unauthorized", "old password does not match")
@@ -361,7 +549,6 @@ func (u *UserHandler) ChangePassword(ctx *gin.Context) {
 	}
 
 	// Hash new password
-	hashCfg.UseRecommended()
 	hashedPassword, err := hashCfg.GenHash(req.NewPassword)
 	if err != nil {
 		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "failed to hash new password")
@@ -382,3 +569,230 @@ func (u *UserHandler) ChangePassword(ctx *gin.Context) {
 		},
 	})
 }
+
+// @Summary Set a new password after a forced reset
+// @Description Uses the reset_token that the login returned instead of a JWT, login again afterwards.
+// @Tags    Auth
+// @Accept  json
+// @Produce json
+// @Param   body body models.ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string "Password changed"
+// @Router  /api/v1/auth/password/reset [post]
+func (u *UserHandler) ResetPassword(ctx *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	var claims pkg.Claims
+	if err := claims.VerifyPasswordResetToken(req.ResetToken); err != nil {
+		utils.HandleError(ctx, http.StatusUnauthorized, "silahkan login kembali", err.Error())
+		return
+	}
+	if err := utils.ValidatePassword(models.ChangePasswordRequest{NewPassword: req.NewPassword}); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	hashedPassword, err := newHashConfig().GenHash(req.NewPassword)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "failed to hash new password")
+		return
+	}
+
+	// the flag is cleared with the new password, a reset token can't be used twice
+	if err := u.ur.ResetPassword(ctx.Request.Context(), claims.UserId, hashedPassword); err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			utils.HandleError(ctx, http.StatusUnauthorized, "silahkan login kembali", "no password reset pending")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data: map[string]string{
+			"message": "Password changed successfully",
+		},
+	})
+}
+
+// emailChangeLifetime is how long the link sent to the new address can be used
+const emailChangeLifetime = 24 * time.Hour
+
+// checkAccountPassword confirms a sensitive action with the current password. Accounts from a social login
+// have none, for them the token is enough. On failure the error response is already written.
+func (u *UserHandler) checkAccountPassword(ctx *gin.Context, userID, password string) bool {
+	userCred, err := u.ur.GetPasswordFromID(ctx.Request.Context(), userID)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusNotFound, "user not found", err.Error())
+		return false
+	}
+	if userCred.Password == "" {
+		return true
+	}
+
+	isMatched, err := newHashConfig().CompareHashAndPassword(password, userCred.Password)
+	if err != nil || !isMatched {
+		utils.HandleError(ctx, http.StatusUnauthorized, "unauthorized", "password does not match")
+		return false
+	}
+	return true
+}
+
+// @Summary Change the login email
+// @Description Sends a confirmation link to the new address (and a notice to the current one).
+// @Description The email only changes once the link is opened, see /auth/email/verify.
+// @Tags    Users
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body models.ChangeEmailRequest true "New email and the current password"
+// @Success 202 {object} models.EmailChangeRespond
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router  /api/v1/users/email [post]
+func (u *UserHandler) RequestEmailChange(ctx *gin.Context) {
+	var req models.ChangeEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	addr, err := mail.ParseAddress(strings.TrimSpace(req.NewEmail))
+	if err != nil || addr.Name != "" {
+		utils.HandleValidationError(ctx, models.FieldErrors{{Field: "new_email", Message: "must be a valid email address"}})
+		return
+	}
+
+	claims, _ := ctx.Get("claims")
+	user, ok := claims.(pkg.Claims)
+	if !ok {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot cast into pkg.claims")
+		return
+	}
+
+	if !u.checkAccountPassword(ctx, user.UserId, req.Password) {
+		return
+	}
+
+	token, err := pkg.GenToken()
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	expiresAt := time.Now().Add(emailChangeLifetime)
+
+	// EMAIL_VERIFY_URL is the page of the frontend that posts the token to /auth/email/verify
+	link := os.Getenv("EMAIL_VERIFY_URL")
+	if link == "" {
+		link = "http://localhost:5173/verify-email"
+	}
+	link += "?token=" + url.QueryEscape(token)
+
+	if err := u.ur.RequestEmailChange(ctx.Request.Context(), user.UserId, addr.Address, pkg.HashToken(token), link, expiresAt); err != nil {
+		if errors.Is(err, repositories.ErrEmailTaken) {
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), err.Error())
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusAccepted, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusAccepted,
+		Data:    models.EmailChangeRespond{NewEmail: addr.Address, ExpiresAt: expiresAt},
+	})
+}
+
+// @Summary Confirm a new email
+// @Description Applies the email change of the token from the confirmation link, the token works once.
+// @Tags    Auth
+// @Accept  json
+// @Produce json
+// @Param   body body models.VerifyEmailRequest true "Token from the link"
+// @Success 200 {object} map[string]string "Email changed"
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router  /api/v1/auth/email/verify [post]
+func (u *UserHandler) ConfirmEmailChange(ctx *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	_, newEmail, err := u.ur.ConfirmEmailChange(ctx.Request.Context(), pkg.HashToken(strings.TrimSpace(req.Token)))
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrEmailChangeNotFound):
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), err.Error())
+		case errors.Is(err, repositories.ErrEmailTaken):
+			utils.HandleError(ctx, http.StatusConflict, err.Error(), err.Error())
+		default:
+			utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		}
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    map[string]string{"message": "Email changed successfully", "email": newEmail},
+	})
+}
+
+// @Summary Delete the account
+// @Description Removes the account, the profile and the login data. Orders are kept without the contact data.
+// @Description Every token of the user stops working.
+// @Tags    Users
+// @Accept  json
+// @Produce json
+// @Security BearerAuth
+// @Param   body body models.DeleteAccountRequest true "Current password"
+// @Success 200 {object} map[string]string "Account deleted"
+// @Failure 401 {object} models.ErrorResponse
+// @Router  /api/v1/users/me [delete]
+func (u *UserHandler) DeleteAccount(ctx *gin.Context) {
+	// accounts without password may send no body at all
+	var req models.DeleteAccountRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.HandleError(ctx, http.StatusBadRequest, "bad request", err.Error())
+		return
+	}
+
+	claims, _ := ctx.Get("claims")
+	user, ok := claims.(pkg.Claims)
+	if !ok {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", "cannot cast into pkg.claims")
+		return
+	}
+
+	if !u.checkAccountPassword(ctx, user.UserId, req.Password) {
+		return
+	}
+
+	img, err := u.ur.DeleteAccount(ctx.Request.Context(), user.UserId)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+	if img != "" {
+		removeUploadedImage(ctx, u.st, profilePicsDir, img)
+	}
+
+	// the account is gone, tokens that are still valid must not reach the routes anymore
+	if err := u.ac.BlacklistUserTokens(ctx.Request.Context(), user.UserId, accessTokenLifetime); err != nil {
+		log.Println("failed to blacklist tokens of the deleted user\nCause: ", err.Error())
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    map[string]string{"message": "Account deleted"},
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+)
+
+// wr : watchlist repository
+type WatchlistHandler struct {
+	wr *repositories.WatchlistRepository
+}
+
+func NewWatchlistHandler(wr *repositories.WatchlistRepository) *WatchlistHandler {
+	return &WatchlistHandler{wr: wr}
+}
+
+// @Summary List own watchlist
+// @Tags    Users
+// @Produce json
+// @Security BearerAuth
+// @Param   page     query int    false "Page number"
+// @Param   per_page query int    false "Movies per page (default 20, max 50)"
+// @Param   cursor   query string false "next_cursor of the previous page, replaces page"
+// @Success 200 {object} models.SuccessResponse{data=pagination.Page[models.WatchlistItem]}
+// @Router  /api/v1/users/watchlist [get]
+func (w *WatchlistHandler) ListWatchlist(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+	params, err := pagination.Parse(ctx.Request.URL.Query(), 20, 50)
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid pagination")
+		return
+	}
+
+	watchlist, err := w.wr.ListWatchlist(ctx.Request.Context(), user.UserId, params)
+	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			utils.HandleError(ctx, http.StatusBadRequest, err.Error(), "invalid cursor")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data:    watchlist,
+	})
+}
+
+// @Summary Add a movie to the watchlist
+// @Description You get a notification once tickets for the movie can be bought
+// @Tags    Users
+// @Produce json
+// @Security BearerAuth
+// @Param   movieId path int true "Movie ID"
+// @Success 201 {object} models.SuccessResponse
+// @Success 200 {object} models.SuccessResponse "Already in the watchlist"
+// @Router  /api/v1/users/watchlist/{movieId} [post]
+func (w *WatchlistHandler) AddToWatchlist(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+	movieID, err := strconv.Atoi(ctx.Param("movieId"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid movie id", err.Error())
+		return
+	}
+
+	created, err := w.wr.AddToWatchlist(ctx.Request.Context(), user.UserId, movieID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrWatchlistMovieNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "add to watchlist failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	utils.HandleResponse(ctx, status, models.SuccessResponse{
+		Success: true,
+		Status:  status,
+		Data: map[string]any{
+			"movie_id": movieID,
+			"message":  "Movie added to watchlist",
+		},
+	})
+}
+
+// @Summary Remove a movie from the watchlist
+// @Tags    Users
+// @Produce json
+// @Security BearerAuth
+// @Param   movieId path int true "Movie ID"
+// @Success 200 {object} models.SuccessResponse
+// @Router  /api/v1/users/watchlist/{movieId} [delete]
+func (w *WatchlistHandler) RemoveFromWatchlist(ctx *gin.Context) {
+	user, ok := claimsFromContext(ctx)
+	if !ok {
+		return
+	}
+	movieID, err := strconv.Atoi(ctx.Param("movieId"))
+	if err != nil {
+		utils.HandleError(ctx, http.StatusBadRequest, "invalid movie id", err.Error())
+		return
+	}
+
+	if err := w.wr.RemoveFromWatchlist(ctx.Request.Context(), user.UserId, movieID); err != nil {
+		if errors.Is(err, repositories.ErrWatchlistItemNotFound) {
+			utils.HandleError(ctx, http.StatusNotFound, err.Error(), "remove from watchlist failed")
+			return
+		}
+		utils.HandleError(ctx, http.StatusInternalServerError, "internal server error", err.Error())
+		return
+	}
+
+	utils.HandleResponse(ctx, http.StatusOK, models.SuccessResponse{
+		Success: true,
+		Status:  http.StatusOK,
+		Data: map[string]string{
+			"message": "Movie removed from watchlist",
+		},
+	})
+}
@@ -0,0 +1,150 @@
+package jobs
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+)
+
+// transactions are read in pages of this size
+const exportTransactionBatch = 100
+
+// DataExportJob builds the ZIP of requested personal data exports and deletes the expired ones
+type DataExportJob struct {
+	er       *repositories.ExportRepository
+	ur       *repositories.UserRepository
+	or       *repositories.OrderRepository
+	interval time.Duration
+	ttl      time.Duration
+}
+
+// ttl is how long a ready export can be downloaded
+func NewDataExportJob(er *repositories.ExportRepository, ur *repositories.UserRepository, or *repositories.OrderRepository, interval, ttl time.Duration) *DataExportJob {
+	return &DataExportJob{er: er, ur: ur, or: or, interval: interval, ttl: ttl}
+}
+
+// Start runs the job every interval until ctx is cancelled
+func (j *DataExportJob) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			j.RunOnce(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce deletes expired exports and builds every pending one
+func (j *DataExportJob) RunOnce(ctx context.Context) {
+	if purged, err := j.er.PurgeExpired(ctx); err != nil {
+		log.Println("failed to purge expired data exports\nCause: ", err.Error())
+	} else if purged > 0 {
+		log.Printf("purged %d expired data exports", purged)
+	}
+
+	for ctx.Err() == nil {
+		export, ok, err := j.er.ClaimPending(ctx)
+		if err != nil {
+			log.Println("failed to claim data export\nCause: ", err.Error())
+			return
+		}
+		if !ok {
+			return
+		}
+
+		archive, err := j.buildArchive(ctx, export.UserID)
+		if err == nil {
+			err = j.er.CompleteExport(ctx, export, archive, j.ttl, utils.ExportDownloadURL(export.ID))
+		}
+		if err != nil {
+			log.Printf("failed to build data export %s\nCause: %s", export.ID, err.Error())
+			if err := j.er.FailExport(context.WithoutCancel(ctx), export.ID, err); err != nil {
+				log.Println("failed to mark data export as failed\nCause: ", err.Error())
+			}
+		}
+	}
+}
+
+// buildArchive collects the data of the user into a ZIP with one JSON file per kind of data
+func (j *DataExportJob) buildArchive(ctx context.Context, userID string) ([]byte, error) {
+	profile, err := j.ur.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := j.listTransactions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	reviews, err := j.er.ListReviews(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	logins, err := j.er.ListLoginEvents(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", profile},
+		{"transactions.json", transactions},
+		{"reviews.json", reviews},
+		{"login_history.json", logins},
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// listTransactions walks through every page of the transaction history with its cursor
+func (j *DataExportJob) listTransactions(ctx context.Context, userID string) ([]models.TransactionHistory, error) {
+	transactions := []models.TransactionHistory{}
+	params := pagination.Params{Page: 1, PerPage: exportTransactionBatch}
+	for {
+		page, err := j.or.ListTransaction(ctx, userID, params)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, page.Items...)
+		if page.NextCursor == "" {
+			return transactions, nil
+		}
+		cursor, err := pagination.DecodeCursor(page.NextCursor)
+		if err != nil {
+			return nil, err
+		}
+		params.Cursor = &cursor
+	}
+}
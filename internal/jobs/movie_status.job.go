@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+)
+
+// MovieStatusJob publishes drafts and archives movies when their publish_at / archive_at has passed
+type MovieStatusJob struct {
+	mr       *repositories.MovieRepository
+	interval time.Duration
+}
+
+func NewMovieStatusJob(mr *repositories.MovieRepository, interval time.Duration) *MovieStatusJob {
+	return &MovieStatusJob{mr: mr, interval: interval}
+}
+
+// Start runs the job every interval until ctx is cancelled
+func (j *MovieStatusJob) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			j.RunOnce(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce applies every publish / archive date that has passed
+func (j *MovieStatusJob) RunOnce(ctx context.Context) {
+	published, archived, err := j.mr.ApplyScheduledStatus(ctx)
+	if err != nil {
+		log.Println("failed to apply scheduled movie status\nCause: ", err.Error())
+		return
+	}
+	if published > 0 || archived > 0 {
+		log.Printf("movie status job: %d published, %d archived", published, archived)
+	}
+}
@@ -0,0 +1,67 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// deliverBatch is the number of notifications sent per transaction
+const deliverBatch = 50
+
+// NotificationJob queues "tickets available" notifications for watchlisted movies
+// and delivers the outbox through the notifier
+type NotificationJob struct {
+	nr       *repositories.NotificationRepository
+	notifier pkg.Notifier
+	interval time.Duration
+}
+
+func NewNotificationJob(nr *repositories.NotificationRepository, notifier pkg.Notifier, interval time.Duration) *NotificationJob {
+	return &NotificationJob{nr: nr, notifier: notifier, interval: interval}
+}
+
+// Start runs the job every interval until ctx is cancelled
+func (j *NotificationJob) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			j.RunOnce(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RunOnce queues new notifications and sends everything that is pending
+func (j *NotificationJob) RunOnce(ctx context.Context) {
+	queued, err := j.nr.QueueScheduledMovies(ctx)
+	if err != nil {
+		log.Println("failed to queue watchlist notifications\nCause: ", err.Error())
+	} else if queued > 0 {
+		log.Printf("queued %d watchlist notifications", queued)
+	}
+
+	for {
+		sent, err := j.nr.DeliverPending(ctx, deliverBatch, func(n pkg.Notification) error {
+			return j.notifier.Notify(ctx, n)
+		})
+		if err != nil {
+			log.Println("failed to deliver notifications\nCause: ", err.Error())
+			return
+		}
+		// a batch that was not full (or only had failures) means the outbox is done for now
+		if sent < deliverBatch {
+			return
+		}
+	}
+}
@@ -27,7 +27,9 @@ func CORSMiddleware(ctx *gin.Context) {
 
 	// Header CORS standar
 	ctx.Header("Access-Control-Allow-Methods", "GET, POST, PATCH, PUT, DELETE, OPTIONS")
-	ctx.Header("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	ctx.Header("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match")
+	// ETag dipakai frontend sebagai If-Match saat edit movie
+	ctx.Header("Access-Control-Expose-Headers", "ETag")
 	// ctx.Header("Access-Control-Allow-Credentials", "true")
 
 	// Jika request adalah preflight (OPTIONS)
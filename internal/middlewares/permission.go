@@ -0,0 +1,36 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// RequirePermission checks the permissions embedded in the token.
+// With scopeParam the route param (e.g. "cinema_id") limits the check to that cinema.
+func RequirePermission(perm string, scopeParam ...string) func(*gin.Context) {
+	return func(ctx *gin.Context) {
+		claims, isExist := ctx.Get("claims")
+		if !isExist {
+			utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "Unauthorized Access")
+			return
+		}
+		user, ok := claims.(pkg.Claims)
+		if !ok {
+			utils.HandleMiddlewareError(ctx, http.StatusInternalServerError, "Internal Server Error", "cannot cast into pkg.claims")
+			return
+		}
+
+		scope := ""
+		if len(scopeParam) > 0 {
+			scope = ctx.Param(scopeParam[0])
+		}
+		if !user.HasPermission(perm, scope) {
+			utils.HandleMiddlewareError(ctx, http.StatusForbidden, "Anda tidak punya hak akses untuk resource ini", "Missing permission "+perm)
+			return
+		}
+		ctx.Next()
+	}
+}
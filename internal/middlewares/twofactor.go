@@ -0,0 +1,41 @@
+package middlewares
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// RequireTwoFactor rejects admin tokens that were issued without a second factor
+// when ADMIN_REQUIRE_2FA is enabled. Admins can still reach /users/2fa to enrol.
+// ADMIN_REQUIRE_2FA is read once, when the routes are registered.
+func RequireTwoFactor() gin.HandlerFunc {
+	required, _ := strconv.ParseBool(os.Getenv("ADMIN_REQUIRE_2FA"))
+
+	return func(ctx *gin.Context) {
+		if !required {
+			ctx.Next()
+			return
+		}
+
+		claims, isExist := ctx.Get("claims")
+		if !isExist {
+			utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "Unauthorized Access")
+			return
+		}
+		user, ok := claims.(pkg.Claims)
+		if !ok {
+			utils.HandleMiddlewareError(ctx, http.StatusInternalServerError, "Internal Server Error", "cannot cast into pkg.claims")
+			return
+		}
+		if user.Role == "admin" && !user.MFA {
+			utils.HandleMiddlewareError(ctx, http.StatusForbidden, "two-factor authentication is required for admin accounts", "Admin without 2FA")
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -1,21 +1,38 @@
 package middlewares
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/configs"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
 	"github.com/radifan9/tickitz-ticketing-backend/pkg"
 	"github.com/redis/go-redis/v9"
 )
 
-var globalAuthCache *utils.AuthCacheManager
+var (
+	globalAuthCache    *utils.AuthCacheManager
+	globalAuthFailOpen bool
+)
 
 func InitAuthCache(rdb *redis.Client) {
 	globalAuthCache = utils.NewAuthCacheManager(rdb)
+	globalAuthFailOpen = configs.AuthRedisFailOpen()
+}
+
+// blacklistUnavailable applies AUTH_REDIS_FAILURE when the blacklist could not be read.
+// It returns true when the request was rejected.
+func blacklistUnavailable(ctx *gin.Context, err error, failOpen bool) bool {
+	if failOpen {
+		log.Println("auth blacklist unavailable, request allowed (fail open)\nCause: ", err.Error())
+		return false
+	}
+	utils.HandleMiddlewareError(ctx, http.StatusServiceUnavailable, "layanan sedang tidak tersedia, coba lagi nanti", "Auth blacklist unavailable: "+err.Error())
+	return true
 }
 
 // Verify Token without checking Redis Cache
@@ -41,8 +58,13 @@ func VerifyToken(ctx *gin.Context) {
 
 	// Check if token is blacklisted
 	if globalAuthCache != nil {
-		if globalAuthCache.IsTokenBlacklisted(ctx.Request.Context(), token) {
+		blacklisted, err := globalAuthCache.IsTokenBlacklisted(ctx.Request.Context(), token)
+		if err != nil && blacklistUnavailable(ctx, err, globalAuthFailOpen) {
+			return
+		}
+		if blacklisted {
 			utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "Token has been invalidated")
+			return
 		}
 	}
 
@@ -56,14 +78,31 @@ func VerifyToken(ctx *gin.Context) {
 			utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "Expired JWT")
 			return
 		}
+		if errors.Is(err, pkg.ErrTokenWrongPurpose) {
+			utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "Not an access token")
+			return
+		}
 		// fmt.Println(jwt.ErrTokenExpired)
 		utils.HandleMiddlewareError(ctx, http.StatusInternalServerError, "Internal Server Error", "Internal Server Error")
 		return
 	}
 
-	// Check if all user tokens are blacklisted (only if auth cache is initialized)
+	// Check if the user is suspended or all user tokens are blacklisted (only if auth cache is initialized)
 	if globalAuthCache != nil {
-		if globalAuthCache.IsUserTokensBlacklisted(ctx.Request.Context(), claims.UserId, claims.IssuedAt.Time) {
+		suspended, err := globalAuthCache.IsUserSuspended(ctx.Request.Context(), claims.UserId)
+		if err != nil && blacklistUnavailable(ctx, err, globalAuthFailOpen) {
+			return
+		}
+		if suspended {
+			utils.HandleMiddlewareError(ctx, http.StatusForbidden, "akun kamu dinonaktifkan", "User is suspended")
+			return
+		}
+
+		blacklisted, err := globalAuthCache.IsUserTokensBlacklisted(ctx.Request.Context(), claims.UserId, claims.IssuedAt.Time)
+		if err != nil && blacklistUnavailable(ctx, err, globalAuthFailOpen) {
+			return
+		}
+		if blacklisted {
 			utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "All user tokens have been invalidated")
 			return
 		}
@@ -76,6 +115,7 @@ func VerifyToken(ctx *gin.Context) {
 // VerifyTokenWithBlacklist creates a middleware that checks both JWT validity and blacklist
 func VerifyTokenWithBlacklist(rdb *redis.Client) gin.HandlerFunc {
 	var authCache *utils.AuthCacheManager
+	failOpen := configs.AuthRedisFailOpen()
 	if rdb != nil {
 		authCache = utils.NewAuthCacheManager(rdb)
 		log.Println("VerifyTokenWithBlacklist: Auth cache initialized")
@@ -104,7 +144,11 @@ func VerifyTokenWithBlacklist(rdb *redis.Client) gin.HandlerFunc {
 
 		// Check if token is blacklisted (only if auth cache is available)
 		if authCache != nil {
-			if authCache.IsTokenBlacklisted(ctx.Request.Context(), token) {
+			blacklisted, err := authCache.IsTokenBlacklisted(ctx.Request.Context(), token)
+			if err != nil && blacklistUnavailable(ctx, err, failOpen) {
+				return
+			}
+			if blacklisted {
 				utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "Token has been invalidated")
 				return
 			}
@@ -120,13 +164,30 @@ func VerifyTokenWithBlacklist(rdb *redis.Client) gin.HandlerFunc {
 				utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "Expired JWT")
 				return
 			}
+			if errors.Is(err, pkg.ErrTokenWrongPurpose) {
+				utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "Not an access token")
+				return
+			}
 			utils.HandleMiddlewareError(ctx, http.StatusInternalServerError, "Internal Server Error", "Internal Server Error")
 			return
 		}
 
-		// Check if all user tokens are blacklisted (only if auth cache is available and claims are valid)
+		// Check if the user is suspended or all user tokens are blacklisted (only if auth cache is available and claims are valid)
 		if authCache != nil && claims.UserId != "" && claims.IssuedAt != nil && !claims.IssuedAt.IsZero() {
-			if authCache.IsUserTokensBlacklisted(ctx.Request.Context(), claims.UserId, claims.IssuedAt.Time) {
+			suspended, err := authCache.IsUserSuspended(ctx.Request.Context(), claims.UserId)
+			if err != nil && blacklistUnavailable(ctx, err, failOpen) {
+				return
+			}
+			if suspended {
+				utils.HandleMiddlewareError(ctx, http.StatusForbidden, "akun kamu dinonaktifkan", "User is suspended")
+				return
+			}
+
+			blacklisted, err := authCache.IsUserTokensBlacklisted(ctx.Request.Context(), claims.UserId, claims.IssuedAt.Time)
+			if err != nil && blacklistUnavailable(ctx, err, failOpen) {
+				return
+			}
+			if blacklisted {
 				utils.HandleMiddlewareError(ctx, http.StatusUnauthorized, "silahkan login kembali", "All user tokens have been invalidated")
 				return
 			}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// DataExport is a requested copy of the personal data, DownloadURL is set once the ZIP is ready
+type DataExport struct {
+	ID          string     `json:"id"`
+	UserID      string     `json:"-"`
+	Status      string     `json:"status" example:"ready"` // pending, processing, ready or failed
+	SizeBytes   int        `json:"size_bytes,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+// LoginEvent is one successful login
+type LoginEvent struct {
+	Method    string    `json:"method"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ExportedReview is a review of the user together with the movie title
+type ExportedReview struct {
+	ID        int        `json:"id"`
+	MovieID   int        `json:"movie_id"`
+	Title     string     `json:"title"`
+	Rating    int        `json:"rating"`
+	Body      string     `json:"body"`
+	HiddenAt  *time.Time `json:"hidden_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
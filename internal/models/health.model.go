@@ -0,0 +1,13 @@
+package models
+
+// HealthStatus is the body of GET /health. Status is ok, degraded (Redis is down, the API keeps serving
+// from Postgres) or down (Postgres is down).
+type HealthStatus struct {
+	Status           string `json:"status" example:"degraded"`
+	Postgres         string `json:"postgres" example:"up"`
+	Redis            string `json:"redis" example:"down"`
+	RedisCircuit     string `json:"redis_circuit" example:"open"`
+	AuthRedisFailure string `json:"auth_redis_failure" example:"closed"`
+	RedisError       string `json:"redis_error,omitempty"`
+	PostgresError    string `json:"postgres_error,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// ImageURLs are the sizes generated from an uploaded poster, backdrop or avatar, *_webp is the same size
+// as WebP. Images uploaded before the sizes were generated only have the original, every size points to it.
+type ImageURLs struct {
+	Original      string `json:"original" example:"/api/v1/img/posters/1727000000_orig.png"`
+	Thumbnail     string `json:"thumbnail" example:"/api/v1/img/posters/1727000000_thumbnail.jpg"`
+	ThumbnailWebP string `json:"thumbnail_webp" example:"/api/v1/img/posters/1727000000_thumbnail.webp"`
+	Medium        string `json:"medium"`
+	MediumWebP    string `json:"medium_webp"`
+	Large         string `json:"large"`
+	LargeWebP     string `json:"large_webp"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "mime/multipart"
+
+// MovieMedia is a trailer (Provider + ExternalID) or a still image (Image = filename in public/stills)
+type MovieMedia struct {
+	ID         int    `json:"id"`
+	MovieID    int    `json:"movie_id"`
+	Kind       string `json:"kind" example:"trailer"`
+	Provider   string `json:"provider,omitempty" example:"youtube"`
+	ExternalID string `json:"external_id,omitempty" example:"dQw4w9WgXcQ"`
+	URL        string `json:"url,omitempty" example:"https://www.youtube.com/watch?v=dQw4w9WgXcQ"`
+	EmbedURL   string `json:"embed_url,omitempty" example:"https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ"`
+	Image      string `json:"image,omitempty"`
+	Caption    string `json:"caption"`
+	Position   int    `json:"position"`
+}
+
+// AddTrailerRequest takes either a full url or provider + video_id
+type AddTrailerRequest struct {
+	URL      string `json:"url" example:"https://youtu.be/dQw4w9WgXcQ"`
+	Provider string `json:"provider" example:"youtube"`
+	VideoID  string `json:"video_id" example:"dQw4w9WgXcQ"`
+	Caption  string `json:"caption" binding:"max=200" example:"Official Trailer"`
+}
+
+type AddImageRequest struct {
+	Image   *multipart.FileHeader `form:"image" binding:"required"`
+	Caption string                `form:"caption" binding:"max=200"`
+}
+
+// ReorderMediaRequest lists every media id of the movie in the new order
+type ReorderMediaRequest struct {
+	IDs []int `json:"ids" binding:"required"`
+}
@@ -3,51 +3,160 @@ package models
 import (
 	"mime/multipart"
 	"time"
+
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
 )
 
 type Movie struct {
-	ID              int        `db:"id" json:"id"`
-	Title           string     `db:"title" json:"title"`
-	Synopsis        string     `db:"synopsis" json:"synopsis,omitempty"`
-	PosterImg       string     `db:"poster_img" json:"poster_img"`
-	BackdropImg     string     `db:"backdrop_img" json:"backdrop_img,omitempty"`
-	DurationMinutes *int       `db:"duration_minutes" json:"duration_minutes,omitempty"`
-	ReleaseDate     *time.Time `db:"release_date" json:"release_date,omitempty"`
-	AgeRatingID     int        `json:"age_rating_id,omitempty"`
-	Genres          []string   `db:"genres" json:"genres"`
-	Director        string     `db:"director" json:"director,omitempty"`
-	Cast            []string   `db:"cast" json:"cast,omitempty"`
-	CreatedAt       time.Time  `db:"created_at" json:"created_at"`
-	UpdatedAt       time.Time  `db:"updated_at" json:"updated_at"`
-}
-
-type CreateMovie struct {
-	ID              int                   `db:"id" form:"id"`
-	PosterImg       *multipart.FileHeader `db:"poster_img" form:"poster_img" json:"poster_img,omitempty"`
-	BackdropImg     *multipart.FileHeader `db:"backdrop_img" form:"backdrop_img,omitempty" json:"backdrop_img,omitempty"`
-	Title           string                `db:"title" form:"title" json:"title,omitempty"`
-	Genres          string                `db:"genres" form:"genres" json:"genres,omitempty"`
-	AgeRating       int                   `form:"age_rating_id" json:"age_rating_id,omitempty"`
-	ReleaseDate     string                `db:"release_date" form:"release_date" json:"release_date,omitempty"`
-	DurationMinutes int                   `db:"duration_minutes" form:"duration_minutes,omitempty" json:"duration_minutes,omitempty"`
-	Director        string                `db:"director" form:"director,omitempty" json:"director,omitempty"`
-	Cast            string                `db:"cast" form:"cast,omitempty" json:"cast,omitempty"`
-	Synopsis        string                `db:"synopsis" form:"synopsis,omitempty" json:"synopsis,omitempty"`
-	CityID          string                `db:"city_id" form:"city_id" json:"city_id"`
-	CinemaID        string                `db:"cinema_id" form:"cinema_id" json:"cinema_id"`
-	ShowDate        string                `db:"show_date" form:"show_date" json:"show_date"`
-	ShowTimeID      string                `db:"show_time_id" form:"show_time_id" json:"show_time_id"`
+	ID              int          `db:"id" json:"id"`
+	Title           string       `db:"title" json:"title"`
+	Synopsis        string       `db:"synopsis" json:"synopsis,omitempty"`
+	PosterImg       string       `db:"poster_img" json:"poster_img"`
+	PosterURLs      *ImageURLs   `json:"poster_urls,omitempty"`
+	BackdropImg     string       `db:"backdrop_img" json:"backdrop_img,omitempty"`
+	BackdropURLs    *ImageURLs   `json:"backdrop_urls,omitempty"`
+	DurationMinutes *int         `db:"duration_minutes" json:"duration_minutes,omitempty"`
+	ReleaseDate     *time.Time   `db:"release_date" json:"release_date,omitempty"`
+	AgeRatingID     int          `json:"age_rating_id,omitempty"`
+	Genres          []string     `db:"genres" json:"genres"`
+	Director        string       `db:"director" json:"director,omitempty"`
+	Cast            []string     `db:"cast" json:"cast,omitempty"`
+	RatingAvg       *float64     `db:"rating_avg" json:"rating_avg"`
+	RatingCount     int          `db:"rating_count" json:"rating_count"`
+	Media           []MovieMedia `json:"media,omitempty"`
+	Status          string       `db:"status" json:"status,omitempty" example:"published"`
+	PublishAt       *time.Time   `db:"publish_at" json:"publish_at,omitempty"`
+	ArchiveAt       *time.Time   `db:"archive_at" json:"archive_at,omitempty"`
+	ArchivedAt      *time.Time   `db:"archived_at" json:"archived_at,omitempty"`
+	CreatedAt       time.Time    `db:"created_at" json:"created_at"`
+	UpdatedAt       time.Time    `db:"updated_at" json:"updated_at"`
+}
+
+// MovieInput is the metadata of POST /admin/movies. It is sent as the JSON body, or as the
+// "metadata" part of a multipart form when poster_img / backdrop_img are uploaded too.
+// Status is draft or published, by default draft when publish_at is set and published otherwise.
+type MovieInput struct {
+	Title           string         `json:"title" example:"Oppenheimer"`
+	Synopsis        string         `json:"synopsis"`
+	ReleaseDate     string         `json:"release_date" example:"2023-07-21"`
+	DurationMinutes int            `json:"duration_minutes" example:"180"`
+	AgeRatingID     int            `json:"age_rating_id" example:"3"`
+	Director        string         `json:"director" example:"Christopher Nolan"`
+	GenreIDs        []int          `json:"genre_ids"`
+	Cast            []string       `json:"cast"`
+	Schedule        *ScheduleInput `json:"schedule,omitempty"`
+	Status          string         `json:"status,omitempty" example:"draft"`
+	PublishAt       string         `json:"publish_at,omitempty" example:"2025-10-01T00:00:00+07:00"`
+	ArchiveAt       string         `json:"archive_at,omitempty" example:"2025-12-01T00:00:00+07:00"`
 }
 
+// ScheduleInput creates a schedule for every city, cinema and show time on the 7 days from ShowDate
+type ScheduleInput struct {
+	ShowDate    string `json:"show_date" example:"2025-10-01"`
+	CityIDs     []int  `json:"city_ids"`
+	CinemaIDs   []int  `json:"cinema_ids"`
+	ShowTimeIDs []int  `json:"show_time_ids"`
+}
+
+// MoviePatch is the payload of PATCH /admin/movies/:id, only the fields that are sent change.
+// genre_ids and cast are the new complete lists, schedule is the new set of schedules.
+// An empty publish_at / archive_at removes the scheduled date.
+type MoviePatch struct {
+	Title           *string        `json:"title,omitempty"`
+	Synopsis        *string        `json:"synopsis,omitempty"`
+	ReleaseDate     *string        `json:"release_date,omitempty" example:"2023-07-21"`
+	DurationMinutes *int           `json:"duration_minutes,omitempty"`
+	AgeRatingID     *int           `json:"age_rating_id,omitempty"`
+	Director        *string        `json:"director,omitempty"`
+	GenreIDs        *[]int         `json:"genre_ids,omitempty"`
+	Cast            *[]string      `json:"cast,omitempty"`
+	Schedule        *ScheduleInput `json:"schedule,omitempty"`
+	PublishAt       *string        `json:"publish_at,omitempty"`
+	ArchiveAt       *string        `json:"archive_at,omitempty"`
+}
+
+// MovieUpload is the multipart form of POST / PATCH /admin/movies, Metadata holds the MovieInput / MoviePatch JSON
+type MovieUpload struct {
+	Metadata    string                `form:"metadata"`
+	PosterImg   *multipart.FileHeader `form:"poster_img"`
+	BackdropImg *multipart.FileHeader `form:"backdrop_img"`
+}
+
+// MovieFilter is built from the query string of GET /movies, nil / empty means "no filter"
 type MovieFilter struct {
-	Keywords []string `db:"keywords"`
-	Genres   []int    `db:"genres"`
-	Offset   int      `db:"offset"`
-	Limit    int      `db:"limit"`
+	Keywords     []string
+	Genres       []int
+	AgeRatings   []int
+	ReleasedFrom *time.Time
+	ReleasedTo   *time.Time
+	MinDuration  *int
+	MaxDuration  *int
+	Status       string // now_showing, upcoming
+	CityID       *int
+	CinemaID     *int
+	Sort         string // title, release_date, popularity, rating
+	Order        string // asc, desc
+}
+
+// AdminMovieFilter is built from the query string of GET /admin/movies, nil / empty means "no filter"
+type AdminMovieFilter struct {
+	Query        string // part of the title
+	Genres       []int
+	Status       string // draft, published, archived, empty = everything but archived
+	ReleasedFrom *time.Time
+	ReleasedTo   *time.Time
+	Sort         string // updated_at, created_at, title, release_date, schedule_count, tickets_sold
+	Order        string // asc, desc
+	WithSales    bool   // tickets_sold is only computed and sortable with revenue:read
 }
 
+// AdminMovie is a row of the admin movie table, with the aggregates over all schedules of the movie
+type AdminMovie struct {
+	Movie
+	ScheduleCount int  `json:"schedule_count"`
+	TicketsSold   *int `json:"tickets_sold,omitempty"` // seats of paid transactions, only with revenue:read
+}
+
+// MovieStatusCounts counts the movies per status with every filter except the status applied
+type MovieStatusCounts struct {
+	Draft     int `json:"draft"`
+	Published int `json:"published"`
+	Archived  int `json:"archived"`
+}
+
+// AdminMoviePage is the page envelope plus the counts for the status tabs of the dashboard
+type AdminMoviePage struct {
+	pagination.Page[AdminMovie]
+	StatusCounts MovieStatusCounts `json:"status_counts"`
+}
+
+// ArchiveMovieRespond tells what archiving did: future schedules without bookings are removed,
+// the ones with bookings are kept
 type ArchiveMovieRespond struct {
-	ID          int       `json:"id"`
-	Title       string    `json:"title"`
-	Archived_at time.Time `json:"archived_at"`
+	ID               int       `json:"id"`
+	Title            string    `json:"title"`
+	Archived_at      time.Time `json:"archived_at"`
+	RemovedSchedules int64     `json:"removed_schedules"`
+	KeptSchedules    int       `json:"kept_schedules"`
+	PaidBookings     int       `json:"paid_bookings"`
+}
+
+// MovieSearchResult is one hit of the full-text search, highlights are wrapped in <mark></mark>
+type MovieSearchResult struct {
+	ID             int        `json:"id"`
+	Title          string     `json:"title"`
+	TitleHighlight string     `json:"title_highlight"`
+	Snippet        string     `json:"snippet"`
+	PosterImg      string     `json:"poster_img"`
+	PosterURLs     *ImageURLs `json:"poster_urls,omitempty"`
+	ReleaseDate    *time.Time `json:"release_date,omitempty"`
+	Genres         []string   `json:"genres"`
+	Rank           float64    `json:"rank"`
+}
+
+// Suggestion is one autocomplete entry, Type is movie, person or genre
+type Suggestion struct {
+	Type string `json:"type" example:"movie"`
+	ID   int    `json:"id"`
+	Text string `json:"text" example:"Demon Slayer: Kimetsu no Yaiba Infinity Castle"`
 }
@@ -0,0 +1,22 @@
+package models
+
+// OIDCLoginState is kept in redis between the redirect to the provider and the callback
+type OIDCLoginState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
+
+// ExternalIdentity is a verified identity from an OIDC provider
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+type OIDCProvidersResponse struct {
+	Providers []string `json:"providers" example:"google"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Role represents the roles table with its permission codes
+type Role struct {
+	ID          int      `db:"id" json:"id"`
+	Name        string   `db:"name" json:"name"`
+	Description string   `db:"description" json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// UserRole is a role assigned to a user, CinemaID nil means every cinema
+type UserRole struct {
+	ID        int        `db:"id" json:"id"`
+	UserID    string     `db:"user_id" json:"user_id"`
+	Role      string     `db:"name" json:"role"`
+	CinemaID  *int       `db:"cinema_id" json:"cinema_id"`
+	CreatedAt *time.Time `db:"created_at" json:"created_at,omitempty"`
+}
+
+type AssignRoleRequest struct {
+	Role     string `json:"role" binding:"required" example:"cinema_staff"`
+	CinemaID *int   `json:"cinema_id,omitempty" example:"1"`
+}
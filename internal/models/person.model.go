@@ -0,0 +1,50 @@
+package models
+
+import (
+	"mime/multipart"
+	"time"
+)
+
+type Person struct {
+	ID        int        `json:"id"`
+	Name      string     `json:"name" example:"Christopher Nolan"`
+	Biography string     `json:"biography,omitempty"`
+	PhotoImg  string     `json:"photo_img,omitempty"`
+	BirthDate *time.Time `json:"birth_date,omitempty"`
+	Aliases   []string   `json:"aliases,omitempty"`
+}
+
+// FilmographyItem is a movie on a person page
+type FilmographyItem struct {
+	MovieID     int        `json:"movie_id"`
+	Title       string     `json:"title"`
+	PosterImg   string     `json:"poster_img"`
+	PosterURLs  *ImageURLs `json:"poster_urls,omitempty"`
+	ReleaseDate *time.Time `json:"release_date,omitempty"`
+}
+
+type PersonDetail struct {
+	Person
+	Directed []FilmographyItem `json:"directed"`
+	Acted    []FilmographyItem `json:"acted"`
+}
+
+// EditPersonRequest, fields that are not sent stay unchanged
+type EditPersonRequest struct {
+	Name      *string               `form:"name"`
+	Biography *string               `form:"biography"`
+	BirthDate *string               `form:"birth_date" example:"1970-07-30"`
+	Photo     *multipart.FileHeader `form:"photo"`
+}
+
+// MergePeopleRequest merges the source people into the person of the url
+type MergePeopleRequest struct {
+	SourceIDs []int `json:"source_ids" binding:"required,min=1"`
+}
+
+// DuplicatePeople is a pair of people that are probably the same person
+type DuplicatePeople struct {
+	A     Person  `json:"a"`
+	B     Person  `json:"b"`
+	Score float64 `json:"score"`
+}
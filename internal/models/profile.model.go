@@ -1,21 +1,23 @@
 package models
 
 import (
+	"encoding/json"
 	"mime/multipart"
 	"time"
 )
 
 // UserProfile represents the user_profiles table
 type UserProfile struct {
-	UserID      string    `db:"user_id" json:"user_id,omitempty"`
-	Email       string    `db:"email" json:"email"`
-	FirstName   string    `db:"first_name" json:"first_name,omitempty" form:"first_name"`
-	LastName    string    `db:"last_name" json:"last_name,omitempty"`
-	Img         string    `db:"img" json:"img,omitempty"`
-	PhoneNumber string    `db:"phone_number" json:"phone_number,omitempty"`
-	Points      int       `db:"points" json:"points,omitempty"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at,omitempty"`
-	UpdatedAt   time.Time `db:"updated_at" json:"updated_at,omitempty"`
+	UserID      string     `db:"user_id" json:"user_id,omitempty"`
+	Email       string     `db:"email" json:"email"`
+	FirstName   string     `db:"first_name" json:"first_name,omitempty" form:"first_name"`
+	LastName    string     `db:"last_name" json:"last_name,omitempty"`
+	Img         string     `db:"img" json:"img,omitempty"`
+	ImgURLs     *ImageURLs `json:"img_urls,omitempty"`
+	PhoneNumber string     `db:"phone_number" json:"phone_number,omitempty"`
+	Points      int        `db:"points" json:"points,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at,omitempty"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at,omitempty"`
 }
 
 // type EditUserProfile struct {
@@ -23,9 +25,61 @@ type UserProfile struct {
 // 	Img *multipart.FileHeader `form:"img"`
 // }
 
+// EditUserProfile is the body of PATCH /users/profile, JSON or multipart form. A field that is not sent
+// stays unchanged, null (an empty value in a form) clears it.
 type EditUserProfile struct {
-	FirstName   string                `form:"first_name"`
-	LastName    string                `form:"last_name"`
-	PhoneNumber string                `form:"phone_number"`
-	Img         *multipart.FileHeader `form:"img"`
+	FirstName   OptionalString        `json:"first_name" form:"first_name" swaggertype:"string" example:"Budi"`
+	LastName    OptionalString        `json:"last_name" form:"last_name" swaggertype:"string"`
+	PhoneNumber OptionalString        `json:"phone_number" form:"phone_number" swaggertype:"string" example:"081234567890"`
+	Img         *multipart.FileHeader `json:"-" form:"img" swaggerignore:"true"`
+}
+
+// OptionalString tells apart a field that was not sent, one sent as null and one with a value.
+// Invalid marks a JSON value that is not a string, the validation reports it with the field name
+// (an error from UnmarshalJSON would lose it).
+type OptionalString struct {
+	Set     bool
+	Null    bool
+	Invalid bool
+	Value   string
+}
+
+func (o *OptionalString) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	o.Invalid = json.Unmarshal(data, &o.Value) != nil
+	return nil
+}
+
+// UnmarshalParam is used by the form binding, only for keys that are present
+func (o *OptionalString) UnmarshalParam(param string) error {
+	o.Set = true
+	o.Null = param == ""
+	o.Value = param
+	return nil
+}
+
+// ChangeEmailRequest starts an email change. The password is required for accounts that have one.
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required" example:"new@example.com"`
+	Password string `json:"password" example:"Str0ngP@ss!"`
+}
+
+// EmailChangeRespond tells where the confirmation link was sent
+type EmailChangeRespond struct {
+	NewEmail  string    `json:"new_email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// VerifyEmailRequest carries the token from the link that was sent to the new address
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// DeleteAccountRequest confirms DELETE /users/me, the password is required for accounts that have one
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"Str0ngP@ss!"`
 }
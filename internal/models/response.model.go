@@ -1,5 +1,7 @@
 package models
 
+import "strings"
+
 type SuccessResponse struct {
 	Success bool `json:"success" example:"true"`
 	Status  int  `json:"status" example:"200"`
@@ -16,3 +18,27 @@ type ErrorResponse struct {
 	Status  int    `json:"status" example:"500"`
 	Error   string `json:"error" example:"error message"`
 }
+
+// FieldError is one invalid field of a request body, Field is the json path (e.g. "schedule.cinema_ids[1]")
+type FieldError struct {
+	Field   string `json:"field" example:"genre_ids[0]"`
+	Message string `json:"message" example:"genre does not exist"`
+}
+
+// FieldErrors lists every invalid field of a request, it is returned as error by the validation
+type FieldErrors []FieldError
+
+func (f FieldErrors) Error() string {
+	msgs := make([]string, 0, len(f))
+	for _, e := range f {
+		msgs = append(msgs, e.Field+": "+e.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+type ValidationErrorResponse struct {
+	Success bool         `json:"success" example:"false"`
+	Status  int          `json:"status" example:"422"`
+	Error   string       `json:"error" example:"validation failed"`
+	Fields  []FieldError `json:"fields"`
+}
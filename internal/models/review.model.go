@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type Review struct {
+	ID         int        `json:"id"`
+	MovieID    int        `json:"movie_id"`
+	UserID     string     `json:"user_id,omitempty"`
+	Author     string     `json:"author" example:"Radif"`
+	Rating     int        `json:"rating" example:"5"`
+	Body       string     `json:"body"`
+	Verified   bool       `json:"verified_watch"` // the ticket was scanned at the cinema
+	HiddenAt   *time.Time `json:"hidden_at,omitempty"`
+	FlaggedAt  *time.Time `json:"flagged_at,omitempty"`
+	FlagReason string     `json:"flag_reason,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type ReviewRequest struct {
+	Rating int    `json:"rating" binding:"required,min=1,max=5" example:"4"`
+	Body   string `json:"body" binding:"max=2000" example:"Animasinya keren banget"`
+}
+
+// ModerateReviewRequest, action is hide, unhide, flag or unflag
+type ModerateReviewRequest struct {
+	Action string `json:"action" binding:"required,oneof=hide unhide flag unflag" example:"hide"`
+	Reason string `json:"reason" binding:"max=500" example:"spoiler"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// UserTOTP represents the user_totp table
+type UserTOTP struct {
+	UserID       string     `db:"user_id" json:"user_id"`
+	Secret       string     `db:"secret" json:"-"`
+	EnabledAt    *time.Time `db:"enabled_at" json:"enabled_at,omitempty"`
+	LastUsedStep *int64     `db:"last_used_step" json:"-"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+	QRCode     string `json:"qr_code"` // PNG as data URI
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code,omitempty" example:"123456"`
+	RecoveryCode   string `json:"recovery_code,omitempty" example:"abcde-fghjk"`
+}
+
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	ChallengeToken    string `json:"challenge_token"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
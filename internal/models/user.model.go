@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type User struct {
 	Id       string `db:"id" json:"id,omitempty"`
 	Role     string `db:"role" json:"role,omitempty"`
@@ -17,4 +19,62 @@ type ChangePasswordRequest struct {
 	NewPassword string `json:"new_password" binding:"required" example:"NewP@ss456!"`
 }
 
+// PasswordResetRequiredResponse is returned by the login instead of a JWT after an admin forced a password reset
+type PasswordResetRequiredResponse struct {
+	PasswordResetRequired bool   `json:"password_reset_required"`
+	ResetToken            string `json:"reset_token"`
+}
+
+// ResetPasswordRequest sets the new password with the reset token from the login
+type ResetPasswordRequest struct {
+	ResetToken  string `json:"reset_token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required" example:"NewP@ss456!"`
+}
+
+// AccountStatus is what the login checks besides the credentials
+type AccountStatus struct {
+	SuspendedAt           *time.Time
+	PasswordResetRequired bool
+}
+
+// AdminUser is a user as the admins see it in the user list
+type AdminUser struct {
+	ID                    string     `json:"id"`
+	Email                 string     `json:"email"`
+	Role                  string     `json:"role"`
+	FirstName             string     `json:"first_name,omitempty"`
+	LastName              string     `json:"last_name,omitempty"`
+	PhoneNumber           string     `json:"phone_number,omitempty"`
+	Points                int        `json:"points"`
+	SuspendedAt           *time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason       string     `json:"suspended_reason,omitempty"`
+	PasswordResetRequired bool       `json:"password_reset_required"`
+	CreatedAt             time.Time  `json:"created_at"`
+}
+
+// AdminUserDetail adds the profile, the extra roles and some activity to AdminUser
+type AdminUserDetail struct {
+	AdminUser
+	Img              string     `json:"img,omitempty"`
+	ImgURLs          *ImageURLs `json:"img_urls,omitempty"`
+	HasPassword      bool       `json:"has_password"` // false for accounts from a social login
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	ExtraRoles       []UserRole `json:"extra_roles"`
+	OrderCount       int        `json:"order_count"`
+	LastLoginAt      *time.Time `json:"last_login_at,omitempty"`
+}
+
+// AdminUserFilter are the query parameters of GET /admin/users
+type AdminUserFilter struct {
+	Query  string `form:"q"`
+	Role   string `form:"role"`   // admin or user
+	Status string `form:"status"` // active or suspended
+}
+
+type ChangeUserRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin user" example:"admin"`
+}
 
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=255" example:"Chargeback fraud"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// WatchlistItem is a bookmarked movie, Scheduled tells if tickets can already be bought
+type WatchlistItem struct {
+	MovieID     int        `json:"movie_id"`
+	Title       string     `json:"title"`
+	PosterImg   string     `json:"poster_img"`
+	PosterURLs  *ImageURLs `json:"poster_urls,omitempty"`
+	ReleaseDate *time.Time `json:"release_date,omitempty"`
+	Genres      []string   `json:"genres"`
+	Scheduled   bool       `json:"scheduled"`
+	AddedAt     time.Time  `json:"added_at"`
+}
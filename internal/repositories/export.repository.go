@@ -0,0 +1,231 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+)
+
+var (
+	// ErrExportInProgress is returned when the user already has an export that is being prepared
+	ErrExportInProgress = errors.New("a data export is already being prepared")
+	// ErrExportNotFound is returned for an unknown export or one that is not ready or expired
+	ErrExportNotFound = errors.New("data export not found")
+)
+
+// an export that fails this often is not retried anymore
+const maxExportAttempts = 3
+
+// a processing export that did not finish in this time (e.g. the instance stopped) is picked up again
+const staleExportAfter = 10 * time.Minute
+
+type ExportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewExportRepository(db *pgxpool.Pool) *ExportRepository {
+	return &ExportRepository{db: db}
+}
+
+// CreateExport queues a new export for the background job
+func (e *ExportRepository) CreateExport(ctx context.Context, userID string) (models.DataExport, error) {
+	query := `
+		INSERT INTO data_exports (user_id) VALUES ($1)
+		RETURNING id, user_id, status, created_at`
+
+	var export models.DataExport
+	if err := e.db.QueryRow(ctx, query, userID).Scan(&export.ID, &export.UserID, &export.Status, &export.CreatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation, data_exports_user_id_active_key
+			return models.DataExport{}, ErrExportInProgress
+		}
+		return models.DataExport{}, err
+	}
+	return export, nil
+}
+
+// LatestExport returns the most recent export of the user, an expired one is not returned
+func (e *ExportRepository) LatestExport(ctx context.Context, userID string) (models.DataExport, error) {
+	query := `
+		SELECT id, user_id, status, COALESCE(size_bytes, 0), created_at, completed_at, expires_at
+		FROM data_exports
+		WHERE user_id = $1 AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	var export models.DataExport
+	if err := e.db.QueryRow(ctx, query, userID).Scan(
+		&export.ID,
+		&export.UserID,
+		&export.Status,
+		&export.SizeBytes,
+		&export.CreatedAt,
+		&export.CompletedAt,
+		&export.ExpiresAt,
+	); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DataExport{}, ErrExportNotFound
+		}
+		return models.DataExport{}, err
+	}
+	return export, nil
+}
+
+// GetArchive returns the ZIP of a ready export of the user that has not expired
+func (e *ExportRepository) GetArchive(ctx context.Context, userID, exportID string) ([]byte, time.Time, error) {
+	query := `
+		SELECT archive, completed_at
+		FROM data_exports
+		WHERE id = $1 AND user_id = $2 AND status = 'ready' AND expires_at > CURRENT_TIMESTAMP`
+
+	var archive []byte
+	var completedAt time.Time
+	if err := e.db.QueryRow(ctx, query, exportID, userID).Scan(&archive, &completedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "22P02") { // not a uuid
+			return nil, time.Time{}, ErrExportNotFound
+		}
+		return nil, time.Time{}, err
+	}
+	return archive, completedAt, nil
+}
+
+// ClaimPending marks the oldest pending export as processing and returns it, ok is false when there is none.
+// SKIP LOCKED keeps two instances from building the same export.
+func (e *ExportRepository) ClaimPending(ctx context.Context) (export models.DataExport, ok bool, err error) {
+	query := `
+		UPDATE data_exports
+		SET status = 'processing', started_at = CURRENT_TIMESTAMP, attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM data_exports
+			WHERE status = 'pending' OR (status = 'processing' AND started_at < CURRENT_TIMESTAMP - make_interval(secs => $1))
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, status, created_at`
+
+	if err := e.db.QueryRow(ctx, query, staleExportAfter.Seconds()).Scan(&export.ID, &export.UserID, &export.Status, &export.CreatedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.DataExport{}, false, nil
+		}
+		return models.DataExport{}, false, err
+	}
+	return export, true, nil
+}
+
+// CompleteExport stores the ZIP and queues the "ready" notification in the same transaction
+func (e *ExportRepository) CompleteExport(ctx context.Context, export models.DataExport, archive []byte, ttl time.Duration, downloadURL string) (err error) {
+	tx, err := e.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	query := `
+		UPDATE data_exports
+		SET status = 'ready', archive = $2, size_bytes = $3, last_error = NULL,
+			completed_at = CURRENT_TIMESTAMP, expires_at = CURRENT_TIMESTAMP + make_interval(secs => $4)
+		WHERE id = $1
+		RETURNING expires_at`
+
+	var expiresAt time.Time
+	if err = tx.QueryRow(ctx, query, export.ID, archive, len(archive), ttl.Seconds()).Scan(&expiresAt); err != nil {
+		return err
+	}
+
+	query = `
+		INSERT INTO notifications (user_id, kind, dedupe_key, title, message, payload)
+		VALUES ($1, 'data_export_ready', 'data_export_ready:' || $2::text, 'Salinan data kamu sudah siap',
+			$3, jsonb_build_object('export_id', $2::text, 'download_url', $4::text, 'expires_at', $5::timestamptz))
+		ON CONFLICT (dedupe_key) DO NOTHING`
+	message := fmt.Sprintf("Salinan data akun Tickitz kamu bisa diunduh sampai %s.", expiresAt.Format("02 Jan 2006 15:04 MST"))
+	if _, err = tx.Exec(ctx, query, export.UserID, export.ID, message, downloadURL, expiresAt); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FailExport puts the export back to pending for the next run, after maxExportAttempts it is failed
+func (e *ExportRepository) FailExport(ctx context.Context, exportID string, cause error) error {
+	query := `
+		UPDATE data_exports
+		SET status = CASE WHEN attempts >= $3 THEN 'failed' ELSE 'pending' END, last_error = $2
+		WHERE id = $1`
+	_, err := e.db.Exec(ctx, query, exportID, cause.Error(), maxExportAttempts)
+	return err
+}
+
+// PurgeExpired deletes exports whose download link has expired, together with their ZIP
+func (e *ExportRepository) PurgeExpired(ctx context.Context) (int64, error) {
+	tag, err := e.db.Exec(ctx, `DELETE FROM data_exports WHERE expires_at < CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// ListReviews returns every review of the user, hidden ones included
+func (e *ExportRepository) ListReviews(ctx context.Context, userID string) ([]models.ExportedReview, error) {
+	query := `
+		SELECT r.id, r.movie_id, m.title, r.rating, r.body, r.hidden_at, r.created_at, r.updated_at
+		FROM reviews r
+		JOIN movies m ON m.id = r.movie_id
+		WHERE r.user_id = $1
+		ORDER BY r.created_at DESC, r.id DESC`
+
+	rows, err := e.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []models.ExportedReview{}
+	for rows.Next() {
+		var r models.ExportedReview
+		if err := rows.Scan(&r.ID, &r.MovieID, &r.Title, &r.Rating, &r.Body, &r.HiddenAt, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, r)
+	}
+	return reviews, rows.Err()
+}
+
+// ListLoginEvents returns the login history of the user, latest first
+func (e *ExportRepository) ListLoginEvents(ctx context.Context, userID string) ([]models.LoginEvent, error) {
+	query := `
+		SELECT method, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		FROM login_events
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	rows, err := e.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.LoginEvent{}
+	for rows.Next() {
+		var l models.LoginEvent
+		if err := rows.Scan(&l.Method, &l.IPAddress, &l.UserAgent, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, l)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,201 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrMediaMovieNotFound = errors.New("movie not found")
+	ErrMediaNotFound      = errors.New("media not found")
+	ErrMediaOrderMismatch = errors.New("ids must contain every media id of the movie exactly once")
+)
+
+type MediaRepository struct {
+	db    *pgxpool.Pool
+	cache *utils.TaggedCache
+}
+
+func NewMediaRepository(db *pgxpool.Pool, rdb *redis.Client) *MediaRepository {
+	return &MediaRepository{db: db, cache: utils.NewTaggedCache(rdb)}
+}
+
+// ListMovieMedia returns trailers and stills of a movie in display order
+func (r *MediaRepository) ListMovieMedia(ctx context.Context, movieID int) ([]models.MovieMedia, error) {
+	query := `
+		SELECT id, movie_id, kind, COALESCE(provider, ''), COALESCE(external_id, ''), COALESCE(file, ''), caption, "position"
+		FROM movie_media
+		WHERE movie_id = $1
+		ORDER BY "position", id`
+
+	rows, err := r.db.Query(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	media := []models.MovieMedia{}
+	for rows.Next() {
+		var m models.MovieMedia
+		if err := rows.Scan(&m.ID, &m.MovieID, &m.Kind, &m.Provider, &m.ExternalID, &m.Image, &m.Caption, &m.Position); err != nil {
+			return nil, err
+		}
+		media = append(media, withMediaURLs(m))
+	}
+	return media, rows.Err()
+}
+
+// AddTrailer appends a trailer at the end of the gallery
+func (r *MediaRepository) AddTrailer(ctx context.Context, movieID int, provider, videoID, caption string) (models.MovieMedia, error) {
+	return r.insertMedia(ctx, movieID, "trailer", &provider, &videoID, nil, caption)
+}
+
+// AddImage appends a still at the end of the gallery, file is the filename in public/stills
+func (r *MediaRepository) AddImage(ctx context.Context, movieID int, file, caption string) (models.MovieMedia, error) {
+	return r.insertMedia(ctx, movieID, "image", nil, nil, &file, caption)
+}
+
+func (r *MediaRepository) insertMedia(ctx context.Context, movieID int, kind string, provider, externalID, file *string, caption string) (media models.MovieMedia, err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return models.MovieMedia{}, err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	// lock the movie, two uploads at the same time would otherwise both get MAX(position) + 1
+	var id int
+	if err = tx.QueryRow(ctx, "SELECT id FROM movies WHERE id = $1 FOR UPDATE", movieID).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrMediaMovieNotFound
+		}
+		return models.MovieMedia{}, err
+	}
+
+	query := `
+		INSERT INTO movie_media (movie_id, kind, provider, external_id, file, caption, "position")
+		VALUES (
+			$1, $2, $3, $4, $5, $6,
+			(SELECT COALESCE(MAX("position") + 1, 0) FROM movie_media WHERE movie_id = $1)
+		)
+		RETURNING id, movie_id, kind, COALESCE(provider, ''), COALESCE(external_id, ''), COALESCE(file, ''), caption, "position"`
+
+	var m models.MovieMedia
+	if err = tx.QueryRow(ctx, query, movieID, kind, provider, externalID, file, caption).Scan(
+		&m.ID, &m.MovieID, &m.Kind, &m.Provider, &m.ExternalID, &m.Image, &m.Caption, &m.Position,
+	); err != nil {
+		return models.MovieMedia{}, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return models.MovieMedia{}, err
+	}
+	r.cache.Invalidate(ctx, utils.MovieTag(movieID))
+	return withMediaURLs(m), nil
+}
+
+// ReorderMedia sets the position of every media of the movie to its index in ids
+func (r *MediaRepository) ReorderMedia(ctx context.Context, movieID int, ids []int) (err error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	// lock the movie like insertMedia does, a concurrent upload would otherwise be left out of the new order
+	if _, err = tx.Exec(ctx, "SELECT id FROM movies WHERE id = $1 FOR UPDATE", movieID); err != nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, "SELECT id FROM movie_media WHERE movie_id = $1 FOR UPDATE", movieID)
+	if err != nil {
+		return err
+	}
+	current := []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		current = append(current, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	sortedIDs := slices.Clone(ids)
+	slices.Sort(sortedIDs)
+	slices.Sort(current)
+	if !slices.Equal(sortedIDs, current) {
+		err = ErrMediaOrderMismatch
+		return err
+	}
+
+	query := `
+		UPDATE movie_media m
+		SET "position" = o.ord - 1
+		FROM unnest($2::int4[]) WITH ORDINALITY AS o(id, ord)
+		WHERE m.id = o.id AND m.movie_id = $1`
+	if _, err = tx.Exec(ctx, query, movieID, ids); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+	r.cache.Invalidate(ctx, utils.MovieTag(movieID))
+	return nil
+}
+
+// DeleteMedia removes one media of the movie, the returned media has the filename of a still to clean up
+func (r *MediaRepository) DeleteMedia(ctx context.Context, movieID, mediaID int) (models.MovieMedia, error) {
+	query := `
+		DELETE FROM movie_media
+		WHERE id = $1 AND movie_id = $2
+		RETURNING id, movie_id, kind, COALESCE(provider, ''), COALESCE(external_id, ''), COALESCE(file, ''), caption, "position"`
+
+	var m models.MovieMedia
+	err := r.db.QueryRow(ctx, query, mediaID, movieID).Scan(
+		&m.ID, &m.MovieID, &m.Kind, &m.Provider, &m.ExternalID, &m.Image, &m.Caption, &m.Position,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.MovieMedia{}, ErrMediaNotFound
+		}
+		return models.MovieMedia{}, err
+	}
+	r.cache.Invalidate(ctx, utils.MovieTag(movieID))
+	return withMediaURLs(m), nil
+}
+
+// withMediaURLs fills the watch and embed url of a trailer
+func withMediaURLs(m models.MovieMedia) models.MovieMedia {
+	switch m.Provider {
+	case "youtube":
+		m.URL = "https://www.youtube.com/watch?v=" + m.ExternalID
+		m.EmbedURL = "https://www.youtube-nocookie.com/embed/" + m.ExternalID
+	case "vimeo":
+		m.URL = "https://vimeo.com/" + m.ExternalID
+		m.EmbedURL = "https://player.vimeo.com/video/" + m.ExternalID
+	}
+	return m
+}
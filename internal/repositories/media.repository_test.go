@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Uploads that arrive at the same time must not get the same position
+func TestInsertMediaConcurrentPositions(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	var movieID int
+	if err := db.QueryRow(ctx, `INSERT INTO movies (title) VALUES ($1) RETURNING id`,
+		fmt.Sprintf("Gallery %d", time.Now().UnixNano())).Scan(&movieID); err != nil {
+		t.Fatalf("insert movie: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM movie_media WHERE movie_id = $1`, movieID)
+		db.Exec(ctx, `DELETE FROM movies WHERE id = $1`, movieID)
+	})
+
+	repo := NewMediaRepository(db, unreachableRedis(t))
+
+	const uploads = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, uploads)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.AddImage(ctx, movieID, fmt.Sprintf("still_%d.jpg", i), "")
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("AddImage: %v", err)
+		}
+	}
+
+	media, err := repo.ListMovieMedia(ctx, movieID)
+	if err != nil {
+		t.Fatalf("ListMovieMedia: %v", err)
+	}
+	positions := []int{}
+	for _, m := range media {
+		positions = append(positions, m.Position)
+	}
+	want := []int{}
+	for i := 0; i < uploads; i++ {
+		want = append(want, i)
+	}
+	if !slices.Equal(positions, want) {
+		t.Errorf("positions = %v, want %v", positions, want)
+	}
+
+	var missing int
+	if err := db.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) + 1000 FROM movies`).Scan(&missing); err != nil {
+		t.Fatalf("max id: %v", err)
+	}
+	if _, err := repo.AddTrailer(ctx, missing, "youtube", "dQw4w9WgXcQ", ""); !errors.Is(err, ErrMediaMovieNotFound) {
+		t.Errorf("AddTrailer for a missing movie = %v, want ErrMediaMovieNotFound", err)
+	}
+}
@@ -2,9 +2,9 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -13,38 +13,61 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrMovieNotFound = errors.New("movie not found")
+	ErrMovieModified = errors.New("movie was changed by someone else, reload it and try again")
+
+	ErrMovieAlreadyArchived = errors.New("movie is already archived")
+	ErrMovieNotArchived     = errors.New("movie is not archived")
+	ErrMovieNotDraft        = errors.New("movie is not a draft")
+	ErrMovieHasBookings     = errors.New("movie has paid bookings for future schedules")
+)
+
+var (
+	movieListCachePolicy = utils.CachePolicy{TTL: 24 * time.Hour, StaleFor: time.Hour}
+	// unknown ids are cached shortly, creating the movie invalidates its tag anyway
+	movieDetailsCachePolicy = utils.CachePolicy{
+		TTL:         time.Hour,
+		StaleFor:    10 * time.Minute,
+		NotFound:    ErrMovieNotFound,
+		NotFoundTTL: time.Minute,
+	}
+)
+
 // Struct that holds shared dependency
 type MovieRepository struct {
-	db    *pgxpool.Pool
-	rdb   *redis.Client
-	cache *utils.CacheManager
+	db      *pgxpool.Pool
+	cache   *utils.TaggedCache
+	suggest *SuggestRepository
+	media   *MediaRepository
 }
 
 // Constructor function
 // Purpose: creates a repository instance in a valid state (db injected), returning a pointer to use its methods.
 func NewMovieRepository(db *pgxpool.Pool, rdb *redis.Client) *MovieRepository {
 	return &MovieRepository{
-		db:    db,
-		rdb:   rdb,
-		cache: utils.NewCacheManager(rdb),
+		db:      db,
+		cache:   utils.NewTaggedCache(rdb),
+		suggest: NewSuggestRepository(db, rdb),
+		media:   NewMediaRepository(db, rdb),
 	}
 }
 
 // List Upcoming Movies (not yet released current_date < release_dateI)
 func (m *MovieRepository) ListUpcomingMovies(ctx context.Context) ([]models.Movie, error) {
 	var movies []models.Movie
-	redisKey := "tickitz:upcoming"
-
 	// Use the cache utils with cache-aside pattern
-	err := m.cache.CacheOrFetch(
+	err := m.cache.Fetch(
 		ctx,
-		redisKey,
-		24*time.Hour,
+		"upcoming",
+		[]string{utils.TagMovieLists},
+		movieListCachePolicy,
 		&movies,
-		func() (interface{}, error) {
+		func(ctx context.Context) (interface{}, error) {
 			// This function will only be called on cache-miss
 			return m.fetchUpcomingMoviesFromDB(ctx)
 		},
@@ -65,14 +88,16 @@ func (m *MovieRepository) fetchUpcomingMoviesFromDB(ctx context.Context) ([]mode
 				g.name
 				ORDER BY
 					g.name
-			) AS genres
+			) AS genres,
+			m.rating_avg,
+			m.rating_count
 		FROM
 			movies m
 			JOIN movie_genres mg ON m.id = mg.movie_id
 			JOIN genres g ON mg.genre_id = g.id
 		WHERE
 			m.release_date > CURRENT_DATE
-			AND m.archived_at IS NULL
+			AND m.status = 'published'
 		GROUP BY
 			m.id,
 			m.title,
@@ -92,26 +117,30 @@ func (m *MovieRepository) fetchUpcomingMoviesFromDB(ctx context.Context) ([]mode
 	// Read rows/records
 	for rows.Next() {
 		var movie models.Movie
-		if err := rows.Scan(&movie.ID, &movie.Title, &movie.PosterImg, &movie.ReleaseDate, &movie.Genres); err != nil {
+		if err := rows.Scan(&movie.ID, &movie.Title, &movie.PosterImg, &movie.ReleaseDate, &movie.Genres, &movie.RatingAvg, &movie.RatingCount); err != nil {
 			return []models.Movie{}, err
 		}
+		setMovieImageURLs(&movie)
 		movies = append(movies, movie)
 	}
 
+	// a broken result must not end up in the cache as an empty list
+	if err := rows.Err(); err != nil {
+		return []models.Movie{}, err
+	}
 	return movies, nil
 }
 
 // List Popular Movies
 func (m *MovieRepository) ListPopularMovies(ctx context.Context) ([]models.Movie, error) {
 	var movies []models.Movie
-	rediskey := "tickitz:popular"
-
-	err := m.cache.CacheOrFetch(
+	err := m.cache.Fetch(
 		ctx,
-		rediskey,
-		24*time.Hour,
+		"popular",
+		[]string{utils.TagMovieLists, utils.TagPopularity},
+		movieListCachePolicy,
 		&movies,
-		func() (interface{}, error) {
+		func(ctx context.Context) (interface{}, error) {
 			return m.fetchPopularMovies(ctx)
 		},
 	)
@@ -148,7 +177,9 @@ func (m *MovieRepository) fetchPopularMovies(ctx context.Context) ([]models.Movi
 			g.name
 			ORDER BY
 				g.name
-		) AS genres
+		) AS genres,
+		m.rating_avg,
+		m.rating_count
 	FROM
 		movies m
 		JOIN movie_genres mg ON m.id = mg.movie_id
@@ -160,7 +191,7 @@ func (m *MovieRepository) fetchPopularMovies(ctx context.Context) ([]models.Movi
 			from
 				get_popular_movie_id
 		)
-		AND m.archived_at IS NULL
+		AND m.status = 'published'
 	GROUP BY
 		m.id,
 		m.title,
@@ -180,53 +211,193 @@ func (m *MovieRepository) fetchPopularMovies(ctx context.Context) ([]models.Movi
 	// Membaca rows/record
 	for rows.Next() {
 		var movie models.Movie
-		if err := rows.Scan(&movie.ID, &movie.Title, &movie.PosterImg, &movie.ReleaseDate, &movie.Genres); err != nil {
+		if err := rows.Scan(&movie.ID, &movie.Title, &movie.PosterImg, &movie.ReleaseDate, &movie.Genres, &movie.RatingAvg, &movie.RatingCount); err != nil {
 			log.Println("scan error, ", err.Error())
 			return []models.Movie{}, err
 		}
+		setMovieImageURLs(&movie)
 		movies = append(movies, movie)
 	}
 
+	if err := rows.Err(); err != nil {
+		return []models.Movie{}, err
+	}
 	return movies, nil
 }
 
-// Filter by keywords, genres, and pagination
+// Filter by keywords, genres, age rating, release date, duration, status, city, cinema and pagination
 func (m *MovieRepository) ListMovieFiltered(
 	ctx context.Context,
 	filter models.MovieFilter,
-) ([]models.Movie, error) {
+	params pagination.Params,
+) (pagination.Page[models.Movie], error) {
 
 	// Check if its the first page of all movies
-	if filter.Limit == 20 && filter.Offset == 0 && len(filter.Keywords) == 0 && len(filter.Genres) == 0 {
+	if params.Page == 1 && params.PerPage == 20 && params.Cursor == nil && isDefaultMovieFilter(filter) {
 
 		// If it's first page then try to get the cache
-		var movies []models.Movie
-		rediskey := "tickitz:movies-all-first-page"
-
-		m.cache.CacheOrFetch(
+		var page pagination.Page[models.Movie]
+		err := m.cache.Fetch(
 			ctx,
-			rediskey,
-			24*time.Hour,
-			&movies,
-			func() (interface{}, error) {
-				return m.fetchMovieFiltered(ctx, filter)
+			"movies-all-first-page",
+			[]string{utils.TagMovieLists},
+			movieListCachePolicy,
+			&page,
+			func(ctx context.Context) (interface{}, error) {
+				return m.fetchMovieFiltered(ctx, filter, params)
 			},
 		)
+		return page, err
+	}
 
-		return movies, nil
-	} else {
-		movies, err := m.fetchMovieFiltered(ctx, filter)
-		if err != nil {
-			return []models.Movie{}, err
-		}
-		return movies, nil
+	return m.fetchMovieFiltered(ctx, filter, params)
+}
+
+// isDefaultMovieFilter is true when no filter and no custom sort is set (cached first page)
+func isDefaultMovieFilter(f models.MovieFilter) bool {
+	return len(f.Keywords) == 0 && len(f.Genres) == 0 && len(f.AgeRatings) == 0 &&
+		f.ReleasedFrom == nil && f.ReleasedTo == nil &&
+		f.MinDuration == nil && f.MaxDuration == nil &&
+		f.Status == "" && f.CityID == nil && f.CinemaID == nil &&
+		(f.Sort == "" || f.Sort == "release_date") && (f.Order == "" || f.Order == "asc")
+}
+
+// movieSort is one whitelisted ordering, user input never ends up in the SQL text.
+// expr must not be NULL (release_date is coalesced) so it can be compared with the cursor.
+type movieSort struct {
+	asc, desc string // sort expression per direction
+	cast      string // type of the cursor value
+}
+
+var movieSortColumns = map[string]movieSort{
+	"title":        {asc: "m.title", desc: "m.title", cast: "text"},
+	"release_date": {asc: "COALESCE(m.release_date, 'infinity'::date)", desc: "COALESCE(m.release_date, '-infinity'::date)", cast: "date"},
+	"popularity":   {asc: "pop.popularity", desc: "pop.popularity", cast: "bigint"},
+	"rating":       {asc: "COALESCE(m.rating_avg, 99)", desc: "COALESCE(m.rating_avg, -1)", cast: "numeric"},
+}
+
+// movieFilterConds turns the filter into WHERE conditions, the values are appended to args
+func movieFilterConds(filter models.MovieFilter, args *[]any) []string {
+	// arg adds a parameter and returns its placeholder ($n)
+	arg := func(v any) string {
+		*args = append(*args, v)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+
+	conds := []string{"m.status = 'published'"}
+
+	if len(filter.Keywords) > 0 {
+		conds = append(conds, fmt.Sprintf(`
+			EXISTS (
+				SELECT 1
+				FROM unnest(%s::text[]) kw
+				WHERE m.title ILIKE '%%' || kw || '%%'
+			)`, arg(filter.Keywords)))
+	}
+	if len(filter.Genres) > 0 {
+		// EXISTS, so the genres column still lists every genre of the movie
+		conds = append(conds, fmt.Sprintf(`
+			EXISTS (
+				SELECT 1 FROM movie_genres fg
+				WHERE fg.movie_id = m.id AND fg.genre_id = ANY(%s::int[])
+			)`, arg(filter.Genres)))
+	}
+	if len(filter.AgeRatings) > 0 {
+		conds = append(conds, fmt.Sprintf("m.age_rating_id = ANY(%s::int[])", arg(filter.AgeRatings)))
+	}
+	if filter.ReleasedFrom != nil {
+		conds = append(conds, fmt.Sprintf("m.release_date >= %s", arg(*filter.ReleasedFrom)))
+	}
+	if filter.ReleasedTo != nil {
+		conds = append(conds, fmt.Sprintf("m.release_date <= %s", arg(*filter.ReleasedTo)))
+	}
+	if filter.MinDuration != nil {
+		conds = append(conds, fmt.Sprintf("m.duration_minutes >= %s", arg(*filter.MinDuration)))
+	}
+	if filter.MaxDuration != nil {
+		conds = append(conds, fmt.Sprintf("m.duration_minutes <= %s", arg(*filter.MaxDuration)))
+	}
+
+	switch filter.Status {
+	case "now_showing":
+		conds = append(conds, `
+			m.release_date <= CURRENT_DATE
+			AND EXISTS (SELECT 1 FROM schedules ns WHERE ns.movie_id = m.id AND ns.show_date >= CURRENT_DATE)`)
+	case "upcoming":
+		conds = append(conds, "m.release_date > CURRENT_DATE")
+	}
+
+	if filter.CityID != nil {
+		conds = append(conds, fmt.Sprintf(`
+			EXISTS (
+				SELECT 1 FROM schedules cs
+				WHERE cs.movie_id = m.id AND cs.city_id = %s AND cs.show_date >= CURRENT_DATE
+			)`, arg(*filter.CityID)))
+	}
+	if filter.CinemaID != nil {
+		conds = append(conds, fmt.Sprintf(`
+			EXISTS (
+				SELECT 1 FROM schedules cs
+				WHERE cs.movie_id = m.id AND cs.cinema_id = %s AND cs.show_date >= CURRENT_DATE
+			)`, arg(*filter.CinemaID)))
+	}
+
+	return conds
+}
+
+// movieOrder resolves sort and order of the filter, popularity and rating default to descending, everything else to ascending
+func movieOrder(filter models.MovieFilter) (name string, expr string, cast string, desc bool) {
+	name = filter.Sort
+	sort, ok := movieSortColumns[name]
+	if !ok {
+		name, sort = "release_date", movieSortColumns["release_date"]
+	}
+
+	desc = filter.Order == "desc" || (filter.Order == "" && (name == "popularity" || name == "rating"))
+	if desc {
+		return name, sort.desc, sort.cast, true
 	}
+	return name, sort.asc, sort.cast, false
 }
 
-func (m *MovieRepository) fetchMovieFiltered(ctx context.Context, filter models.MovieFilter) ([]models.Movie, error) {
+func (m *MovieRepository) fetchMovieFiltered(ctx context.Context, filter models.MovieFilter, params pagination.Params) (pagination.Page[models.Movie], error) {
+	args := []any{}
+	conds := movieFilterConds(filter, &args)
+
+	sortName, sortExpr, sortCast, desc := movieOrder(filter)
+	direction, compare := "ASC", ">"
+	if desc {
+		direction, compare = "DESC", "<"
+	}
+	sortKey := sortName + ":" + strings.ToLower(direction)
+
+	// the total is counted without the cursor condition
+	where := strings.Join(conds, " AND ")
+	var total int
+	if err := m.db.QueryRow(ctx, "SELECT COUNT(*) FROM movies m WHERE "+where, args...).Scan(&total); err != nil {
+		return pagination.Page[models.Movie]{}, err
+	}
+
+	from := `
+		FROM movies m
+		CROSS JOIN LATERAL (
+			SELECT COUNT(*) AS popularity
+			FROM transactions t
+			JOIN schedules s ON t.schedule_id = s.id
+			WHERE s.movie_id = m.id AND t.paid_at IS NOT NULL
+		) pop
+		WHERE ` + where
+
+	cursor, err := params.CursorFor(sortKey)
+	if err != nil {
+		return pagination.Page[models.Movie]{}, err
+	}
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		from += fmt.Sprintf(" AND (%s, m.id) %s ($%d::%s, $%d::int4)", sortExpr, compare, len(args)-1, sortCast, len(args))
+	}
 
-	// Base Query, this works even without any search query
-	baseQuery := `
+	query := fmt.Sprintf(`
 		SELECT
 			m.id,
 			m.title,
@@ -235,73 +406,38 @@ func (m *MovieRepository) fetchMovieFiltered(ctx context.Context, filter models.
 			m.backdrop_img,
 			m.duration_minutes,
 			m.release_date,
-			ARRAY_AGG(DISTINCT g.name ORDER BY g.name) AS genres
-		FROM movies m
-		JOIN movie_genres mg ON m.id = mg.movie_id
-		JOIN genres g ON mg.genre_id = g.id
-	`
-
-	conds := []string{}     // accumulates SQL condition snippets (default: exclude archived)
-	args := []interface{}{} // accumulates parameter values
-	argPos := 1             // track the next $n (start at 1)
-
-	// Use reflection to inspect struct fields
-	v := reflect.ValueOf(filter)
-	t := reflect.TypeOf(filter)
-
-	for i := 0; i < v.NumField(); i++ {
-		field := t.Field(i)
-		value := v.Field(i).Interface()
-
-		switch field.Tag.Get("db") {
-		case "keywords":
-			if kws, ok := value.([]string); ok && len(kws) > 0 {
-				conds = append(conds, fmt.Sprintf(`
-					EXISTS (
-						SELECT 1 
-						FROM unnest($%d::text[]) kw
-						WHERE m.title ILIKE '%%' || kw || '%%'
-					)
-				`, argPos))
-				args = append(args, kws)
-				argPos++
-			}
-
-		case "genres":
-			if gs, ok := value.([]int); ok && len(gs) > 0 {
-				conds = append(conds, fmt.Sprintf("g.id = ANY($%d::int[])", argPos))
-				args = append(args, gs)
-				argPos++
-			}
-
-			// offset & limit are handled later
-		}
-	}
-
-	if len(conds) > 0 {
-		baseQuery += " WHERE " + strings.Join(conds, " AND ")
-	}
-
-	// Final GROUP BY, ORDER, OFFSET/LIMIT
-	baseQuery += fmt.Sprintf(`
-		GROUP BY m.id, m.title, m.synopsis, m.poster_img, m.backdrop_img, m.duration_minutes, m.release_date
-		ORDER BY m.release_date ASC
+			COALESCE((
+				SELECT ARRAY_AGG(g.name ORDER BY g.name)
+				FROM movie_genres mg
+				JOIN genres g ON mg.genre_id = g.id
+				WHERE mg.movie_id = m.id
+			), '{}') AS genres,
+			m.rating_avg,
+			m.rating_count,
+			(%s)::text AS sort_key
+		%s
+		ORDER BY %s %s, m.id %s
 		OFFSET $%d LIMIT $%d
-	`, argPos, argPos+1)
+	`, sortExpr, from, sortExpr, direction, direction, len(args)+1, len(args)+2)
 
-	args = append(args, filter.Offset, filter.Limit)
+	args = append(args, params.Offset(), params.Limit())
 
 	// Run query
-	rows, err := m.db.Query(ctx, baseQuery, args...)
+	rows, err := m.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		if cursor != nil {
+			return pagination.Page[models.Movie]{}, cursorError(err)
+		}
+		return pagination.Page[models.Movie]{}, err
 	}
 	defer rows.Close()
 
 	// Scan results
 	var movies []models.Movie
+	var sortKeys []string
 	for rows.Next() {
 		var movie models.Movie
+		var key string
 		if err := rows.Scan(
 			&movie.ID,
 			&movie.Title,
@@ -311,12 +447,48 @@ func (m *MovieRepository) fetchMovieFiltered(ctx context.Context, filter models.
 			&movie.DurationMinutes,
 			&movie.ReleaseDate,
 			&movie.Genres,
+			&movie.RatingAvg,
+			&movie.RatingCount,
+			&key,
 		); err != nil {
-			return nil, err
+			return pagination.Page[models.Movie]{}, err
 		}
+		setMovieImageURLs(&movie)
 		movies = append(movies, movie)
+		sortKeys = append(sortKeys, key)
 	}
-	return movies, nil
+	if err := rows.Err(); err != nil {
+		if cursor != nil {
+			return pagination.Page[models.Movie]{}, cursorError(err)
+		}
+		return pagination.Page[models.Movie]{}, err
+	}
+
+	return pagination.NewPage(movies, params, total, func(i int) pagination.Cursor {
+		return pagination.Cursor{Sort: sortKey, Value: sortKeys[i], ID: strconv.Itoa(movies[i].ID)}
+	}), nil
+}
+
+// GetCachedMovieDetails is GetMovieDetails for the public page, cached until the movie, its media or
+// its people change. The admin reads GetMovieDetails, it compares versions against the database.
+func (m *MovieRepository) GetCachedMovieDetails(ctx context.Context, movieId string) (models.Movie, error) {
+	id, err := strconv.Atoi(movieId)
+	if err != nil {
+		return m.GetMovieDetails(ctx, movieId)
+	}
+
+	var movie models.Movie
+	err = m.cache.Fetch(
+		ctx,
+		"movie:"+strconv.Itoa(id)+":details",
+		[]string{utils.MovieTag(id), utils.TagPeople},
+		movieDetailsCachePolicy,
+		&movie,
+		func(ctx context.Context) (interface{}, error) {
+			return m.GetMovieDetails(ctx, movieId)
+		},
+	)
+	return movie, err
 }
 
 // Movie Detail
@@ -343,7 +515,14 @@ func (m *MovieRepository) GetMovieDetails(ctx context.Context, movieId string) (
 			distinct a.name
 			order by
 				a.name
-		) as cast
+		) as cast,
+		m.rating_avg,
+		m.rating_count,
+		m.status,
+		m.publish_at,
+		m.archive_at,
+		m.archived_at,
+		coalesce(m.updated_at, m.created_at) as updated_at
 	from
 		movies m
 		join movie_genres mg on m.id = mg.movie_id
@@ -377,7 +556,25 @@ func (m *MovieRepository) GetMovieDetails(ctx context.Context, movieId string) (
 		&movieDetails.Genres,
 		&movieDetails.Director,
 		&movieDetails.Cast,
+		&movieDetails.RatingAvg,
+		&movieDetails.RatingCount,
+		&movieDetails.Status,
+		&movieDetails.PublishAt,
+		&movieDetails.ArchiveAt,
+		&movieDetails.ArchivedAt,
+		&movieDetails.UpdatedAt,
 	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Movie{}, ErrMovieNotFound
+		}
+		return models.Movie{}, err
+	}
+
+	setMovieImageURLs(&movieDetails)
+
+	// Trailers & stills
+	movieDetails.Media, err = m.media.ListMovieMedia(ctx, movieDetails.ID)
 	if err != nil {
 		return models.Movie{}, err
 	}
@@ -385,79 +582,375 @@ func (m *MovieRepository) GetMovieDetails(ctx context.Context, movieId string) (
 	return movieDetails, nil
 }
 
-// (admin) Archive a movie (delete)
-func (m *MovieRepository) ArchiveMovieByID(ctx context.Context, movieId string) (models.ArchiveMovieRespond, error) {
+// futureSchedule matches schedules s of the movie that haven't started yet
+const futureSchedule = `
+	s.show_date + (SELECT st.start_at FROM show_times st WHERE st.id = s.show_time_id) > LOCALTIMESTAMP`
+
+// paidFutureBookings counts paid tickets of $1 for schedules that haven't started yet
+const paidFutureBookings = `
+	SELECT COUNT(*)
+	FROM transactions t
+	JOIN schedules s ON s.id = t.schedule_id
+	WHERE s.movie_id = $1 AND t.paid_at IS NOT NULL AND` + futureSchedule
+
+// (admin) Archive a movie (delete). Future schedules without bookings are removed, with paid future
+// bookings archiving is refused (ErrMovieHasBookings, PaidBookings is set) unless force is true.
+func (m *MovieRepository) ArchiveMovieByID(ctx context.Context, movieID int, force bool) (archivedMovie models.ArchiveMovieRespond, err error) {
+	tx, err := m.db.Begin(ctx)
+	if err != nil {
+		return models.ArchiveMovieRespond{}, err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	var status string
+	err = tx.QueryRow(ctx, `SELECT status FROM movies WHERE id = $1 FOR UPDATE`, movieID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrMovieNotFound
+		}
+		return models.ArchiveMovieRespond{}, err
+	}
+	if status == "archived" {
+		err = ErrMovieAlreadyArchived
+		return models.ArchiveMovieRespond{}, err
+	}
+
+	if err = tx.QueryRow(ctx, paidFutureBookings, movieID).Scan(&archivedMovie.PaidBookings); err != nil {
+		return models.ArchiveMovieRespond{}, err
+	}
+	if archivedMovie.PaidBookings > 0 && !force {
+		err = ErrMovieHasBookings
+		return archivedMovie, err
+	}
+
+	// schedules nobody booked yet are not needed anymore, booked ones still have to be played
+	removed, err := tx.Exec(ctx, `
+		DELETE FROM schedules s
+		WHERE s.movie_id = $1
+			AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.schedule_id = s.id)
+			AND`+futureSchedule, movieID)
+	if err != nil {
+		return models.ArchiveMovieRespond{}, err
+	}
+	archivedMovie.RemovedSchedules = removed.RowsAffected()
+
+	err = tx.QueryRow(ctx, `SELECT COUNT(*) FROM schedules s WHERE s.movie_id = $1 AND`+futureSchedule, movieID).Scan(&archivedMovie.KeptSchedules)
+	if err != nil {
+		return models.ArchiveMovieRespond{}, err
+	}
 
 	// Query
 	query := `
 	UPDATE movies
 	SET 
+		status = 'archived',
 		archived_at = CURRENT_TIMESTAMP,
+		publish_at = NULL,
+		archive_at = NULL,
 		updated_at = CURRENT_TIMESTAMP
 	WHERE id = $1 returning id, title, archived_at
 	`
 
-	var archivedMovie models.ArchiveMovieRespond
-	err := m.db.QueryRow(ctx, query, movieId).Scan(&archivedMovie.ID, &archivedMovie.Title, &archivedMovie.Archived_at)
+	err = tx.QueryRow(ctx, query, movieID).Scan(&archivedMovie.ID, &archivedMovie.Title, &archivedMovie.Archived_at)
 	if err != nil {
 		return models.ArchiveMovieRespond{}, err
 	}
 
-	keysToInvalidate := []string{
-		"tickitz:upcoming",
-		"tickitz:popular",
-		"tickitz:movies-all-first-page",
+	if err = tx.Commit(ctx); err != nil {
+		return models.ArchiveMovieRespond{}, err
 	}
-	for _, k := range keysToInvalidate {
-		if delErr := m.rdb.Del(ctx, k).Err(); delErr != nil {
-			log.Printf("failed to invalidate cache for key %s: %v", k, delErr)
+
+	m.invalidateMovieCaches(ctx, movieID)
+	return archivedMovie, nil
+}
+
+// (admin) UnarchiveMovie restores an archived movie as draft or published. Schedules removed by
+// archiving are not restored, they can be created again with the edit.
+func (m *MovieRepository) UnarchiveMovie(ctx context.Context, movieID int, status string) error {
+	return m.changeStatus(ctx, movieID, "archived", status, ErrMovieNotArchived)
+}
+
+// (admin) PublishMovie publishes a draft now, a scheduled publish_at is dropped
+func (m *MovieRepository) PublishMovie(ctx context.Context, movieID int) error {
+	return m.changeStatus(ctx, movieID, "draft", "published", ErrMovieNotDraft)
+}
+
+// changeStatus moves the movie from one status to another, wrongStatus is returned when it has another status
+func (m *MovieRepository) changeStatus(ctx context.Context, movieID int, from, to string, wrongStatus error) error {
+	query := `
+		WITH cur AS (
+			SELECT id, status FROM movies WHERE id = $1
+		), changed AS (
+			UPDATE movies m
+			SET
+				status = $3,
+				archived_at = NULL,
+				publish_at = NULL,
+				updated_at = CURRENT_TIMESTAMP
+			FROM cur c
+			WHERE m.id = c.id AND c.status = $2
+			RETURNING m.id
+		)
+		SELECT c.status, EXISTS (SELECT 1 FROM changed) FROM cur c`
+
+	var current string
+	var changed bool
+	if err := m.db.QueryRow(ctx, query, movieID, from, to).Scan(&current, &changed); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrMovieNotFound
 		}
+		return err
+	}
+	if !changed {
+		return wrongStatus
 	}
 
-	return archivedMovie, nil
+	m.invalidateMovieCaches(ctx, movieID)
+	return nil
+}
+
+// ApplyScheduledStatus publishes drafts whose publish_at has passed and archives movies whose
+// archive_at has passed. A movie with paid future bookings is not archived until those are played.
+func (m *MovieRepository) ApplyScheduledStatus(ctx context.Context) (published int64, archived int64, err error) {
+	// the ids of the changed movies are collected for the cache
+	var publishedIDs, archivedIDs []int
+	err = m.db.QueryRow(ctx, `
+		WITH due AS (
+			UPDATE movies
+			SET status = 'published', publish_at = NULL, updated_at = CURRENT_TIMESTAMP
+			WHERE status = 'draft' AND publish_at <= CURRENT_TIMESTAMP
+			RETURNING id
+		)
+		SELECT COALESCE(ARRAY_AGG(id), '{}') FROM due`).Scan(&publishedIDs)
+	if err != nil {
+		return 0, 0, err
+	}
+	published = int64(len(publishedIDs))
+
+	query := `
+		WITH due AS (
+			UPDATE movies m
+			SET
+				status = 'archived',
+				archived_at = CURRENT_TIMESTAMP,
+				archive_at = NULL,
+				updated_at = CURRENT_TIMESTAMP
+			WHERE m.status <> 'archived'
+				AND m.archive_at <= CURRENT_TIMESTAMP
+				AND NOT EXISTS (
+					SELECT 1
+					FROM transactions t
+					JOIN schedules s ON s.id = t.schedule_id
+					WHERE s.movie_id = m.id AND t.paid_at IS NOT NULL AND` + futureSchedule + `
+				)
+			RETURNING m.id
+		), removed AS (
+			DELETE FROM schedules s
+			USING due
+			WHERE s.movie_id = due.id
+				AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.schedule_id = s.id)
+				AND` + futureSchedule + `
+		)
+		SELECT COALESCE(ARRAY_AGG(id), '{}') FROM due`
+
+	if err = m.db.QueryRow(ctx, query).Scan(&archivedIDs); err != nil {
+		m.invalidateMovieCaches(ctx, publishedIDs...)
+		return published, 0, err
+	}
+	archived = int64(len(archivedIDs))
+
+	if published > 0 || archived > 0 {
+		m.invalidateMovieCaches(ctx, append(publishedIDs, archivedIDs...)...)
+	}
+	return published, archived, nil
+}
+
+// adminMovieSortColumns are the orderings of the admin list, like movieSortColumns.
+// The timestamps are coalesced because movies from before the audit columns have none.
+var adminMovieSortColumns = map[string]movieSort{
+	"updated_at":     {asc: "COALESCE(m.updated_at, '-infinity')", desc: "COALESCE(m.updated_at, '-infinity')", cast: "timestamptz"},
+	"created_at":     {asc: "COALESCE(m.created_at, '-infinity')", desc: "COALESCE(m.created_at, '-infinity')", cast: "timestamptz"},
+	"title":          movieSortColumns["title"],
+	"release_date":   movieSortColumns["release_date"],
+	"schedule_count": {asc: "agg.schedule_count", desc: "agg.schedule_count", cast: "bigint"},
+	"tickets_sold":   {asc: "agg.tickets_sold", desc: "agg.tickets_sold", cast: "bigint"},
+}
+
+// adminMovieOrder resolves sort and order, title and release date default to ascending, everything else
+// to descending. Without sort (or tickets_sold without WithSales) the newest changes come first.
+func adminMovieOrder(filter models.AdminMovieFilter) (name string, expr string, cast string, desc bool) {
+	name = filter.Sort
+	sort, ok := adminMovieSortColumns[name]
+	if !ok || (name == "tickets_sold" && !filter.WithSales) {
+		name, sort = "updated_at", adminMovieSortColumns["updated_at"]
+	}
+
+	desc = filter.Order == "desc" || (filter.Order == "" && name != "title" && name != "release_date")
+	if desc {
+		return name, sort.desc, sort.cast, true
+	}
+	return name, sort.asc, sort.cast, false
+}
+
+// adminMovieFilterConds turns the filter into WHERE conditions without the status, the values are appended to args
+func adminMovieFilterConds(filter models.AdminMovieFilter, args *[]any) []string {
+	arg := func(v any) string {
+		*args = append(*args, v)
+		return fmt.Sprintf("$%d", len(*args))
+	}
+
+	conds := []string{"TRUE"}
+	if filter.Query != "" {
+		conds = append(conds, fmt.Sprintf("m.title ILIKE '%%' || %s || '%%'", arg(filter.Query)))
+	}
+	if len(filter.Genres) > 0 {
+		conds = append(conds, fmt.Sprintf(`
+			EXISTS (
+				SELECT 1 FROM movie_genres fg
+				WHERE fg.movie_id = m.id AND fg.genre_id = ANY(%s::int[])
+			)`, arg(filter.Genres)))
+	}
+	if filter.ReleasedFrom != nil {
+		conds = append(conds, fmt.Sprintf("m.release_date >= %s", arg(*filter.ReleasedFrom)))
+	}
+	if filter.ReleasedTo != nil {
+		conds = append(conds, fmt.Sprintf("m.release_date <= %s", arg(*filter.ReleasedTo)))
+	}
+	return conds
 }
 
 // (admin)
-func (m *MovieRepository) ListAllMovies(ctx context.Context, offset int) ([]models.Movie, error) {
+// Search, filters and sorting of the dashboard table, every movie comes with its schedule count, and with the
+// tickets sold when filter.WithSales is set (revenue:read).
+// status is draft, published or archived, empty lists every movie that is not archived.
+// The status counts are computed with the other filters, total is the count of the selected status.
+func (m *MovieRepository) ListAllMovies(ctx context.Context, filter models.AdminMovieFilter, params pagination.Params) (models.AdminMoviePage, error) {
+	args := []any{}
+	where := strings.Join(adminMovieFilterConds(filter, &args), " AND ")
+
+	var counts models.MovieStatusCounts
+	countQuery := `
+		SELECT
+			COUNT(*) FILTER (WHERE m.status = 'draft'),
+			COUNT(*) FILTER (WHERE m.status = 'published'),
+			COUNT(*) FILTER (WHERE m.status = 'archived')
+		FROM movies m
+		WHERE ` + where
+	if err := m.db.QueryRow(ctx, countQuery, args...).Scan(&counts.Draft, &counts.Published, &counts.Archived); err != nil {
+		return models.AdminMoviePage{}, err
+	}
+
+	var total int
+	switch filter.Status {
+	case "draft":
+		total = counts.Draft
+	case "published":
+		total = counts.Published
+	case "archived":
+		total = counts.Archived
+	default:
+		total = counts.Draft + counts.Published
+	}
+
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		where += fmt.Sprintf(" AND m.status = $%d", len(args))
+	} else {
+		where += " AND m.status <> 'archived'"
+	}
+
+	sortName, sortExpr, sortCast, desc := adminMovieOrder(filter)
+	direction, compare := "ASC", ">"
+	if desc {
+		direction, compare = "DESC", "<"
+	}
+	sortKey := sortName + ":" + strings.ToLower(direction)
+
+	cursor, err := params.CursorFor(sortKey)
+	if err != nil {
+		return models.AdminMoviePage{}, err
+	}
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		where += fmt.Sprintf(" AND (%s, m.id) %s ($%d::%s, $%d::int4)", sortExpr, compare, len(args)-1, sortCast, len(args))
+	}
+
+	// without WithSales the transactions are not even read
+	aggregates := `
+		SELECT
+			COUNT(*) AS schedule_count,
+			NULL::bigint AS tickets_sold
+		FROM schedules s
+		WHERE s.movie_id = m.id`
+	if filter.WithSales {
+		aggregates = `
+		SELECT
+			COUNT(DISTINCT s.id) AS schedule_count,
+			COUNT(ts.seats_id) AS tickets_sold
+		FROM schedules s
+		LEFT JOIN transactions t ON t.schedule_id = s.id AND t.paid_at IS NOT NULL
+		LEFT JOIN transactions_seats ts ON ts.transactions_id = t.id
+		WHERE s.movie_id = m.id`
+	}
+
 	// Query for getting movies list (admin)
-	query := `
+	query := fmt.Sprintf(`
 	SELECT
 		m.id,
 		m.title,
 		m.poster_img,
 		m.release_date,
-		ARRAY_AGG(DISTINCT g.name ORDER BY g.name) AS genres,
+		COALESCE((
+			SELECT ARRAY_AGG(g.name ORDER BY g.name)
+			FROM movie_genres mg
+			JOIN genres g ON mg.genre_id = g.id
+			WHERE mg.movie_id = m.id
+		), '{}') AS genres,
 		m.duration_minutes,
 		m.created_at,
-		m.updated_at
+		m.updated_at,
+		m.rating_avg,
+		m.rating_count,
+		m.status,
+		m.publish_at,
+		m.archive_at,
+		m.archived_at,
+		agg.schedule_count,
+		agg.tickets_sold,
+		(%s)::text AS sort_key
 	FROM
 		movies m
-		JOIN movie_genres mg ON m.id = mg.movie_id
-		JOIN genres g ON mg.genre_id = g.id
+	CROSS JOIN LATERAL (%s
+	) agg
 	WHERE
-		m.archived_at IS NULL
-	GROUP BY
-		m.id,
-		m.title,
-		m.poster_img,
-		m.release_date,
-		m.duration_minutes,
-		m.created_at,
-		m.updated_at
-	ORDER BY m.updated_at DESC
-	LIMIT 10 OFFSET $1;`
-	rows, err := m.db.Query(ctx, query, offset)
+		%s
+	ORDER BY %s %s, m.id %s
+	OFFSET $%d LIMIT $%d;`, sortExpr, aggregates, where, sortExpr, direction, direction, len(args)+1, len(args)+2)
+	args = append(args, params.Offset(), params.Limit())
+
+	rows, err := m.db.Query(ctx, query, args...)
 	if err != nil {
 		log.Println("internal server error : ", err.Error())
-		return []models.Movie{}, err
+		if cursor != nil {
+			return models.AdminMoviePage{}, cursorError(err)
+		}
+		return models.AdminMoviePage{}, err
 	}
 	defer rows.Close()
 
-	var movies []models.Movie
+	var movies []models.AdminMovie
+	var sortKeys []string
 
 	// Read rows/records
 	for rows.Next() {
-		var movie models.Movie
+		var movie models.AdminMovie
+		var key string
 		if err := rows.Scan(
 			&movie.ID,
 			&movie.Title,
@@ -467,22 +960,45 @@ func (m *MovieRepository) ListAllMovies(ctx context.Context, offset int) ([]mode
 			&movie.DurationMinutes,
 			&movie.CreatedAt,
 			&movie.UpdatedAt,
+			&movie.RatingAvg,
+			&movie.RatingCount,
+			&movie.Status,
+			&movie.PublishAt,
+			&movie.ArchiveAt,
+			&movie.ArchivedAt,
+			&movie.ScheduleCount,
+			&movie.TicketsSold,
+			&key,
 		); err != nil {
 			log.Println("scan error, ", err.Error())
-			return []models.Movie{}, err
+			return models.AdminMoviePage{}, err
 		}
+		setMovieImageURLs(&movie.Movie)
 		movies = append(movies, movie)
+		sortKeys = append(sortKeys, key)
+	}
+	if err := rows.Err(); err != nil {
+		if cursor != nil {
+			return models.AdminMoviePage{}, cursorError(err)
+		}
+		return models.AdminMoviePage{}, err
 	}
 
-	return movies, nil
+	return models.AdminMoviePage{
+		Page: pagination.NewPage(movies, params, total, func(i int) pagination.Cursor {
+			return pagination.Cursor{Sort: sortKey, Value: sortKeys[i], ID: strconv.Itoa(movies[i].ID)}
+		}),
+		StatusCounts: counts,
+	}, nil
 }
 
-// (admin)
-func (m *MovieRepository) CreateMovie(ctx context.Context, movie models.CreateMovie, locationPoster string, locationBackdrop string) (models.CreateMovie, error) {
+// (admin) CreateMovie inserts the movie with its genres, cast and optional schedules.
+// Ids that don't exist are returned as models.FieldErrors.
+func (m *MovieRepository) CreateMovie(ctx context.Context, movie models.MovieInput, locationPoster string, locationBackdrop string) (newMovieID int, err error) {
 	// Begin transaction
 	tx, err := m.db.Begin(ctx)
 	if err != nil {
-		return models.CreateMovie{}, err
+		return 0, err
 	}
 	defer func() {
 		if err != nil {
@@ -492,128 +1008,130 @@ func (m *MovieRepository) CreateMovie(ctx context.Context, movie models.CreateMo
 		}
 	}()
 
-	// Step 1: Insert Genres and get their IDs
-	var insertedGenreIDs []int
-	genreList := strings.Split(movie.Genres, ",")
-	if len(movie.Genres) > 0 {
-		insertedGenreIDs, err = m.insertGenres(ctx, tx, genreList)
-		if err != nil {
-			log.Println("error inserting genres:", err)
-			return models.CreateMovie{}, err
-		}
+	// Step 1: Every referenced id must exist
+	if err = m.checkMovieReferences(ctx, tx, inputReferences(movie)); err != nil {
+		return 0, err
 	}
-	log.Println("Inserted genre IDs : ", insertedGenreIDs)
 
 	// Step 2: Actors and Director into People
 	var insertedCastIDs []int
-	castList := strings.Split(movie.Cast, ",")
-	log.Println("cast : ", movie.Cast)
-	if len(movie.Cast) > 0 {
-		insertedCastIDs, err = m.insertPeople(ctx, tx, castList)
-		if err != nil {
-			log.Println("error inserting cast:", err)
-			return models.CreateMovie{}, err
-		}
+	insertedCastIDs, err = m.insertPeople(ctx, tx, movie.Cast)
+	if err != nil {
+		log.Println("error inserting cast:", err)
+		return 0, err
 	}
-	log.Println("Inserted cast IDs : ", insertedCastIDs)
 
 	var insertedDirectorID int
-	if len(movie.Director) > 0 {
-		insertedDirectorID, err = m.insertDirector(ctx, tx, movie.Director)
-		if err != nil {
-			log.Println("error inserting director:", err)
-			return models.CreateMovie{}, err
-		}
+	insertedDirectorID, err = m.insertDirector(ctx, tx, movie.Director)
+	if err != nil {
+		log.Println("error inserting director:", err)
+		return 0, err
 	}
-	log.Println("Inserted director ID : ", insertedDirectorID)
 
 	// Step 3: Create a new movie
-	var newMovieID int
 	newMovieID, err = m.insertMovie(ctx, tx, movie, insertedDirectorID, locationPoster, locationBackdrop)
-
-	log.Println("new movie ID : ", newMovieID)
+	if err != nil {
+		return 0, err
+	}
 
 	// Step 4: Insert Movie_Genres
-	if len(insertedGenreIDs) > 0 {
-		err = m.insertMovieGenres(ctx, tx, newMovieID, insertedGenreIDs)
-		if err != nil {
-			log.Println("error inserting movie:", err)
-			return models.CreateMovie{}, err
-		}
+	if err = m.insertMovieGenres(ctx, tx, newMovieID, movie.GenreIDs); err != nil {
+		return 0, err
 	}
 
 	// Step 5: Insert Movie_Actors
-	if len(insertedCastIDs) > 0 {
-		err = m.insertMovieActors(ctx, tx, newMovieID, insertedCastIDs)
-		if err != nil {
-			return models.CreateMovie{}, err
-		}
+	if err = m.insertMovieActors(ctx, tx, newMovieID, insertedCastIDs); err != nil {
+		return 0, err
 	}
 
-	// Step 6: Create schedules for 1 week from movie.ShowDate
-	cinemaList := strings.Split(movie.CinemaID, ",")
-	showTimeList := strings.Split(movie.ShowTimeID, ",")
-	cityList := strings.Split(movie.CityID, ",")
-
-	log.Println("Creating schedules...")
-	log.Println("cinemaList : ", cinemaList)
-	log.Println("show_date : ", movie.ShowDate)
-	log.Println("show_time_ids : ", showTimeList)
-
-	err = m.createSchedules(ctx, tx, newMovieID, movie.ShowDate, cinemaList, showTimeList, cityList)
-	if err != nil {
-		log.Println("error creating schedules:", err)
-		return models.CreateMovie{}, err
+	// Step 6: Create schedules for 1 week from schedule.show_date
+	if movie.Schedule != nil {
+		if err = m.createSchedules(ctx, tx, newMovieID, *movie.Schedule); err != nil {
+			log.Println("error creating schedules:", err)
+			return 0, err
+		}
 	}
 
 	// Commit transaction if everything succeeds
 	if err = tx.Commit(ctx); err != nil {
-		return models.CreateMovie{}, err
+		return 0, err
 	}
 
-	keysToInvalidate := []string{
-		"tickitz:upcoming",
-		"tickitz:popular",
-		"tickitz:movies-all-first-page",
-	}
-	for _, k := range keysToInvalidate {
-		if delErr := m.rdb.Del(ctx, k).Err(); delErr != nil {
-			log.Printf("failed to invalidate cache for key %s: %v", k, delErr)
-		}
-	}
+	m.invalidateMovieCaches(ctx, newMovieID)
+	return newMovieID, nil
+}
 
-	return models.CreateMovie{ID: newMovieID, Title: movie.Title, ReleaseDate: movie.ReleaseDate}, nil
+// movieReference is an id field of the movie payload and the table it points to
+type movieReference struct {
+	field string
+	table string
+	ids   []int
+	list  bool
 }
 
-func (m *MovieRepository) insertGenres(ctx context.Context, tx pgx.Tx, genres []string) ([]int, error) {
-	if len(genres) == 0 {
-		return []int{}, nil
+func inputReferences(movie models.MovieInput) []movieReference {
+	refs := []movieReference{
+		{field: "age_rating_id", table: "age_ratings", ids: []int{movie.AgeRatingID}},
+		{field: "genre_ids", table: "genres", ids: movie.GenreIDs, list: true},
 	}
+	return append(refs, scheduleReferences(movie.Schedule)...)
+}
 
-	var insertedGenreIDs []int
+func patchReferences(patch models.MoviePatch) []movieReference {
+	var refs []movieReference
+	if patch.AgeRatingID != nil {
+		refs = append(refs, movieReference{field: "age_rating_id", table: "age_ratings", ids: []int{*patch.AgeRatingID}})
+	}
+	if patch.GenreIDs != nil {
+		refs = append(refs, movieReference{field: "genre_ids", table: "genres", ids: *patch.GenreIDs, list: true})
+	}
+	return append(refs, scheduleReferences(patch.Schedule)...)
+}
 
-	for _, g := range genres {
-		query := `
-		WITH ins AS (
-			INSERT INTO genres (name)
-			VALUES ($1)
-			ON CONFLICT (name) DO NOTHING
-			RETURNING id
-		)
-		SELECT id FROM ins
-		UNION
-		SELECT id FROM genres WHERE name = $1;
-		`
+func scheduleReferences(s *models.ScheduleInput) []movieReference {
+	if s == nil {
+		return nil
+	}
+	return []movieReference{
+		{field: "schedule.city_ids", table: "cities", ids: s.CityIDs, list: true},
+		{field: "schedule.cinema_ids", table: "cinemas", ids: s.CinemaIDs, list: true},
+		{field: "schedule.show_time_ids", table: "show_times", ids: s.ShowTimeIDs, list: true},
+	}
+}
 
-		var id int
-		err := tx.QueryRow(ctx, query, g).Scan(&id)
+// checkMovieReferences returns models.FieldErrors with every id of refs that doesn't exist
+func (m *MovieRepository) checkMovieReferences(ctx context.Context, tx pgx.Tx, refs []movieReference) error {
+	var fieldErrs models.FieldErrors
+	for _, ref := range refs {
+		// table comes from the list above, never from the request
+		query := fmt.Sprintf(`
+			SELECT o.ord
+			FROM unnest($1::int4[]) WITH ORDINALITY AS o(id, ord)
+			WHERE NOT EXISTS (SELECT 1 FROM %s t WHERE t.id = o.id)
+			ORDER BY o.ord`, ref.table)
+
+		rows, err := tx.Query(ctx, query, ref.ids)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		missing, err := pgx.CollectRows(rows, pgx.RowTo[int])
+		if err != nil {
+			return err
+		}
+
+		for _, ord := range missing {
+			field := ref.field
+			if ref.list {
+				field = fmt.Sprintf("%s[%d]", ref.field, ord-1)
+			}
+			fieldErrs = append(fieldErrs, models.FieldError{Field: field, Message: "does not exist"})
 		}
-		insertedGenreIDs = append(insertedGenreIDs, id)
 	}
 
-	return insertedGenreIDs, nil
+	if len(fieldErrs) > 0 {
+		return fieldErrs
+	}
+	return nil
 }
 
 func (m *MovieRepository) insertPeople(ctx context.Context, tx pgx.Tx, people []string) ([]int, error) {
@@ -624,20 +1142,7 @@ func (m *MovieRepository) insertPeople(ctx context.Context, tx pgx.Tx, people []
 	var insertedPeopleIDs []int
 
 	for _, g := range people {
-		query := `
-		WITH ins AS (
-			INSERT INTO people (name)
-			VALUES ($1)
-			ON CONFLICT (name) DO NOTHING
-			RETURNING id
-		)
-		SELECT id FROM ins
-		UNION
-		SELECT id FROM people WHERE name = $1;
-		`
-
-		var id int
-		err := tx.QueryRow(ctx, query, g).Scan(&id)
+		id, err := findOrCreatePerson(ctx, tx, g)
 		if err != nil {
 			return nil, err
 		}
@@ -648,29 +1153,42 @@ func (m *MovieRepository) insertPeople(ctx context.Context, tx pgx.Tx, people []
 }
 
 func (m *MovieRepository) insertDirector(ctx context.Context, tx pgx.Tx, director string) (int, error) {
-	var insertedDirectorID int
+	return findOrCreatePerson(ctx, tx, director)
+}
+
+// findOrCreatePerson matches the name case-insensitively, also against the aliases of merged people,
+// so "christopher  nolan" in the free-text Cast field doesn't create a second Christopher Nolan
+func findOrCreatePerson(ctx context.Context, tx pgx.Tx, name string) (int, error) {
+	name = strings.Join(strings.Fields(name), " ")
 
 	query := `
-		WITH ins AS (
+		WITH existing AS (
+			(SELECT id FROM people WHERE lower(name) = lower($1) ORDER BY id LIMIT 1)
+			UNION ALL
+			(SELECT person_id FROM people_aliases WHERE lower(alias) = lower($1) LIMIT 1)
+		), ins AS (
 			INSERT INTO people (name)
-			VALUES ($1)
+			SELECT $1
+			WHERE NOT EXISTS (SELECT 1 FROM existing)
 			ON CONFLICT (name) DO NOTHING
 			RETURNING id
 		)
+		SELECT id FROM existing
+		UNION ALL
 		SELECT id FROM ins
-		UNION
-		SELECT id FROM people WHERE name = $1;
+		UNION ALL
+		SELECT id FROM people WHERE name = $1
+		LIMIT 1;
 		`
 
-	err := tx.QueryRow(ctx, query, director).Scan(&insertedDirectorID)
-	if err != nil {
+	var id int
+	if err := tx.QueryRow(ctx, query, name).Scan(&id); err != nil {
 		return 0, err
 	}
-
-	return insertedDirectorID, nil
+	return id, nil
 }
 
-func (m *MovieRepository) insertMovie(ctx context.Context, tx pgx.Tx, body models.CreateMovie, directorID int, locationPoster string, locationBackdrop string) (int, error) {
+func (m *MovieRepository) insertMovie(ctx context.Context, tx pgx.Tx, body models.MovieInput, directorID int, locationPoster string, locationBackdrop string) (int, error) {
 	var insertedMovieID int
 
 	query := `
@@ -683,20 +1201,35 @@ func (m *MovieRepository) insertMovie(ctx context.Context, tx pgx.Tx, body model
 				release_date,
 				duration_minutes,
 				director_id,
-				synopsis
+				synopsis,
+				status,
+				publish_at,
+				archive_at
 			)
 		values
-			($1, $2, $3, $4, $5, $6, $7, $8) returning id`
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::timestamptz, NULLIF($11, '')::timestamptz) returning id`
+
+	// a movie with a publish date waits as draft until the job publishes it
+	status := body.Status
+	if status == "" {
+		status = "published"
+		if body.PublishAt != "" {
+			status = "draft"
+		}
+	}
 
 	err := tx.QueryRow(ctx, query,
 		locationPoster,
 		locationBackdrop,
-		body.Title,
-		body.AgeRating,
+		strings.TrimSpace(body.Title),
+		body.AgeRatingID,
 		body.ReleaseDate,
 		body.DurationMinutes,
 		directorID,
-		body.Synopsis).Scan(&insertedMovieID)
+		body.Synopsis,
+		status,
+		body.PublishAt,
+		body.ArchiveAt).Scan(&insertedMovieID)
 	if err != nil {
 		log.Printf("insertMovie failed: %v", err)
 		return 0, err
@@ -744,99 +1277,35 @@ func (m *MovieRepository) insertMovieActors(ctx context.Context, tx pgx.Tx, movi
 	return nil
 }
 
-// createSchedules creates schedules for a movie for 1 week starting from showDate
+// createSchedules creates schedules for a movie for 1 week starting from schedule.ShowDate
 // for each combination of cinema, city, and show time
-func (m *MovieRepository) createSchedules(ctx context.Context, tx pgx.Tx, movieID int, showDate string, cinemaIDs []string, showTimeIDs []string, cityIDs []string) error {
-	if len(cinemaIDs) == 0 || len(showTimeIDs) == 0 || len(cityIDs) == 0 {
-		log.Println("No cinemas, show times, or cities provided, skipping schedule creation")
-		return nil
-	}
-
-	// Parse the show date
-	startDate, err := time.Parse("2006-01-02", showDate)
+func (m *MovieRepository) createSchedules(ctx context.Context, tx pgx.Tx, movieID int, schedule models.ScheduleInput) error {
+	query := `
+		INSERT INTO schedules (movie_id, city_id, show_time_id, cinema_id, show_date)
+		SELECT $1, city.id, show_time.id, cinema.id, day::date
+		FROM generate_series($2::date, $2::date + 6, interval '1 day') AS day
+		CROSS JOIN unnest($3::int4[]) AS city(id)
+		CROSS JOIN unnest($4::int4[]) AS cinema(id)
+		CROSS JOIN unnest($5::int4[]) AS show_time(id)`
+
+	tag, err := tx.Exec(ctx, query, movieID, schedule.ShowDate, schedule.CityIDs, schedule.CinemaIDs, schedule.ShowTimeIDs)
 	if err != nil {
-		return fmt.Errorf("invalid show date format: %w", err)
-	}
-
-	// Convert cinema IDs from strings to integers
-	var cinemaIntIDs []int
-	for _, cidStr := range cinemaIDs {
-		if cid, err := strconv.Atoi(cidStr); err == nil {
-			cinemaIntIDs = append(cinemaIntIDs, cid)
-		} else {
-			log.Printf("Invalid cinema ID: %s, skipping", cidStr)
-		}
-	}
-
-	// Convert show time IDs from strings to integers
-	var showTimeIntIDs []int
-	for _, stidStr := range showTimeIDs {
-		if stid, err := strconv.Atoi(stidStr); err == nil {
-			showTimeIntIDs = append(showTimeIntIDs, stid)
-		} else {
-			log.Printf("Invalid show time ID: %s, skipping", stidStr)
-		}
-	}
-
-	// Convert city IDs from strings to integers
-	var cityIntIDs []int
-	for _, cityStr := range cityIDs {
-		if cid, err := strconv.Atoi(cityStr); err == nil {
-			cityIntIDs = append(cityIntIDs, cid)
-		} else {
-			log.Printf("Invalid city ID: %s, skipping", cityStr)
-		}
-	}
-
-	if len(cinemaIntIDs) == 0 || len(showTimeIntIDs) == 0 || len(cityIntIDs) == 0 {
-		return fmt.Errorf("no valid cinema IDs, show time IDs, or city IDs after parsing")
-	}
-
-	// Create schedules for 7 days (1 week)
-	schedulesCreated := 0
-	for day := 0; day < 7; day++ {
-		currentDate := startDate.AddDate(0, 0, day)
-
-		// For each city
-		for _, cityID := range cityIntIDs {
-			// For each cinema
-			for _, cinemaID := range cinemaIntIDs {
-				// For each show time
-				for _, showTimeID := range showTimeIntIDs {
-					scheduleQuery := `
-						INSERT INTO schedules (movie_id, city_id, show_time_id, cinema_id, show_date)
-						VALUES ($1, $2, $3, $4, $5)
-					`
-
-					_, err := tx.Exec(ctx, scheduleQuery,
-						movieID,
-						cityID,
-						showTimeID,
-						cinemaID,
-						currentDate.Format("2006-01-02"))
-
-					if err != nil {
-						return fmt.Errorf("failed to insert schedule for movie %d, city %d, cinema %d, showtime %d, date %s: %w",
-							movieID, cityID, cinemaID, showTimeID, currentDate.Format("2006-01-02"), err)
-					}
-
-					schedulesCreated++
-					log.Printf("Created schedule: movie=%d, city=%d, cinema=%d, showtime=%d, date=%s",
-						movieID, cityID, cinemaID, showTimeID, currentDate.Format("2006-01-02"))
-				}
-			}
-		}
+		return fmt.Errorf("failed to insert schedules for movie %d: %w", movieID, err)
 	}
 
-	log.Printf("Successfully created %d schedules for movie %d", schedulesCreated, movieID)
+	log.Printf("Successfully created %d schedules for movie %d", tag.RowsAffected(), movieID)
 	return nil
 }
 
-// (admin) EditMovie updates a movie and recreates its schedules for 7 days
-func (m *MovieRepository) EditMovie(ctx context.Context, movieID int, movie models.CreateMovie, locationPoster string, locationBackdrop string) (models.CreateMovie, error) {
+// (admin) EditMovie applies a partial update, only the fields of the patch that are set change.
+// Genres and cast are diffed against the current lists and schedules are only touched when
+// patch.Schedule is set. version is the updated_at the client has seen (from its ETag), a
+// different updated_at means another admin saved in between and gives ErrMovieModified.
+// It returns the new updated_at and the poster / backdrop that were replaced by the new ones.
+func (m *MovieRepository) EditMovie(ctx context.Context, movieID int, patch models.MoviePatch, locationPoster string, locationBackdrop string, version *time.Time) (updatedAt time.Time, oldPoster string, oldBackdrop string, err error) {
 	tx, err := m.db.Begin(ctx)
 	if err != nil {
-		return models.CreateMovie{}, err
+		return time.Time{}, "", "", err
 	}
 	defer func() {
 		if err != nil {
@@ -846,123 +1315,289 @@ func (m *MovieRepository) EditMovie(ctx context.Context, movieID int, movie mode
 		}
 	}()
 
-	// Step 1: Update movie info
+	// Step 1: Lock the movie and compare the version
+	var current time.Time
+	var currentPoster, currentBackdrop string
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(updated_at, created_at), COALESCE(poster_img, ''), COALESCE(backdrop_img, '')
+		FROM movies WHERE id = $1 FOR UPDATE`, movieID).Scan(&current, &currentPoster, &currentBackdrop)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrMovieNotFound
+		}
+		return time.Time{}, "", "", err
+	}
+	if version != nil && !current.Equal(*version) {
+		err = ErrMovieModified
+		return time.Time{}, "", "", err
+	}
+
+	// Step 2: Every referenced id must exist
+	if err = m.checkMovieReferences(ctx, tx, patchReferences(patch)); err != nil {
+		return time.Time{}, "", "", err
+	}
+
+	// Step 3: Director
+	var directorID *int
+	if patch.Director != nil {
+		var id int
+		id, err = m.insertDirector(ctx, tx, *patch.Director)
+		if err != nil {
+			return time.Time{}, "", "", err
+		}
+		directorID = &id
+	}
+
+	// Step 4: Update movie info, NULL keeps the current value
+	var title *string
+	if patch.Title != nil {
+		trimmed := strings.TrimSpace(*patch.Title)
+		title = &trimmed
+	}
+
 	updateQuery := `
 		UPDATE movies
 		SET
-			title = $1,
-			age_rating_id = $2,
-			release_date = $3,
-			duration_minutes = $4,
-			synopsis = $5,
-			poster_img = COALESCE(NULLIF($6, ''), poster_img),
-			backdrop_img = COALESCE(NULLIF($7, ''), backdrop_img),
+			title = COALESCE($1, title),
+			synopsis = COALESCE($2, synopsis),
+			release_date = COALESCE($3::date, release_date),
+			duration_minutes = COALESCE($4, duration_minutes),
+			age_rating_id = COALESCE($5, age_rating_id),
+			director_id = COALESCE($6, director_id),
+			poster_img = COALESCE(NULLIF($7, ''), poster_img),
+			backdrop_img = COALESCE(NULLIF($8, ''), backdrop_img),
+			publish_at = CASE WHEN $10::boolean THEN NULLIF($11, '')::timestamptz ELSE publish_at END,
+			archive_at = CASE WHEN $12::boolean THEN NULLIF($13, '')::timestamptz ELSE archive_at END,
 			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $8
-		RETURNING id;
+		WHERE id = $9
+		RETURNING updated_at;
 	`
 
-	var updatedMovieID int
 	err = tx.QueryRow(ctx, updateQuery,
-		movie.Title,
-		movie.AgeRating,
-		movie.ReleaseDate,
-		movie.DurationMinutes,
-		movie.Synopsis,
+		title,
+		patch.Synopsis,
+		patch.ReleaseDate,
+		patch.DurationMinutes,
+		patch.AgeRatingID,
+		directorID,
 		locationPoster,
 		locationBackdrop,
 		movieID,
-	).Scan(&updatedMovieID)
+		patch.PublishAt != nil,
+		patch.PublishAt,
+		patch.ArchiveAt != nil,
+		patch.ArchiveAt,
+	).Scan(&updatedAt)
 	if err != nil {
 		log.Printf("update movie failed: %v", err)
-		return models.CreateMovie{}, err
+		return time.Time{}, "", "", err
 	}
 
-	// Step 2: Update director
-	if len(movie.Director) > 0 {
-		insertedDirectorID, err := m.insertDirector(ctx, tx, movie.Director)
-		if err != nil {
-			return models.CreateMovie{}, err
+	// Step 5: Genres, only the difference is written
+	if patch.GenreIDs != nil {
+		if _, err = tx.Exec(ctx, `DELETE FROM movie_genres WHERE movie_id = $1 AND genre_id <> ALL($2::int4[])`, movieID, *patch.GenreIDs); err != nil {
+			return time.Time{}, "", "", err
 		}
-		_, err = tx.Exec(ctx, `UPDATE movies SET director_id = $1 WHERE id = $2`, insertedDirectorID, movieID)
-		if err != nil {
-			return models.CreateMovie{}, err
+		if err = m.insertMovieGenres(ctx, tx, movieID, *patch.GenreIDs); err != nil {
+			return time.Time{}, "", "", err
 		}
 	}
 
-	// Step 3: Update genres
-	if len(movie.Genres) > 0 {
-		genreList := strings.Split(movie.Genres, ",")
-		insertedGenreIDs, err := m.insertGenres(ctx, tx, genreList)
+	// Step 6: Cast, only the difference is written
+	if patch.Cast != nil {
+		var castIDs []int
+		castIDs, err = m.insertPeople(ctx, tx, *patch.Cast)
 		if err != nil {
-			return models.CreateMovie{}, err
+			return time.Time{}, "", "", err
 		}
-		_, err = tx.Exec(ctx, `DELETE FROM movie_genres WHERE movie_id = $1`, movieID)
-		if err != nil {
-			return models.CreateMovie{}, err
+		if _, err = tx.Exec(ctx, `DELETE FROM movie_actors WHERE movie_id = $1 AND actor_id <> ALL($2::int4[])`, movieID, castIDs); err != nil {
+			return time.Time{}, "", "", err
 		}
-		err = m.insertMovieGenres(ctx, tx, movieID, insertedGenreIDs)
-		if err != nil {
-			return models.CreateMovie{}, err
+		if err = m.insertMovieActors(ctx, tx, movieID, castIDs); err != nil {
+			return time.Time{}, "", "", err
 		}
 	}
 
-	// Step 4: Update cast
-	if len(movie.Cast) > 0 {
-		castList := strings.Split(movie.Cast, ",")
-		insertedCastIDs, err := m.insertPeople(ctx, tx, castList)
-		if err != nil {
-			return models.CreateMovie{}, err
-		}
-		_, err = tx.Exec(ctx, `DELETE FROM movie_actors WHERE movie_id = $1`, movieID)
-		if err != nil {
-			return models.CreateMovie{}, err
-		}
-		err = m.insertMovieActors(ctx, tx, movieID, insertedCastIDs)
-		if err != nil {
-			return models.CreateMovie{}, err
+	// Step 7: Schedules, only when asked for
+	if patch.Schedule != nil {
+		if err = m.syncSchedules(ctx, tx, movieID, *patch.Schedule); err != nil {
+			log.Printf("error syncing schedules: %v", err)
+			return time.Time{}, "", "", err
 		}
 	}
 
-	// Step 5: Delete old schedules
-	_, err = tx.Exec(ctx, `DELETE FROM schedules WHERE movie_id = $1`, movieID)
-	if err != nil {
-		log.Printf("failed to delete old schedules: %v", err)
-		return models.CreateMovie{}, err
+	// Step 8: Commit
+	if err = tx.Commit(ctx); err != nil {
+		return time.Time{}, "", "", err
 	}
 
-	// Step 6: Create new schedules (7 days)
-	cinemaList := strings.Split(movie.CinemaID, ",")
-	showTimeList := strings.Split(movie.ShowTimeID, ",")
-	cityList := strings.Split(movie.CityID, ",")
+	// Step 9: Invalidate caches
+	m.invalidateMovieCaches(ctx, movieID)
 
-	err = m.createSchedules(ctx, tx, movieID, movie.ShowDate, cinemaList, showTimeList, cityList)
+	// Step 10: Images that were replaced, the caller removes them from the storage
+	if locationPoster != "" && currentPoster != locationPoster {
+		oldPoster = currentPoster
+	}
+	if locationBackdrop != "" && currentBackdrop != locationBackdrop {
+		oldBackdrop = currentBackdrop
+	}
+	return updatedAt, oldPoster, oldBackdrop, nil
+}
+
+// setMovieImageURLs fills the urls of every size of the poster and backdrop
+func setMovieImageURLs(movie *models.Movie) {
+	movie.PosterURLs = utils.ImageURLs("posters", movie.PosterImg)
+	movie.BackdropURLs = utils.ImageURLs("backdrops", movie.BackdropImg)
+}
+
+// syncSchedules makes the schedules of the movie match the 7 days of schedule. Schedules outside of it
+// are removed unless tickets were already bought for them, those always stay.
+func (m *MovieRepository) syncSchedules(ctx context.Context, tx pgx.Tx, movieID int, schedule models.ScheduleInput) error {
+	deleteQuery := `
+		DELETE FROM schedules s
+		WHERE s.movie_id = $1
+			AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.schedule_id = s.id)
+			AND NOT (
+				s.show_date BETWEEN $2::date AND $2::date + 6
+				AND s.city_id = ANY($3::int4[])
+				AND s.cinema_id = ANY($4::int4[])
+				AND s.show_time_id = ANY($5::int4[])
+			)`
+
+	deleted, err := tx.Exec(ctx, deleteQuery, movieID, schedule.ShowDate, schedule.CityIDs, schedule.CinemaIDs, schedule.ShowTimeIDs)
 	if err != nil {
-		log.Printf("error creating new schedules: %v", err)
-		return models.CreateMovie{}, err
+		return fmt.Errorf("failed to delete schedules of movie %d: %w", movieID, err)
 	}
 
-	// Step 7: Commit
-	if err = tx.Commit(ctx); err != nil {
-		return models.CreateMovie{}, err
+	insertQuery := `
+		INSERT INTO schedules (movie_id, city_id, show_time_id, cinema_id, show_date)
+		SELECT $1, city.id, show_time.id, cinema.id, day::date
+		FROM generate_series($2::date, $2::date + 6, interval '1 day') AS day
+		CROSS JOIN unnest($3::int4[]) AS city(id)
+		CROSS JOIN unnest($4::int4[]) AS cinema(id)
+		CROSS JOIN unnest($5::int4[]) AS show_time(id)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM schedules s
+			WHERE s.movie_id = $1 AND s.city_id = city.id AND s.cinema_id = cinema.id
+				AND s.show_time_id = show_time.id AND s.show_date = day::date
+		)`
+
+	inserted, err := tx.Exec(ctx, insertQuery, movieID, schedule.ShowDate, schedule.CityIDs, schedule.CinemaIDs, schedule.ShowTimeIDs)
+	if err != nil {
+		return fmt.Errorf("failed to insert schedules for movie %d: %w", movieID, err)
+	}
+
+	log.Printf("Schedules of movie %d: %d removed, %d created", movieID, deleted.RowsAffected(), inserted.RowsAffected())
+	return nil
+}
+
+// invalidateMovieCaches drops the cached lists and the details and schedules of the movies after they were
+// created, edited or changed status
+func (m *MovieRepository) invalidateMovieCaches(ctx context.Context, movieIDs ...int) {
+	tags := []string{utils.TagMovieLists}
+	for _, id := range movieIDs {
+		tags = append(tags, utils.MovieTag(id))
+	}
+	m.cache.Invalidate(ctx, tags...)
+	m.suggest.RefreshAsync()
+}
+
+// Suggest answers the search box from the prefix index
+func (m *MovieRepository) Suggest(ctx context.Context, q string, limit int) ([]models.Suggestion, error) {
+	return m.suggest.Suggest(ctx, q, limit)
+}
+
+// searchMoviesWhere matches the full-text query ($1 text, search.tsq) or, for typos,
+// a similar title or a similar director / cast name
+const searchMoviesWhere = `
+	m.status = 'published'
+	AND (
+		m.search_vector @@ search.tsq
+		OR $1 <% m.title
+		OR EXISTS (
+			SELECT 1
+			FROM people p
+			WHERE $1 <% p.name
+				AND (
+					p.id = m.director_id
+					OR p.id IN (SELECT ma.actor_id FROM movie_actors ma WHERE ma.movie_id = m.id)
+				)
+		)
+	)`
+
+// SearchMovies ranks movies by full-text relevance (title > people > genres > synopsis).
+// Ranked results can only be paged by page number, like the people search.
+func (m *MovieRepository) SearchMovies(ctx context.Context, q string, params pagination.Params) (pagination.Page[models.MovieSearchResult], error) {
+	if params.Cursor != nil {
+		return pagination.Page[models.MovieSearchResult]{}, pagination.ErrInvalidCursor
+	}
+
+	query := `
+		WITH search AS (
+			SELECT websearch_to_tsquery('english', $1) || websearch_to_tsquery('simple', $1) AS tsq
+		)
+		SELECT
+			m.id,
+			m.title,
+			ts_headline('english', m.title, search.tsq, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+			ts_headline('english', COALESCE(m.synopsis, ''), search.tsq, 'StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2'),
+			COALESCE(m.poster_img, ''),
+			m.release_date,
+			COALESCE((
+				SELECT ARRAY_AGG(g.name ORDER BY g.name)
+				FROM movie_genres mg
+				JOIN genres g ON g.id = mg.genre_id
+				WHERE mg.movie_id = m.id
+			), '{}') AS genres,
+			ts_rank_cd(m.search_vector, search.tsq, 32) + word_similarity($1, m.title) AS rank,
+			COUNT(*) OVER () AS total
+		FROM movies m, search
+		WHERE ` + searchMoviesWhere + `
+		ORDER BY rank DESC, m.release_date DESC NULLS LAST, m.id
+		OFFSET $2 LIMIT $3
+	`
+
+	rows, err := m.db.Query(ctx, query, q, params.Offset(), params.Limit())
+	if err != nil {
+		return pagination.Page[models.MovieSearchResult]{}, err
 	}
+	defer rows.Close()
 
-	// Step 8: Invalidate caches
-	keysToInvalidate := []string{
-		"tickitz:upcoming",
-		"tickitz:popular",
-		"tickitz:movies-all-first-page",
+	var results []models.MovieSearchResult
+	total := 0
+	for rows.Next() {
+		var r models.MovieSearchResult
+		if err := rows.Scan(
+			&r.ID,
+			&r.Title,
+			&r.TitleHighlight,
+			&r.Snippet,
+			&r.PosterImg,
+			&r.ReleaseDate,
+			&r.Genres,
+			&r.Rank,
+			&total,
+		); err != nil {
+			return pagination.Page[models.MovieSearchResult]{}, err
+		}
+		r.PosterURLs = utils.ImageURLs("posters", r.PosterImg)
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.MovieSearchResult]{}, err
 	}
-	for _, k := range keysToInvalidate {
-		if delErr := m.rdb.Del(ctx, k).Err(); delErr != nil {
-			log.Printf("failed to invalidate cache for key %s: %v", k, delErr)
+
+	// Page setelah hasil terakhir tidak punya row, jadi total dihitung terpisah
+	if len(results) == 0 && params.Offset() > 0 {
+		countQuery := `
+			WITH search AS (
+				SELECT websearch_to_tsquery('english', $1) || websearch_to_tsquery('simple', $1) AS tsq
+			)
+			SELECT COUNT(*) FROM movies m, search WHERE ` + searchMoviesWhere
+		if err := m.db.QueryRow(ctx, countQuery, q).Scan(&total); err != nil {
+			return pagination.Page[models.MovieSearchResult]{}, err
 		}
 	}
 
-	return models.CreateMovie{
-		ID:              movieID,
-		Title:           movie.Title,
-		ReleaseDate:     movie.ReleaseDate,
-		DurationMinutes: movie.DurationMinutes,
-	}, nil
+	return pagination.NewPage(results, params, total, nil), nil
 }
@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"log"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// a notification that failed this often is not retried anymore
+const maxNotificationAttempts = 5
+
+type NotificationRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewNotificationRepository(db *pgxpool.Pool) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// QueueScheduledMovies puts a notification into the outbox for every watchlist entry whose movie
+// got its first schedule. Marking the entry and queueing happen in one statement, so nothing is lost
+// or queued twice when the job runs on several instances.
+func (n *NotificationRepository) QueueScheduledMovies(ctx context.Context) (int64, error) {
+	query := `
+		WITH due AS (
+			UPDATE watchlists w
+			SET notified_at = CURRENT_TIMESTAMP
+			FROM movies m
+			WHERE
+				w.movie_id = m.id
+				AND w.notified_at IS NULL
+				AND m.status = 'published'
+				AND EXISTS (
+					SELECT 1 FROM schedules s WHERE s.movie_id = w.movie_id AND s.show_date >= CURRENT_DATE
+				)
+			RETURNING w.user_id, w.movie_id, m.title
+		)
+		INSERT INTO notifications (user_id, kind, dedupe_key, title, message, payload)
+		SELECT
+			user_id,
+			'movie_scheduled',
+			'movie_scheduled:' || movie_id || ':' || user_id,
+			'Tiket ' || title || ' sudah tersedia',
+			title || ' dari watchlist kamu sudah punya jadwal tayang, pesan tiketnya sekarang!',
+			jsonb_build_object('movie_id', movie_id)
+		FROM due
+		ON CONFLICT (dedupe_key) DO NOTHING`
+
+	tag, err := n.db.Exec(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeliverPending sends up to limit unsent notifications with send. The rows stay locked
+// (SKIP LOCKED) until the transaction ends, so two instances never send the same notification.
+// payload.to overrides the recipient, e.g. the confirmation of a new email address.
+func (n *NotificationRepository) DeliverPending(ctx context.Context, limit int, send func(pkg.Notification) error) (sent int, err error) {
+	tx, err := n.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	query := `
+		SELECT n.id, n.user_id, COALESCE(n.payload->>'to', u.email), n.kind, n.title, n.message, n.payload, n.created_at
+		FROM notifications n
+		JOIN users u ON u.id = n.user_id
+		WHERE n.sent_at IS NULL AND n.attempts < $1
+		ORDER BY n.id
+		LIMIT $2
+		FOR UPDATE OF n SKIP LOCKED`
+
+	rows, err := tx.Query(ctx, query, maxNotificationAttempts, limit)
+	if err != nil {
+		return 0, err
+	}
+	var pending []pkg.Notification
+	for rows.Next() {
+		var p pkg.Notification
+		if err = rows.Scan(&p.ID, &p.UserID, &p.Email, &p.Kind, &p.Title, &p.Message, &p.Payload, &p.CreatedAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, p := range pending {
+		if sendErr := send(p); sendErr != nil {
+			if _, err = tx.Exec(ctx, "UPDATE notifications SET attempts = attempts + 1, last_error = $2 WHERE id = $1", p.ID, sendErr.Error()); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if _, err = tx.Exec(ctx, "UPDATE notifications SET attempts = attempts + 1, sent_at = CURRENT_TIMESTAMP, last_error = NULL WHERE id = $1", p.ID); err != nil {
+			return 0, err
+		}
+		sent++
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+	return sent, nil
+}
@@ -2,28 +2,36 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrTicketNotFound       = errors.New("ticket not found at this cinema")
+	ErrTicketNotPaid        = errors.New("ticket has not been paid")
+	ErrTicketAlreadyScanned = errors.New("ticket has already been scanned")
+	ErrScheduleNotBookable  = errors.New("schedule not found or movie is not showing anymore")
+)
+
 type OrderRepository struct {
 	db    *pgxpool.Pool
-	rdb   *redis.Client
-	cache *utils.CacheManager
+	cache *utils.TaggedCache
 }
 
 func NewOrderRepository(db *pgxpool.Pool, rdb *redis.Client) *OrderRepository {
 	return &OrderRepository{
 		db:    db,
-		rdb:   rdb,
-		cache: utils.NewCacheManager(rdb),
+		cache: utils.NewTaggedCache(rdb),
 	}
 }
 
@@ -133,7 +141,13 @@ func (o *OrderRepository) insertTransaction(ctx context.Context, tx pgx.Tx, t mo
 			email,
 			phone_number,
 			schedule_id
-		) VALUES ($1, $2, $3, $4, $5, $6, $7) 
+		)
+		SELECT $1, $2, $3, $4, $5, $6, $7
+		-- drafts and archived movies can't be booked anymore
+		WHERE EXISTS (
+			SELECT 1 FROM schedules s JOIN movies m ON m.id = s.movie_id
+			WHERE s.id = $7 AND m.status = 'published'
+		)
 		RETURNING id::text, user_id::text, schedule_id`
 
 	var newT models.Transaction
@@ -151,6 +165,9 @@ func (o *OrderRepository) insertTransaction(ctx context.Context, tx pgx.Tx, t mo
 		&newT.ScheduleID)
 
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transaction{}, ErrScheduleNotBookable
+		}
 		return models.Transaction{}, err
 	}
 
@@ -188,27 +205,57 @@ func (o *OrderRepository) PayTransaction(ctx context.Context, transactionID stri
 			paid_at = CURRENT_TIMESTAMP,
 			updated_at = CURRENT_TIMESTAMP
 		WHERE id = $1 AND paid_at IS NULL
-		returning id
+		returning id, schedule_id
 	`
 	var id string
-	if err := o.db.QueryRow(ctx, query, transactionID).Scan(&id); err != nil {
+	var scheduleID *int
+	if err := o.db.QueryRow(ctx, query, transactionID).Scan(&id, &scheduleID); err != nil {
 		return "", err
 	}
 
-	keysToInvalidate := []string{
-		"tickitz:popular",
-	}
-	for _, k := range keysToInvalidate {
-		if delErr := o.rdb.Del(ctx, k).Err(); delErr != nil {
-			log.Printf("failed to invalidate cache for key %s: %v", k, delErr)
-		}
+	// the paid seats are sold now, and the ticket counts for the popularity
+	tags := []string{utils.TagPopularity}
+	if scheduleID != nil {
+		tags = append(tags, utils.ScheduleTag(*scheduleID))
 	}
+	o.cache.Invalidate(ctx, tags...)
 
 	return id, nil
 }
 
 // Transaction History
-func (o *OrderRepository) ListTransaction(ctx context.Context, userID string) ([]models.TransactionHistory, error) {
+// Latest first, the cursor is (created_at, id) of the last transaction of the previous page. Paying and
+// scanning change updated_at, a list ordered by it would move rows between pages while a client is paging.
+func (o *OrderRepository) ListTransaction(ctx context.Context, userID string, params pagination.Params) (pagination.Page[models.TransactionHistory], error) {
+	// same joins as the list, a transaction without seats or age rating is not shown there either
+	from := `
+		from transactions t
+			join schedules s on t.schedule_id = s.id
+			join movies m on s.movie_id = m.id
+			join cinemas c on s.cinema_id = c.id
+			join age_ratings ar on m.age_rating_id = ar.id
+			join show_times st on s.show_time_id = st.id
+			join transactions_seats ts on t.id = ts.transactions_id
+			join seat_codes sc on ts.seats_id = sc.id
+		where t.user_id = $1
+	`
+
+	var total int
+	if err := o.db.QueryRow(ctx, "select count(distinct t.id) "+from, userID).Scan(&total); err != nil {
+		return pagination.Page[models.TransactionHistory]{}, err
+	}
+
+	cursor, err := params.CursorFor("created_at:desc")
+	if err != nil {
+		return pagination.Page[models.TransactionHistory]{}, err
+	}
+
+	args := []any{userID, params.Offset(), params.Limit()}
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		from += " and (coalesce(t.created_at, '-infinity'), t.id) < ($4::timestamptz, $5::uuid)"
+	}
+
 	query := `
 		select 
 			t.id,
@@ -224,30 +271,30 @@ func (o *OrderRepository) ListTransaction(ctx context.Context, userID string) ([
 			t.paid_at, 
 			t.updated_at,
 			t.scanned_at, 
-			t.schedule_id
-		from transactions t
-			join schedules s on t.schedule_id = s.id
-			join movies m on s.movie_id = m.id
-			join cinemas c on s.cinema_id = c.id
-			join age_ratings ar on m.age_rating_id = ar.id
-			join show_times st on s.show_time_id = st.id
-			join transactions_seats ts on t.id = ts.transactions_id
-			join seat_codes sc on ts.seats_id = sc.id
-		where t.user_id = $1
+			t.schedule_id,
+			coalesce(t.created_at, '-infinity')::text as sort_key
+		` + from + `
 		group by t.id, c.name, c.img, s.show_date, m.title,
 			ar.age_rating, st.start_at, 
 			t.total_payment, t.phone_number, 
 			t.paid_at, t.scanned_at, t.schedule_id
-		order by t.updated_at desc
+		order by coalesce(t.created_at, '-infinity') desc, t.id desc
+		offset $2 limit $3
 	`
-	rows, err := o.db.Query(ctx, query, userID)
+	rows, err := o.db.Query(ctx, query, args...)
 	if err != nil {
-		return []models.TransactionHistory{}, err
+		if cursor != nil {
+			return pagination.Page[models.TransactionHistory]{}, cursorError(err)
+		}
+		return pagination.Page[models.TransactionHistory]{}, err
 	}
+	defer rows.Close()
 
 	var listTransaction []models.TransactionHistory
+	var sortKeys []string
 	for rows.Next() {
 		var t models.TransactionHistory
+		var key string
 		if err := rows.Scan(
 			&t.ID,
 			&t.Cinema,
@@ -263,10 +310,67 @@ func (o *OrderRepository) ListTransaction(ctx context.Context, userID string) ([
 			&t.UpdatedAt,
 			&t.ScannedAt,
 			&t.ScheduleID,
+			&key,
 		); err != nil {
-			return []models.TransactionHistory{}, err
+			return pagination.Page[models.TransactionHistory]{}, err
 		}
 		listTransaction = append(listTransaction, t)
+		sortKeys = append(sortKeys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.TransactionHistory]{}, err
+	}
+
+	return pagination.NewPage(listTransaction, params, total, func(i int) pagination.Cursor {
+		return pagination.Cursor{Sort: "created_at:desc", Value: sortKeys[i], ID: listTransaction[i].ID}
+	}), nil
+}
+
+// ScanTicket marks a paid ticket as used, only staff of the cinema of the schedule may scan it
+func (o *OrderRepository) ScanTicket(ctx context.Context, transactionID string, cinemaID int) (models.Transaction, error) {
+	query := `
+		UPDATE transactions t
+		SET
+			scanned_at = CURRENT_TIMESTAMP,
+			updated_at = CURRENT_TIMESTAMP
+		FROM schedules s
+		WHERE t.schedule_id = s.id
+			AND t.id = $1
+			AND s.cinema_id = $2
+			AND t.paid_at IS NOT NULL
+			AND t.scanned_at IS NULL
+		RETURNING t.id, COALESCE(t.full_name, ''), COALESCE(t.total_payment, 0), t.paid_at, t.scanned_at, t.schedule_id`
+
+	var t models.Transaction
+	err := o.db.QueryRow(ctx, query, transactionID, cinemaID).Scan(
+		&t.ID, &t.FullName, &t.TotalPayment, &t.PaidAt, &t.ScannedAt, &t.ScheduleID,
+	)
+	if err == nil {
+		return t, nil
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "22P02" { // invalid uuid
+		return models.Transaction{}, ErrTicketNotFound
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return models.Transaction{}, err
+	}
+
+	// Cari tahu kenapa tidak bisa di-scan
+	query = `
+		SELECT t.paid_at, t.scanned_at
+		FROM transactions t
+		JOIN schedules s ON t.schedule_id = s.id
+		WHERE t.id = $1 AND s.cinema_id = $2`
+	if err := o.db.QueryRow(ctx, query, transactionID, cinemaID).Scan(&t.PaidAt, &t.ScannedAt); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Transaction{}, ErrTicketNotFound
+		}
+		return models.Transaction{}, err
+	}
+	if t.PaidAt == nil {
+		return models.Transaction{}, ErrTicketNotPaid
 	}
-	return listTransaction, nil
+	return models.Transaction{}, ErrTicketAlreadyScanned
 }
@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+)
+
+// cursorError turns a failed cast of the cursor values into pagination.ErrInvalidCursor,
+// the cursor comes from the client so a tampered one is a bad request and not a server error
+func cursorError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "22P02", "22003", "22007", "22008": // invalid text, number out of range, invalid datetime, datetime out of range
+			return pagination.ErrInvalidCursor
+		}
+	}
+	return err
+}
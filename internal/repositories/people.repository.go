@@ -0,0 +1,341 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrPersonNotFound     = errors.New("person not found")
+	ErrPersonNameTaken    = errors.New("another person already has this name, merge them instead")
+	ErrInvalidMergeSource = errors.New("source_ids must be other existing people")
+)
+
+type PeopleRepository struct {
+	db      *pgxpool.Pool
+	suggest *SuggestRepository
+	cache   *utils.TaggedCache
+}
+
+func NewPeopleRepository(db *pgxpool.Pool, rdb *redis.Client) *PeopleRepository {
+	return &PeopleRepository{db: db, suggest: NewSuggestRepository(db, rdb), cache: utils.NewTaggedCache(rdb)}
+}
+
+const personSelect = `
+	SELECT
+		p.id,
+		p.name,
+		p.biography,
+		COALESCE(p.photo_img, ''),
+		p.birth_date,
+		COALESCE((SELECT ARRAY_AGG(pa.alias ORDER BY pa.alias) FROM people_aliases pa WHERE pa.person_id = p.id), '{}')
+	FROM people p`
+
+func scanPerson(row pgx.Row, p *models.Person, extra ...any) error {
+	return row.Scan(append([]any{&p.ID, &p.Name, &p.Biography, &p.PhotoImg, &p.BirthDate, &p.Aliases}, extra...)...)
+}
+
+// SearchPeople finds people by (part of) the name, typos are tolerated through pg_trgm.
+// Without q every person is listed alphabetically and can be paged with a cursor.
+func (r *PeopleRepository) SearchPeople(ctx context.Context, q string, params pagination.Params) (pagination.Page[models.Person], error) {
+	if q == "" {
+		return r.listPeople(ctx, params)
+	}
+	// ranked results can only be paged by page number
+	if params.Cursor != nil {
+		return pagination.Page[models.Person]{}, pagination.ErrInvalidCursor
+	}
+
+	where := ` WHERE p.name ILIKE '%' || $1 || '%' OR p.name % $1
+		OR EXISTS (SELECT 1 FROM people_aliases pa WHERE pa.person_id = p.id AND pa.alias ILIKE '%' || $1 || '%')`
+
+	var total int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM people p"+where, q).Scan(&total); err != nil {
+		return pagination.Page[models.Person]{}, err
+	}
+
+	query := personSelect + where + `
+		ORDER BY (p.name ILIKE $1 || '%') DESC, similarity(p.name, $1) DESC, p.name, p.id
+		OFFSET $2 LIMIT $3`
+
+	rows, err := r.db.Query(ctx, query, q, params.Offset(), params.Limit())
+	if err != nil {
+		return pagination.Page[models.Person]{}, err
+	}
+	defer rows.Close()
+
+	var people []models.Person
+	for rows.Next() {
+		var p models.Person
+		if err := scanPerson(rows, &p); err != nil {
+			return pagination.Page[models.Person]{}, err
+		}
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.Person]{}, err
+	}
+
+	return pagination.NewPage(people, params, total, nil), nil
+}
+
+func (r *PeopleRepository) listPeople(ctx context.Context, params pagination.Params) (pagination.Page[models.Person], error) {
+	var total int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM people").Scan(&total); err != nil {
+		return pagination.Page[models.Person]{}, err
+	}
+
+	cursor, err := params.CursorFor("name:asc")
+	if err != nil {
+		return pagination.Page[models.Person]{}, err
+	}
+
+	args := []any{params.Offset(), params.Limit()}
+	keyset := ""
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		keyset = " WHERE (p.name, p.id) > ($3::text, $4::int4)"
+	}
+
+	rows, err := r.db.Query(ctx, personSelect+keyset+" ORDER BY p.name, p.id OFFSET $1 LIMIT $2", args...)
+	if err != nil {
+		if cursor != nil {
+			return pagination.Page[models.Person]{}, cursorError(err)
+		}
+		return pagination.Page[models.Person]{}, err
+	}
+	defer rows.Close()
+
+	var people []models.Person
+	for rows.Next() {
+		var p models.Person
+		if err := scanPerson(rows, &p); err != nil {
+			return pagination.Page[models.Person]{}, err
+		}
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.Person]{}, err
+	}
+
+	return pagination.NewPage(people, params, total, func(i int) pagination.Cursor {
+		return pagination.Cursor{Sort: "name:asc", Value: people[i].Name, ID: strconv.Itoa(people[i].ID)}
+	}), nil
+}
+
+// GetPerson returns the person with the published movies they directed and acted in
+func (r *PeopleRepository) GetPerson(ctx context.Context, personID int) (models.PersonDetail, error) {
+	var detail models.PersonDetail
+	if err := scanPerson(r.db.QueryRow(ctx, personSelect+" WHERE p.id = $1", personID), &detail.Person); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.PersonDetail{}, ErrPersonNotFound
+		}
+		return models.PersonDetail{}, err
+	}
+
+	query := `
+		SELECT m.id, m.title, COALESCE(m.poster_img, ''), m.release_date, f.directed, f.acted
+		FROM movies m
+		CROSS JOIN LATERAL (
+			SELECT
+				m.director_id IS NOT DISTINCT FROM $1 AS directed,
+				EXISTS (SELECT 1 FROM movie_actors ma WHERE ma.movie_id = m.id AND ma.actor_id = $1) AS acted
+		) f
+		WHERE m.status = 'published' AND (f.directed OR f.acted)
+		ORDER BY m.release_date DESC NULLS LAST, m.id DESC`
+
+	rows, err := r.db.Query(ctx, query, personID)
+	if err != nil {
+		return models.PersonDetail{}, err
+	}
+	defer rows.Close()
+
+	// a director who also acts in the movie is listed in both
+	detail.Directed = []models.FilmographyItem{}
+	detail.Acted = []models.FilmographyItem{}
+	for rows.Next() {
+		var item models.FilmographyItem
+		var directed, acted bool
+		if err := rows.Scan(&item.MovieID, &item.Title, &item.PosterImg, &item.ReleaseDate, &directed, &acted); err != nil {
+			return models.PersonDetail{}, err
+		}
+		item.PosterURLs = utils.ImageURLs("posters", item.PosterImg)
+		if directed {
+			detail.Directed = append(detail.Directed, item)
+		}
+		if acted {
+			detail.Acted = append(detail.Acted, item)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.PersonDetail{}, err
+	}
+
+	return detail, nil
+}
+
+// EditPerson updates the given fields, photo is the filename in public/people ("" = unchanged).
+// It returns the old photo so the handler can remove the file.
+func (r *PeopleRepository) EditPerson(ctx context.Context, personID int, req models.EditPersonRequest, birthDate *time.Time, photo string) (models.Person, string, error) {
+	var oldPhoto string
+	if err := r.db.QueryRow(ctx, "SELECT COALESCE(photo_img, '') FROM people WHERE id = $1", personID).Scan(&oldPhoto); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Person{}, "", ErrPersonNotFound
+		}
+		return models.Person{}, "", err
+	}
+
+	query := `
+		UPDATE people
+		SET
+			name = COALESCE($2, name),
+			biography = COALESCE($3, biography),
+			birth_date = CASE WHEN $4::boolean THEN $5::date ELSE birth_date END,
+			photo_img = COALESCE(NULLIF($6, ''), photo_img),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, personID, req.Name, req.Biography, req.BirthDate != nil, birthDate, photo)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return models.Person{}, "", ErrPersonNameTaken
+		}
+		return models.Person{}, "", err
+	}
+
+	if req.Name != nil {
+		r.suggest.RefreshAsync()
+		r.cache.Invalidate(ctx, utils.TagPeople)
+	}
+
+	var person models.Person
+	if err := scanPerson(r.db.QueryRow(ctx, personSelect+" WHERE p.id = $1", personID), &person); err != nil {
+		return models.Person{}, "", err
+	}
+	if photo == "" {
+		oldPhoto = ""
+	}
+	return person, oldPhoto, nil
+}
+
+// MergePeople moves the movies of the sources to the target and deletes the sources.
+// The names of the sources become aliases of the target, so they are not created again.
+func (r *PeopleRepository) MergePeople(ctx context.Context, targetID int, sourceIDs []int) (person models.Person, err error) {
+	if slices.Contains(sourceIDs, targetID) {
+		return models.Person{}, ErrInvalidMergeSource
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return models.Person{}, err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	// lock target and sources, two merges of the same person must not run at the same time
+	var found int
+	if err = tx.QueryRow(ctx, "SELECT COUNT(*) FROM (SELECT id FROM people WHERE id = $1 OR id = ANY($2::int4[]) FOR UPDATE) p", targetID, sourceIDs).Scan(&found); err != nil {
+		return models.Person{}, err
+	}
+	uniqueSources := slices.Compact(slices.Sorted(slices.Values(sourceIDs)))
+	if found != len(uniqueSources)+1 {
+		var targetExists bool
+		if err = tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM people WHERE id = $1)", targetID).Scan(&targetExists); err != nil {
+			return models.Person{}, err
+		}
+		if !targetExists {
+			err = ErrPersonNotFound
+		} else {
+			err = ErrInvalidMergeSource
+		}
+		return models.Person{}, err
+	}
+
+	statements := []string{
+		// cast: the target may already be in the cast of the same movie
+		`INSERT INTO movie_actors (actor_id, movie_id)
+		 SELECT $1, movie_id FROM movie_actors WHERE actor_id = ANY($2::int4[])
+		 ON CONFLICT DO NOTHING`,
+		`DELETE FROM movie_actors WHERE actor_id = ANY($2::int4[])`,
+		`UPDATE movies SET director_id = $1, updated_at = CURRENT_TIMESTAMP WHERE director_id = ANY($2::int4[])`,
+		// the target keeps its own details, missing ones are taken from a source
+		`UPDATE people t
+		 SET
+			biography = CASE WHEN t.biography = '' THEN COALESCE((SELECT s.biography FROM people s WHERE s.id = ANY($2::int4[]) AND s.biography <> '' ORDER BY s.id LIMIT 1), '') ELSE t.biography END,
+			photo_img = COALESCE(t.photo_img, (SELECT s.photo_img FROM people s WHERE s.id = ANY($2::int4[]) AND s.photo_img IS NOT NULL ORDER BY s.id LIMIT 1)),
+			birth_date = COALESCE(t.birth_date, (SELECT s.birth_date FROM people s WHERE s.id = ANY($2::int4[]) AND s.birth_date IS NOT NULL ORDER BY s.id LIMIT 1)),
+			updated_at = CURRENT_TIMESTAMP
+		 WHERE t.id = $1`,
+		`UPDATE people_aliases SET person_id = $1 WHERE person_id = ANY($2::int4[])`,
+		`INSERT INTO people_aliases (person_id, alias)
+		 SELECT $1, s.name FROM people s WHERE s.id = ANY($2::int4[])
+		 ON CONFLICT DO NOTHING`,
+		`DELETE FROM people WHERE id = ANY($2::int4[])`,
+	}
+	for _, stmt := range statements {
+		if _, err = tx.Exec(ctx, stmt, targetID, uniqueSources); err != nil {
+			return models.Person{}, err
+		}
+	}
+
+	if err = scanPerson(tx.QueryRow(ctx, personSelect+" WHERE p.id = $1", targetID), &person); err != nil {
+		return models.Person{}, err
+	}
+	if err = tx.Commit(ctx); err != nil {
+		return models.Person{}, err
+	}
+
+	r.suggest.RefreshAsync()
+	r.cache.Invalidate(ctx, utils.TagPeople)
+	return person, nil
+}
+
+// FindDuplicates lists pairs of people with (almost) the same name: equal after removing case,
+// spaces and punctuation, or a trigram similarity of at least minScore
+func (r *PeopleRepository) FindDuplicates(ctx context.Context, minScore float64, limit int) ([]models.DuplicatePeople, error) {
+	query := `
+		WITH normalized AS (
+			SELECT id, name, regexp_replace(lower(name), '[^[:alnum:]]', '', 'g') AS norm
+			FROM people
+		)
+		SELECT a.id, a.name, b.id, b.name,
+			CASE WHEN a.norm = b.norm THEN 1 ELSE similarity(a.name, b.name) END AS score
+		FROM normalized a
+		JOIN normalized b ON a.id < b.id AND (a.norm = b.norm OR similarity(a.name, b.name) >= $1)
+		ORDER BY score DESC, a.id, b.id
+		LIMIT $2`
+
+	rows, err := r.db.Query(ctx, query, minScore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	duplicates := []models.DuplicatePeople{}
+	for rows.Next() {
+		var d models.DuplicatePeople
+		if err := rows.Scan(&d.A.ID, &d.A.Name, &d.B.ID, &d.B.Name, &d.Score); err != nil {
+			return nil, err
+		}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, rows.Err()
+}
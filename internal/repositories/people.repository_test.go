@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// unreachableRedis makes the index rebuild and the cache invalidation fail fast, like when Redis is down
+func unreachableRedis(t *testing.T) *redis.Client {
+	t.Helper()
+	rdb := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1, DialTimeout: 100 * time.Millisecond})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func TestMergePeople(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	marker := fmt.Sprintf("zqx%d", time.Now().UnixNano()%1_000_000_000)
+
+	insertPerson := func(name string) int {
+		var id int
+		if err := db.QueryRow(ctx, `INSERT INTO people (name) VALUES ($1) RETURNING id`, name).Scan(&id); err != nil {
+			t.Fatalf("insert person: %v", err)
+		}
+		return id
+	}
+	insertMovie := func(title string, directorID int, actorIDs ...int) int {
+		var id int
+		if err := db.QueryRow(ctx, `INSERT INTO movies (title, director_id) VALUES ($1, $2) RETURNING id`, title, directorID).Scan(&id); err != nil {
+			t.Fatalf("insert movie: %v", err)
+		}
+		for _, actorID := range actorIDs {
+			if _, err := db.Exec(ctx, `INSERT INTO movie_actors (actor_id, movie_id) VALUES ($1, $2)`, actorID, id); err != nil {
+				t.Fatalf("insert cast: %v", err)
+			}
+		}
+		return id
+	}
+
+	target := insertPerson("Target " + marker)
+	sourceA := insertPerson("Source A " + marker)
+	sourceB := insertPerson("Source B " + marker)
+	other := insertPerson("Other " + marker)
+
+	shared := insertMovie("Shared "+marker, other, target, sourceA)
+	castB := insertMovie("Cast B "+marker, other, sourceB)
+	directedA := insertMovie("Directed A "+marker, sourceA, other)
+	movies := []int{shared, castB, directedA}
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM movie_actors WHERE movie_id = ANY($1)`, movies)
+		db.Exec(ctx, `DELETE FROM movies WHERE id = ANY($1)`, movies)
+		db.Exec(ctx, `DELETE FROM people WHERE id = ANY($1)`, []int{target, sourceA, sourceB, other})
+	})
+
+	repo := NewPeopleRepository(db, unreachableRedis(t))
+
+	t.Run("target as source", func(t *testing.T) {
+		if _, err := repo.MergePeople(ctx, target, []int{sourceA, target}); !errors.Is(err, ErrInvalidMergeSource) {
+			t.Fatalf("error = %v, want ErrInvalidMergeSource", err)
+		}
+	})
+
+	var missing int
+	if err := db.QueryRow(ctx, `SELECT COALESCE(MAX(id), 0) + 1000 FROM people`).Scan(&missing); err != nil {
+		t.Fatalf("max id: %v", err)
+	}
+	t.Run("missing target", func(t *testing.T) {
+		if _, err := repo.MergePeople(ctx, missing, []int{sourceA}); !errors.Is(err, ErrPersonNotFound) {
+			t.Fatalf("error = %v, want ErrPersonNotFound", err)
+		}
+	})
+	t.Run("missing source", func(t *testing.T) {
+		if _, err := repo.MergePeople(ctx, target, []int{sourceA, missing}); !errors.Is(err, ErrInvalidMergeSource) {
+			t.Fatalf("error = %v, want ErrInvalidMergeSource", err)
+		}
+	})
+
+	// nothing was changed by the failed merges
+	var sources int
+	if err := db.QueryRow(ctx, `SELECT COUNT(*) FROM people WHERE id = ANY($1)`, []int{sourceA, sourceB}).Scan(&sources); err != nil || sources != 2 {
+		t.Fatalf("sources after the failed merges = %d, %v, want 2", sources, err)
+	}
+
+	// a source listed twice is merged once
+	person, err := repo.MergePeople(ctx, target, []int{sourceA, sourceB, sourceA})
+	if err != nil {
+		t.Fatalf("MergePeople: %v", err)
+	}
+	if person.ID != target || person.Name != "Target "+marker {
+		t.Errorf("merged person = %d %q, want the target", person.ID, person.Name)
+	}
+	if !slices.Contains(person.Aliases, "Source A "+marker) || !slices.Contains(person.Aliases, "Source B "+marker) {
+		t.Errorf("aliases = %v, want the names of both sources", person.Aliases)
+	}
+
+	castOf := func(movieID int) []int {
+		rows, err := db.Query(ctx, `SELECT actor_id FROM movie_actors WHERE movie_id = $1 ORDER BY actor_id`, movieID)
+		if err != nil {
+			t.Fatalf("cast: %v", err)
+		}
+		defer rows.Close()
+		ids := []int{}
+		for rows.Next() {
+			var id int
+			rows.Scan(&id)
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	if got := castOf(shared); !slices.Equal(got, []int{target}) {
+		t.Errorf("cast of the shared movie = %v, want only the target once", got)
+	}
+	if got := castOf(castB); !slices.Equal(got, []int{target}) {
+		t.Errorf("cast of source B's movie = %v, want the target", got)
+	}
+
+	var directorID int
+	if err := db.QueryRow(ctx, `SELECT director_id FROM movies WHERE id = $1`, directedA).Scan(&directorID); err != nil || directorID != target {
+		t.Errorf("director = %d, %v, want the target %d", directorID, err, target)
+	}
+	if err := db.QueryRow(ctx, `SELECT COUNT(*) FROM people WHERE id = ANY($1)`, []int{sourceA, sourceB}).Scan(&sources); err != nil || sources != 0 {
+		t.Errorf("sources after the merge = %d, %v, want deleted", sources, err)
+	}
+
+	// a new movie with the old spelling links the target instead of creating the duplicate again
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	defer tx.Rollback(ctx)
+	id, err := findOrCreatePerson(ctx, tx, "  source   a "+marker)
+	if err != nil {
+		t.Fatalf("findOrCreatePerson: %v", err)
+	}
+	if id != target {
+		t.Errorf("findOrCreatePerson(alias) = %d, want the target %d", id, target)
+	}
+}
@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+)
+
+var (
+	ErrRoleNotFound        = errors.New("role not found")
+	ErrRoleAlreadyAssigned = errors.New("role is already assigned to this user")
+	ErrBaseRole            = errors.New("user and admin come from the account role and cannot be assigned")
+	ErrUserRoleNotFound    = errors.New("role assignment not found")
+	ErrUserOrCinemaMissing = errors.New("user or cinema does not exist")
+)
+
+type PermissionRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPermissionRepository(db *pgxpool.Pool) *PermissionRepository {
+	return &PermissionRepository{db: db}
+}
+
+// ListRoles returns every role together with its permission codes
+func (p *PermissionRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	query := `
+		SELECT
+			r.id,
+			r.name,
+			COALESCE(r.description, ''),
+			COALESCE(array_agg(rp.permission_code ORDER BY rp.permission_code) FILTER (WHERE rp.permission_code IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		GROUP BY r.id
+		ORDER BY r.id`
+
+	rows, err := p.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var r models.Role
+		if err := rows.Scan(&r.ID, &r.Name, &r.Description, &r.Permissions); err != nil {
+			return nil, err
+		}
+		roles = append(roles, r)
+	}
+	return roles, rows.Err()
+}
+
+// ListUserRoles returns the extra roles of a user (the base role is users.role)
+func (p *PermissionRepository) ListUserRoles(ctx context.Context, userID string) ([]models.UserRole, error) {
+	query := `
+		SELECT ur.id, ur.user_id, r.name, ur.cinema_id, ur.created_at
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY ur.id`
+
+	rows, err := p.db.Query(ctx, query, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+			return nil, ErrUserOrCinemaMissing
+		}
+		return nil, err
+	}
+	defer rows.Close()
+
+	userRoles := []models.UserRole{}
+	for rows.Next() {
+		var ur models.UserRole
+		if err := rows.Scan(&ur.ID, &ur.UserID, &ur.Role, &ur.CinemaID, &ur.CreatedAt); err != nil {
+			return nil, err
+		}
+		userRoles = append(userRoles, ur)
+	}
+	return userRoles, rows.Err()
+}
+
+func (p *PermissionRepository) AssignRole(ctx context.Context, userID, roleName string, cinemaID *int) (models.UserRole, error) {
+	if roleName == "user" || roleName == "admin" {
+		return models.UserRole{}, ErrBaseRole
+	}
+
+	var roleID int
+	if err := p.db.QueryRow(ctx, `SELECT id FROM roles WHERE name = $1`, roleName).Scan(&roleID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.UserRole{}, ErrRoleNotFound
+		}
+		return models.UserRole{}, err
+	}
+
+	query := `
+		INSERT INTO user_roles (user_id, role_id, cinema_id)
+		VALUES ($1, $2, $3)
+		RETURNING id, user_id, cinema_id, created_at`
+
+	ur := models.UserRole{Role: roleName}
+	if err := p.db.QueryRow(ctx, query, userID, roleID, cinemaID).Scan(&ur.ID, &ur.UserID, &ur.CinemaID, &ur.CreatedAt); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) {
+			switch pgErr.Code {
+			case "23505": // unique_violation
+				return models.UserRole{}, ErrRoleAlreadyAssigned
+			case "23503", "22P02": // foreign_key_violation, invalid uuid
+				return models.UserRole{}, ErrUserOrCinemaMissing
+			}
+		}
+		return models.UserRole{}, fmt.Errorf("failed to assign role: %w", err)
+	}
+	return ur, nil
+}
+
+func (p *PermissionRepository) RevokeRole(ctx context.Context, userID string, userRoleID int) error {
+	tag, err := p.db.Exec(ctx, `DELETE FROM user_roles WHERE id = $1 AND user_id = $2`, userRoleID, userID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" {
+			return ErrUserRoleNotFound
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserRoleNotFound
+	}
+	return nil
+}
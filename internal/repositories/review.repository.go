@@ -0,0 +1,267 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrReviewNotFound      = errors.New("review not found")
+	ErrReviewNotAllowed    = errors.New("only users with a paid ticket for this movie can review it")
+	ErrReviewAlreadyExists = errors.New("you already reviewed this movie")
+)
+
+// ReviewStatuses are the filters of the moderation list
+var ReviewStatuses = []string{"flagged", "hidden", "all"}
+
+type ReviewRepository struct {
+	db    *pgxpool.Pool
+	cache *utils.TaggedCache
+}
+
+func NewReviewRepository(db *pgxpool.Pool, rdb *redis.Client) *ReviewRepository {
+	return &ReviewRepository{db: db, cache: utils.NewTaggedCache(rdb)}
+}
+
+// reviewSelect is shared by every query that returns a review, r is public.reviews
+const reviewSelect = `
+	SELECT
+		r.id,
+		r.movie_id,
+		r.user_id,
+		COALESCE(NULLIF(TRIM(CONCAT(up.first_name, ' ', up.last_name)), ''), 'Tickitz User') AS author,
+		r.rating,
+		r.body,
+		EXISTS (
+			SELECT 1
+			FROM transactions t
+			JOIN schedules s ON t.schedule_id = s.id
+			WHERE t.user_id = r.user_id AND s.movie_id = r.movie_id AND t.scanned_at IS NOT NULL
+		) AS verified,
+		r.hidden_at,
+		r.flagged_at,
+		COALESCE(r.flag_reason, ''),
+		r.created_at,
+		r.updated_at,
+		r.created_at::text AS sort_key
+	FROM reviews r
+	LEFT JOIN user_profiles up ON up.user_id = r.user_id`
+
+func scanReview(row pgx.Row, review *models.Review, sortKey *string) error {
+	return row.Scan(
+		&review.ID,
+		&review.MovieID,
+		&review.UserID,
+		&review.Author,
+		&review.Rating,
+		&review.Body,
+		&review.Verified,
+		&review.HiddenAt,
+		&review.FlaggedAt,
+		&review.FlagReason,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+		sortKey,
+	)
+}
+
+// ListMovieReviews returns the visible reviews of a movie, newest first
+func (r *ReviewRepository) ListMovieReviews(ctx context.Context, movieID int, params pagination.Params) (pagination.Page[models.Review], error) {
+	page, err := r.listReviews(ctx, "r.movie_id = $1 AND r.hidden_at IS NULL", []any{movieID}, params)
+	if err != nil {
+		return page, err
+	}
+
+	// the user id is only needed by the moderators
+	for i := range page.Items {
+		page.Items[i].UserID = ""
+		page.Items[i].FlaggedAt = nil
+		page.Items[i].FlagReason = ""
+	}
+	return page, nil
+}
+
+// ListReviewsForModeration returns flagged, hidden or all reviews, newest first
+func (r *ReviewRepository) ListReviewsForModeration(ctx context.Context, status string, params pagination.Params) (pagination.Page[models.Review], error) {
+	where := "TRUE"
+	switch status {
+	case "flagged":
+		where = "r.flagged_at IS NOT NULL"
+	case "hidden":
+		where = "r.hidden_at IS NOT NULL"
+	}
+	return r.listReviews(ctx, where, []any{}, params)
+}
+
+// listReviews pages through the reviews matching where, args are the parameters of where
+func (r *ReviewRepository) listReviews(ctx context.Context, where string, args []any, params pagination.Params) (pagination.Page[models.Review], error) {
+	var total int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM reviews r WHERE "+where, args...).Scan(&total); err != nil {
+		return pagination.Page[models.Review]{}, err
+	}
+
+	cursor, err := params.CursorFor("created_at:desc")
+	if err != nil {
+		return pagination.Page[models.Review]{}, err
+	}
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		where += " AND (r.created_at, r.id) < ($" + strconv.Itoa(len(args)-1) + "::timestamptz, $" + strconv.Itoa(len(args)) + "::int4)"
+	}
+	args = append(args, params.Offset(), params.Limit())
+
+	query := reviewSelect + `
+	WHERE ` + where + `
+	ORDER BY r.created_at DESC, r.id DESC
+	OFFSET $` + strconv.Itoa(len(args)-1) + ` LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		if cursor != nil {
+			return pagination.Page[models.Review]{}, cursorError(err)
+		}
+		return pagination.Page[models.Review]{}, err
+	}
+	defer rows.Close()
+
+	var reviews []models.Review
+	var sortKeys []string
+	for rows.Next() {
+		var review models.Review
+		var key string
+		if err := scanReview(rows, &review, &key); err != nil {
+			return pagination.Page[models.Review]{}, err
+		}
+		reviews = append(reviews, review)
+		sortKeys = append(sortKeys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.Review]{}, err
+	}
+
+	return pagination.NewPage(reviews, params, total, func(i int) pagination.Cursor {
+		return pagination.Cursor{Sort: "created_at:desc", Value: sortKeys[i], ID: strconv.Itoa(reviews[i].ID)}
+	}), nil
+}
+
+// CreateReview only inserts when the user has a paid ticket for the movie, one review per user and movie
+func (r *ReviewRepository) CreateReview(ctx context.Context, movieID int, userID string, req models.ReviewRequest) (models.Review, error) {
+	query := `
+		INSERT INTO reviews (movie_id, user_id, rating, body)
+		SELECT $1, $2, $3, $4
+		WHERE EXISTS (
+			SELECT 1
+			FROM transactions t
+			JOIN schedules s ON t.schedule_id = s.id
+			WHERE t.user_id = $2 AND s.movie_id = $1 AND t.paid_at IS NOT NULL
+		)
+		RETURNING id`
+
+	var reviewID int
+	if err := r.db.QueryRow(ctx, query, movieID, userID, req.Rating, req.Body).Scan(&reviewID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Review{}, ErrReviewNotAllowed
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			return models.Review{}, ErrReviewAlreadyExists
+		}
+		return models.Review{}, err
+	}
+
+	r.invalidateMovieCaches(ctx, movieID)
+	return r.getReview(ctx, reviewID)
+}
+
+// UpdateOwnReview edits a review of the user, a hidden review stays hidden
+func (r *ReviewRepository) UpdateOwnReview(ctx context.Context, reviewID int, userID string, req models.ReviewRequest) (models.Review, error) {
+	query := `
+		UPDATE reviews
+		SET
+			rating = $3,
+			body = $4,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2
+		RETURNING id, movie_id`
+
+	var movieID int
+	if err := r.db.QueryRow(ctx, query, reviewID, userID, req.Rating, req.Body).Scan(&reviewID, &movieID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Review{}, ErrReviewNotFound
+		}
+		return models.Review{}, err
+	}
+
+	r.invalidateMovieCaches(ctx, movieID)
+	return r.getReview(ctx, reviewID)
+}
+
+func (r *ReviewRepository) DeleteOwnReview(ctx context.Context, reviewID int, userID string) error {
+	var movieID int
+	err := r.db.QueryRow(ctx, "DELETE FROM reviews WHERE id = $1 AND user_id = $2 RETURNING movie_id", reviewID, userID).Scan(&movieID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrReviewNotFound
+		}
+		return err
+	}
+
+	r.invalidateMovieCaches(ctx, movieID)
+	return nil
+}
+
+// ModerateReview hides / unhides or flags / unflags a review, hidden reviews don't count in the average
+func (r *ReviewRepository) ModerateReview(ctx context.Context, reviewID int, req models.ModerateReviewRequest) (models.Review, error) {
+	var set string
+	args := []any{reviewID}
+	switch req.Action {
+	case "hide":
+		set = "hidden_at = COALESCE(hidden_at, CURRENT_TIMESTAMP)"
+	case "unhide":
+		set = "hidden_at = NULL"
+	case "flag":
+		set = "flagged_at = COALESCE(flagged_at, CURRENT_TIMESTAMP), flag_reason = NULLIF($2, '')"
+		args = append(args, req.Reason)
+	case "unflag":
+		set = "flagged_at = NULL, flag_reason = NULL"
+	default:
+		return models.Review{}, errors.New("unknown moderation action " + req.Action)
+	}
+
+	var movieID int
+	if err := r.db.QueryRow(ctx, "UPDATE reviews SET "+set+" WHERE id = $1 RETURNING id, movie_id", args...).Scan(&reviewID, &movieID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Review{}, ErrReviewNotFound
+		}
+		return models.Review{}, err
+	}
+
+	r.invalidateMovieCaches(ctx, movieID)
+	return r.getReview(ctx, reviewID)
+}
+
+func (r *ReviewRepository) getReview(ctx context.Context, reviewID int) (models.Review, error) {
+	var review models.Review
+	var key string
+	if err := scanReview(r.db.QueryRow(ctx, reviewSelect+" WHERE r.id = $1", reviewID), &review, &key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Review{}, ErrReviewNotFound
+		}
+		return models.Review{}, err
+	}
+	return review, nil
+}
+
+// invalidateMovieCaches drops the cached movie lists and the details of the movie, they contain the rating aggregate
+func (r *ReviewRepository) invalidateMovieCaches(ctx context.Context, movieID int) {
+	r.cache.Invalidate(ctx, utils.TagMovieLists, utils.MovieTag(movieID))
+}
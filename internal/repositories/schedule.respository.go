@@ -2,17 +2,22 @@ package repositories
 
 import (
 	"context"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/redis/go-redis/v9"
 )
 
 type ScheduleRepository struct {
-	db *pgxpool.Pool
+	db    *pgxpool.Pool
+	cache *utils.TaggedCache
 }
 
-func NewScheduleRepository(db *pgxpool.Pool) *ScheduleRepository {
-	return &ScheduleRepository{db: db}
+func NewScheduleRepository(db *pgxpool.Pool, rdb *redis.Client) *ScheduleRepository {
+	return &ScheduleRepository{db: db, cache: utils.NewTaggedCache(rdb)}
 }
 
 func (s *ScheduleRepository) ListCinemas(ctx context.Context) ([]models.Cinema, error) {
@@ -40,7 +45,28 @@ func (s *ScheduleRepository) ListCinemas(ctx context.Context) ([]models.Cinema,
 	return cinemas, nil
 }
 
+// FilterSchedule lists the schedules of the movie, cached until the movie or its schedules change
 func (s *ScheduleRepository) FilterSchedule(ctx context.Context, queryParam models.ScheduleFilter) ([]models.Schedule, error) {
+	movieID, err := strconv.Atoi(queryParam.MovieID)
+	if err != nil {
+		return s.fetchSchedules(ctx, queryParam)
+	}
+
+	var schedules []models.Schedule
+	err = s.cache.Fetch(
+		ctx,
+		"movie:"+strconv.Itoa(movieID)+":schedules",
+		[]string{utils.MovieTag(movieID)},
+		utils.CachePolicy{TTL: time.Hour, StaleFor: 10 * time.Minute},
+		&schedules,
+		func(ctx context.Context) (interface{}, error) {
+			return s.fetchSchedules(ctx, queryParam)
+		},
+	)
+	return schedules, err
+}
+
+func (s *ScheduleRepository) fetchSchedules(ctx context.Context, queryParam models.ScheduleFilter) ([]models.Schedule, error) {
 	query := `
 			SELECT 
 				s.id as schedule_id,
@@ -87,6 +113,9 @@ func (s *ScheduleRepository) FilterSchedule(ctx context.Context, queryParam mode
 		}
 		schedules = append(schedules, s)
 	}
+	if err := rows.Err(); err != nil {
+		return []models.Schedule{}, err
+	}
 	return schedules, nil
 }
 
@@ -97,6 +126,28 @@ func (s *ScheduleRepository) FilterSchedule(ctx context.Context, queryParam mode
 // --> Cari transactions_seats yang melekat pada transaction tersebut
 // --> Cari seat_codes yang melekat pada transaksi tersebut
 func (s *ScheduleRepository) GetSoldSeatsByScheduleID(ctx context.Context, scheduleID string) ([]string, error) {
+	id, err := strconv.Atoi(scheduleID)
+	if err != nil {
+		return s.fetchSoldSeats(ctx, scheduleID)
+	}
+
+	// a payment invalidates the schedule, so the cache never shows a sold seat as free.
+	// Never served stale, a seat map that is off is worse than a slower one.
+	var seatCodes []string
+	err = s.cache.Fetch(
+		ctx,
+		"schedule:"+strconv.Itoa(id)+":sold-seats",
+		[]string{utils.ScheduleTag(id)},
+		utils.CachePolicy{TTL: 10 * time.Minute},
+		&seatCodes,
+		func(ctx context.Context) (interface{}, error) {
+			return s.fetchSoldSeats(ctx, scheduleID)
+		},
+	)
+	return seatCodes, err
+}
+
+func (s *ScheduleRepository) fetchSoldSeats(ctx context.Context, scheduleID string) ([]string, error) {
 	query := `
 	WITH get_paid_transaction_id_by_schedule AS (
 			SELECT id AS transaction_id
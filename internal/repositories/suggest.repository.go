@@ -0,0 +1,259 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// Prefix index for the search box: one sorted set where every member has score 0,
+// so ZRANGEBYLEX returns the members that start with the typed prefix.
+// member = <term> \x00 <type> \x00 <id> \x00 <word position> \x00 <display text>
+const suggestKey = "tickitz:suggest"
+
+type SuggestRepository struct {
+	db  *pgxpool.Pool
+	rdb *redis.Client
+
+	mu      sync.Mutex
+	running bool
+	dirty   bool
+}
+
+func NewSuggestRepository(db *pgxpool.Pool, rdb *redis.Client) *SuggestRepository {
+	return &SuggestRepository{db: db, rdb: rdb}
+}
+
+// Suggest returns up to limit entries whose name (or a word inside it) starts with q
+func (s *SuggestRepository) Suggest(ctx context.Context, q string, limit int) ([]models.Suggestion, error) {
+	prefix := normalizeSuggestTerm(q)
+	if prefix == "" {
+		return []models.Suggestion{}, nil
+	}
+
+	members, err := s.rdb.ZRangeByLex(ctx, suggestKey, &redis.ZRangeBy{
+		Min:   "[" + prefix,
+		Max:   "[" + prefix + "\xff",
+		Count: int64(limit * 5),
+	}).Result()
+	if err != nil {
+		// redis tidak tersedia, jawab langsung dari db
+		log.Println("redis error, suggestions from db.\nCause: ", err.Error())
+		return s.suggestFromDB(ctx, prefix, limit)
+	}
+	if len(members) == 0 {
+		// index belum dibuat (redis baru / di-flush), jawab dari db sambil membangun index
+		exists, err := s.rdb.Exists(ctx, suggestKey).Result()
+		if err != nil {
+			log.Println("redis error, suggestions from db.\nCause: ", err.Error())
+			return s.suggestFromDB(ctx, prefix, limit)
+		}
+		if exists == 0 {
+			s.RefreshAsync()
+			return s.suggestFromDB(ctx, prefix, limit)
+		}
+		return []models.Suggestion{}, nil
+	}
+
+	type hit struct {
+		models.Suggestion
+		pos int
+	}
+	seen := map[string]bool{}
+	hits := []hit{}
+	for _, member := range members {
+		parts := strings.SplitN(member, "\x00", 5)
+		if len(parts) != 5 {
+			continue
+		}
+		key := parts[1] + ":" + parts[2]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		id, _ := strconv.Atoi(parts[2])
+		pos, _ := strconv.Atoi(parts[3])
+		hits = append(hits, hit{Suggestion: models.Suggestion{Type: parts[1], ID: id, Text: parts[4]}, pos: pos})
+	}
+
+	// matches at the start of the name come before matches of a later word
+	sort.SliceStable(hits, func(i, j int) bool {
+		return (hits[i].pos == 0) && (hits[j].pos != 0)
+	})
+
+	suggestions := make([]models.Suggestion, 0, limit)
+	for _, h := range hits {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = append(suggestions, h.Suggestion)
+	}
+	return suggestions, nil
+}
+
+// suggestFromDB is used while the index is unavailable. The names are normalized like normalizeSuggestTerm
+// ("Spider-Man: No Way Home" -> "spider man no way home"), and like the index it matches the start of the name
+// or of a later word, the matches at the start first.
+func (s *SuggestRepository) suggestFromDB(ctx context.Context, prefix string, limit int) ([]models.Suggestion, error) {
+	query := `
+		(SELECT 'movie', id, title, n.term LIKE $1 || '%' AS at_start
+		FROM movies, LATERAL (SELECT trim(regexp_replace(lower(title), '[^[:alnum:]]+', ' ', 'g')) AS term) n
+		WHERE status = 'published' AND (n.term LIKE $1 || '%' OR n.term LIKE '% ' || $1 || '%')
+		ORDER BY 4 DESC, title LIMIT $2)
+		UNION ALL
+		(SELECT 'person', id, name, n.term LIKE $1 || '%' AS at_start
+		FROM people, LATERAL (SELECT trim(regexp_replace(lower(name), '[^[:alnum:]]+', ' ', 'g')) AS term) n
+		WHERE n.term LIKE $1 || '%' OR n.term LIKE '% ' || $1 || '%'
+		ORDER BY 4 DESC, name LIMIT $2)
+		UNION ALL
+		(SELECT 'genre', id, name, n.term LIKE $1 || '%' AS at_start
+		FROM genres, LATERAL (SELECT trim(regexp_replace(lower(name), '[^[:alnum:]]+', ' ', 'g')) AS term) n
+		WHERE n.term LIKE $1 || '%' OR n.term LIKE '% ' || $1 || '%'
+		ORDER BY 4 DESC, name LIMIT $2)
+	`
+	rows, err := s.db.Query(ctx, query, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type hit struct {
+		models.Suggestion
+		atStart bool
+	}
+	hits := []hit{}
+	for rows.Next() {
+		var h hit
+		if err := rows.Scan(&h.Type, &h.ID, &h.Text, &h.atStart); err != nil {
+			return nil, err
+		}
+		hits = append(hits, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// across the three types the matches at the start come first too
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].atStart && !hits[j].atStart
+	})
+
+	suggestions := make([]models.Suggestion, 0, limit)
+	for _, h := range hits {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = append(suggestions, h.Suggestion)
+	}
+	return suggestions, nil
+}
+
+// RefreshAsync rebuilds the index in the background. Requests that come in while
+// a rebuild is running are merged into one more rebuild afterwards.
+func (s *SuggestRepository) RefreshAsync() {
+	s.mu.Lock()
+	if s.running {
+		s.dirty = true
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.mu.Unlock()
+
+	go func() {
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			if err := s.Rebuild(ctx); err != nil {
+				log.Printf("failed to rebuild suggest index: %v", err)
+			}
+			cancel()
+
+			s.mu.Lock()
+			if !s.dirty {
+				s.running = false
+				s.mu.Unlock()
+				return
+			}
+			s.dirty = false
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// Rebuild writes the complete index into a temporary key and swaps it in with RENAME,
+// readers never see a half built index
+func (s *SuggestRepository) Rebuild(ctx context.Context) error {
+	query := `
+		SELECT 'movie', id, title FROM movies WHERE status = 'published'
+		UNION ALL
+		SELECT 'person', id, name FROM people
+		UNION ALL
+		SELECT 'genre', id, name FROM genres
+	`
+	rows, err := s.db.Query(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	members := []redis.Z{}
+	for rows.Next() {
+		var kind, text string
+		var id int
+		if err := rows.Scan(&kind, &id, &text); err != nil {
+			return err
+		}
+
+		words := strings.Fields(normalizeSuggestTerm(text))
+		for pos := range words {
+			term := strings.Join(words[pos:], " ")
+			members = append(members, redis.Z{
+				Score:  0,
+				Member: fmt.Sprintf("%s\x00%s\x00%d\x00%d\x00%s", term, kind, id, pos, text),
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tmpKey := fmt.Sprintf("%s:building:%d", suggestKey, time.Now().UnixNano())
+	if len(members) == 0 {
+		return s.rdb.Del(ctx, suggestKey).Err()
+	}
+
+	for start := 0; start < len(members); start += 1000 {
+		end := min(start+1000, len(members))
+		if err := s.rdb.ZAdd(ctx, tmpKey, members[start:end]...).Err(); err != nil {
+			s.rdb.Del(ctx, tmpKey)
+			return err
+		}
+	}
+
+	if err := s.rdb.Rename(ctx, tmpKey, suggestKey).Err(); err != nil {
+		s.rdb.Del(ctx, tmpKey)
+		return err
+	}
+
+	log.Printf("suggest index rebuilt with %d entries", len(members))
+	return nil
+}
+
+// normalizeSuggestTerm lowercases and turns punctuation into single spaces ("Spider-Man: No" -> "spider man no")
+func normalizeSuggestTerm(s string) string {
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+)
+
+// The db answers while Redis or the index is unavailable, it has to find what the index would find
+func TestSuggestFromDBMatchesLikeTheIndex(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	marker := fmt.Sprintf("zqx%d", time.Now().UnixNano()%1_000_000_000)
+
+	var movieID, laterID, startID int
+	if err := db.QueryRow(ctx, `INSERT INTO movies (title, status) VALUES ($1, 'published') RETURNING id`,
+		"Spider-Man: "+marker+" Home").Scan(&movieID); err != nil {
+		t.Fatalf("insert movie: %v", err)
+	}
+	if err := db.QueryRow(ctx, `INSERT INTO people (name) VALUES ($1) RETURNING id`, "Mary "+marker).Scan(&laterID); err != nil {
+		t.Fatalf("insert person: %v", err)
+	}
+	if err := db.QueryRow(ctx, `INSERT INTO people (name) VALUES ($1) RETURNING id`, marker+"-Jones").Scan(&startID); err != nil {
+		t.Fatalf("insert person: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM movies WHERE id = $1`, movieID)
+		db.Exec(ctx, `DELETE FROM people WHERE id = ANY($1)`, []int{laterID, startID})
+	})
+
+	repo := NewSuggestRepository(db, nil)
+
+	got, err := repo.suggestFromDB(ctx, normalizeSuggestTerm("spider man "+marker), 10)
+	if err != nil {
+		t.Fatalf("suggestFromDB: %v", err)
+	}
+	if len(got) != 1 || got[0] != (models.Suggestion{Type: "movie", ID: movieID, Text: "Spider-Man: " + marker + " Home"}) {
+		t.Fatalf("\"spider man\" = %+v, want the movie with the hyphen", got)
+	}
+
+	got, err = repo.suggestFromDB(ctx, marker, 10)
+	if err != nil {
+		t.Fatalf("suggestFromDB: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("%s = %+v, want the movie and both people", marker, got)
+	}
+	if got[0].Type != "person" || got[0].ID != startID {
+		t.Errorf("first = %+v, want the name that starts with the prefix", got[0])
+	}
+}
@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+)
+
+var ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+
+type TwoFactorRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewTwoFactorRepository(db *pgxpool.Pool) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// GetTOTP returns pgx.ErrNoRows when the user never started an enrolment
+func (t *TwoFactorRepository) GetTOTP(ctx context.Context, userID string) (models.UserTOTP, error) {
+	query := `SELECT user_id, secret, enabled_at, last_used_step FROM user_totp WHERE user_id = $1`
+
+	var totp models.UserTOTP
+	if err := t.db.QueryRow(ctx, query, userID).Scan(&totp.UserID, &totp.Secret, &totp.EnabledAt, &totp.LastUsedStep); err != nil {
+		return models.UserTOTP{}, err
+	}
+	return totp, nil
+}
+
+func (t *TwoFactorRepository) IsEnabled(ctx context.Context, userID string) (bool, error) {
+	totp, err := t.GetTOTP(ctx, userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, err
+	}
+	return totp.EnabledAt != nil, nil
+}
+
+// SavePendingSecret stores a new secret that still has to be confirmed with a code.
+// An already enabled secret is never overwritten.
+func (t *TwoFactorRepository) SavePendingSecret(ctx context.Context, userID, secret string) error {
+	query := `
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET
+			secret = EXCLUDED.secret,
+			last_used_step = NULL,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_totp.enabled_at IS NULL
+	`
+	tag, err := t.db.Exec(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrTwoFactorAlreadyEnabled
+	}
+	return nil
+}
+
+// EnableTOTP activates the pending secret and stores the hashed recovery codes
+func (t *TwoFactorRepository) EnableTOTP(ctx context.Context, userID string, step uint64, codeHashes []string) error {
+	tx, err := t.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	query := `
+		UPDATE user_totp
+		SET
+			enabled_at = CURRENT_TIMESTAMP,
+			last_used_step = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND enabled_at IS NULL
+	`
+	tag, err := tx.Exec(ctx, query, userID, int64(step))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		err = ErrTwoFactorAlreadyEnabled
+		return err
+	}
+
+	if err = t.replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ReplaceRecoveryCodes invalidates every old recovery code of the user
+func (t *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := t.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	if err = t.replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (t *TwoFactorRepository) replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO user_recovery_codes (user_id, code_hash)
+		SELECT $1, UNNEST($2::text[])
+	`
+	_, err := tx.Exec(ctx, query, userID, codeHashes)
+	return err
+}
+
+// DisableTOTP removes the secret and all recovery codes
+func (t *TwoFactorRepository) DisableTOTP(ctx context.Context, userID string) error {
+	tx, err := t.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	if _, err = tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// MarkStepUsed returns false when the code of this step (or a later one) was already used
+func (t *TwoFactorRepository) MarkStepUsed(ctx context.Context, userID string, step uint64) (bool, error) {
+	query := `
+		UPDATE user_totp
+		SET last_used_step = $2
+		WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)
+	`
+	tag, err := t.db.Exec(ctx, query, userID, int64(step))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// UseRecoveryCode consumes a recovery code, every code only works once
+func (t *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	query := `
+		UPDATE user_recovery_codes
+		SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+	tag, err := t.db.Exec(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMarkStepUsedRejectsReplay(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+
+	var userID string
+	if err := db.QueryRow(ctx,
+		`INSERT INTO users (email, password) VALUES ('totp-replay-' || gen_random_uuid() || '@test.local', 'x') RETURNING id`,
+	).Scan(&userID); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM user_totp WHERE user_id = $1`, userID)
+		db.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	})
+	if _, err := db.Exec(ctx,
+		`INSERT INTO user_totp (user_id, secret, enabled_at) VALUES ($1, 'GEZDGNBVGY3TQOJQ', CURRENT_TIMESTAMP)`, userID,
+	); err != nil {
+		t.Fatalf("insert totp: %v", err)
+	}
+
+	repo := NewTwoFactorRepository(db)
+	steps := []struct {
+		name string
+		step uint64
+		want bool
+	}{
+		{name: "first use", step: 100, want: true},
+		{name: "same step again", step: 100, want: false},
+		{name: "earlier step", step: 99, want: false},
+		{name: "later step", step: 101, want: true},
+	}
+	for _, s := range steps {
+		got, err := repo.MarkStepUsed(ctx, userID, s.step)
+		if err != nil {
+			t.Fatalf("%s: MarkStepUsed: %v", s.name, err)
+		}
+		if got != s.want {
+			t.Errorf("%s: MarkStepUsed(%d) = %v, want %v", s.name, s.step, got, s.want)
+		}
+	}
+}
@@ -5,13 +5,22 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrUnverifiedIdentityEmail is returned when a new social login has no verified email
+var ErrUnverifiedIdentityEmail = errors.New("email of the external account is not verified")
+
 type UserRepository struct {
 	db  *pgxpool.Pool
 	rdb *redis.Client
@@ -89,7 +98,8 @@ func (u *UserRepository) GetIDFromEmail(ctx context.Context, email string) (mode
 }
 
 func (u *UserRepository) GetPasswordFromID(ctx context.Context, id string) (models.User, error) {
-	query := `SELECT role, password FROM users WHERE id = $1`
+	// user dari social login tidak punya password
+	query := `SELECT role, COALESCE(password, '') FROM users WHERE id = $1`
 
 	var user models.User
 
@@ -99,6 +109,41 @@ func (u *UserRepository) GetPasswordFromID(ctx context.Context, id string) (mode
 	return user, nil
 }
 
+// GetPermissions returns the permissions for the token: from the base role (users.role)
+// and from user_roles, where a cinema scope becomes "code@<cinema_id>"
+func (u *UserRepository) GetPermissions(ctx context.Context, userID string) ([]string, error) {
+	query := `
+		SELECT DISTINCT rp.permission_code
+		FROM users u
+		JOIN roles r ON r.name = u.role::text
+		JOIN role_permissions rp ON rp.role_id = r.id
+		WHERE u.id = $1
+		UNION
+		SELECT DISTINCT
+			CASE WHEN ur.cinema_id IS NULL THEN rp.permission_code
+			ELSE rp.permission_code || '@' || ur.cinema_id END
+		FROM user_roles ur
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		WHERE ur.user_id = $1
+		ORDER BY 1`
+
+	rows, err := u.db.Query(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []string
+	for rows.Next() {
+		var perm string
+		if err := rows.Scan(&perm); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, perm)
+	}
+	return permissions, rows.Err()
+}
+
 // GetProfileByUserID fetches a user's profile from user_profiles by user_id
 func (u *UserRepository) GetProfile(ctx context.Context, userID string) (models.UserProfile, error) {
 	query := `
@@ -127,24 +172,32 @@ func (u *UserRepository) GetProfile(ctx context.Context, userID string) (models.
 	); err != nil {
 		return models.UserProfile{}, fmt.Errorf("profile not found or error fetching profile: %w", err)
 	}
+	p.ImgURLs = utils.ImageURLs("profile_pics", p.Img)
 	return p, nil
 }
 
-func (u *UserRepository) EditProfile(ctx context.Context, userID string, body models.EditUserProfile, imagePath string) (models.UserProfile, error) {
-	sql := "UPDATE user_profiles SET "
+// EditProfile updates the fields that are sent, a field sent as null is cleared. imagePath is only written
+// when an image was uploaded. It also returns the image that was replaced so the caller can remove it from the storage.
+func (u *UserRepository) EditProfile(ctx context.Context, userID string, body models.EditUserProfile, imagePath string) (models.UserProfile, string, error) {
+	sql := "UPDATE user_profiles up SET "
 	values := []any{}
 
-	if body.FirstName != "" {
-		sql += fmt.Sprintf("%s=$%d, ", "first_name", len(values)+1)
-		values = append(values, body.FirstName)
-	}
-	if body.LastName != "" {
-		sql += fmt.Sprintf("%s=$%d, ", "last_name", len(values)+1)
-		values = append(values, body.LastName)
-	}
-	if body.PhoneNumber != "" {
-		sql += fmt.Sprintf("%s=$%d, ", "phone_number", len(values)+1)
-		values = append(values, body.PhoneNumber)
+	for _, f := range []struct {
+		column string
+		value  models.OptionalString
+	}{
+		{"first_name", body.FirstName},
+		{"last_name", body.LastName},
+		{"phone_number", body.PhoneNumber},
+	} {
+		switch {
+		case !f.value.Set:
+		case f.value.Null:
+			sql += f.column + "=NULL, "
+		default:
+			sql += fmt.Sprintf("%s=$%d, ", f.column, len(values)+1)
+			values = append(values, f.value.Value)
+		}
 	}
 	// if you decide to save image filename:
 	if body.Img != nil {
@@ -152,22 +205,25 @@ func (u *UserRepository) EditProfile(ctx context.Context, userID string, body mo
 		values = append(values, imagePath)
 	}
 
-	// sql += fmt.Sprintf("updated_at=CURRENT_TIMESTAMP WHERE user_id=$%d RETURNING user_id, first_name, last_name, img, phone_number, points, created_at, updated_at", len(values)+1)
+	// old is the row before the update, RETURNING only sees the new values of up
 	sql += fmt.Sprintf(`updated_at=CURRENT_TIMESTAMP 
-    WHERE user_id=$%d 
+    FROM (SELECT user_id, img FROM user_profiles WHERE user_id=$%d FOR UPDATE) old
+    WHERE up.user_id = old.user_id
     RETURNING 
-        user_id, 
-        COALESCE(first_name, ''), 
-        COALESCE(last_name, ''), 
-        COALESCE(img, ''), 
-        COALESCE(phone_number, ''), 
-        COALESCE(points, 0), 
-        created_at, 
-        updated_at`, len(values)+1)
+        up.user_id, 
+        COALESCE(up.first_name, ''), 
+        COALESCE(up.last_name, ''), 
+        COALESCE(up.img, ''), 
+        COALESCE(up.phone_number, ''), 
+        COALESCE(up.points, 0), 
+        up.created_at, 
+        up.updated_at,
+        COALESCE(old.img, '')`, len(values)+1)
 
 	values = append(values, userID)
 
 	var profile models.UserProfile
+	var oldImg string
 	if err := u.db.QueryRow(ctx, sql, values...).Scan(
 		&profile.UserID,
 		&profile.FirstName,
@@ -177,11 +233,192 @@ func (u *UserRepository) EditProfile(ctx context.Context, userID string, body mo
 		&profile.Points,
 		&profile.CreatedAt,
 		&profile.UpdatedAt,
+		&oldImg,
 	); err != nil {
-		return models.UserProfile{}, err
+		return models.UserProfile{}, "", err
+	}
+
+	if body.Img == nil {
+		oldImg = ""
+	}
+	profile.ImgURLs = utils.ImageURLs("profile_pics", profile.Img)
+	return profile, oldImg, nil
+}
+
+// RemoveAvatar clears the profile picture and returns the filename it had, empty when there was none
+func (u *UserRepository) RemoveAvatar(ctx context.Context, userID string) (string, error) {
+	query := `
+		UPDATE user_profiles up SET img = NULL, updated_at = CURRENT_TIMESTAMP
+		FROM (SELECT user_id, img FROM user_profiles WHERE user_id = $1 FOR UPDATE) old
+		WHERE up.user_id = old.user_id
+		RETURNING COALESCE(old.img, '')`
+
+	var oldImg string
+	if err := u.db.QueryRow(ctx, query, userID).Scan(&oldImg); err != nil {
+		return "", fmt.Errorf("failed to remove profile picture: %w", err)
+	}
+	return oldImg, nil
+}
+
+var (
+	// ErrEmailTaken is returned when the new email already belongs to an account
+	ErrEmailTaken = errors.New("email is already used by another account")
+	// ErrEmailChangeNotFound is returned for a confirmation token that is unknown, used or expired
+	ErrEmailChangeNotFound = errors.New("invalid or expired email confirmation token")
+)
+
+// RequestEmailChange stores the pending change (a previous one of the user is replaced) and queues two
+// notifications in the same transaction: the link for the new address and a notice for the current one.
+// The link holds the token itself, only its hash goes into email_changes.
+func (u *UserRepository) RequestEmailChange(ctx context.Context, userID, newEmail, tokenHash, link string, expiresAt time.Time) (err error) {
+	tx, err := u.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	var taken bool
+	if err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE lower(email) = lower($1))`, newEmail).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		err = ErrEmailTaken
+		return err
+	}
+
+	query := `
+		INSERT INTO email_changes (user_id, new_email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET
+			new_email = EXCLUDED.new_email,
+			token_hash = EXCLUDED.token_hash,
+			expires_at = EXCLUDED.expires_at,
+			created_at = CURRENT_TIMESTAMP`
+	if _, err = tx.Exec(ctx, query, userID, newEmail, tokenHash, expiresAt); err != nil {
+		return err
+	}
+
+	// payload.to sends the confirmation to the new address instead of users.email
+	query = `
+		INSERT INTO notifications (user_id, kind, dedupe_key, title, message, payload)
+		VALUES
+			($1, 'email_change_verify', 'email_change_verify:' || $3::text, 'Konfirmasi email baru kamu',
+				'Buka link berikut untuk memakai email ini di akun Tickitz kamu: ' || $4::text,
+				jsonb_build_object('to', $2::text, 'link', $4::text)),
+			($1, 'email_change_requested', 'email_change_requested:' || $3::text, 'Permintaan ganti email',
+				'Ada permintaan untuk mengganti email akun Tickitz kamu ke ' || $2::text || '. Abaikan jika itu kamu, jika bukan segera ganti password kamu.',
+				jsonb_build_object('new_email', $2::text))`
+	if _, err = tx.Exec(ctx, query, userID, newEmail, tokenHash, link); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// ConfirmEmailChange applies the pending change of the token and returns the user and the new email.
+// The token can only be used once.
+func (u *UserRepository) ConfirmEmailChange(ctx context.Context, tokenHash string) (userID, newEmail string, err error) {
+	tx, err := u.db.Begin(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	query := `
+		DELETE FROM email_changes
+		WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id, new_email`
+	if err = tx.QueryRow(ctx, query, tokenHash).Scan(&userID, &newEmail); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = ErrEmailChangeNotFound
+		}
+		return "", "", err
+	}
+
+	// the address may have been registered by someone else since the request
+	if _, err = tx.Exec(ctx, `UPDATE users SET email = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`, newEmail, userID); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" { // unique_violation
+			err = ErrEmailTaken
+		}
+		return "", "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", "", err
+	}
+	return userID, newEmail, nil
+}
+
+// DeleteAccount removes the user with the profile and the login data. Orders are kept for the reports but
+// lose the contact data, their user_id becomes NULL (ON DELETE SET NULL). Reviews, watchlist entries,
+// notifications, pending email changes, the login history and data exports are removed by their foreign keys.
+// It returns the profile picture so the caller can remove it from the storage.
+func (u *UserRepository) DeleteAccount(ctx context.Context, userID string) (img string, err error) {
+	tx, err := u.db.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	if err = tx.QueryRow(ctx, `SELECT COALESCE(img, '') FROM user_profiles WHERE user_id = $1`, userID).Scan(&img); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return "", err
 	}
 
-	return profile, nil
+	if _, err = tx.Exec(ctx, `
+		UPDATE transactions
+		SET full_name = NULL, email = NULL, phone_number = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1`, userID); err != nil {
+		return "", err
+	}
+
+	// the tables without ON DELETE CASCADE
+	for _, table := range []string{"user_profiles", "user_totp", "user_recovery_codes", "user_identities", "user_roles"} {
+		if _, err = tx.Exec(ctx, "DELETE FROM "+table+" WHERE user_id = $1", userID); err != nil {
+			return "", err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return "", err
+	}
+	if tag.RowsAffected() == 0 {
+		err = pgx.ErrNoRows
+		return "", err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return "", err
+	}
+	return img, nil
+}
+
+// RecordLogin adds a successful login to the login history
+func (u *UserRepository) RecordLogin(ctx context.Context, userID, method, ipAddress, userAgent string) error {
+	query := `INSERT INTO login_events (user_id, method, ip_address, user_agent) VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))`
+	if _, err := u.db.Exec(ctx, query, userID, method, ipAddress, userAgent); err != nil {
+		return fmt.Errorf("failed to record login: %w", err)
+	}
+	return nil
 }
 
 func (u *UserRepository) UpdatePassword(ctx context.Context, userID, hashedPassword string) error {
@@ -192,3 +429,344 @@ func (u *UserRepository) UpdatePassword(ctx context.Context, userID, hashedPassw
 	}
 	return nil
 }
+
+// RehashPassword replaces the hash only if it wasn't changed in the meantime (e.g. by ChangePassword)
+func (u *UserRepository) RehashPassword(ctx context.Context, userID, oldHash, newHash string) error {
+	query := `UPDATE users SET password = $1 WHERE id = $2 AND password = $3`
+	if _, err := u.db.Exec(ctx, query, newHash, userID, oldHash); err != nil {
+		return fmt.Errorf("failed to rehash password: %w", err)
+	}
+	return nil
+}
+
+var (
+	// ErrUserNotFound is returned by the admin user management for an unknown user id
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserWithoutPassword is returned when a password reset is forced on an account from a social login
+	ErrUserWithoutPassword = errors.New("user has no password, it logs in with a social login")
+)
+
+// GetAccountStatus returns the suspension and the forced password reset of the user, checked at login
+func (u *UserRepository) GetAccountStatus(ctx context.Context, userID string) (models.AccountStatus, error) {
+	query := `SELECT suspended_at, password_reset_required FROM users WHERE id = $1`
+
+	var status models.AccountStatus
+	if err := u.db.QueryRow(ctx, query, userID).Scan(&status.SuspendedAt, &status.PasswordResetRequired); err != nil {
+		return models.AccountStatus{}, fmt.Errorf("failed to get account status: %w", err)
+	}
+	return status, nil
+}
+
+// ResetPassword sets the password chosen after a forced reset and clears the flag
+func (u *UserRepository) ResetPassword(ctx context.Context, userID, hashedPassword string) error {
+	query := `
+		UPDATE users SET password = $1, password_reset_required = false, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2 AND password_reset_required`
+	tag, err := u.db.Exec(ctx, query, hashedPassword, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+const adminUserSelect = `
+	SELECT
+		u.id,
+		u.email,
+		u.role::text,
+		COALESCE(up.first_name, ''),
+		COALESCE(up.last_name, ''),
+		COALESCE(up.phone_number, ''),
+		COALESCE(up.points, 0),
+		u.suspended_at,
+		COALESCE(u.suspended_reason, ''),
+		u.password_reset_required,
+		COALESCE(u.created_at, '-infinity'),
+		COALESCE(u.created_at, '-infinity')::text AS sort_key
+	FROM users u
+	LEFT JOIN user_profiles up ON up.user_id = u.id`
+
+func scanAdminUser(row pgx.Row, user *models.AdminUser, extra ...any) error {
+	return row.Scan(append([]any{
+		&user.ID,
+		&user.Email,
+		&user.Role,
+		&user.FirstName,
+		&user.LastName,
+		&user.PhoneNumber,
+		&user.Points,
+		&user.SuspendedAt,
+		&user.SuspendedReason,
+		&user.PasswordResetRequired,
+		&user.CreatedAt,
+	}, extra...)...)
+}
+
+// ListUsers pages through the users for the admins, newest first. q searches the email and the name.
+func (u *UserRepository) ListUsers(ctx context.Context, filter models.AdminUserFilter, params pagination.Params) (pagination.Page[models.AdminUser], error) {
+	where := "TRUE"
+	args := []any{}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		args = append(args, q)
+		n := strconv.Itoa(len(args))
+		where += " AND (position(lower($" + n + ") in lower(u.email)) > 0" +
+			" OR position(lower($" + n + ") in lower(concat_ws(' ', up.first_name, up.last_name))) > 0)"
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		where += " AND u.role = $" + strconv.Itoa(len(args)) + "::role_type"
+	}
+	switch filter.Status {
+	case "active":
+		where += " AND u.suspended_at IS NULL"
+	case "suspended":
+		where += " AND u.suspended_at IS NOT NULL"
+	}
+
+	var total int
+	if err := u.db.QueryRow(ctx, "SELECT COUNT(*) FROM users u LEFT JOIN user_profiles up ON up.user_id = u.id WHERE "+where, args...).Scan(&total); err != nil {
+		return pagination.Page[models.AdminUser]{}, err
+	}
+
+	cursor, err := params.CursorFor("created_at:desc")
+	if err != nil {
+		return pagination.Page[models.AdminUser]{}, err
+	}
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		where += " AND (COALESCE(u.created_at, '-infinity'), u.id) < ($" + strconv.Itoa(len(args)-1) + "::timestamptz, $" + strconv.Itoa(len(args)) + "::uuid)"
+	}
+	args = append(args, params.Offset(), params.Limit())
+
+	query := adminUserSelect + `
+	WHERE ` + where + `
+	ORDER BY COALESCE(u.created_at, '-infinity') DESC, u.id DESC
+	OFFSET $` + strconv.Itoa(len(args)-1) + ` LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := u.db.Query(ctx, query, args...)
+	if err != nil {
+		if cursor != nil {
+			return pagination.Page[models.AdminUser]{}, cursorError(err)
+		}
+		return pagination.Page[models.AdminUser]{}, err
+	}
+	defer rows.Close()
+
+	var users []models.AdminUser
+	var sortKeys []string
+	for rows.Next() {
+		var user models.AdminUser
+		var key string
+		if err := scanAdminUser(rows, &user, &key); err != nil {
+			return pagination.Page[models.AdminUser]{}, err
+		}
+		users = append(users, user)
+		sortKeys = append(sortKeys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.AdminUser]{}, err
+	}
+
+	return pagination.NewPage(users, params, total, func(i int) pagination.Cursor {
+		return pagination.Cursor{Sort: "created_at:desc", Value: sortKeys[i], ID: users[i].ID}
+	}), nil
+}
+
+// GetUserDetail returns the user with the profile, 2FA, the number of orders and the last login.
+// The extra roles are filled by the caller.
+func (u *UserRepository) GetUserDetail(ctx context.Context, userID string) (models.AdminUserDetail, error) {
+	query := strings.Replace(adminUserSelect, "AS sort_key", `AS sort_key,
+		COALESCE(up.img, ''),
+		u.password IS NOT NULL AND u.password <> '',
+		EXISTS (SELECT 1 FROM user_totp t WHERE t.user_id = u.id AND t.enabled_at IS NOT NULL),
+		(SELECT COUNT(*) FROM transactions t WHERE t.user_id = u.id),
+		(SELECT MAX(l.created_at) FROM login_events l WHERE l.user_id = u.id)`, 1) + `
+	WHERE u.id = $1`
+
+	var user models.AdminUserDetail
+	var key string
+	if err := scanAdminUser(u.db.QueryRow(ctx, query, userID), &user.AdminUser,
+		&key, &user.Img, &user.HasPassword, &user.TwoFactorEnabled, &user.OrderCount, &user.LastLoginAt,
+	); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.Is(err, pgx.ErrNoRows) || (errors.As(err, &pgErr) && pgErr.Code == "22P02") { // not a uuid
+			return models.AdminUserDetail{}, ErrUserNotFound
+		}
+		return models.AdminUserDetail{}, err
+	}
+	user.ImgURLs = utils.ImageURLs("profile_pics", user.Img)
+	return user, nil
+}
+
+// UserExists tells whether there is a user with the id, an id that is not a uuid does not exist either
+func (u *UserRepository) UserExists(ctx context.Context, userID string) (bool, error) {
+	var exists bool
+	if err := u.db.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id::text = $1)`, userID).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// updateUser runs an UPDATE on one user and turns "no row" into ErrUserNotFound
+func (u *UserRepository) updateUser(ctx context.Context, query string, args ...any) error {
+	tag, err := u.db.Exec(ctx, query, args...)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" { // not a uuid
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// ChangeRole sets the base role of the user (users.role)
+func (u *UserRepository) ChangeRole(ctx context.Context, userID, role string) error {
+	return u.updateUser(ctx, `UPDATE users SET role = $2::role_type, updated_at = CURRENT_TIMESTAMP WHERE id = $1`, userID, role)
+}
+
+// SuspendUser blocks the login of the user, a suspended user keeps the first suspension date
+func (u *UserRepository) SuspendUser(ctx context.Context, userID, reason string) error {
+	return u.updateUser(ctx, `
+		UPDATE users SET suspended_at = COALESCE(suspended_at, CURRENT_TIMESTAMP), suspended_reason = $2, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, userID, reason)
+}
+
+// ReactivateUser lifts the suspension
+func (u *UserRepository) ReactivateUser(ctx context.Context, userID string) error {
+	return u.updateUser(ctx, `
+		UPDATE users SET suspended_at = NULL, suspended_reason = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, userID)
+}
+
+// RequirePasswordReset makes the next password login set a new password and notifies the user
+func (u *UserRepository) RequirePasswordReset(ctx context.Context, userID string) error {
+	query := `
+		WITH reset AS (
+			UPDATE users SET password_reset_required = true, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND password IS NOT NULL AND password <> ''
+			RETURNING id
+		)
+		INSERT INTO notifications (user_id, kind, dedupe_key, title, message)
+		SELECT id, 'password_reset_required', 'password_reset_required:' || id || ':' || extract(epoch FROM CURRENT_TIMESTAMP),
+			'Password kamu perlu diganti',
+			'Demi keamanan akun, kamu harus membuat password baru saat login berikutnya.'
+		FROM reset
+		ON CONFLICT (dedupe_key) DO NOTHING
+		RETURNING user_id`
+
+	var id string
+	if err := u.db.QueryRow(ctx, query, userID).Scan(&id); err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "22P02" { // not a uuid
+			return ErrUserNotFound
+		}
+		if !errors.Is(err, pgx.ErrNoRows) {
+			return err
+		}
+		// nothing updated: unknown user or one without password
+		var hasPassword bool
+		if err := u.db.QueryRow(ctx, `SELECT password IS NOT NULL AND password <> '' FROM users WHERE id = $1`, userID).Scan(&hasPassword); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		if !hasPassword {
+			return ErrUserWithoutPassword
+		}
+	}
+	return nil
+}
+
+// FindOrCreateUserByIdentity returns the user linked to the external identity.
+// An unknown identity is linked to the user with the same (verified) email,
+// otherwise a new user without password is created together with its profile.
+func (u *UserRepository) FindOrCreateUserByIdentity(ctx context.Context, identity models.ExternalIdentity) (models.User, error) {
+	tx, err := u.db.Begin(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(ctx); rollbackErr != nil {
+				log.Println("failed to rollback transaction: ", rollbackErr)
+			}
+		}
+	}()
+
+	var user models.User
+
+	// Step 1: Identity sudah pernah login
+	query := `
+		UPDATE user_identities ui
+		SET last_login_at = CURRENT_TIMESTAMP, email = $3
+		FROM users u
+		WHERE ui.user_id = u.id AND ui.provider = $1 AND ui.subject = $2
+		RETURNING u.id, u.email, u.role`
+	err = tx.QueryRow(ctx, query, identity.Provider, identity.Subject, identity.Email).Scan(&user.Id, &user.Email, &user.Role)
+	if err == nil {
+		err = tx.Commit(ctx)
+		return user, err
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return models.User{}, err
+	}
+	err = nil
+
+	// Step 2: Link ke user yang sudah ada, hanya kalau provider sudah memverifikasi email
+	linked := false
+	if identity.EmailVerified && identity.Email != "" {
+		err = tx.QueryRow(ctx, `SELECT id, email, role FROM users WHERE lower(email) = lower($1)`, identity.Email).Scan(&user.Id, &user.Email, &user.Role)
+		switch {
+		case err == nil:
+			linked = true
+		case errors.Is(err, pgx.ErrNoRows):
+			err = nil
+		default:
+			return models.User{}, err
+		}
+	}
+
+	// Step 3: Buat user baru + profile
+	if !linked {
+		if identity.Email == "" || !identity.EmailVerified {
+			err = ErrUnverifiedIdentityEmail
+			return models.User{}, err
+		}
+
+		query = `INSERT INTO users (email, password) VALUES ($1, NULL) RETURNING id, email, role`
+		if err = tx.QueryRow(ctx, query, identity.Email).Scan(&user.Id, &user.Email, &user.Role); err != nil {
+			return models.User{}, fmt.Errorf("failed to register user: %w", err)
+		}
+
+		if _, err = u.createProfile(ctx, tx, user.Id); err != nil {
+			return models.User{}, err
+		}
+
+		query = `
+			UPDATE user_profiles
+			SET first_name = NULLIF($2, ''), last_name = NULLIF($3, '')
+			WHERE user_id = $1`
+		if _, err = tx.Exec(ctx, query, user.Id, identity.FirstName, identity.LastName); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	query = `INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)`
+	if _, err = tx.Exec(ctx, query, user.Id, identity.Provider, identity.Subject, identity.Email); err != nil {
+		return models.User{}, fmt.Errorf("failed to link identity: %w", err)
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
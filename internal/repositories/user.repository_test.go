@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+)
+
+func TestFindOrCreateUserByIdentityLinksOnlyVerifiedEmail(t *testing.T) {
+	db := testDB(t)
+	ctx := context.Background()
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	email := "oidc-link-" + suffix + "@test.local"
+
+	var userID string
+	if err := db.QueryRow(ctx, `INSERT INTO users (email, password) VALUES ($1, 'x') RETURNING id`, email).Scan(&userID); err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Exec(ctx, `DELETE FROM user_identities WHERE user_id = $1`, userID)
+		db.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	})
+
+	repo := NewUserRepository(db, nil)
+
+	// same email, but the provider did not verify it: somebody else could have registered it there
+	_, err := repo.FindOrCreateUserByIdentity(ctx, models.ExternalIdentity{
+		Provider: "mock", Subject: "unverified-" + suffix, Email: email, EmailVerified: false,
+	})
+	if !errors.Is(err, ErrUnverifiedIdentityEmail) {
+		t.Fatalf("unverified email: err = %v, want ErrUnverifiedIdentityEmail", err)
+	}
+	var identities int
+	if err := db.QueryRow(ctx, `SELECT count(*) FROM user_identities WHERE user_id = $1`, userID).Scan(&identities); err != nil {
+		t.Fatalf("count identities: %v", err)
+	}
+	if identities != 0 {
+		t.Fatalf("unverified identity was linked to the existing user")
+	}
+
+	user, err := repo.FindOrCreateUserByIdentity(ctx, models.ExternalIdentity{
+		Provider: "mock", Subject: "verified-" + suffix, Email: email, EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("verified email: %v", err)
+	}
+	if user.Id != userID {
+		t.Errorf("verified email linked to user %s, want the existing user %s", user.Id, userID)
+	}
+}
@@ -0,0 +1,154 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg/pagination"
+)
+
+var (
+	ErrWatchlistMovieNotFound = errors.New("movie not found")
+	ErrWatchlistItemNotFound  = errors.New("movie is not in the watchlist")
+)
+
+type WatchlistRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWatchlistRepository(db *pgxpool.Pool) *WatchlistRepository {
+	return &WatchlistRepository{db: db}
+}
+
+// AddToWatchlist bookmarks a movie, created is false when it was already in the watchlist.
+// A movie that already has schedules is marked as notified, the notification is only for new schedules.
+func (w *WatchlistRepository) AddToWatchlist(ctx context.Context, userID string, movieID int) (bool, error) {
+	query := `
+		INSERT INTO watchlists (user_id, movie_id, notified_at)
+		SELECT
+			$1,
+			m.id,
+			CASE WHEN EXISTS (
+				SELECT 1 FROM schedules s WHERE s.movie_id = m.id AND s.show_date >= CURRENT_DATE
+			) THEN CURRENT_TIMESTAMP END
+		FROM movies m
+		WHERE m.id = $2 AND m.status = 'published'
+		ON CONFLICT (user_id, movie_id) DO NOTHING
+		RETURNING movie_id`
+
+	var id int
+	err := w.db.QueryRow(ctx, query, userID, movieID).Scan(&id)
+	if err == nil {
+		return true, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, err
+	}
+
+	// no row: either already bookmarked or the movie does not exist
+	var exists bool
+	if err := w.db.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM watchlists WHERE user_id = $1 AND movie_id = $2)", userID, movieID).Scan(&exists); err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, ErrWatchlistMovieNotFound
+	}
+	return false, nil
+}
+
+func (w *WatchlistRepository) RemoveFromWatchlist(ctx context.Context, userID string, movieID int) error {
+	tag, err := w.db.Exec(ctx, "DELETE FROM watchlists WHERE user_id = $1 AND movie_id = $2", userID, movieID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrWatchlistItemNotFound
+	}
+	return nil
+}
+
+// ListWatchlist returns the bookmarked movies, last added first
+func (w *WatchlistRepository) ListWatchlist(ctx context.Context, userID string, params pagination.Params) (pagination.Page[models.WatchlistItem], error) {
+	var total int
+	if err := w.db.QueryRow(ctx, "SELECT COUNT(*) FROM watchlists WHERE user_id = $1", userID).Scan(&total); err != nil {
+		return pagination.Page[models.WatchlistItem]{}, err
+	}
+
+	cursor, err := params.CursorFor("added_at:desc")
+	if err != nil {
+		return pagination.Page[models.WatchlistItem]{}, err
+	}
+
+	args := []any{userID, params.Offset(), params.Limit()}
+	keyset := ""
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.ID)
+		keyset = "AND (w.created_at, w.movie_id) < ($4::timestamptz, $5::int4)"
+	}
+
+	query := `
+		SELECT
+			m.id,
+			m.title,
+			m.poster_img,
+			m.release_date,
+			COALESCE((
+				SELECT ARRAY_AGG(g.name ORDER BY g.name)
+				FROM movie_genres mg
+				JOIN genres g ON mg.genre_id = g.id
+				WHERE mg.movie_id = m.id
+			), '{}') AS genres,
+			EXISTS (
+				SELECT 1 FROM schedules s WHERE s.movie_id = m.id AND s.show_date >= CURRENT_DATE
+			) AS scheduled,
+			w.created_at,
+			w.created_at::text AS sort_key
+		FROM watchlists w
+		JOIN movies m ON m.id = w.movie_id
+		WHERE w.user_id = $1 ` + keyset + `
+		ORDER BY w.created_at DESC, w.movie_id DESC
+		OFFSET $2 LIMIT $3`
+
+	rows, err := w.db.Query(ctx, query, args...)
+	if err != nil {
+		if cursor != nil {
+			return pagination.Page[models.WatchlistItem]{}, cursorError(err)
+		}
+		return pagination.Page[models.WatchlistItem]{}, err
+	}
+	defer rows.Close()
+
+	var items []models.WatchlistItem
+	var sortKeys []string
+	for rows.Next() {
+		var item models.WatchlistItem
+		var key string
+		if err := rows.Scan(
+			&item.MovieID,
+			&item.Title,
+			&item.PosterImg,
+			&item.ReleaseDate,
+			&item.Genres,
+			&item.Scheduled,
+			&item.AddedAt,
+			&key,
+		); err != nil {
+			return pagination.Page[models.WatchlistItem]{}, err
+		}
+		item.PosterURLs = utils.ImageURLs("posters", item.PosterImg)
+		items = append(items, item)
+		sortKeys = append(sortKeys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return pagination.Page[models.WatchlistItem]{}, err
+	}
+
+	return pagination.NewPage(items, params, total, func(i int) pagination.Cursor {
+		return pagination.Cursor{Sort: "added_at:desc", Value: sortKeys[i], ID: strconv.Itoa(items[i].MovieID)}
+	}), nil
+}
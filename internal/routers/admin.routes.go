@@ -6,17 +6,50 @@ import (
 	"github.com/radifan9/tickitz-ticketing-backend/internal/handlers"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/middlewares"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
 	"github.com/redis/go-redis/v9"
 )
 
-func RegisterAdminRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client) {
+func RegisterAdminRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client, st pkg.Storage) {
 	adminRepo := repositories.NewMovieRepository(db, rdb)
-	adminHandler := handlers.NewMovieHandler(adminRepo)
+	adminHandler := handlers.NewMovieHandler(adminRepo, st)
+	mediaHandler := handlers.NewMediaHandler(repositories.NewMediaRepository(db, rdb), st)
+	permissionRepo := repositories.NewPermissionRepository(db)
+	permissionHandler := handlers.NewPermissionHandler(permissionRepo, rdb)
+	adminUserHandler := handlers.NewAdminUserHandler(repositories.NewUserRepository(db, rdb), repositories.NewOrderRepository(db, rdb), permissionRepo, rdb)
+
+	// Akses per route lewat permission, jadi content editor juga bisa masuk ke /admin/movies.
+	// Pakai blacklist supaya token lama tidak berlaku lagi setelah role berubah.
 	admin := v1.Group("/admin")
-	admin.Use(middlewares.VerifyToken, middlewares.Access("admin"))
+	admin.Use(middlewares.VerifyTokenWithBlacklist(rdb), middlewares.RequireTwoFactor())
+
+	admin.POST("/movies", middlewares.RequirePermission("movies:write"), adminHandler.CreateMovie)
+	admin.PATCH("/movies/:id", middlewares.RequirePermission("movies:write"), adminHandler.EditMovie)
+	admin.GET("/movies", middlewares.RequirePermission("movies:write"), adminHandler.ListAllMovies)
+	admin.GET("/movies/:id", middlewares.RequirePermission("movies:write"), adminHandler.GetAdminMovie)
+	admin.DELETE("/movies/:id/archive", middlewares.RequirePermission("movies:archive"), adminHandler.ArchiveMovieByID)
+	admin.POST("/movies/:id/unarchive", middlewares.RequirePermission("movies:archive"), adminHandler.UnarchiveMovie)
+	admin.POST("/movies/:id/publish", middlewares.RequirePermission("movies:write"), adminHandler.PublishMovie)
+
+	// Trailers & stills
+	admin.GET("/movies/:id/media", middlewares.RequirePermission("movies:write"), mediaHandler.ListMedia)
+	admin.POST("/movies/:id/media/trailers", middlewares.RequirePermission("movies:write"), mediaHandler.AddTrailer)
+	admin.POST("/movies/:id/media/images", middlewares.RequirePermission("movies:write"), mediaHandler.AddImage)
+	admin.PUT("/movies/:id/media/order", middlewares.RequirePermission("movies:write"), mediaHandler.ReorderMedia)
+	admin.DELETE("/movies/:id/media/:media_id", middlewares.RequirePermission("movies:write"), mediaHandler.DeleteMedia)
+
+	// Roles & permissions
+	admin.GET("/roles", middlewares.RequirePermission("roles:manage"), permissionHandler.ListRoles)
+	admin.GET("/users/:id/roles", middlewares.RequirePermission("roles:manage"), permissionHandler.ListUserRoles)
+	admin.POST("/users/:id/roles", middlewares.RequirePermission("roles:manage"), permissionHandler.AssignRole)
+	admin.DELETE("/users/:id/roles/:role_id", middlewares.RequirePermission("roles:manage"), permissionHandler.RevokeRole)
 
-	admin.POST("/movies", adminHandler.CreateMovie)
-	admin.PATCH("/movies/:id", adminHandler.EditMovie)
-	admin.GET("/movies", adminHandler.ListAllMovies)
-	admin.DELETE("/movies/:id/archive", adminHandler.ArchiveMovieByID)
+	// Users
+	admin.GET("/users", middlewares.RequirePermission("users:manage"), adminUserHandler.ListUsers)
+	admin.GET("/users/:id", middlewares.RequirePermission("users:manage"), adminUserHandler.GetUser)
+	admin.GET("/users/:id/orders", middlewares.RequirePermission("users:manage"), adminUserHandler.ListUserOrders)
+	admin.PATCH("/users/:id/role", middlewares.RequirePermission("users:manage"), adminUserHandler.ChangeRole)
+	admin.POST("/users/:id/suspend", middlewares.RequirePermission("users:manage"), adminUserHandler.SuspendUser)
+	admin.POST("/users/:id/reactivate", middlewares.RequirePermission("users:manage"), adminUserHandler.ReactivateUser)
+	admin.POST("/users/:id/password-reset", middlewares.RequirePermission("users:manage"), adminUserHandler.ForcePasswordReset)
 }
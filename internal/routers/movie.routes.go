@@ -5,12 +5,13 @@ import (
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/handlers"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
 	"github.com/redis/go-redis/v9"
 )
 
-func RegisterMovieRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client) {
+func RegisterMovieRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client, st pkg.Storage) {
 	movieRepo := repositories.NewMovieRepository(db, rdb)
-	movieHandler := handlers.NewMovieHandler(movieRepo)
+	movieHandler := handlers.NewMovieHandler(movieRepo, st)
 	movies := v1.Group("/movies")
 
 	movies.GET("/", movieHandler.ListFilteredMovies)
@@ -19,5 +20,7 @@ func RegisterMovieRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Clien
 	// Sub-resources for movies
 	movies.GET("/upcoming", movieHandler.ListUpcomingMovies)
 	movies.GET("/popular", movieHandler.ListPopularMovies)
+	movies.GET("/search", movieHandler.SearchMovies)
+	movies.GET("/suggest", movieHandler.SuggestMovies)
 
 }
@@ -20,4 +20,10 @@ func RegisterOrderRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Clien
 	orders.POST("", orderHandler.AddTransaction)
 	orders.PATCH("/transactions/:id", orderHandler.PayTransaction)
 	orders.GET("/histories", orderHandler.ListTransaction)
+
+	// Cinema staff, scoped to the cinema in the url
+	staff := v1.Group("/staff")
+	staff.Use(VerifyTokenWithBlacklist)
+
+	staff.PATCH("/cinemas/:cinema_id/tickets/:id/scan", middlewares.RequirePermission("tickets:scan", "cinema_id"), orderHandler.ScanTicket)
 }
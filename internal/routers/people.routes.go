@@ -0,0 +1,27 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/handlers"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/middlewares"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+	"github.com/redis/go-redis/v9"
+)
+
+func RegisterPeopleRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client, st pkg.Storage) {
+	peopleRepo := repositories.NewPeopleRepository(db, rdb)
+	personHandler := handlers.NewPersonHandler(peopleRepo, st)
+
+	// Public person pages
+	v1.GET("/people", personHandler.SearchPeople)
+	v1.GET("/people/:id", personHandler.GetPerson)
+
+	// Editing and deduplication
+	admin := v1.Group("/admin/people")
+	admin.Use(middlewares.VerifyTokenWithBlacklist(rdb), middlewares.RequireTwoFactor(), middlewares.RequirePermission("movies:write"))
+	admin.GET("/duplicates", personHandler.FindDuplicates)
+	admin.PATCH("/:id", personHandler.EditPerson)
+	admin.POST("/:id/merge", personHandler.MergePeople)
+}
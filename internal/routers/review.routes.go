@@ -0,0 +1,31 @@
+package routers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/handlers"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/middlewares"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/redis/go-redis/v9"
+)
+
+func RegisterReviewRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client) {
+	reviewRepo := repositories.NewReviewRepository(db, rdb)
+	reviewHandler := handlers.NewReviewHandler(reviewRepo)
+	verifyTokenWithBlacklist := middlewares.VerifyTokenWithBlacklist(rdb)
+
+	// Public list, writing needs a login
+	v1.GET("/movies/:id/reviews", reviewHandler.ListMovieReviews)
+	v1.POST("/movies/:id/reviews", verifyTokenWithBlacklist, middlewares.Access("user"), reviewHandler.CreateReview)
+
+	reviews := v1.Group("/reviews")
+	reviews.Use(verifyTokenWithBlacklist, middlewares.Access("user"))
+	reviews.PATCH("/:id", reviewHandler.UpdateReview)
+	reviews.DELETE("/:id", reviewHandler.DeleteReview)
+
+	// Moderation
+	admin := v1.Group("/admin/reviews")
+	admin.Use(verifyTokenWithBlacklist, middlewares.RequireTwoFactor(), middlewares.RequirePermission("reviews:moderate"))
+	admin.GET("", reviewHandler.ListReviewsForModeration)
+	admin.PATCH("/:id/moderation", reviewHandler.ModerateReview)
+}
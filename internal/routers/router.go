@@ -5,9 +5,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/handlers"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/middlewares"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/utils"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
 	"github.com/redis/go-redis/v9"
 
 	docs "github.com/radifan9/tickitz-ticketing-backend/docs"
@@ -15,7 +17,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func InitRouter(db *pgxpool.Pool, rdb *redis.Client) *gin.Engine {
+func InitRouter(db *pgxpool.Pool, rdb *redis.Client, st pkg.Storage) *gin.Engine {
 	router := gin.Default()
 
 	// Tambahkan CORS
@@ -25,17 +27,29 @@ func InitRouter(db *pgxpool.Pool, rdb *redis.Client) *gin.Engine {
 	docs.SwaggerInfo.BasePath = "/"
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
+	// Public keys for verifying our JWT
+	router.GET("/.well-known/jwks.json", handlers.GetJWKS)
+
+	// Health check, answers 200 while Postgres is up (also without Redis)
+	router.GET("/health", handlers.NewHealthHandler(db, rdb).Check)
+
 	// API Version 1
 	v1 := router.Group("/api/v1")
 	{
-		RegisterUserRoutes(v1, db, rdb)
-		RegisterMovieRoutes(v1, db, rdb)
+		RegisterUserRoutes(v1, db, rdb, st)
+		RegisterMovieRoutes(v1, db, rdb, st)
 		RegisterOrderRoutes(v1, db, rdb)
 		RegisterSchedulesRoutes(v1, db, rdb)
-		RegisterAdminRoutes(v1, db, rdb)
+		RegisterAdminRoutes(v1, db, rdb, st)
+		RegisterReviewRoutes(v1, db, rdb)
+		RegisterPeopleRoutes(v1, db, rdb, st)
 
-		// Static File Image
-		v1.Static("/img", "public")
+		// Images: straight from disk with local storage, otherwise a redirect to the object storage
+		if local, ok := st.(*pkg.LocalStorage); ok {
+			v1.Static("/img", local.Root)
+		} else {
+			v1.GET("/img/*filepath", handlers.NewImageHandler(st).ServeImage)
+		}
 	}
 
 	// Catch all route
@@ -10,7 +10,7 @@ import (
 )
 
 func RegisterSchedulesRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client) {
-	scheduleRepo := repositories.NewScheduleRepository(db)
+	scheduleRepo := repositories.NewScheduleRepository(db, rdb)
 	scheduleHandler := handlers.NewScheduleHandler(scheduleRepo)
 	VerifyTokenWithBlacklist := middlewares.VerifyTokenWithBlacklist(rdb)
 
@@ -1,17 +1,31 @@
 package routers
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/radifan9/tickitz-ticketing-backend/internal/configs"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/handlers"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/middlewares"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/repositories"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
 	"github.com/redis/go-redis/v9"
 )
 
-func RegisterUserRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client) {
+func RegisterUserRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client, st pkg.Storage) {
 	userRepo := repositories.NewUserRepository(db, rdb)
-	userHandler := handlers.NewUserHandler(userRepo, rdb)
+	twoFactorRepo := repositories.NewTwoFactorRepository(db)
+	userHandler := handlers.NewUserHandler(userRepo, twoFactorRepo, rdb, st)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorRepo, userRepo, rdb)
+	watchlistHandler := handlers.NewWatchlistHandler(repositories.NewWatchlistRepository(db))
+	exportHandler := handlers.NewExportHandler(repositories.NewExportRepository(db))
+	// Social login tetap jalan tanpa provider, login biasa tidak terganggu
+	oidcProviders, err := configs.InitOIDCProviders()
+	if err != nil {
+		log.Println("failed to load OIDC providers, social login disabled\nCause: ", err.Error())
+	}
+	oidcHandler := handlers.NewOIDCHandler(oidcProviders, userRepo, twoFactorRepo, rdb)
 	verifyTokenWithBlacklist := middlewares.VerifyTokenWithBlacklist(rdb) // Create middleware instance with redis client
 
 	// Authentication routes (no auth required)
@@ -20,14 +34,41 @@ func RegisterUserRoutes(v1 *gin.RouterGroup, db *pgxpool.Pool, rdb *redis.Client
 		auth.POST("/register", userHandler.Register) // POST /api/v1/auth/register
 		auth.POST("/login", userHandler.Login)       // POST /api/v1/auth/login
 		auth.DELETE("/logout", verifyTokenWithBlacklist, userHandler.Logout)
+		auth.POST("/2fa/verify", twoFactorHandler.VerifyLogin)     // POST /api/v1/auth/2fa/verify
+		auth.POST("/email/verify", userHandler.ConfirmEmailChange) // POST /api/v1/auth/email/verify
+		auth.POST("/password/reset", userHandler.ResetPassword)    // POST /api/v1/auth/password/reset
+
+		// Social login (OpenID Connect)
+		auth.GET("/oidc/providers", oidcHandler.ListProviders)     // GET /api/v1/auth/oidc/providers
+		auth.GET("/oidc/:provider/login", oidcHandler.Login)       // GET /api/v1/auth/oidc/:provider/login
+		auth.GET("/oidc/:provider/callback", oidcHandler.Callback) // GET /api/v1/auth/oidc/:provider/callback
 	}
 
 	// User profile routes (auth required)
 	users := v1.Group("/users")
 	users.Use(verifyTokenWithBlacklist, middlewares.Access("admin", "user"))
 	{
-		users.GET("/profile", userHandler.GetProfile)        // GET /api/v1/users/profile
-		users.PATCH("/profile", userHandler.EditProfile)     // PATCH /api/v1/users/profile
-		users.PATCH("/password", userHandler.ChangePassword) // PATCH /api/v1/users/password
+		users.GET("/profile", userHandler.GetProfile)          // GET /api/v1/users/profile
+		users.PATCH("/profile", userHandler.EditProfile)       // PATCH /api/v1/users/profile
+		users.PATCH("/password", userHandler.ChangePassword)   // PATCH /api/v1/users/password
+		users.DELETE("/profile/img", userHandler.RemoveAvatar) // DELETE /api/v1/users/profile/img
+		users.POST("/email", userHandler.RequestEmailChange)   // POST /api/v1/users/email
+		users.DELETE("/me", userHandler.DeleteAccount)         // DELETE /api/v1/users/me
+
+		// Two-factor authentication
+		users.POST("/2fa/setup", twoFactorHandler.Setup)                            // POST /api/v1/users/2fa/setup
+		users.POST("/2fa/confirm", twoFactorHandler.Confirm)                        // POST /api/v1/users/2fa/confirm
+		users.POST("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes) // POST /api/v1/users/2fa/recovery-codes
+		users.DELETE("/2fa", twoFactorHandler.Disable)                              // DELETE /api/v1/users/2fa
+
+		// Watchlist
+		users.GET("/watchlist", watchlistHandler.ListWatchlist)                   // GET /api/v1/users/watchlist
+		users.POST("/watchlist/:movieId", watchlistHandler.AddToWatchlist)        // POST /api/v1/users/watchlist/:movieId
+		users.DELETE("/watchlist/:movieId", watchlistHandler.RemoveFromWatchlist) // DELETE /api/v1/users/watchlist/:movieId
+
+		// Personal data export
+		users.POST("/me/export", exportHandler.RequestExport)              // POST /api/v1/users/me/export
+		users.GET("/me/export", exportHandler.GetExport)                   // GET /api/v1/users/me/export
+		users.GET("/me/export/:id/download", exportHandler.DownloadExport) // GET /api/v1/users/me/export/:id/download
 	}
 }
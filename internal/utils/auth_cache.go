@@ -2,13 +2,19 @@ package utils
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrOIDCStateNotFound means the state is unknown, expired or was already used
+var ErrOIDCStateNotFound = errors.New("oidc state not found")
+
 // AuthCacheManager handles authentication-related caching operations
 type AuthCacheManager struct {
 	rdb *redis.Client
@@ -40,15 +46,15 @@ func (a *AuthCacheManager) BlacklistToken(ctx context.Context, tokenString strin
 	return nil
 }
 
-// IsTokenBlacklisted checks if a token is in the blacklist
-func (a *AuthCacheManager) IsTokenBlacklisted(ctx context.Context, tokenString string) bool {
+// IsTokenBlacklisted checks if a token is in the blacklist, err is set when Redis could not answer
+func (a *AuthCacheManager) IsTokenBlacklisted(ctx context.Context, tokenString string) (bool, error) {
 	key := fmt.Sprintf("tickitz:blacklist:%s", tokenString)
 
 	// Check if the key exists
 	result := a.rdb.Exists(ctx, key)
 	if result.Err() != nil {
 		log.Printf("Error checking token blacklist: %v", result.Err())
-		return false
+		return false, result.Err()
 	}
 
 	exists := result.Val() > 0
@@ -56,47 +62,85 @@ func (a *AuthCacheManager) IsTokenBlacklisted(ctx context.Context, tokenString s
 		log.Printf("Token is blacklisted")
 	}
 
-	return exists
+	return exists, nil
 }
 
 // BlacklistUserTokens blacklists all tokens for a specific user (useful for "logout from all devices")
-// func (a *AuthCacheManager) BlacklistUserTokens(ctx context.Context, userID string, duration time.Duration) error {
-// 	// This creates a user-level blacklist
-// 	key := fmt.Sprintf("tickitz:user_blacklist:%s", userID)
+func (a *AuthCacheManager) BlacklistUserTokens(ctx context.Context, userID string, duration time.Duration) error {
+	// This creates a user-level blacklist
+	key := fmt.Sprintf("tickitz:user_blacklist:%s", userID)
 
-// 	err := a.rdb.Set(ctx, key, time.Now().Unix(), duration).Err()
-// 	if err != nil {
-// 		log.Printf("Failed to blacklist user tokens: %v", err)
-// 		return fmt.Errorf("failed to blacklist user tokens: %w", err)
-// 	}
+	err := a.rdb.Set(ctx, key, time.Now().Unix(), duration).Err()
+	if err != nil {
+		log.Printf("Failed to blacklist user tokens: %v", err)
+		return fmt.Errorf("failed to blacklist user tokens: %w", err)
+	}
 
-// 	log.Printf("All tokens for user %s blacklisted for %v", userID, duration)
-// 	return nil
-// }
+	log.Printf("All tokens for user %s blacklisted for %v", userID, duration)
+	return nil
+}
 
-// IsUserTokensBlacklisted checks if all tokens for a user should be considered invalid
-func (a *AuthCacheManager) IsUserTokensBlacklisted(ctx context.Context, userID string, tokenIssuedAt time.Time) bool {
+// IsUserTokensBlacklisted checks if all tokens for a user should be considered invalid, err is set when
+// Redis could not answer
+func (a *AuthCacheManager) IsUserTokensBlacklisted(ctx context.Context, userID string, tokenIssuedAt time.Time) (bool, error) {
 	key := fmt.Sprintf("tickitz:user_blacklist:%s", userID)
 
 	result := a.rdb.Get(ctx, key)
 	if result.Err() != nil {
 		if result.Err() == redis.Nil {
 			// Key doesn't exist, so user tokens are not blacklisted
-			return false
+			return false, nil
 		}
 		log.Printf("Error checking user token blacklist: %v", result.Err())
-		return false
+		return false, result.Err()
 	}
 
 	// Get the blacklist timestamp
 	blacklistTimestamp, err := result.Int64()
 	if err != nil {
 		log.Printf("Error parsing blacklist timestamp: %v", err)
-		return false
+		return false, nil
 	}
 
 	// If the token was issued before the blacklist time, it should be considered invalid
-	return tokenIssuedAt.Unix() < blacklistTimestamp
+	return tokenIssuedAt.Unix() < blacklistTimestamp, nil
+}
+
+// SuspendUserTokens rejects every token of the user until ReactivateUserTokens. The suspension has its own
+// key without expiry, so a later user blacklist (role change, forced reset, ...) can't overwrite it.
+func (a *AuthCacheManager) SuspendUserTokens(ctx context.Context, userID string) error {
+	key := fmt.Sprintf("tickitz:user_suspended:%s", userID)
+
+	if err := a.rdb.Set(ctx, key, time.Now().Unix(), 0).Err(); err != nil {
+		return fmt.Errorf("failed to suspend user tokens: %w", err)
+	}
+	return nil
+}
+
+// ReactivateUserTokens lifts the suspension. Tokens from before the suspension stay invalid until they expire,
+// so the user blacklist is set before the suspension key is deleted.
+func (a *AuthCacheManager) ReactivateUserTokens(ctx context.Context, userID string, duration time.Duration) error {
+	if err := a.BlacklistUserTokens(ctx, userID, duration); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("tickitz:user_suspended:%s", userID)
+	if err := a.rdb.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to reactivate user tokens: %w", err)
+	}
+	return nil
+}
+
+// IsUserSuspended checks the suspension key, err is set when Redis could not answer
+func (a *AuthCacheManager) IsUserSuspended(ctx context.Context, userID string) (bool, error) {
+	key := fmt.Sprintf("tickitz:user_suspended:%s", userID)
+
+	exists, err := a.rdb.Exists(ctx, key).Result()
+	if err != nil {
+		log.Printf("Error checking user suspension: %v", err)
+		return false, err
+	}
+	return exists > 0, nil
 }
 
 // ClearUserTokenBlacklist removes the user-level token blacklist (useful after password reset, etc.)
@@ -112,3 +156,95 @@ func (a *AuthCacheManager) IsUserTokensBlacklisted(ctx context.Context, userID s
 // 	log.Printf("User token blacklist cleared for user %s", userID)
 // 	return nil
 // }
+
+// SaveOIDCState keeps nonce & PKCE verifier of a pending social login
+func (a *AuthCacheManager) SaveOIDCState(ctx context.Context, state string, data models.OIDCLoginState, ttl time.Duration) error {
+	key := fmt.Sprintf("tickitz:oidc:state:%s", state)
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := a.rdb.Set(ctx, key, raw, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to save oidc state: %w", err)
+	}
+	return nil
+}
+
+// PopOIDCState returns and deletes the state, so every state can only be used once
+func (a *AuthCacheManager) PopOIDCState(ctx context.Context, state string) (models.OIDCLoginState, error) {
+	key := fmt.Sprintf("tickitz:oidc:state:%s", state)
+
+	raw, err := a.rdb.GetDel(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return models.OIDCLoginState{}, ErrOIDCStateNotFound
+		}
+		return models.OIDCLoginState{}, fmt.Errorf("failed to get oidc state: %w", err)
+	}
+
+	var data models.OIDCLoginState
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return models.OIDCLoginState{}, err
+	}
+	return data, nil
+}
+
+// TwoFactorMaxFailures wrong codes within TwoFactorLockout lock the second factor of a user, a 6 digit code
+// can't be guessed online then
+const (
+	TwoFactorMaxFailures = 5
+	TwoFactorLockout     = 15 * time.Minute
+)
+
+// IsTwoFactorLocked tells whether the user entered too many wrong two-factor codes recently
+func (a *AuthCacheManager) IsTwoFactorLocked(ctx context.Context, userID string) (bool, error) {
+	key := fmt.Sprintf("tickitz:2fa_failures:%s", userID)
+
+	failures, err := a.rdb.Get(ctx, key).Int()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read 2fa failures: %w", err)
+	}
+	return failures >= TwoFactorMaxFailures, nil
+}
+
+// RecordTwoFactorFailure counts a wrong code, the counter expires TwoFactorLockout after the first failure
+func (a *AuthCacheManager) RecordTwoFactorFailure(ctx context.Context, userID string) (int64, error) {
+	key := fmt.Sprintf("tickitz:2fa_failures:%s", userID)
+
+	failures, err := a.rdb.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count 2fa failure: %w", err)
+	}
+	if failures == 1 {
+		if err := a.rdb.Expire(ctx, key, TwoFactorLockout).Err(); err != nil {
+			return 0, fmt.Errorf("failed to count 2fa failure: %w", err)
+		}
+	}
+	return failures, nil
+}
+
+// ResetTwoFactorFailures is called after a correct code
+func (a *AuthCacheManager) ResetTwoFactorFailures(ctx context.Context, userID string) error {
+	key := fmt.Sprintf("tickitz:2fa_failures:%s", userID)
+
+	if err := a.rdb.Del(ctx, key).Err(); err != nil {
+		return fmt.Errorf("failed to reset 2fa failures: %w", err)
+	}
+	return nil
+}
+
+// ConsumeChallenge marks a 2FA challenge token (by its jti) as used until it expires.
+// It returns false when the challenge was already used.
+func (a *AuthCacheManager) ConsumeChallenge(ctx context.Context, challengeID string, ttl time.Duration) (bool, error) {
+	key := fmt.Sprintf("tickitz:2fa_challenge_used:%s", challengeID)
+
+	ok, err := a.rdb.SetNX(ctx, key, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume 2fa challenge: %w", err)
+	}
+	return ok, nil
+}
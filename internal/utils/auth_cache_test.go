@@ -0,0 +1,137 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTwoFactorLockout(t *testing.T) {
+	_, rdb := newFakeRedis(t)
+	ac := NewAuthCacheManager(rdb)
+	ctx := context.Background()
+
+	for i := 1; i <= TwoFactorMaxFailures; i++ {
+		locked, err := ac.IsTwoFactorLocked(ctx, "u1")
+		if err != nil || locked {
+			t.Fatalf("before failure %d: locked = %v, err = %v", i, locked, err)
+		}
+		if _, err := ac.RecordTwoFactorFailure(ctx, "u1"); err != nil {
+			t.Fatalf("RecordTwoFactorFailure: %v", err)
+		}
+	}
+	if locked, _ := ac.IsTwoFactorLocked(ctx, "u1"); !locked {
+		t.Fatalf("not locked after %d failures", TwoFactorMaxFailures)
+	}
+	if locked, _ := ac.IsTwoFactorLocked(ctx, "u2"); locked {
+		t.Fatal("the lockout of one user must not lock another")
+	}
+
+	if err := ac.ResetTwoFactorFailures(ctx, "u1"); err != nil {
+		t.Fatalf("ResetTwoFactorFailures: %v", err)
+	}
+	if locked, _ := ac.IsTwoFactorLocked(ctx, "u1"); locked {
+		t.Fatal("still locked after reset")
+	}
+}
+
+func TestTwoFactorFailuresExpire(t *testing.T) {
+	f, rdb := newFakeRedis(t)
+	ac := NewAuthCacheManager(rdb)
+	ctx := context.Background()
+
+	if _, err := ac.RecordTwoFactorFailure(ctx, "u1"); err != nil {
+		t.Fatalf("RecordTwoFactorFailure: %v", err)
+	}
+	f.mu.Lock()
+	entry := f.data["tickitz:2fa_failures:u1"]
+	f.mu.Unlock()
+	if ttl := time.Until(entry.expiresAt); ttl <= 0 || ttl > TwoFactorLockout {
+		t.Fatalf("failure counter expires in %v, want at most %v", ttl, TwoFactorLockout)
+	}
+}
+
+func TestConsumeChallengeOnlyOnce(t *testing.T) {
+	_, rdb := newFakeRedis(t)
+	ac := NewAuthCacheManager(rdb)
+	ctx := context.Background()
+
+	first, err := ac.ConsumeChallenge(ctx, "jti-1", time.Minute)
+	if err != nil || !first {
+		t.Fatalf("first use = %v, %v, want true", first, err)
+	}
+	second, err := ac.ConsumeChallenge(ctx, "jti-1", time.Minute)
+	if err != nil || second {
+		t.Fatalf("second use = %v, %v, want false", second, err)
+	}
+	other, _ := ac.ConsumeChallenge(ctx, "jti-2", time.Minute)
+	if !other {
+		t.Fatal("another challenge must still work")
+	}
+}
+
+func TestTwoFactorLimitsReportRedisErrors(t *testing.T) {
+	ac := NewAuthCacheManager(deadRedisClient(t))
+	ctx := context.Background()
+
+	if _, err := ac.IsTwoFactorLocked(ctx, "u1"); err == nil {
+		t.Error("IsTwoFactorLocked without Redis must return an error")
+	}
+	if _, err := ac.ConsumeChallenge(ctx, "jti-1", time.Minute); err == nil {
+		t.Error("ConsumeChallenge without Redis must return an error")
+	}
+}
+
+// A role change, forced reset or account deletion blacklists the tokens of the user, that must not lift a suspension
+func TestSuspensionSurvivesUserBlacklist(t *testing.T) {
+	_, rdb := newFakeRedis(t)
+	ac := NewAuthCacheManager(rdb)
+	ctx := context.Background()
+
+	if err := ac.SuspendUserTokens(ctx, "u1"); err != nil {
+		t.Fatalf("SuspendUserTokens: %v", err)
+	}
+	if err := ac.BlacklistUserTokens(ctx, "u1", time.Hour); err != nil {
+		t.Fatalf("BlacklistUserTokens: %v", err)
+	}
+
+	suspended, err := ac.IsUserSuspended(ctx, "u1")
+	if err != nil || !suspended {
+		t.Fatalf("after a role change: suspended = %v, %v, want true", suspended, err)
+	}
+	if other, _ := ac.IsUserSuspended(ctx, "u2"); other {
+		t.Fatal("the suspension of one user must not suspend another")
+	}
+}
+
+func TestReactivateKeepsOldTokensInvalid(t *testing.T) {
+	_, rdb := newFakeRedis(t)
+	ac := NewAuthCacheManager(rdb)
+	ctx := context.Background()
+	issuedBefore := time.Now().Add(-time.Minute)
+
+	if err := ac.SuspendUserTokens(ctx, "u1"); err != nil {
+		t.Fatalf("SuspendUserTokens: %v", err)
+	}
+	if err := ac.ReactivateUserTokens(ctx, "u1", time.Hour); err != nil {
+		t.Fatalf("ReactivateUserTokens: %v", err)
+	}
+
+	if suspended, err := ac.IsUserSuspended(ctx, "u1"); err != nil || suspended {
+		t.Fatalf("after reactivation: suspended = %v, %v, want false", suspended, err)
+	}
+	if blacklisted, _ := ac.IsUserTokensBlacklisted(ctx, "u1", issuedBefore); !blacklisted {
+		t.Error("a token from before the suspension must stay invalid")
+	}
+	if blacklisted, _ := ac.IsUserTokensBlacklisted(ctx, "u1", time.Now().Add(time.Minute)); blacklisted {
+		t.Error("a token from after the reactivation must be accepted")
+	}
+}
+
+func TestIsUserSuspendedReportsRedisErrors(t *testing.T) {
+	ac := NewAuthCacheManager(deadRedisClient(t))
+
+	if _, err := ac.IsUserSuspended(context.Background(), "u1"); err == nil {
+		t.Error("IsUserSuspended without Redis must return an error")
+	}
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrRedisUnavailable is returned for every Redis command while the circuit is open
+var ErrRedisUnavailable = errors.New("redis unavailable (circuit open)")
+
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops calling a dependency after threshold failures in a row, for cooldown.
+// After the cooldown one call is let through (half open), its result closes or opens the circuit again.
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+	probing   bool
+	now       func() time.Time
+}
+
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: max(1, threshold), cooldown: cooldown, now: time.Now}
+}
+
+// Allow tells whether a call may be made now
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.threshold {
+		return true
+	}
+	if b.now().Before(b.openUntil) || b.probing {
+		return false
+	}
+	b.probing = true
+	return true
+}
+
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures >= b.threshold {
+		log.Println("✅ circuit closed again")
+	}
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.failures >= b.threshold {
+		if b.failures == b.threshold {
+			log.Printf("⚠️ circuit opened after %d failures, retry in %v", b.failures, b.cooldown)
+		}
+		b.openUntil = b.now().Add(b.cooldown)
+	}
+}
+
+// Release ends a call that neither succeeded nor failed, a half open circuit lets the next call through
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.failures < b.threshold:
+		return CircuitClosed
+	case b.now().Before(b.openUntil):
+		return CircuitOpen
+	default:
+		return CircuitHalfOpen
+	}
+}
+
+// RedisBreaker guards every Redis command of the process, configs.InitRDB installs it as a hook.
+// With the circuit open the cache is skipped and the catalog is read from Postgres.
+var RedisBreaker = NewCircuitBreaker(5, 30*time.Second)
+
+// RedisBreakerHook fails fast while the circuit is open and counts connection errors.
+// A reply of the server (also redis.Nil or an error reply) counts as success.
+type RedisBreakerHook struct {
+	Breaker *CircuitBreaker
+}
+
+func (h RedisBreakerHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h RedisBreakerHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !h.Breaker.Allow() {
+			cmd.SetErr(ErrRedisUnavailable)
+			return ErrRedisUnavailable
+		}
+		err := next(ctx, cmd)
+		h.record(err)
+		return err
+	}
+}
+
+func (h RedisBreakerHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !h.Breaker.Allow() {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrRedisUnavailable)
+			}
+			return ErrRedisUnavailable
+		}
+		err := next(ctx, cmds)
+		h.record(err)
+		return err
+	}
+}
+
+func (h RedisBreakerHook) record(err error) {
+	var replyErr redis.Error
+	switch {
+	case err == nil, errors.Is(err, redis.Nil), errors.As(err, &replyErr):
+		h.Breaker.Success()
+	case errors.Is(err, context.Canceled):
+		// the request went away, that says nothing about Redis
+		h.Breaker.Release()
+	default:
+		h.Breaker.Failure()
+	}
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock is moved by the test, the breaker never has to wait for a real cooldown
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestBreaker(threshold int, cooldown time.Duration) (*CircuitBreaker, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := NewCircuitBreaker(threshold, cooldown)
+	b.now = clock.now
+	return b, clock
+}
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b, clock := newTestBreaker(3, 30*time.Second)
+
+	for i := 0; i < 2; i++ {
+		if !b.Allow() {
+			t.Fatalf("call %d rejected before the threshold", i+1)
+		}
+		b.Failure()
+	}
+	if state := b.State(); state != CircuitClosed {
+		t.Fatalf("state after 2 failures = %s, want closed", state)
+	}
+
+	// a success in between starts the count again
+	b.Success()
+	b.Failure()
+	b.Failure()
+	if state := b.State(); state != CircuitClosed {
+		t.Fatalf("state after success and 2 failures = %s, want closed", state)
+	}
+
+	b.Failure()
+	if state := b.State(); state != CircuitOpen {
+		t.Fatalf("state after 3 failures = %s, want open", state)
+	}
+	clock.advance(29 * time.Second)
+	if b.Allow() {
+		t.Fatal("call allowed during the cooldown")
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	b, clock := newTestBreaker(1, 30*time.Second)
+	b.Failure()
+
+	clock.advance(30 * time.Second)
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Fatalf("state after the cooldown = %s, want half_open", state)
+	}
+	if !b.Allow() {
+		t.Fatal("the probe after the cooldown was rejected")
+	}
+	if b.Allow() {
+		t.Fatal("a second call was allowed while the probe runs")
+	}
+
+	// a failed probe opens the circuit for another cooldown
+	b.Failure()
+	if state := b.State(); state != CircuitOpen {
+		t.Fatalf("state after a failed probe = %s, want open", state)
+	}
+	clock.advance(10 * time.Second)
+	if b.Allow() {
+		t.Fatal("call allowed during the second cooldown")
+	}
+
+	// a successful probe closes it
+	clock.advance(20 * time.Second)
+	if !b.Allow() {
+		t.Fatal("the second probe was rejected")
+	}
+	b.Success()
+	if state := b.State(); state != CircuitClosed {
+		t.Fatalf("state after a successful probe = %s, want closed", state)
+	}
+	if !b.Allow() || !b.Allow() {
+		t.Fatal("a closed circuit must allow every call")
+	}
+}
+
+func TestCircuitBreakerReleaseFreesTheProbe(t *testing.T) {
+	b, clock := newTestBreaker(1, time.Second)
+	b.Failure()
+	clock.advance(time.Second)
+
+	if !b.Allow() {
+		t.Fatal("the probe was rejected")
+	}
+	// the request of the probe was cancelled, that says nothing about Redis
+	b.Release()
+	if state := b.State(); state != CircuitHalfOpen {
+		t.Fatalf("state after release = %s, want half_open", state)
+	}
+	if !b.Allow() {
+		t.Fatal("the next call must be the new probe")
+	}
+}
+
+func TestRedisBreakerHook(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("connection errors open the circuit", func(t *testing.T) {
+		b, _ := newTestBreaker(2, time.Minute)
+		rdb := deadRedisClient(t)
+		rdb.AddHook(RedisBreakerHook{Breaker: b})
+
+		for i := 0; i < 2; i++ {
+			if err := rdb.Get(ctx, "k").Err(); err == nil || errors.Is(err, ErrRedisUnavailable) {
+				t.Fatalf("call %d: err = %v, want a connection error", i+1, err)
+			}
+		}
+		if err := rdb.Get(ctx, "k").Err(); !errors.Is(err, ErrRedisUnavailable) {
+			t.Fatalf("err with open circuit = %v, want ErrRedisUnavailable", err)
+		}
+		pipe := rdb.Pipeline()
+		pipe.Incr(ctx, "a")
+		if _, err := pipe.Exec(ctx); !errors.Is(err, ErrRedisUnavailable) {
+			t.Fatalf("err of a pipeline with open circuit = %v, want ErrRedisUnavailable", err)
+		}
+	})
+
+	t.Run("replies of the server count as success", func(t *testing.T) {
+		b, _ := newTestBreaker(1, time.Minute)
+		_, rdb := newFakeRedis(t)
+		rdb.AddHook(RedisBreakerHook{Breaker: b})
+
+		if err := rdb.Get(ctx, "missing").Err(); err == nil {
+			t.Fatal("want redis.Nil for a missing key")
+		}
+		if err := rdb.Do(ctx, "NOSUCHCOMMAND").Err(); err == nil {
+			t.Fatal("want an error reply for an unknown command")
+		}
+		if state := b.State(); state != CircuitClosed {
+			t.Fatalf("state = %s, want closed", state)
+		}
+	})
+
+	t.Run("cancelled requests are not counted", func(t *testing.T) {
+		b, _ := newTestBreaker(1, time.Minute)
+		rdb := deadRedisClient(t)
+		rdb.AddHook(RedisBreakerHook{Breaker: b})
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_ = rdb.Get(cancelled, "k").Err()
+		if state := b.State(); state != CircuitClosed {
+			t.Fatalf("state = %s, want closed", state)
+		}
+	})
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"log"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
@@ -24,3 +25,14 @@ func HandleMiddlewareError(ctx *gin.Context, status int, err string, logMsg stri
 		Error:   err,
 	})
 }
+
+// HandleValidationError answers 422 with every invalid field
+func HandleValidationError(ctx *gin.Context, fields models.FieldErrors) {
+	log.Printf("validation failed\nCause: %s\n", fields.Error())
+	ctx.JSON(http.StatusUnprocessableEntity, models.ValidationErrorResponse{
+		Success: false,
+		Status:  http.StatusUnprocessableEntity,
+		Error:   "validation failed",
+		Fields:  fields,
+	})
+}
@@ -0,0 +1,6 @@
+package utils
+
+// ExportDownloadURL is where the ZIP of a ready data export is downloaded (with the token of its owner)
+func ExportDownloadURL(exportID string) string {
+	return "/api/v1/users/me/export/" + exportID + "/download"
+}
@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis speaks enough RESP2 for the commands of this package (strings, counters, expiry and the unlock
+// script). miniredis is not a dependency of the module, a real Redis is not needed for these tests.
+type fakeRedis struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	data map[string]fakeValue
+}
+
+type fakeValue struct {
+	val       string
+	expiresAt time.Time // zero = no expiry
+}
+
+// newFakeRedis starts the server and returns a client for it, both are closed with the test
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeRedis{ln: ln, data: map[string]fakeValue{}}
+	go f.serve()
+
+	rdb := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIdentity: true})
+	t.Cleanup(func() {
+		rdb.Close()
+		ln.Close()
+	})
+	return f, rdb
+}
+
+// deadRedisClient points to a port nobody listens on, every command fails with a connection error
+func deadRedisClient(t *testing.T) *redis.Client {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	rdb := redis.NewClient(&redis.Options{Addr: addr, Protocol: 2, DisableIdentity: true, MaxRetries: -1, DialTimeout: 200 * time.Millisecond})
+	t.Cleanup(func() { rdb.Close() })
+	return rdb
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.exec(w, args)
+		if err := w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected %q", line)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		head, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(head[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) get(key string) (string, bool) {
+	v, ok := f.data[key]
+	if !ok {
+		return "", false
+	}
+	if !v.expiresAt.IsZero() && time.Now().After(v.expiresAt) {
+		delete(f.data, key)
+		return "", false
+	}
+	return v.val, true
+}
+
+func (f *fakeRedis) exec(w *bufio.Writer, args []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		w.WriteString("+PONG\r\n")
+	case "SELECT", "CLIENT":
+		w.WriteString("+OK\r\n")
+	case "GET":
+		if v, ok := f.get(args[1]); ok {
+			writeBulk(w, v)
+		} else {
+			w.WriteString("$-1\r\n")
+		}
+	case "MGET":
+		fmt.Fprintf(w, "*%d\r\n", len(args)-1)
+		for _, key := range args[1:] {
+			if v, ok := f.get(key); ok {
+				writeBulk(w, v)
+			} else {
+				w.WriteString("$-1\r\n")
+			}
+		}
+	case "SET":
+		value := fakeValue{val: args[2]}
+		nx := false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "NX":
+				nx = true
+			case "EX":
+				s, _ := strconv.Atoi(args[i+1])
+				value.expiresAt = time.Now().Add(time.Duration(s) * time.Second)
+				i++
+			case "PX":
+				ms, _ := strconv.Atoi(args[i+1])
+				value.expiresAt = time.Now().Add(time.Duration(ms) * time.Millisecond)
+				i++
+			}
+		}
+		if _, exists := f.get(args[1]); nx && exists {
+			w.WriteString("$-1\r\n")
+			return
+		}
+		f.data[args[1]] = value
+		w.WriteString("+OK\r\n")
+	case "INCR":
+		current, _ := f.get(args[1])
+		n, _ := strconv.ParseInt(current, 10, 64)
+		n++
+		v := f.data[args[1]]
+		v.val = strconv.FormatInt(n, 10)
+		f.data[args[1]] = v
+		fmt.Fprintf(w, ":%d\r\n", n)
+	case "EXPIRE":
+		v, ok := f.data[args[1]]
+		if !ok {
+			w.WriteString(":0\r\n")
+			return
+		}
+		s, _ := strconv.Atoi(args[2])
+		v.expiresAt = time.Now().Add(time.Duration(s) * time.Second)
+		f.data[args[1]] = v
+		w.WriteString(":1\r\n")
+	case "DEL", "EXISTS":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := f.get(key); ok {
+				n++
+				if strings.EqualFold(args[0], "DEL") {
+					delete(f.data, key)
+				}
+			}
+		}
+		fmt.Fprintf(w, ":%d\r\n", n)
+	case "EVALSHA":
+		w.WriteString("-NOSCRIPT No matching script.\r\n")
+	case "EVAL":
+		// only the compare-and-delete of unlockScript is known
+		key, token := args[3], args[4]
+		if v, ok := f.get(key); ok && v == token {
+			delete(f.data, key)
+			w.WriteString(":1\r\n")
+			return
+		}
+		w.WriteString(":0\r\n")
+	default:
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func writeBulk(w *bufio.Writer, v string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+	"github.com/radifan9/tickitz-ticketing-backend/pkg"
+)
+
+// ImageBaseURL is where the images are served, straight from disk or as a redirect to the object storage.
+// The urls stay the same when the storage changes, so they can be cached with the rest of the response.
+const ImageBaseURL = "/api/v1/img"
+
+// ImageURLs gives the url of every size of filename in dir, nil when there is no image
+func ImageURLs(dir, filename string) *models.ImageURLs {
+	if filename == "" {
+		return nil
+	}
+	base := ImageBaseURL + "/" + dir + "/"
+	original := base + filename
+	if !pkg.HasImageVariants(filename) {
+		return &models.ImageURLs{
+			Original: original, Thumbnail: original, ThumbnailWebP: original,
+			Medium: original, MediumWebP: original, Large: original, LargeWebP: original,
+		}
+	}
+
+	variant := func(name, ext string) string {
+		return base + pkg.ImageVariantName(filename, name, ext)
+	}
+	return &models.ImageURLs{
+		Original:      original,
+		Thumbnail:     variant("thumbnail", ".jpg"),
+		ThumbnailWebP: variant("thumbnail", ".webp"),
+		Medium:        variant("medium", ".jpg"),
+		MediumWebP:    variant("medium", ".webp"),
+		Large:         variant("large", ".jpg"),
+		LargeWebP:     variant("large", ".webp"),
+	}
+}
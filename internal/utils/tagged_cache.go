@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// Tags group cached entries by the rows they were built from, a write invalidates the tags it touched
+// instead of deleting a list of keys.
+const (
+	TagMovieLists = "movies"            // upcoming, popular and the first page of /movies
+	TagPopularity = "movies:popularity" // ordering by paid tickets
+	TagPeople     = "people"            // director and cast names in the movie details
+)
+
+func MovieTag(movieID int) string {
+	return "movie:" + strconv.Itoa(movieID)
+}
+
+func ScheduleTag(scheduleID int) string {
+	return "schedule:" + strconv.Itoa(scheduleID)
+}
+
+const (
+	cacheKeyPrefix = "tickitz:"
+	tagKeyPrefix   = "tickitz:tag:"
+	tagAll         = "all" // part of every key, bumped to drop everything
+
+	cacheLockTTL      = 10 * time.Second // longest fetch we expect, the lock is released earlier
+	cacheLockWait     = 2 * time.Second  // how long a miss waits for the instance that holds the lock
+	cacheFetchTimeout = 30 * time.Second
+)
+
+// CachePolicy says how long an entry is fresh and how long it may still be served after that while one
+// request refreshes it in the background. An error that is NotFound is cached for NotFoundTTL too, other
+// errors are never cached.
+type CachePolicy struct {
+	TTL         time.Duration
+	StaleFor    time.Duration
+	NotFound    error
+	NotFoundTTL time.Duration
+}
+
+// invalidationLost is set when Invalidate could not reach Redis. Entries of those tags could be read again
+// once Redis is back, so the next request that reaches Redis bumps tagAll first.
+var invalidationLost atomic.Bool
+
+// cacheFlights coalesces the fetches of a key in this instance, the Redis lock does it across instances
+var cacheFlights singleflight.Group
+
+var unlockScript = redis.NewScript(`
+	if redis.call("GET", KEYS[1]) == ARGV[1] then
+		return redis.call("DEL", KEYS[1])
+	end
+	return 0`)
+
+// cacheEntry is what is stored in Redis, the key itself lives until FreshUntil + StaleFor
+type cacheEntry struct {
+	Data       json.RawMessage `json:"data,omitempty"`
+	NotFound   bool            `json:"not_found,omitempty"`
+	FreshUntil int64           `json:"fresh_until"` // unix milliseconds
+}
+
+func (e *cacheEntry) decode(dest interface{}, policy CachePolicy) error {
+	if e.NotFound {
+		return policy.NotFound
+	}
+	return json.Unmarshal(e.Data, dest)
+}
+
+// TaggedCache stores entries under versioned keys.
+// Every tag has a counter in Redis and the counters of its tags are part of the key of an entry, e.g.
+// tickitz:movie:12:details@0.3.1, the first counter is the one of tagAll. Invalidating a tag increments its
+// counter, so old entries are never read again and expire with their TTL. A request that read the old counters before a write can only store its
+// (stale) result under the old key, nobody reads that one anymore. Serving stale entries therefore only
+// happens after the TTL, never after an invalidation.
+type TaggedCache struct {
+	rdb *redis.Client
+}
+
+func NewTaggedCache(rdb *redis.Client) *TaggedCache {
+	return &TaggedCache{rdb: rdb}
+}
+
+// Fetch is the cache-aside read, key is without the tickitz: prefix.
+// A fresh entry is returned as is, a stale one is returned and refreshed in the background. On a miss only
+// one request per key fetches, the others wait for its result. When the tag versions can't be read (Redis
+// down or its circuit open) the cache is skipped and fetchFunc answers directly. Errors of fetchFunc are
+// returned to every waiting caller.
+func (t *TaggedCache) Fetch(
+	ctx context.Context,
+	key string,
+	tags []string,
+	policy CachePolicy,
+	dest interface{},
+	fetchFunc func(ctx context.Context) (interface{}, error),
+) error {
+	versionedKey, err := t.versionedKey(ctx, key, tags)
+	if err != nil {
+		log.Println("redis error, cache skipped.\nCause: ", err.Error())
+		data, err := fetchFunc(ctx)
+		if err != nil {
+			return err
+		}
+		bt, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(bt, dest)
+	}
+
+	if entry, ok := t.get(ctx, versionedKey); ok {
+		if time.Now().UnixMilli() >= entry.FreshUntil {
+			t.refreshAsync(ctx, versionedKey, policy, fetchFunc)
+		}
+		return entry.decode(dest, policy)
+	}
+
+	// the fetch is shared, so it must not stop when the request that started it is cancelled
+	ch := cacheFlights.DoChan(versionedKey, func() (interface{}, error) {
+		fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheFetchTimeout)
+		defer cancel()
+		return t.load(fetchCtx, versionedKey, policy, fetchFunc)
+	})
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return res.Err
+		}
+		return res.Val.(*cacheEntry).decode(dest, policy)
+	}
+}
+
+// Invalidate bumps the version of every tag, the entries built with the old versions are not read anymore
+func (t *TaggedCache) Invalidate(ctx context.Context, tags ...string) {
+	if len(tags) == 0 {
+		return
+	}
+	pipe := t.rdb.Pipeline()
+	for _, tag := range tags {
+		pipe.Incr(ctx, tagKeyPrefix+tag)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		invalidationLost.Store(true)
+		log.Printf("failed to invalidate cache tags %v: %v", tags, err)
+	}
+}
+
+// load answers a miss. Only the instance that gets the lock fetches, the others wait for the entry it
+// stores and fetch themselves when it takes too long.
+func (t *TaggedCache) load(ctx context.Context, key string, policy CachePolicy, fetchFunc func(ctx context.Context) (interface{}, error)) (*cacheEntry, error) {
+	token, locked := t.lock(ctx, key)
+	if locked {
+		defer t.unlock(ctx, key, token)
+	} else if entry, ok := t.waitFor(ctx, key); ok {
+		return entry, nil
+	}
+	return t.fetchAndStore(ctx, key, policy, fetchFunc)
+}
+
+// refreshAsync replaces a stale entry, at most one refresh per key runs in all instances
+func (t *TaggedCache) refreshAsync(ctx context.Context, key string, policy CachePolicy, fetchFunc func(ctx context.Context) (interface{}, error)) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cacheFetchTimeout)
+		defer cancel()
+
+		_, _, _ = cacheFlights.Do("refresh:"+key, func() (interface{}, error) {
+			token, locked := t.lock(ctx, key)
+			if !locked {
+				return nil, nil
+			}
+			defer t.unlock(ctx, key, token)
+
+			if _, err := t.fetchAndStore(ctx, key, policy, fetchFunc); err != nil {
+				log.Printf("failed to refresh cache for key %s: %v", key, err)
+			}
+			return nil, nil
+		})
+	}()
+}
+
+func (t *TaggedCache) fetchAndStore(ctx context.Context, key string, policy CachePolicy, fetchFunc func(ctx context.Context) (interface{}, error)) (*cacheEntry, error) {
+	data, err := fetchFunc(ctx)
+	if err != nil {
+		if policy.NotFound == nil || !errors.Is(err, policy.NotFound) {
+			return nil, err
+		}
+		entry := &cacheEntry{NotFound: true}
+		t.store(ctx, key, entry, policy.NotFoundTTL, 0)
+		return entry, nil
+	}
+
+	bt, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{Data: bt}
+	t.store(ctx, key, entry, policy.TTL, policy.StaleFor)
+	return entry, nil
+}
+
+func (t *TaggedCache) get(ctx context.Context, key string) (*cacheEntry, bool) {
+	bt, err := t.rdb.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Println("Redis Error. \nCause: ", err.Error())
+		}
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(bt, &entry); err != nil {
+		log.Println("internal server error.\nCause: ", err.Error())
+		return nil, false
+	}
+	return &entry, true
+}
+
+func (t *TaggedCache) store(ctx context.Context, key string, entry *cacheEntry, ttl, staleFor time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	entry.FreshUntil = time.Now().Add(ttl).UnixMilli()
+	bt, err := json.Marshal(entry)
+	if err != nil {
+		log.Println("internal server error.\nCause: ", err.Error())
+		return
+	}
+	if err := t.rdb.Set(ctx, key, bt, ttl+staleFor).Err(); err != nil {
+		log.Println("redis error.\nCause: ", err.Error())
+	}
+}
+
+// lock takes the fetch lock of key. When Redis fails the caller fetches anyway (locked is true, with an
+// empty token that unlock ignores).
+func (t *TaggedCache) lock(ctx context.Context, key string) (token string, locked bool) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	token = hex.EncodeToString(b)
+
+	ok, err := t.rdb.SetNX(ctx, key+":lock", token, cacheLockTTL).Result()
+	if err != nil {
+		log.Println("redis error.\nCause: ", err.Error())
+		return "", true
+	}
+	return token, ok
+}
+
+// unlock only deletes our own lock, it could have expired and been taken by another instance
+func (t *TaggedCache) unlock(ctx context.Context, key, token string) {
+	if token == "" {
+		return
+	}
+	if err := unlockScript.Run(ctx, t.rdb, []string{key + ":lock"}, token).Err(); err != nil {
+		log.Println("redis error.\nCause: ", err.Error())
+	}
+}
+
+func (t *TaggedCache) waitFor(ctx context.Context, key string) (*cacheEntry, bool) {
+	deadline := time.Now().Add(cacheLockWait)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-time.After(50 * time.Millisecond):
+		}
+		if entry, ok := t.get(ctx, key); ok {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+func (t *TaggedCache) versionedKey(ctx context.Context, key string, tags []string) (string, error) {
+	if invalidationLost.Swap(false) {
+		if err := t.rdb.Incr(ctx, tagKeyPrefix+tagAll).Err(); err != nil {
+			invalidationLost.Store(true)
+			return "", err
+		}
+		log.Println("cache flushed, invalidations were lost while redis was unavailable")
+	}
+	tags = append([]string{tagAll}, tags...)
+
+	tagKeys := make([]string, len(tags))
+	for i, tag := range tags {
+		tagKeys[i] = tagKeyPrefix + tag
+	}
+	values, err := t.rdb.MGet(ctx, tagKeys...).Result()
+	if err != nil {
+		return "", err
+	}
+
+	// a tag that was never invalidated has no counter yet, that is version 0
+	versions := make([]string, len(values))
+	for i, v := range values {
+		versions[i] = "0"
+		if s, ok := v.(string); ok {
+			versions[i] = s
+		}
+	}
+	return cacheKeyPrefix + key + "@" + strings.Join(versions, "."), nil
+}
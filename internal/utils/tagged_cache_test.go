@@ -0,0 +1,250 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errTestNotFound = errors.New("movie not found")
+
+// countingFetch returns value and counts how often the cache had to ask the database
+func countingFetch(calls *atomic.Int32, value string) func(context.Context) (interface{}, error) {
+	return func(context.Context) (interface{}, error) {
+		calls.Add(1)
+		return value, nil
+	}
+}
+
+func resetInvalidationLost(t *testing.T) {
+	t.Helper()
+	invalidationLost.Store(false)
+	t.Cleanup(func() { invalidationLost.Store(false) })
+}
+
+func TestTaggedCacheHit(t *testing.T) {
+	resetInvalidationLost(t)
+	_, rdb := newFakeRedis(t)
+	c := NewTaggedCache(rdb)
+	ctx := context.Background()
+	policy := CachePolicy{TTL: time.Minute}
+
+	var calls atomic.Int32
+	for i := 0; i < 3; i++ {
+		var got string
+		if err := c.Fetch(ctx, "movie:1:details", []string{MovieTag(1)}, policy, &got, countingFetch(&calls, "Dune")); err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		if got != "Dune" {
+			t.Fatalf("Fetch = %q, want Dune", got)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fetchFunc called %d times, want 1", n)
+	}
+}
+
+func TestTaggedCacheCoalescesMisses(t *testing.T) {
+	resetInvalidationLost(t)
+	_, rdb := newFakeRedis(t)
+	c := NewTaggedCache(rdb)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	slowFetch := func(context.Context) (interface{}, error) {
+		calls.Add(1)
+		<-release
+		return "Dune", nil
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var got string
+			if err := c.Fetch(ctx, "movies:popular", []string{TagMovieLists}, CachePolicy{TTL: time.Minute}, &got, slowFetch); err != nil {
+				errs <- err
+			} else if got != "Dune" {
+				errs <- fmt.Errorf("Fetch = %q, want Dune", got)
+			}
+		}()
+	}
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fetchFunc called %d times for 10 concurrent misses, want 1", n)
+	}
+}
+
+func TestTaggedCacheServesStaleWhileRevalidating(t *testing.T) {
+	resetInvalidationLost(t)
+	_, rdb := newFakeRedis(t)
+	c := NewTaggedCache(rdb)
+	ctx := context.Background()
+	policy := CachePolicy{TTL: 50 * time.Millisecond, StaleFor: time.Minute}
+
+	var calls atomic.Int32
+	var got string
+	if err := c.Fetch(ctx, "movies:upcoming", []string{TagMovieLists}, policy, &got, countingFetch(&calls, "v1")); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	// stale: the old value is answered right away, the refresh runs in the background
+	if err := c.Fetch(ctx, "movies:upcoming", []string{TagMovieLists}, policy, &got, countingFetch(&calls, "v2")); err != nil {
+		t.Fatalf("Fetch stale: %v", err)
+	}
+	if got != "v1" {
+		t.Fatalf("stale Fetch = %q, want v1", got)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for got != "v2" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if err := c.Fetch(ctx, "movies:upcoming", []string{TagMovieLists}, policy, &got, countingFetch(&calls, "v3")); err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+	}
+	if got != "v2" {
+		t.Fatalf("Fetch after the refresh = %q, want v2", got)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("fetchFunc called %d times, want 2 (first load and one refresh)", n)
+	}
+}
+
+func TestTaggedCacheNegativeCaching(t *testing.T) {
+	resetInvalidationLost(t)
+	_, rdb := newFakeRedis(t)
+	c := NewTaggedCache(rdb)
+	ctx := context.Background()
+	policy := CachePolicy{TTL: time.Minute, NotFound: errTestNotFound, NotFoundTTL: time.Minute}
+
+	var calls atomic.Int32
+	notFound := func(context.Context) (interface{}, error) {
+		calls.Add(1)
+		return nil, fmt.Errorf("movie 404: %w", errTestNotFound)
+	}
+	for i := 0; i < 3; i++ {
+		var got string
+		if err := c.Fetch(ctx, "movie:404:details", []string{MovieTag(404)}, policy, &got, notFound); !errors.Is(err, errTestNotFound) {
+			t.Fatalf("Fetch error = %v, want errTestNotFound", err)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("fetchFunc called %d times, want 1 (not found is cached)", n)
+	}
+
+	// the movie is created, invalidating its tag ends the negative entry
+	c.Invalidate(ctx, MovieTag(404))
+	var got string
+	if err := c.Fetch(ctx, "movie:404:details", []string{MovieTag(404)}, policy, &got, countingFetch(&calls, "New movie")); err != nil || got != "New movie" {
+		t.Fatalf("Fetch after invalidate = %q, %v, want New movie", got, err)
+	}
+}
+
+func TestTaggedCacheDoesNotCacheErrors(t *testing.T) {
+	resetInvalidationLost(t)
+	_, rdb := newFakeRedis(t)
+	c := NewTaggedCache(rdb)
+	ctx := context.Background()
+	policy := CachePolicy{TTL: time.Minute, NotFound: errTestNotFound, NotFoundTTL: time.Minute}
+
+	errDB := errors.New("connection refused")
+	var calls atomic.Int32
+	failing := func(context.Context) (interface{}, error) {
+		calls.Add(1)
+		return nil, errDB
+	}
+	for i := 0; i < 2; i++ {
+		var got string
+		if err := c.Fetch(ctx, "movie:1:details", []string{MovieTag(1)}, policy, &got, failing); !errors.Is(err, errDB) {
+			t.Fatalf("Fetch error = %v, want errDB", err)
+		}
+	}
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("fetchFunc called %d times, want 2 (errors are not cached)", n)
+	}
+}
+
+func TestTaggedCacheInvalidateOnlyTouchesItsTags(t *testing.T) {
+	resetInvalidationLost(t)
+	_, rdb := newFakeRedis(t)
+	c := NewTaggedCache(rdb)
+	ctx := context.Background()
+	policy := CachePolicy{TTL: time.Minute}
+
+	var movie1, movie2 atomic.Int32
+	var got string
+	c.Fetch(ctx, "movie:1:details", []string{MovieTag(1)}, policy, &got, countingFetch(&movie1, "one"))
+	c.Fetch(ctx, "movie:2:details", []string{MovieTag(2)}, policy, &got, countingFetch(&movie2, "two"))
+
+	c.Invalidate(ctx, MovieTag(1))
+
+	c.Fetch(ctx, "movie:1:details", []string{MovieTag(1)}, policy, &got, countingFetch(&movie1, "one"))
+	c.Fetch(ctx, "movie:2:details", []string{MovieTag(2)}, policy, &got, countingFetch(&movie2, "two"))
+	if movie1.Load() != 2 || movie2.Load() != 1 {
+		t.Fatalf("fetches = movie 1: %d, movie 2: %d, want 2 and 1", movie1.Load(), movie2.Load())
+	}
+}
+
+// An Invalidate that could not reach Redis is not lost: the next request that reaches Redis bumps tagAll,
+// every entry from before is dropped
+func TestTaggedCacheBumpsAllAfterLostInvalidation(t *testing.T) {
+	resetInvalidationLost(t)
+	f, rdb := newFakeRedis(t)
+	c := NewTaggedCache(rdb)
+	down := NewTaggedCache(deadRedisClient(t))
+	ctx := context.Background()
+	policy := CachePolicy{TTL: time.Minute}
+
+	var calls atomic.Int32
+	var got string
+	if err := c.Fetch(ctx, "movie:1:details", []string{MovieTag(1)}, policy, &got, countingFetch(&calls, "old title")); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+
+	// the movie is edited while Redis is down
+	down.Invalidate(ctx, MovieTag(1))
+	if !invalidationLost.Load() {
+		t.Fatal("a failed Invalidate must be remembered")
+	}
+
+	// still down: the database answers and the bump is kept for later
+	if err := down.Fetch(ctx, "movie:1:details", []string{MovieTag(1)}, policy, &got, countingFetch(&calls, "new title")); err != nil || got != "new title" {
+		t.Fatalf("Fetch without Redis = %q, %v, want new title from fetchFunc", got, err)
+	}
+	if !invalidationLost.Load() {
+		t.Fatal("the bump must wait until Redis is reachable")
+	}
+
+	// Redis is back: the old entry must not be served
+	if err := c.Fetch(ctx, "movie:1:details", []string{MovieTag(1)}, policy, &got, countingFetch(&calls, "new title")); err != nil || got != "new title" {
+		t.Fatalf("Fetch after Redis is back = %q, %v, want new title", got, err)
+	}
+	if invalidationLost.Load() {
+		t.Fatal("the flag must be cleared after the bump")
+	}
+	f.mu.Lock()
+	version := f.data[tagKeyPrefix+tagAll].val
+	f.mu.Unlock()
+	if version != "1" {
+		t.Fatalf("version of tagAll = %q, want 1", version)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("fetchFunc called %d times, want 3", n)
+	}
+}
@@ -1,8 +1,16 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
 )
@@ -30,3 +38,372 @@ func ValidatePassword(user models.ChangePasswordRequest) error {
 	}
 	return nil
 }
+
+var (
+	MovieStatuses = []string{"now_showing", "upcoming"}
+	// MovieLifecycleStatuses are the values of movies.status, MovieStatuses is the now_showing / upcoming filter
+	MovieLifecycleStatuses = []string{"draft", "published", "archived"}
+	MovieSortFields        = []string{"title", "release_date", "popularity", "rating"}
+	AdminMovieSortFields   = []string{"updated_at", "created_at", "title", "release_date", "schedule_count", "tickets_sold"}
+)
+
+func ValidateMovieFilter(filter models.MovieFilter) error {
+	if filter.Status != "" && !slices.Contains(MovieStatuses, filter.Status) {
+		return errors.New("status must be now_showing or upcoming")
+	}
+	if filter.Sort != "" && !slices.Contains(MovieSortFields, filter.Sort) {
+		return errors.New("sort must be one of title, release_date, popularity, rating")
+	}
+	if filter.Order != "" && filter.Order != "asc" && filter.Order != "desc" {
+		return errors.New("order must be asc or desc")
+	}
+	if filter.ReleasedFrom != nil && filter.ReleasedTo != nil && filter.ReleasedFrom.After(*filter.ReleasedTo) {
+		return errors.New("release_from must be before release_to")
+	}
+	if (filter.MinDuration != nil && *filter.MinDuration < 0) || (filter.MaxDuration != nil && *filter.MaxDuration < 0) {
+		return errors.New("duration must not be negative")
+	}
+	if filter.MinDuration != nil && filter.MaxDuration != nil && *filter.MinDuration > *filter.MaxDuration {
+		return errors.New("min_duration must not be greater than max_duration")
+	}
+	return nil
+}
+
+func ValidateAdminMovieFilter(filter models.AdminMovieFilter) error {
+	if filter.Status != "" && !slices.Contains(MovieLifecycleStatuses, filter.Status) {
+		return errors.New("status must be draft, published or archived")
+	}
+	if filter.Sort != "" && !slices.Contains(AdminMovieSortFields, filter.Sort) {
+		return errors.New("sort must be one of " + strings.Join(AdminMovieSortFields, ", "))
+	}
+	if filter.Order != "" && filter.Order != "asc" && filter.Order != "desc" {
+		return errors.New("order must be asc or desc")
+	}
+	if filter.ReleasedFrom != nil && filter.ReleasedTo != nil && filter.ReleasedFrom.After(*filter.ReleasedTo) {
+		return errors.New("release_from must be before release_to")
+	}
+	if len([]rune(filter.Query)) > 100 {
+		return errors.New("q must be at most 100 characters")
+	}
+	return nil
+}
+
+var (
+	reYouTubeID = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+	reVimeoID   = regexp.MustCompile(`^[0-9]{6,12}$`)
+)
+
+// ParseTrailer returns provider and video id from a YouTube / Vimeo url, or checks provider + id
+// when no url is given. Accepted urls: youtube.com/watch?v=, youtu.be/, youtube.com/embed/,
+// youtube.com/shorts/, vimeo.com/<id>, player.vimeo.com/video/<id>.
+func ParseTrailer(req models.AddTrailerRequest) (provider string, videoID string, err error) {
+	if req.URL == "" {
+		provider, videoID = strings.ToLower(req.Provider), req.VideoID
+	} else {
+		u, parseErr := url.Parse(strings.TrimSpace(req.URL))
+		if parseErr != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return "", "", errors.New("url must be a http(s) YouTube or Vimeo link")
+		}
+		host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+		host = strings.TrimPrefix(host, "m.")
+		segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+		switch host {
+		case "youtube.com", "youtube-nocookie.com":
+			provider = "youtube"
+			if segments[0] == "watch" {
+				videoID = u.Query().Get("v")
+			} else if len(segments) == 2 && (segments[0] == "embed" || segments[0] == "shorts") {
+				videoID = segments[1]
+			}
+		case "youtu.be":
+			provider, videoID = "youtube", segments[0]
+		case "vimeo.com":
+			provider, videoID = "vimeo", segments[len(segments)-1]
+		case "player.vimeo.com":
+			if len(segments) == 2 && segments[0] == "video" {
+				provider, videoID = "vimeo", segments[1]
+			}
+		default:
+			return "", "", errors.New("only YouTube and Vimeo trailers are supported")
+		}
+	}
+
+	switch provider {
+	case "youtube":
+		if !reYouTubeID.MatchString(videoID) {
+			return "", "", errors.New("invalid YouTube video id")
+		}
+	case "vimeo":
+		if !reVimeoID.MatchString(videoID) {
+			return "", "", errors.New("invalid Vimeo video id")
+		}
+	default:
+		return "", "", errors.New("provider must be youtube or vimeo")
+	}
+	return provider, videoID, nil
+}
+
+// DecodeStrictJSON decodes data into v and reports unknown fields and wrong types per field,
+// so a typo like "genres" instead of "genre_ids" is not silently ignored
+func DecodeStrictJSON(data []byte, v any) models.FieldErrors {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after the JSON object")
+	}
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return models.FieldErrors{{Field: typeErr.Field, Message: "must be " + jsonTypeName(typeErr.Type.Kind().String())}}
+	case errors.As(err, &syntaxErr):
+		return models.FieldErrors{{Field: "body", Message: fmt.Sprintf("invalid JSON at offset %d", syntaxErr.Offset)}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return models.FieldErrors{{Field: field, Message: "unknown field"}}
+	default:
+		return models.FieldErrors{{Field: "body", Message: err.Error()}}
+	}
+}
+
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "int", "int32", "int64":
+		return "an integer"
+	case "slice":
+		return "an array"
+	case "struct", "ptr":
+		return "an object"
+	default:
+		return "a " + kind
+	}
+}
+
+// ValidateMovieInput checks the shape of the payload, whether the ids exist is checked by the repository
+func ValidateMovieInput(input models.MovieInput) models.FieldErrors {
+	v := movieValidator{}
+	v.title(input.Title)
+	v.releaseDate(input.ReleaseDate)
+	v.duration(input.DurationMinutes)
+	v.ageRating(input.AgeRatingID)
+	v.director(input.Director)
+	v.genres(input.GenreIDs)
+	v.cast(input.Cast)
+	if input.Schedule != nil {
+		v.schedule(*input.Schedule)
+	}
+	if input.Status != "" && input.Status != "draft" && input.Status != "published" {
+		v.add("status", "must be draft or published")
+	}
+	v.lifecycle(input.PublishAt, input.ArchiveAt)
+	return v.errs
+}
+
+// ValidateMoviePatch checks the fields that are sent with the same rules as ValidateMovieInput
+func ValidateMoviePatch(patch models.MoviePatch) models.FieldErrors {
+	v := movieValidator{}
+	if patch.Title != nil {
+		v.title(*patch.Title)
+	}
+	if patch.ReleaseDate != nil {
+		v.releaseDate(*patch.ReleaseDate)
+	}
+	if patch.DurationMinutes != nil {
+		v.duration(*patch.DurationMinutes)
+	}
+	if patch.AgeRatingID != nil {
+		v.ageRating(*patch.AgeRatingID)
+	}
+	if patch.Director != nil {
+		v.director(*patch.Director)
+	}
+	if patch.GenreIDs != nil {
+		v.genres(*patch.GenreIDs)
+	}
+	if patch.Cast != nil {
+		v.cast(*patch.Cast)
+	}
+	if patch.Schedule != nil {
+		v.schedule(*patch.Schedule)
+	}
+	var publishAt, archiveAt string
+	if patch.PublishAt != nil {
+		publishAt = *patch.PublishAt
+	}
+	if patch.ArchiveAt != nil {
+		archiveAt = *patch.ArchiveAt
+	}
+	v.lifecycle(publishAt, archiveAt)
+	return v.errs
+}
+
+type movieValidator struct {
+	errs models.FieldErrors
+}
+
+func (v *movieValidator) add(field, msg string) {
+	v.errs = append(v.errs, models.FieldError{Field: field, Message: msg})
+}
+
+func (v *movieValidator) title(title string) {
+	if strings.TrimSpace(title) == "" {
+		v.add("title", "is required")
+	} else if len(title) > 255 {
+		v.add("title", "must be at most 255 characters")
+	}
+}
+
+func (v *movieValidator) releaseDate(date string) {
+	v.date("release_date", date)
+}
+
+func (v *movieValidator) date(field, date string) {
+	if date == "" {
+		v.add(field, "is required")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		v.add(field, "must be a date (YYYY-MM-DD)")
+	}
+}
+
+func (v *movieValidator) duration(minutes int) {
+	if minutes < 1 || minutes > 600 {
+		v.add("duration_minutes", "must be between 1 and 600")
+	}
+}
+
+func (v *movieValidator) ageRating(id int) {
+	if id <= 0 {
+		v.add("age_rating_id", "is required")
+	}
+}
+
+func (v *movieValidator) director(name string) {
+	if strings.TrimSpace(name) == "" {
+		v.add("director", "is required")
+	}
+}
+
+func (v *movieValidator) genres(ids []int) {
+	if len(ids) == 0 {
+		v.add("genre_ids", "must contain at least one genre")
+	}
+	v.ids("genre_ids", ids)
+}
+
+func (v *movieValidator) cast(names []string) {
+	seen := map[string]bool{}
+	for i, name := range names {
+		key := strings.ToLower(strings.Join(strings.Fields(name), " "))
+		field := fmt.Sprintf("cast[%d]", i)
+		switch {
+		case key == "":
+			v.add(field, "can't be empty")
+		case seen[key]:
+			v.add(field, "is listed twice")
+		}
+		seen[key] = true
+	}
+}
+
+func (v *movieValidator) schedule(s models.ScheduleInput) {
+	v.date("schedule.show_date", s.ShowDate)
+
+	lists := []struct {
+		field string
+		ids   []int
+	}{
+		{"schedule.city_ids", s.CityIDs},
+		{"schedule.cinema_ids", s.CinemaIDs},
+		{"schedule.show_time_ids", s.ShowTimeIDs},
+	}
+	for _, l := range lists {
+		if len(l.ids) == 0 {
+			v.add(l.field, "must contain at least one id")
+		}
+		v.ids(l.field, l.ids)
+	}
+}
+
+// lifecycle checks the scheduled publish / archive dates, empty means not scheduled
+func (v *movieValidator) lifecycle(publishAt, archiveAt string) {
+	var publish, archive time.Time
+	var err error
+	if publishAt != "" {
+		if publish, err = time.Parse(time.RFC3339, publishAt); err != nil {
+			v.add("publish_at", "must be a timestamp (RFC 3339)")
+		}
+	}
+	if archiveAt != "" {
+		if archive, err = time.Parse(time.RFC3339, archiveAt); err != nil {
+			v.add("archive_at", "must be a timestamp (RFC 3339)")
+		}
+	}
+	if !publish.IsZero() && !archive.IsZero() && !archive.After(publish) {
+		v.add("archive_at", "must be after publish_at")
+	}
+}
+
+func (v *movieValidator) ids(field string, ids []int) {
+	seen := map[int]bool{}
+	for i, id := range ids {
+		switch {
+		case id <= 0:
+			v.add(fmt.Sprintf("%s[%d]", field, i), "must be a positive id")
+		case seen[id]:
+			v.add(fmt.Sprintf("%s[%d]", field, i), "is listed twice")
+		}
+		seen[id] = true
+	}
+}
+
+var (
+	rePhoneSeparators = regexp.MustCompile(`[\s\-().]`)
+	// phone_number is varchar(13): an optional + and 8 to 12 digits
+	rePhoneNumber = regexp.MustCompile(`^\+?[0-9]{8,12}$`)
+	reSpaces      = regexp.MustCompile(`\s+`)
+)
+
+// ValidateProfileEdit normalizes the fields that are sent (names are trimmed, separators are removed from
+// the phone number) and checks them against the columns of user_profiles. A field set to null is not checked.
+func ValidateProfileEdit(body *models.EditUserProfile) models.FieldErrors {
+	var errs models.FieldErrors
+	for _, f := range []struct {
+		field string
+		value *models.OptionalString
+	}{{"first_name", &body.FirstName}, {"last_name", &body.LastName}} {
+		if !f.value.Set || f.value.Null {
+			continue
+		}
+		if f.value.Invalid {
+			errs = append(errs, models.FieldError{Field: f.field, Message: "must be a string or null"})
+			continue
+		}
+		f.value.Value = reSpaces.ReplaceAllString(strings.TrimSpace(f.value.Value), " ")
+		if f.value.Value == "" {
+			errs = append(errs, models.FieldError{Field: f.field, Message: "must not be empty, use null to clear it"})
+		} else if len([]rune(f.value.Value)) > 30 {
+			errs = append(errs, models.FieldError{Field: f.field, Message: "must be at most 30 characters"})
+		}
+	}
+
+	if body.PhoneNumber.Invalid {
+		errs = append(errs, models.FieldError{Field: "phone_number", Message: "must be a string or null"})
+	} else if body.PhoneNumber.Set && !body.PhoneNumber.Null {
+		body.PhoneNumber.Value = rePhoneSeparators.ReplaceAllString(body.PhoneNumber.Value, "")
+		if !rePhoneNumber.MatchString(body.PhoneNumber.Value) {
+			errs = append(errs, models.FieldError{Field: "phone_number", Message: "must be 8 to 12 digits, optionally starting with +"})
+		}
+	}
+
+	if !body.FirstName.Set && !body.LastName.Set && !body.PhoneNumber.Set && body.Img == nil {
+		errs = append(errs, models.FieldError{Field: "body", Message: "nothing to update"})
+	}
+	return errs
+}
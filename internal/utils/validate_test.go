@@ -0,0 +1,317 @@
+package utils
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/radifan9/tickitz-ticketing-backend/internal/models"
+)
+
+func TestDecodeStrictJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		field   string
+		message string
+	}{
+		{name: "unknown field", body: `{"title":"Dune","genres":[1]}`, field: "genres", message: "unknown field"},
+		{name: "string instead of integer", body: `{"duration_minutes":"120"}`, field: "duration_minutes", message: "must be an integer"},
+		{name: "fraction instead of integer", body: `{"age_rating_id":1.5}`, field: "age_rating_id", message: "must be an integer"},
+		{name: "number instead of string", body: `{"title":5}`, field: "title", message: "must be a string"},
+		{name: "string instead of array", body: `{"cast":"Timothée Chalamet"}`, field: "cast", message: "must be an array"},
+		{name: "nested field", body: `{"schedule":{"city_ids":"1"}}`, field: "schedule.city_ids", message: "must be an array"},
+		{name: "syntax error", body: `{"title":"Dune",}`, field: "body", message: "invalid JSON at offset"},
+		{name: "cut off", body: `{"title":`, field: "body", message: "unexpected EOF"},
+		{name: "second object", body: `{"title":"Dune"} {}`, field: "body", message: "unexpected data after the JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var input models.MovieInput
+			errs := DecodeStrictJSON([]byte(tt.body), &input)
+			if len(errs) != 1 {
+				t.Fatalf("errors = %+v, want one", errs)
+			}
+			if errs[0].Field != tt.field || !strings.HasPrefix(errs[0].Message, tt.message) {
+				t.Errorf("error = %s: %s, want %s: %s", errs[0].Field, errs[0].Message, tt.field, tt.message)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		var patch models.MoviePatch
+		if errs := DecodeStrictJSON([]byte(`{"title":"Dune","genre_ids":[1,2]}`), &patch); errs != nil {
+			t.Fatalf("errors = %+v, want none", errs)
+		}
+		if patch.Title == nil || *patch.Title != "Dune" || patch.GenreIDs == nil || len(*patch.GenreIDs) != 2 {
+			t.Errorf("patch = %+v", patch)
+		}
+	})
+}
+
+func validMovieInput() models.MovieInput {
+	return models.MovieInput{
+		Title:           "Dune: Part Two",
+		ReleaseDate:     "2024-03-01",
+		DurationMinutes: 166,
+		AgeRatingID:     3,
+		Director:        "Denis Villeneuve",
+		GenreIDs:        []int{1, 4},
+		Cast:            []string{"Timothée Chalamet", "Zendaya"},
+		Schedule: &models.ScheduleInput{
+			ShowDate:    "2024-03-01",
+			CityIDs:     []int{1},
+			CinemaIDs:   []int{1, 2},
+			ShowTimeIDs: []int{3},
+		},
+	}
+}
+
+// fieldsOf lists "field: message" of every error, in order
+func fieldsOf(errs models.FieldErrors) []string {
+	out := []string{}
+	for _, e := range errs {
+		out = append(out, e.Field+": "+e.Message)
+	}
+	return out
+}
+
+func TestValidateMovieInput(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*models.MovieInput)
+		want   []string
+	}{
+		{name: "valid", modify: func(*models.MovieInput) {}, want: []string{}},
+		{name: "without schedule", modify: func(in *models.MovieInput) { in.Schedule = nil }, want: []string{}},
+		{
+			name: "several fields are reported together",
+			modify: func(in *models.MovieInput) {
+				in.Title = "  "
+				in.ReleaseDate = "01-03-2024"
+				in.DurationMinutes = 0
+				in.AgeRatingID = 0
+				in.Director = ""
+				in.GenreIDs = nil
+			},
+			want: []string{
+				"title: is required",
+				"release_date: must be a date (YYYY-MM-DD)",
+				"duration_minutes: must be between 1 and 600",
+				"age_rating_id: is required",
+				"director: is required",
+				"genre_ids: must contain at least one genre",
+			},
+		},
+		{
+			name:   "title too long",
+			modify: func(in *models.MovieInput) { in.Title = strings.Repeat("a", 256) },
+			want:   []string{"title: must be at most 255 characters"},
+		},
+		{
+			name:   "empty cast name",
+			modify: func(in *models.MovieInput) { in.Cast = []string{"Zendaya", " \t "} },
+			want:   []string{"cast[1]: can't be empty"},
+		},
+		{
+			name:   "cast listed twice with other spacing and case",
+			modify: func(in *models.MovieInput) { in.Cast = []string{"Austin Butler", "austin  butler"} },
+			want:   []string{"cast[1]: is listed twice"},
+		},
+		{
+			name:   "bad genre ids",
+			modify: func(in *models.MovieInput) { in.GenreIDs = []int{1, 0, 1, -2} },
+			want:   []string{"genre_ids[1]: must be a positive id", "genre_ids[2]: is listed twice", "genre_ids[3]: must be a positive id"},
+		},
+		{
+			name: "bad schedule ids",
+			modify: func(in *models.MovieInput) {
+				in.Schedule = &models.ScheduleInput{ShowDate: "2024-03-01", CityIDs: []int{}, CinemaIDs: []int{2, 2}, ShowTimeIDs: []int{-1}}
+			},
+			want: []string{
+				"schedule.city_ids: must contain at least one id",
+				"schedule.cinema_ids[1]: is listed twice",
+				"schedule.show_time_ids[0]: must be a positive id",
+			},
+		},
+		{
+			name:   "schedule without date",
+			modify: func(in *models.MovieInput) { in.Schedule.ShowDate = "" },
+			want:   []string{"schedule.show_date: is required"},
+		},
+		{
+			name:   "unknown status",
+			modify: func(in *models.MovieInput) { in.Status = "archived" },
+			want:   []string{"status: must be draft or published"},
+		},
+		{
+			name: "scheduled publish and archive",
+			modify: func(in *models.MovieInput) {
+				in.Status = "draft"
+				in.PublishAt = "2024-02-20T00:00:00+07:00"
+				in.ArchiveAt = "2024-05-01T00:00:00+07:00"
+			},
+			want: []string{},
+		},
+		{
+			name: "publish_at after archive_at",
+			modify: func(in *models.MovieInput) {
+				in.PublishAt = "2024-05-01T00:00:00+07:00"
+				in.ArchiveAt = "2024-02-20T00:00:00+07:00"
+			},
+			want: []string{"archive_at: must be after publish_at"},
+		},
+		{
+			name: "publish_at equal to archive_at",
+			modify: func(in *models.MovieInput) {
+				in.PublishAt = "2024-05-01T07:00:00+07:00"
+				in.ArchiveAt = "2024-05-01T00:00:00Z"
+			},
+			want: []string{"archive_at: must be after publish_at"},
+		},
+		{
+			name:   "publish_at is not RFC 3339",
+			modify: func(in *models.MovieInput) { in.PublishAt = "2024-05-01" },
+			want:   []string{"publish_at: must be a timestamp (RFC 3339)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := validMovieInput()
+			tt.modify(&input)
+			if got := fieldsOf(ValidateMovieInput(input)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateMoviePatch(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+
+	tests := []struct {
+		name  string
+		patch models.MoviePatch
+		want  []string
+	}{
+		{name: "empty patch", patch: models.MoviePatch{}, want: []string{}},
+		{name: "only the title", patch: models.MoviePatch{Title: str("Dune")}, want: []string{}},
+		{
+			name:  "fields that are sent are checked like in ValidateMovieInput",
+			patch: models.MoviePatch{Title: str(""), DurationMinutes: num(601), GenreIDs: &[]int{}, Cast: &[]string{""}},
+			want: []string{
+				"title: is required",
+				"duration_minutes: must be between 1 and 600",
+				"genre_ids: must contain at least one genre",
+				"cast[0]: can't be empty",
+			},
+		},
+		{
+			name:  "bad schedule ids",
+			patch: models.MoviePatch{Schedule: &models.ScheduleInput{ShowDate: "2024-03-01", CityIDs: []int{0}, CinemaIDs: []int{1}, ShowTimeIDs: []int{}}},
+			want:  []string{"schedule.city_ids[0]: must be a positive id", "schedule.show_time_ids: must contain at least one id"},
+		},
+		{
+			name:  "publish_at after archive_at",
+			patch: models.MoviePatch{PublishAt: str("2024-05-01T00:00:00Z"), ArchiveAt: str("2024-04-01T00:00:00Z")},
+			want:  []string{"archive_at: must be after publish_at"},
+		},
+		{
+			name:  "empty dates remove the schedule",
+			patch: models.MoviePatch{PublishAt: str(""), ArchiveAt: str("")},
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldsOf(ValidateMoviePatch(tt.patch)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("errors = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateProfileEdit(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+		// values after the normalization, for the fields that are sent
+		first, last, phone string
+	}{
+		{name: "absent fields are not checked", body: `{"first_name":"Budi"}`, want: []string{}, first: "Budi"},
+		{name: "null clears the field", body: `{"first_name":null,"last_name":null,"phone_number":null}`, want: []string{}},
+		{
+			name: "wrong types",
+			body: `{"first_name":5,"last_name":["Santoso"],"phone_number":true}`,
+			want: []string{"first_name: must be a string or null", "last_name: must be a string or null", "phone_number: must be a string or null"},
+		},
+		{name: "whitespace is collapsed", body: `{"first_name":"  Budi\t  Setiawan ","last_name":" Santoso "}`, want: []string{}, first: "Budi Setiawan", last: "Santoso"},
+		{name: "only whitespace", body: `{"last_name":" \n "}`, want: []string{"last_name: must not be empty, use null to clear it"}},
+		{name: "empty string", body: `{"first_name":""}`, want: []string{"first_name: must not be empty, use null to clear it"}},
+		{name: "30 characters", body: `{"first_name":"` + strings.Repeat("a", 30) + `"}`, want: []string{}, first: strings.Repeat("a", 30)},
+		{name: "30 characters that are not ascii", body: `{"last_name":"` + strings.Repeat("é", 30) + `"}`, want: []string{}, last: strings.Repeat("é", 30)},
+		{name: "31 characters", body: `{"first_name":"` + strings.Repeat("a", 31) + `"}`, want: []string{"first_name: must be at most 30 characters"}},
+		{name: "30 characters after collapsing", body: `{"first_name":"` + strings.Repeat("a", 14) + "     " + strings.Repeat("b", 15) + `"}`, want: []string{}, first: strings.Repeat("a", 14) + " " + strings.Repeat("b", 15)},
+		{name: "phone with dashes", body: `{"phone_number":"0812-3456-7890"}`, want: []string{}, phone: "081234567890"},
+		{name: "phone with +, spaces and brackets", body: `{"phone_number":"+62 (812) 3456.789"}`, want: []string{}, phone: "+628123456789"},
+		{name: "phone with 8 digits", body: `{"phone_number":"5551-2345"}`, want: []string{}, phone: "55512345"},
+		{name: "phone with 10 digits", body: `{"phone_number":"(021) 555-1234"}`, want: []string{}, phone: "0215551234"},
+		{name: "phone with 7 digits", body: `{"phone_number":"555-1234"}`, want: []string{"phone_number: must be 8 to 12 digits, optionally starting with +"}},
+		{name: "phone with 13 digits", body: `{"phone_number":"0812 3456 78901"}`, want: []string{"phone_number: must be 8 to 12 digits, optionally starting with +"}},
+		{name: "phone with + and 13 digits", body: `{"phone_number":"+62 8123 4567 8901"}`, want: []string{"phone_number: must be 8 to 12 digits, optionally starting with +"}},
+		{name: "phone with + in the middle", body: `{"phone_number":"0812+3456789"}`, want: []string{"phone_number: must be 8 to 12 digits, optionally starting with +"}},
+		{name: "phone with letters", body: `{"phone_number":"0812-CALL-ME"}`, want: []string{"phone_number: must be 8 to 12 digits, optionally starting with +"}},
+		{name: "nothing to update", body: `{}`, want: []string{"body: nothing to update"}},
+		{
+			name: "several fields are reported together",
+			body: `{"first_name":" ","last_name":` + `"` + strings.Repeat("x", 40) + `","phone_number":"12"}`,
+			want: []string{
+				"first_name: must not be empty, use null to clear it",
+				"last_name: must be at most 30 characters",
+				"phone_number: must be 8 to 12 digits, optionally starting with +",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body models.EditUserProfile
+			if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+				t.Fatalf("unmarshal %s: %v", tt.body, err)
+			}
+			if got := fieldsOf(ValidateProfileEdit(&body)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("errors = %q, want %q", got, tt.want)
+			}
+			if len(tt.want) > 0 {
+				return
+			}
+			for _, f := range []struct {
+				name  string
+				value models.OptionalString
+				want  string
+			}{{"first_name", body.FirstName, tt.first}, {"last_name", body.LastName, tt.last}, {"phone_number", body.PhoneNumber, tt.phone}} {
+				if f.value.Set && !f.value.Null && f.value.Value != f.want {
+					t.Errorf("%s = %q, want %q", f.name, f.value.Value, f.want)
+				}
+			}
+		})
+	}
+
+	t.Run("only a new image", func(t *testing.T) {
+		body := models.EditUserProfile{Img: &multipart.FileHeader{Filename: "avatar.png"}}
+		if errs := ValidateProfileEdit(&body); errs != nil {
+			t.Errorf("errors = %+v, want none", errs)
+		}
+	})
+
+	t.Run("null is not the same as absent", func(t *testing.T) {
+		var body models.EditUserProfile
+		json.Unmarshal([]byte(`{"phone_number":null}`), &body)
+		if !body.PhoneNumber.Set || !body.PhoneNumber.Null || body.FirstName.Set {
+			t.Errorf("body = %+v", body)
+		}
+	})
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"strings"
 
 	"golang.org/x/crypto/argon2"
@@ -67,53 +69,117 @@ func (h *HashConfig) genSalt() ([]byte, error) {
 	return salt, nil
 }
 
-func (h *HashConfig) CompareHashAndPassword(password, hashedPassword string) (bool, error) {
+// UseEnv starts from the recommended config and overrides it with
+// HASH_MEMORY (KiB), HASH_TIME, HASH_THREADS, HASH_KEY_LEN and HASH_SALT_LEN.
+// On an invalid value the recommended config is kept and the error is returned.
+func (h *HashConfig) UseEnv() error {
+	h.UseRecommended()
+	cfg := *h
+
+	envs := []struct {
+		name string
+		dest *uint32
+	}{
+		{"HASH_MEMORY", &cfg.Memory},
+		{"HASH_TIME", &cfg.Time},
+		{"HASH_KEY_LEN", &cfg.KeyLen},
+		{"HASH_SALT_LEN", &cfg.SaltLen},
+	}
+	for _, env := range envs {
+		raw := os.Getenv(env.name)
+		if raw == "" {
+			continue
+		}
+		value, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil || value == 0 {
+			return fmt.Errorf("invalid %s: %q", env.name, raw)
+		}
+		*env.dest = uint32(value)
+	}
+
+	if raw := os.Getenv("HASH_THREADS"); raw != "" {
+		value, err := strconv.ParseUint(raw, 10, 8)
+		if err != nil || value == 0 {
+			return fmt.Errorf("invalid HASH_THREADS: %q", raw)
+		}
+		cfg.Thread = uint8(value)
+	}
+
+	*h = cfg
+	return nil
+}
+
+// decodeHash parses $argon2id$v=19$m=65536,t=2,p=1$salt$hash.
+// The parameters are returned as a new HashConfig so the receiver is never mutated.
+func decodeHash(hashedPassword string) (HashConfig, []byte, []byte, error) {
 	result := strings.Split(hashedPassword, "$")
-	fmt.Println(len(result), result)
 	// Cek panjang hasil split, kalau bukan 6 maka format hash invalid
 	if len(result) != 6 {
-		return false, errors.New("invalid hash format")
+		return HashConfig{}, nil, nil, errors.New("invalid hash format")
 	}
 
 	// Cek kriptografi yang digunakan
 	if result[1] != "argon2id" {
-		return false, errors.New("invalid crypto method")
+		return HashConfig{}, nil, nil, errors.New("invalid crypto method")
 	}
 
 	// Cek versi nya
 	var version int
-	fmt.Sscanf(result[2], "v=%d", &version)
-	if version != argon2.Version {
-		return false, errors.New("invalid argon2id version")
+	if _, err := fmt.Sscanf(result[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return HashConfig{}, nil, nil, errors.New("invalid argon2id version")
 	}
+
 	// Ambil konfigurasi memory, time dan thread
-	if _, err := fmt.Sscanf(result[3], "m=%d,t=%d,p=%d", &h.Memory, &h.Time, &h.Thread); err != nil {
-		return false, errors.New("invalid format")
+	var params HashConfig
+	if _, err := fmt.Sscanf(result[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Thread); err != nil {
+		return HashConfig{}, nil, nil, errors.New("invalid format")
 	}
+
 	// Ambil nilai salt
 	salt, err := base64.RawStdEncoding.DecodeString(result[4])
 	if err != nil {
-		return false, err
+		return HashConfig{}, nil, nil, err
 	}
-	h.SaltLen = uint32(len(salt))
+	params.SaltLen = uint32(len(salt))
 
 	// Ambil nilai hash
 	hash, err := base64.RawStdEncoding.DecodeString(result[5])
+	if err != nil {
+		return HashConfig{}, nil, nil, err
+	}
+	params.KeyLen = uint32(len(hash))
+
+	return params, salt, hash, nil
+}
+
+// CompareHashAndPassword only reads the receiver, so one config can be shared between goroutines
+func (h *HashConfig) CompareHashAndPassword(password, hashedPassword string) (bool, error) {
+	params, salt, hash, err := decodeHash(hashedPassword)
 	if err != nil {
 		return false, err
 	}
-	h.KeyLen = uint32(len(hash))
 
 	// Comparison
 	// Generate Hash dari password
-	hashPwd := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Thread, h.KeyLen)
-	// Komparasi hasil hash dengan waktu tidak konstan
-	// if slices.Compare(hash, hashPwd) != 0 {
-	// 	return false, nil
-	// }
+	hashPwd := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Thread, params.KeyLen)
 	// Komparasi hasil hash dengan waktu konstan (lebih aman dari timing attack di hash)
 	if subtle.ConstantTimeCompare(hash, hashPwd) == 0 {
 		return false, nil
 	}
 	return true, nil
 }
+
+// NeedsRehash reports whether the hash was generated with other parameters than the receiver,
+// e.g. after the recommended/env values were raised. Call it after a successful comparison.
+func (h *HashConfig) NeedsRehash(hashedPassword string) (bool, error) {
+	params, _, _, err := decodeHash(hashedPassword)
+	if err != nil {
+		return false, err
+	}
+
+	return params.Memory != h.Memory ||
+		params.Time != h.Time ||
+		params.Thread != h.Thread ||
+		params.KeyLen != h.KeyLen ||
+		params.SaltLen != h.SaltLen, nil
+}
@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"sync"
+	"testing"
+)
+
+// small parameters keep the tests fast, the values don't matter for the logic
+func testHashConfig() *HashConfig {
+	h := NewHashConfig()
+	h.SetConfig(1024, 1, 32, 16, 1)
+	return h
+}
+
+func TestNeedsRehash(t *testing.T) {
+	base := testHashConfig()
+	hashed, err := base.GenHash("Str0ngP@ss!")
+	if err != nil {
+		t.Fatalf("GenHash: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		target  func(h *HashConfig)
+		hash    string
+		want    bool
+		wantErr bool
+	}{
+		{name: "same parameters", target: func(h *HashConfig) {}, hash: hashed, want: false},
+		{name: "memory raised", target: func(h *HashConfig) { h.Memory = 2048 }, hash: hashed, want: true},
+		{name: "time raised", target: func(h *HashConfig) { h.Time = 3 }, hash: hashed, want: true},
+		{name: "threads changed", target: func(h *HashConfig) { h.Thread = 2 }, hash: hashed, want: true},
+		{name: "key length changed", target: func(h *HashConfig) { h.KeyLen = 64 }, hash: hashed, want: true},
+		{name: "salt length changed", target: func(h *HashConfig) { h.SaltLen = 32 }, hash: hashed, want: true},
+		{name: "parameters lowered", target: func(h *HashConfig) { h.Memory = 512 }, hash: hashed, want: true},
+		{
+			name:   "legacy recommended hash",
+			target: func(h *HashConfig) { h.UseRecommended(); h.Time = 3 },
+			hash:   "$argon2id$v=19$m=65536,t=2,p=1$OMvylx6kRLCN7jomvbBdTw$r1exHPow1JotPGoe7s7+6/WUn0+DvHO7e7NkuXKJ2ys",
+			want:   true,
+		},
+		{
+			name:   "current recommended hash",
+			target: func(h *HashConfig) { h.UseRecommended() },
+			hash:   "$argon2id$v=19$m=65536,t=2,p=1$OMvylx6kRLCN7jomvbBdTw$r1exHPow1JotPGoe7s7+6/WUn0+DvHO7e7NkuXKJ2ys",
+			want:   false,
+		},
+		{name: "invalid format", target: func(h *HashConfig) {}, hash: "not-a-hash", wantErr: true},
+		{name: "other algorithm", target: func(h *HashConfig) {}, hash: "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$aGFzaA", wantErr: true},
+		{name: "other version", target: func(h *HashConfig) {}, hash: "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$aGFzaA", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := testHashConfig()
+			tt.target(target)
+
+			got, err := target.NeedsRehash(tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NeedsRehash() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("NeedsRehash() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareHashAndPassword(t *testing.T) {
+	gen := testHashConfig()
+	hashed, err := gen.GenHash("Str0ngP@ss!")
+	if err != nil {
+		t.Fatalf("GenHash: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		hash     string
+		want     bool
+		wantErr  bool
+	}{
+		{name: "correct password", password: "Str0ngP@ss!", hash: hashed, want: true},
+		{name: "wrong password", password: "Str0ngP@ss?", hash: hashed, want: false},
+		{name: "empty password", password: "", hash: hashed, want: false},
+		{name: "invalid format", password: "Str0ngP@ss!", hash: "$argon2id$v=19", wantErr: true},
+		{name: "invalid salt", password: "Str0ngP@ss!", hash: "$argon2id$v=19$m=1024,t=1,p=1$***$aGFzaA", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the comparing config uses other parameters than the hash on purpose
+			h := NewHashConfig()
+			h.UseRecommended()
+
+			got, err := h.CompareHashAndPassword(tt.password, tt.hash)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CompareHashAndPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CompareHashAndPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareHashAndPasswordDoesNotMutateConfig(t *testing.T) {
+	shared := NewHashConfig()
+	shared.UseRecommended()
+	before := *shared
+
+	hashes := make([]string, 4)
+	for i := range hashes {
+		gen := testHashConfig()
+		gen.Time = uint32(i + 1)
+		hashed, err := gen.GenHash("Str0ngP@ss!")
+		if err != nil {
+			t.Fatalf("GenHash: %v", err)
+		}
+		hashes[i] = hashed
+	}
+
+	var wg sync.WaitGroup
+	for _, hashed := range hashes {
+		wg.Add(1)
+		go func(hashed string) {
+			defer wg.Done()
+			ok, err := shared.CompareHashAndPassword("Str0ngP@ss!", hashed)
+			if err != nil || !ok {
+				t.Errorf("CompareHashAndPassword() = %v, %v", ok, err)
+			}
+		}(hashed)
+	}
+	wg.Wait()
+
+	if *shared != before {
+		t.Errorf("config was mutated: got %+v, want %+v", *shared, before)
+	}
+}
+
+func TestUseEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    HashConfig
+		wantErr bool
+	}{
+		{
+			name: "defaults to recommended",
+			env:  map[string]string{},
+			want: HashConfig{Memory: 64 * 1024, Time: 2, Thread: 1, KeyLen: 32, SaltLen: 16},
+		},
+		{
+			name: "overrides",
+			env:  map[string]string{"HASH_MEMORY": "131072", "HASH_TIME": "3", "HASH_THREADS": "2"},
+			want: HashConfig{Memory: 128 * 1024, Time: 3, Thread: 2, KeyLen: 32, SaltLen: 16},
+		},
+		{
+			name:    "invalid value keeps recommended",
+			env:     map[string]string{"HASH_MEMORY": "lots", "HASH_TIME": "3"},
+			want:    HashConfig{Memory: 64 * 1024, Time: 2, Thread: 1, KeyLen: 32, SaltLen: 16},
+			wantErr: true,
+		},
+		{
+			name:    "zero is invalid",
+			env:     map[string]string{"HASH_THREADS": "0"},
+			want:    HashConfig{Memory: 64 * 1024, Time: 2, Thread: 1, KeyLen: 32, SaltLen: 16},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"HASH_MEMORY", "HASH_TIME", "HASH_THREADS", "HASH_KEY_LEN", "HASH_SALT_LEN"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			h := NewHashConfig()
+			err := h.UseEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UseEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if *h != tt.want {
+				t.Errorf("UseEnv() = %+v, want %+v", *h, tt.want)
+			}
+		})
+	}
+}
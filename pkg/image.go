@@ -0,0 +1,228 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrInvalidImage is returned for uploads that are not accepted, the message tells why
+type ErrInvalidImage struct {
+	Reason string
+}
+
+func (e ErrInvalidImage) Error() string {
+	return e.Reason
+}
+
+// ImageLimits are checked before an upload is decoded completely
+type ImageLimits struct {
+	MaxBytes     int64
+	MaxDimension int // width and height
+	MinDimension int
+}
+
+// ImageLimitsFromEnv reads IMAGE_MAX_BYTES and IMAGE_MAX_DIMENSION, an empty or invalid value keeps the
+// default (10 MB, 6000 px). Images smaller than 64 px are always rejected.
+func ImageLimitsFromEnv() ImageLimits {
+	limits := ImageLimits{MaxBytes: 10 << 20, MaxDimension: 6000, MinDimension: 64}
+	if v, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_BYTES"), 10, 64); err == nil && v > 0 {
+		limits.MaxBytes = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("IMAGE_MAX_DIMENSION")); err == nil && v >= limits.MinDimension {
+		limits.MaxDimension = v
+	}
+	return limits
+}
+
+// ImageVariant is one generated size. The image is scaled to fit Width x Height (0 = any height),
+// with Crop it fills the box and the middle is cut out. Images are never scaled up.
+type ImageVariant struct {
+	Name   string
+	Width  int
+	Height int
+	Crop   bool
+}
+
+var (
+	PosterVariants = []ImageVariant{
+		{Name: "thumbnail", Width: 154},
+		{Name: "medium", Width: 342},
+		{Name: "large", Width: 780},
+	}
+	BackdropVariants = []ImageVariant{
+		{Name: "thumbnail", Width: 300},
+		{Name: "medium", Width: 780},
+		{Name: "large", Width: 1280},
+	}
+	AvatarVariants = []ImageVariant{
+		{Name: "thumbnail", Width: 64, Height: 64, Crop: true},
+		{Name: "medium", Width: 160, Height: 160, Crop: true},
+		{Name: "large", Width: 400, Height: 400, Crop: true},
+	}
+)
+
+// ImageVariantNames are the sizes every variant list above has
+var ImageVariantNames = []string{"thumbnail", "medium", "large"}
+
+// ProcessedImage is a validated upload, Ext and ContentType come from the content and not from the filename
+type ProcessedImage struct {
+	Ext         string
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+	Variants    []EncodedImage
+}
+
+type EncodedImage struct {
+	Name        string // e.g. thumbnail
+	Ext         string
+	ContentType string
+	Data        []byte
+}
+
+var imageTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+}
+
+// ProcessImage checks that r really is a png, jpeg or webp within limits and renders every variant
+// as jpeg and as webp
+func ProcessImage(r io.Reader, limits ImageLimits, variants []ImageVariant) (*ProcessedImage, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limits.MaxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limits.MaxBytes {
+		return nil, ErrInvalidImage{"must be at most " + formatBytes(limits.MaxBytes)}
+	}
+
+	// the content decides the type, a renamed file is caught here
+	contentType := http.DetectContentType(data)
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return nil, ErrInvalidImage{"must be a png, jpg, jpeg or webp image"}
+	}
+
+	// only the header is read first, so a huge image is rejected before it is decoded into memory
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage{"is not a valid image"}
+	}
+	if cfg.Width > limits.MaxDimension || cfg.Height > limits.MaxDimension {
+		return nil, ErrInvalidImage{fmt.Sprintf("must be at most %dx%d pixels", limits.MaxDimension, limits.MaxDimension)}
+	}
+	if cfg.Width < limits.MinDimension || cfg.Height < limits.MinDimension {
+		return nil, ErrInvalidImage{fmt.Sprintf("must be at least %dx%d pixels", limits.MinDimension, limits.MinDimension)}
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage{"is not a valid image"}
+	}
+
+	processed := &ProcessedImage{Ext: ext, ContentType: contentType, Data: data, Width: cfg.Width, Height: cfg.Height}
+	for _, v := range variants {
+		resized := resizeImage(src, v)
+
+		var jpg bytes.Buffer
+		if err := jpeg.Encode(&jpg, resized, &jpeg.Options{Quality: 82}); err != nil {
+			return nil, err
+		}
+		var webp bytes.Buffer
+		if err := nativewebp.Encode(&webp, resized, nil); err != nil {
+			return nil, err
+		}
+		processed.Variants = append(processed.Variants,
+			EncodedImage{Name: v.Name, Ext: ".jpg", ContentType: "image/jpeg", Data: jpg.Bytes()},
+			EncodedImage{Name: v.Name, Ext: ".webp", ContentType: "image/webp", Data: webp.Bytes()},
+		)
+	}
+	return processed, nil
+}
+
+func formatBytes(n int64) string {
+	if n >= 1<<20 && n%(1<<20) == 0 {
+		return fmt.Sprintf("%d MB", n>>20)
+	}
+	if n >= 1<<10 {
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// resizeImage scales src into the box of v on a white background, jpeg has no transparency
+func resizeImage(src image.Image, v ImageVariant) image.Image {
+	b := src.Bounds()
+	srcRect := b
+	w, h := b.Dx(), b.Dy()
+
+	if v.Crop && v.Height > 0 {
+		// cut the middle of src with the aspect ratio of the box
+		if w*v.Height > h*v.Width {
+			cropW := h * v.Width / v.Height
+			srcRect = image.Rect(b.Min.X+(w-cropW)/2, b.Min.Y, b.Min.X+(w-cropW)/2+cropW, b.Max.Y)
+		} else {
+			cropH := w * v.Height / v.Width
+			srcRect = image.Rect(b.Min.X, b.Min.Y+(h-cropH)/2, b.Max.X, b.Min.Y+(h-cropH)/2+cropH)
+		}
+		w, h = srcRect.Dx(), srcRect.Dy()
+	}
+
+	scale := min(1, float64(v.Width)/float64(w))
+	if v.Height > 0 {
+		scale = min(scale, float64(v.Height)/float64(h))
+	}
+	dstW, dstH := max(1, int(float64(w)*scale+0.5)), max(1, int(float64(h)*scale+0.5))
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, srcRect, draw.Over, nil)
+	return dst
+}
+
+// originalSuffix marks an original that has variants next to it, uploads from before the variants don't have it
+const originalSuffix = "_orig"
+
+// OriginalImageName is the filename of a new upload, e.g. 1727000000_orig.png
+func OriginalImageName(prefix, ext string) string {
+	return prefix + originalSuffix + ext
+}
+
+// HasImageVariants tells whether the variants of filename were generated
+func HasImageVariants(filename string) bool {
+	return strings.HasSuffix(strings.TrimSuffix(filename, path.Ext(filename)), originalSuffix)
+}
+
+// ImageVariantName is the filename of a variant: 1727000000_orig.png -> 1727000000_thumbnail.webp
+func ImageVariantName(filename, variant, ext string) string {
+	stem := strings.TrimSuffix(strings.TrimSuffix(filename, path.Ext(filename)), originalSuffix)
+	return stem + "_" + variant + ext
+}
+
+// ImageVariantFiles lists every variant file of filename, nothing when it has none
+func ImageVariantFiles(filename string) []string {
+	if !HasImageVariants(filename) {
+		return nil
+	}
+	var files []string
+	for _, name := range ImageVariantNames {
+		files = append(files, ImageVariantName(filename, name, ".jpg"), ImageVariantName(filename, name, ".webp"))
+	}
+	return files
+}
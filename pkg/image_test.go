@@ -0,0 +1,210 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/HugoSmits86/nativewebp"
+)
+
+var testLimits = ImageLimits{MaxBytes: 10 << 20, MaxDimension: 2000, MinDimension: 64}
+
+// testImage is a w x h gradient, encoded as format (png, jpeg, webp or gif)
+func testImage(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "webp":
+		err = nativewebp.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		t.Fatalf("unknown format %s", format)
+	}
+	if err != nil {
+		t.Fatalf("encode %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessImageFormats(t *testing.T) {
+	tests := []struct {
+		format      string
+		ext         string
+		contentType string
+	}{
+		{format: "png", ext: ".png", contentType: "image/png"},
+		{format: "jpeg", ext: ".jpg", contentType: "image/jpeg"},
+		{format: "webp", ext: ".webp", contentType: "image/webp"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			data := testImage(t, tt.format, 120, 80)
+			img, err := ProcessImage(bytes.NewReader(data), testLimits, nil)
+			if err != nil {
+				t.Fatalf("ProcessImage: %v", err)
+			}
+			if img.Ext != tt.ext || img.ContentType != tt.contentType {
+				t.Errorf("type = %s %s, want %s %s", img.Ext, img.ContentType, tt.ext, tt.contentType)
+			}
+			if img.Width != 120 || img.Height != 80 {
+				t.Errorf("size = %dx%d, want 120x80", img.Width, img.Height)
+			}
+			if !bytes.Equal(img.Data, data) {
+				t.Error("the original must be kept as uploaded")
+			}
+			if len(img.Variants) != 0 {
+				t.Errorf("%d variants without a variant list", len(img.Variants))
+			}
+		})
+	}
+}
+
+func TestProcessImageRejects(t *testing.T) {
+	pngData := testImage(t, "png", 120, 80)
+	tests := []struct {
+		name   string
+		data   []byte
+		limits ImageLimits
+		reason string
+	}{
+		{name: "text renamed to .png", data: []byte("#!/bin/sh\nrm -rf /\n" + strings.Repeat("x", 600)), limits: testLimits, reason: "must be a png"},
+		{name: "html", data: []byte("<html><body><script>alert(1)</script></body></html>"), limits: testLimits, reason: "must be a png"},
+		{name: "gif", data: testImage(t, "gif", 120, 80), limits: testLimits, reason: "must be a png"},
+		{name: "png signature, broken content", data: append(append([]byte{}, pngData[:40]...), bytes.Repeat([]byte{0}, 100)...), limits: testLimits, reason: "not a valid image"},
+		{name: "larger than MaxBytes", data: pngData, limits: ImageLimits{MaxBytes: int64(len(pngData) - 1), MaxDimension: 2000, MinDimension: 64}, reason: "must be at most"},
+		{name: "wider than MaxDimension", data: testImage(t, "png", 300, 80), limits: ImageLimits{MaxBytes: 10 << 20, MaxDimension: 200, MinDimension: 64}, reason: "at most 200x200 pixels"},
+		{name: "taller than MaxDimension", data: testImage(t, "jpeg", 80, 300), limits: ImageLimits{MaxBytes: 10 << 20, MaxDimension: 200, MinDimension: 64}, reason: "at most 200x200 pixels"},
+		{name: "smaller than MinDimension", data: testImage(t, "webp", 120, 40), limits: testLimits, reason: "at least 64x64 pixels"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ProcessImage(bytes.NewReader(tt.data), tt.limits, PosterVariants)
+			var invalid ErrInvalidImage
+			if !errors.As(err, &invalid) {
+				t.Fatalf("error = %v, want ErrInvalidImage", err)
+			}
+			if !strings.Contains(invalid.Reason, tt.reason) {
+				t.Errorf("reason = %q, want %q", invalid.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestProcessImageExactlyAtTheLimits(t *testing.T) {
+	data := testImage(t, "png", 200, 64)
+	limits := ImageLimits{MaxBytes: int64(len(data)), MaxDimension: 200, MinDimension: 64}
+	if _, err := ProcessImage(bytes.NewReader(data), limits, nil); err != nil {
+		t.Fatalf("image exactly at the limits rejected: %v", err)
+	}
+}
+
+func TestProcessImageVariants(t *testing.T) {
+	type size struct{ w, h int }
+	tests := []struct {
+		name     string
+		w, h     int
+		variants []ImageVariant
+		want     map[string]size
+	}{
+		{
+			name: "poster is scaled to the width", w: 1000, h: 1500, variants: PosterVariants,
+			want: map[string]size{"thumbnail": {154, 231}, "medium": {342, 513}, "large": {780, 1170}},
+		},
+		{
+			name: "small poster is never scaled up", w: 300, h: 450, variants: PosterVariants,
+			want: map[string]size{"thumbnail": {154, 231}, "medium": {300, 450}, "large": {300, 450}},
+		},
+		{
+			name: "backdrop", w: 1920, h: 1080, variants: BackdropVariants,
+			want: map[string]size{"thumbnail": {300, 169}, "medium": {780, 439}, "large": {1280, 720}},
+		},
+		{
+			name: "avatar is cropped to a square", w: 600, h: 300, variants: AvatarVariants,
+			want: map[string]size{"thumbnail": {64, 64}, "medium": {160, 160}, "large": {300, 300}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := ProcessImage(bytes.NewReader(testImage(t, "png", tt.w, tt.h)), testLimits, tt.variants)
+			if err != nil {
+				t.Fatalf("ProcessImage: %v", err)
+			}
+			if len(img.Variants) != 2*len(tt.variants) {
+				t.Fatalf("%d variants, want a jpeg and a webp of each of %d sizes", len(img.Variants), len(tt.variants))
+			}
+
+			formats := map[string][]string{}
+			for _, v := range img.Variants {
+				if got := http.DetectContentType(v.Data); got != v.ContentType {
+					t.Errorf("%s%s: content is %s, labelled %s", v.Name, v.Ext, got, v.ContentType)
+				}
+				cfg, _, err := image.DecodeConfig(bytes.NewReader(v.Data))
+				if err != nil {
+					t.Fatalf("%s%s: decode: %v", v.Name, v.Ext, err)
+				}
+				if want := tt.want[v.Name]; cfg.Width != want.w || cfg.Height != want.h {
+					t.Errorf("%s%s = %dx%d, want %dx%d", v.Name, v.Ext, cfg.Width, cfg.Height, want.w, want.h)
+				}
+				formats[v.Name] = append(formats[v.Name], v.Ext+" "+v.ContentType)
+			}
+			for name := range tt.want {
+				if want := []string{".jpg image/jpeg", ".webp image/webp"}; !reflect.DeepEqual(formats[name], want) {
+					t.Errorf("%s formats = %v, want %v", name, formats[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestImageVariantNames(t *testing.T) {
+	original := OriginalImageName("1727000000", ".png")
+	if original != "1727000000_orig.png" {
+		t.Fatalf("OriginalImageName = %s, want 1727000000_orig.png", original)
+	}
+	if got := ImageVariantName(original, "thumbnail", ".webp"); got != "1727000000_thumbnail.webp" {
+		t.Errorf("ImageVariantName = %s, want 1727000000_thumbnail.webp", got)
+	}
+	if !HasImageVariants(original) {
+		t.Error("HasImageVariants(original) = false")
+	}
+
+	want := []string{
+		"1727000000_thumbnail.jpg", "1727000000_thumbnail.webp",
+		"1727000000_medium.jpg", "1727000000_medium.webp",
+		"1727000000_large.jpg", "1727000000_large.webp",
+	}
+	if got := ImageVariantFiles(original); !reflect.DeepEqual(got, want) {
+		t.Errorf("ImageVariantFiles = %v, want %v", got, want)
+	}
+
+	// uploads from before the variants have none
+	legacy := "1727000000_images_.png"
+	if HasImageVariants(legacy) {
+		t.Error("HasImageVariants(legacy) = true")
+	}
+	if got := ImageVariantFiles(legacy); got != nil {
+		t.Errorf("ImageVariantFiles(legacy) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,406 @@
+package pkg
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTKey is a single key identified by its kid.
+// Signer is nil for keys that are only kept to verify tokens issued before a rotation.
+type JWTKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Signer crypto.Signer
+	Public crypto.PublicKey
+}
+
+// KeySet holds the active signing key and every key that is still accepted for verification
+type KeySet struct {
+	active *JWTKey
+	keys   map[string]*JWTKey
+	// secret is the legacy HS256 key (JWT_SECRET), only used for tokens without kid
+	secret []byte
+}
+
+var (
+	keySetMu sync.RWMutex
+	keySet   *KeySet
+)
+
+// InitKeySet loads the keys from the environment once at startup
+func InitKeySet() error {
+	ks, err := LoadKeySetFromEnv()
+	if err != nil {
+		return err
+	}
+	SetKeySet(ks)
+	return nil
+}
+
+func SetKeySet(ks *KeySet) {
+	keySetMu.Lock()
+	defer keySetMu.Unlock()
+	keySet = ks
+}
+
+// CurrentKeySet returns the loaded key set, loading it from env when InitKeySet was not called
+func CurrentKeySet() (*KeySet, error) {
+	keySetMu.RLock()
+	ks := keySet
+	keySetMu.RUnlock()
+	if ks != nil {
+		return ks, nil
+	}
+
+	if err := InitKeySet(); err != nil {
+		return nil, err
+	}
+	return CurrentKeySet()
+}
+
+// LoadKeySetFromEnv reads
+//
+//	JWT_SIGNING_KEY_FILE   PEM private key (RSA, Ed25519 or ECDSA P-256) used to sign new tokens
+//	JWT_SIGNING_KEY_ID     kid of the signing key (derived from the public key when empty)
+//	JWT_VERIFICATION_KEYS  kid=path.pem,kid=path.pem public keys that are still accepted
+//	JWT_SECRET             legacy HS256 secret, signs only when no signing key is configured
+func LoadKeySetFromEnv() (*KeySet, error) {
+	ks := &KeySet{keys: map[string]*JWTKey{}}
+
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		ks.secret = []byte(secret)
+	}
+
+	if path := os.Getenv("JWT_SIGNING_KEY_FILE"); path != "" {
+		signer, err := readPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+		key, err := newJWTKey(os.Getenv("JWT_SIGNING_KEY_ID"), signer.Public())
+		if err != nil {
+			return nil, fmt.Errorf("signing key: %w", err)
+		}
+		key.Signer = signer
+		ks.active = key
+		ks.keys[key.ID] = key
+	}
+
+	if list := os.Getenv("JWT_VERIFICATION_KEYS"); list != "" {
+		for _, entry := range strings.Split(list, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			kid, path, found := strings.Cut(entry, "=")
+			if !found {
+				kid, path = "", entry
+			}
+			public, err := readPublicKey(strings.TrimSpace(path))
+			if err != nil {
+				return nil, fmt.Errorf("verification key %s: %w", entry, err)
+			}
+			key, err := newJWTKey(strings.TrimSpace(kid), public)
+			if err != nil {
+				return nil, fmt.Errorf("verification key %s: %w", entry, err)
+			}
+			if _, exists := ks.keys[key.ID]; exists {
+				continue
+			}
+			ks.keys[key.ID] = key
+		}
+	}
+
+	if ks.active == nil && ks.secret == nil {
+		return nil, errors.New("no secret found")
+	}
+	return ks, nil
+}
+
+// Sign signs the claims with the active key, or with the legacy secret when no key is configured
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	if k.active == nil {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString(k.secret)
+	}
+
+	token := jwt.NewWithClaims(k.active.Method, claims)
+	token.Header["kid"] = k.active.ID
+	return token.SignedString(k.active.Signer)
+}
+
+// Keyfunc picks the verification key by kid and refuses algorithms that don't belong to that key
+func (k *KeySet) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	if kid == "" {
+		if k.secret != nil && t.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return k.secret, nil
+		}
+		return nil, errors.New("token has no kid")
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if t.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", t.Method.Alg(), kid)
+	}
+	return key.Public, nil
+}
+
+// ValidMethods lists every algorithm that can currently be verified
+func (k *KeySet) ValidMethods() []string {
+	methods := []string{}
+	if k.secret != nil {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	for _, key := range k.keys {
+		alg := key.Method.Alg()
+		exists := false
+		for _, m := range methods {
+			if m == alg {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// JWKS publishes the public part of every asymmetric key (the HS256 secret is never published)
+func (k *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if k.active != nil {
+		if jwk, err := NewJWK(k.active); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	for id, key := range k.keys {
+		if k.active != nil && id == k.active.ID {
+			continue
+		}
+		if jwk, err := NewJWK(key); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	// keep the active key first, the rest in a stable order
+	if len(set.Keys) > 1 {
+		rest := set.Keys[1:]
+		if k.active == nil {
+			rest = set.Keys
+		}
+		sort.Slice(rest, func(i, j int) bool { return rest[i].Kid < rest[j].Kid })
+	}
+	return set
+}
+
+// JWK is a JSON Web Key (RFC 7517), only the fields for RSA, EC and OKP public keys
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewJWK(key *JWTKey) (JWK, error) {
+	b64 := base64.RawURLEncoding
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64.EncodeToString(pub.N.Bytes())
+		jwk.E = b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64.EncodeToString(pub)
+	case *ecdsa.PublicKey:
+		ecdhKey, err := pub.ECDH()
+		if err != nil {
+			return JWK{}, err
+		}
+		// uncompressed point: 0x04 || X || Y
+		raw := ecdhKey.Bytes()
+		size := (len(raw) - 1) / 2
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64.EncodeToString(raw[1 : 1+size])
+		jwk.Y = b64.EncodeToString(raw[1+size:])
+	default:
+		return JWK{}, fmt.Errorf("unsupported key type %T", key.Public)
+	}
+	return jwk, nil
+}
+
+// PublicKey converts the JWK back into a Go public key (used to verify tokens of other issuers)
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	b64 := base64.RawURLEncoding
+
+	switch j.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %s", j.Crv)
+		}
+		x, err := b64.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "EC":
+		var curve elliptic.Curve
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", j.Crv)
+		}
+		x, err := b64.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		pub := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("invalid EC key")
+		}
+		return pub, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", j.Kty)
+	}
+}
+
+func newJWTKey(kid string, public crypto.PublicKey) (*JWTKey, error) {
+	var method jwt.SigningMethod
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		method = jwt.SigningMethodEdDSA
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, errors.New("only P-256 ECDSA keys are supported")
+		}
+		method = jwt.SigningMethodES256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+
+	if kid == "" {
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		kid = hex.EncodeToString(sum[:8])
+	}
+
+	return &JWTKey{ID: kid, Method: method, Public: public}, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
@@ -0,0 +1,344 @@
+package pkg
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ed25519 ed25519.PrivateKey
+	p256    *ecdsa.PrivateKey
+}
+
+func genTestKeys(t *testing.T) testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa: %v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("p-256: %v", err)
+	}
+	return testKeys{rsa: rsaKey, ed25519: edKey, p256: ecKey}
+}
+
+// writeKeyFiles stores the private key (PKCS#8) and its public key (PKIX) like openssl would
+func writeKeyFiles(t *testing.T, name string, signer crypto.Signer) (privatePath, publicPath string) {
+	t.Helper()
+	dir := t.TempDir()
+
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		t.Fatalf("marshal private key: %v", err)
+	}
+	privatePath = filepath.Join(dir, name+".pem")
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	der, err = x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	publicPath = filepath.Join(dir, name+".pub.pem")
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return privatePath, publicPath
+}
+
+// loadKeySet runs LoadKeySetFromEnv with only the given JWT_* variables set
+func loadKeySet(t *testing.T, env map[string]string) *KeySet {
+	t.Helper()
+	for _, name := range []string{"JWT_SECRET", "JWT_SIGNING_KEY_FILE", "JWT_SIGNING_KEY_ID", "JWT_VERIFICATION_KEYS"} {
+		t.Setenv(name, env[name])
+	}
+	ks, err := LoadKeySetFromEnv()
+	if err != nil {
+		t.Fatalf("LoadKeySetFromEnv: %v", err)
+	}
+	return ks
+}
+
+func testClaims() *Claims {
+	return &Claims{
+		UserId: "user-1",
+		Role:   "user",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+// parseWith verifies like Claims.verify does, without the global key set
+func parseWith(ks *KeySet, token string) error {
+	_, err := jwt.ParseWithClaims(token, &Claims{}, ks.Keyfunc, jwt.WithValidMethods(ks.ValidMethods()))
+	return err
+}
+
+// signRaw signs a token outside of the key set, the way an attacker or another service would
+func signRaw(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, testClaims())
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign %s: %v", method.Alg(), err)
+	}
+	return signed
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+	keys := genTestKeys(t)
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		alg    string
+	}{
+		{name: "RSA", signer: keys.rsa, alg: "RS256"},
+		{name: "Ed25519", signer: keys.ed25519, alg: "EdDSA"},
+		{name: "P-256", signer: keys.p256, alg: "ES256"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			private, _ := writeKeyFiles(t, "signing", tt.signer)
+			ks := loadKeySet(t, map[string]string{"JWT_SIGNING_KEY_FILE": private})
+
+			token, err := ks.Sign(testClaims())
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(token, &Claims{})
+			if err != nil {
+				t.Fatalf("ParseUnverified: %v", err)
+			}
+			if parsed.Method.Alg() != tt.alg || parsed.Header["kid"] == "" || parsed.Header["kid"] == nil {
+				t.Fatalf("header = %v, want alg %s and a derived kid", parsed.Header, tt.alg)
+			}
+			if err := parseWith(ks, token); err != nil {
+				t.Fatalf("verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestKeySetRejects(t *testing.T) {
+	keys := genTestKeys(t)
+	private, _ := writeKeyFiles(t, "signing", keys.rsa)
+	ks := loadKeySet(t, map[string]string{
+		"JWT_SECRET":           "legacy-secret",
+		"JWT_SIGNING_KEY_FILE": private,
+		"JWT_SIGNING_KEY_ID":   "rsa-1",
+	})
+	publicDER, _ := x509.MarshalPKIXPublicKey(keys.rsa.Public())
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	otherRSA, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "unknown kid", token: signRaw(t, jwt.SigningMethodRS256, "rsa-2", otherRSA)},
+		{name: "known kid, signed by another key", token: signRaw(t, jwt.SigningMethodRS256, "rsa-1", otherRSA)},
+		// alg confusion: the public key of the kid used as HMAC secret
+		{name: "HS256 with the public key as secret", token: signRaw(t, jwt.SigningMethodHS256, "rsa-1", publicPEM)},
+		{name: "HS256 with the legacy secret and a kid", token: signRaw(t, jwt.SigningMethodHS256, "rsa-1", []byte("legacy-secret"))},
+		{name: "ES256 for an RSA kid", token: signRaw(t, jwt.SigningMethodES256, "rsa-1", keys.p256)},
+		{name: "EdDSA without kid", token: signRaw(t, jwt.SigningMethodEdDSA, "", keys.ed25519)},
+		{name: "alg none", token: signRaw(t, jwt.SigningMethodNone, "rsa-1", jwt.UnsafeAllowNoneSignatureType)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := parseWith(ks, tt.token); err == nil {
+				t.Fatal("token was accepted")
+			}
+		})
+	}
+}
+
+func TestKeySetLegacySecret(t *testing.T) {
+	keys := genTestKeys(t)
+	private, _ := writeKeyFiles(t, "signing", keys.p256)
+	legacyToken := signRaw(t, jwt.SigningMethodHS256, "", []byte("legacy-secret"))
+
+	t.Run("accepted while JWT_SECRET is set", func(t *testing.T) {
+		ks := loadKeySet(t, map[string]string{"JWT_SECRET": "legacy-secret", "JWT_SIGNING_KEY_FILE": private})
+		if err := parseWith(ks, legacyToken); err != nil {
+			t.Fatalf("legacy token rejected: %v", err)
+		}
+	})
+
+	t.Run("rejected once JWT_SECRET is removed", func(t *testing.T) {
+		ks := loadKeySet(t, map[string]string{"JWT_SIGNING_KEY_FILE": private})
+		if err := parseWith(ks, legacyToken); err == nil {
+			t.Fatal("legacy token accepted without JWT_SECRET")
+		}
+		for _, alg := range ks.ValidMethods() {
+			if alg == "HS256" {
+				t.Fatalf("ValidMethods = %v, HS256 without JWT_SECRET", ks.ValidMethods())
+			}
+		}
+	})
+
+	t.Run("signed with the secret when there is no key", func(t *testing.T) {
+		ks := loadKeySet(t, map[string]string{"JWT_SECRET": "legacy-secret"})
+		token, err := ks.Sign(testClaims())
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		if err := parseWith(ks, token); err != nil {
+			t.Fatalf("verify: %v", err)
+		}
+	})
+
+	t.Run("no key and no secret", func(t *testing.T) {
+		for _, name := range []string{"JWT_SECRET", "JWT_SIGNING_KEY_FILE", "JWT_SIGNING_KEY_ID", "JWT_VERIFICATION_KEYS"} {
+			t.Setenv(name, "")
+		}
+		if _, err := LoadKeySetFromEnv(); err == nil {
+			t.Fatal("LoadKeySetFromEnv without any key must fail")
+		}
+	})
+}
+
+func TestKeySetRotation(t *testing.T) {
+	keys := genTestKeys(t)
+	oldPrivate, oldPublic := writeKeyFiles(t, "old", keys.rsa)
+	newPrivate, _ := writeKeyFiles(t, "new", keys.ed25519)
+
+	before := loadKeySet(t, map[string]string{"JWT_SIGNING_KEY_FILE": oldPrivate, "JWT_SIGNING_KEY_ID": "2024-01"})
+	oldToken, err := before.Sign(testClaims())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+
+	after := loadKeySet(t, map[string]string{
+		"JWT_SIGNING_KEY_FILE":  newPrivate,
+		"JWT_SIGNING_KEY_ID":    "2025-01",
+		"JWT_VERIFICATION_KEYS": "2024-01=" + oldPublic,
+	})
+	if err := parseWith(after, oldToken); err != nil {
+		t.Fatalf("token of the old key rejected after the rotation: %v", err)
+	}
+	newToken, _ := after.Sign(testClaims())
+	if err := parseWith(after, newToken); err != nil {
+		t.Fatalf("token of the new key rejected: %v", err)
+	}
+
+	// the old key was removed from JWT_VERIFICATION_KEYS
+	retired := loadKeySet(t, map[string]string{"JWT_SIGNING_KEY_FILE": newPrivate, "JWT_SIGNING_KEY_ID": "2025-01"})
+	if err := parseWith(retired, oldToken); err == nil {
+		t.Fatal("token of a retired key accepted")
+	}
+
+	// without kids on both sides the kid is derived from the public key, so it still matches
+	derived := loadKeySet(t, map[string]string{"JWT_SIGNING_KEY_FILE": oldPrivate})
+	derivedToken, _ := derived.Sign(testClaims())
+	rotated := loadKeySet(t, map[string]string{"JWT_SIGNING_KEY_FILE": newPrivate, "JWT_VERIFICATION_KEYS": oldPublic})
+	if err := parseWith(rotated, derivedToken); err != nil {
+		t.Fatalf("token with derived kid rejected after the rotation: %v", err)
+	}
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	keys := genTestKeys(t)
+	signing, _ := writeKeyFiles(t, "signing", keys.p256)
+	_, rsaPublic := writeKeyFiles(t, "rsa", keys.rsa)
+	_, edPublic := writeKeyFiles(t, "ed", keys.ed25519)
+
+	ks := loadKeySet(t, map[string]string{
+		"JWT_SECRET":            "legacy-secret",
+		"JWT_SIGNING_KEY_FILE":  signing,
+		"JWT_SIGNING_KEY_ID":    "ec-1",
+		"JWT_VERIFICATION_KEYS": "rsa-1=" + rsaPublic + ", ed-1=" + edPublic,
+	})
+
+	set := ks.JWKS()
+	if len(set.Keys) != 3 {
+		t.Fatalf("JWKS has %d keys, want 3 (the HS256 secret is never published)", len(set.Keys))
+	}
+	if set.Keys[0].Kid != "ec-1" {
+		t.Errorf("first key = %s, want the active key ec-1", set.Keys[0].Kid)
+	}
+
+	want := map[string]struct {
+		public interface{ Equal(crypto.PublicKey) bool }
+		kty    string
+		alg    string
+	}{
+		"ec-1":  {public: &keys.p256.PublicKey, kty: "EC", alg: "ES256"},
+		"rsa-1": {public: &keys.rsa.PublicKey, kty: "RSA", alg: "RS256"},
+		"ed-1":  {public: keys.ed25519.Public().(ed25519.PublicKey), kty: "OKP", alg: "EdDSA"},
+	}
+	for _, jwk := range set.Keys {
+		w, ok := want[jwk.Kid]
+		if !ok {
+			t.Fatalf("unexpected kid %s", jwk.Kid)
+		}
+		if jwk.Kty != w.kty || jwk.Alg != w.alg || jwk.Use != "sig" {
+			t.Errorf("%s: kty %s alg %s use %s, want %s %s sig", jwk.Kid, jwk.Kty, jwk.Alg, jwk.Use, w.kty, w.alg)
+		}
+		public, err := jwk.PublicKey()
+		if err != nil {
+			t.Fatalf("%s: PublicKey: %v", jwk.Kid, err)
+		}
+		if !w.public.Equal(public) {
+			t.Errorf("%s: the key changed in the round trip", jwk.Kid)
+		}
+	}
+
+	// a token of the active key verifies with the published key alone
+	token, _ := ks.Sign(testClaims())
+	public, _ := set.Keys[0].PublicKey()
+	if _, err := jwt.Parse(token, func(*jwt.Token) (any, error) { return public, nil }, jwt.WithValidMethods([]string{"ES256"})); err != nil {
+		t.Fatalf("verify with the JWKS key: %v", err)
+	}
+}
+
+func TestJWKPublicKeyRejectsInvalidKeys(t *testing.T) {
+	tests := []JWK{
+		{Kty: "oct", Kid: "secret"},
+		{Kty: "OKP", Crv: "X25519", X: "AAAA"},
+		{Kty: "OKP", Crv: "Ed25519", X: "AAAA"},
+		{Kty: "EC", Crv: "P-256", X: "AQ", Y: "Ag"}, // not on the curve
+		{Kty: "EC", Crv: "secp256k1", X: "AQ", Y: "Ag"},
+		{Kty: "RSA", N: "!!!", E: "AQAB"},
+	}
+	for _, jwk := range tests {
+		if _, err := jwk.PublicKey(); err == nil {
+			t.Errorf("PublicKey(%+v) accepted an invalid key", jwk)
+		}
+	}
+}
+
+func TestNewJWTKeyRejectsOtherCurves(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newJWTKey("", &key.PublicKey); err == nil || !strings.Contains(err.Error(), "P-256") {
+		t.Fatalf("newJWTKey(P-384) error = %v, want only P-256", err)
+	}
+}
@@ -60,6 +60,7 @@
 package pkg
 
 import (
+	"crypto/rand"
 	"errors"
 	"os"
 	"time"
@@ -71,9 +72,22 @@ import (
 type Claims struct {
 	UserId string `json:"id"`
 	Role   string `json:"role"`
+	// MFA is true when the login was completed with a second factor
+	MFA bool `json:"mfa,omitempty"`
+	// Purpose is only set on short-lived tokens that are not access tokens (e.g. 2FA challenge)
+	Purpose string `json:"purpose,omitempty"`
+	// Permissions are "code" (every cinema) or "code@<cinema_id>" (one cinema)
+	Permissions []string `json:"perms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+const (
+	PurposeTwoFactorChallenge = "2fa_challenge"
+	PurposePasswordReset      = "password_reset"
+)
+
+var ErrTokenWrongPurpose = errors.New("token is not valid for this purpose")
+
 func NewJWTClaims(userid string, role string) *Claims {
 	now := time.Now()
 	return &Claims{
@@ -87,21 +101,68 @@ func NewJWTClaims(userid string, role string) *Claims {
 	}
 }
 
+// NewChallengeClaims is issued after a correct password when the user still has to enter a TOTP code.
+// The jti makes it single use, a used challenge is remembered in Redis until it expires.
+func NewChallengeClaims(userid string, role string) *Claims {
+	now := time.Now()
+	return &Claims{
+		UserId:  userid,
+		Role:    role,
+		Purpose: PurposeTwoFactorChallenge,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        rand.Text(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * 5)),
+			Issuer:    os.Getenv("JWT_ISSUER"),
+		},
+	}
+}
+
+// NewPasswordResetClaims is issued after a correct password when an admin forced a password reset,
+// it only allows setting a new password
+func NewPasswordResetClaims(userid string, role string) *Claims {
+	now := time.Now()
+	return &Claims{
+		UserId:  userid,
+		Role:    role,
+		Purpose: PurposePasswordReset,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * 15)),
+			Issuer:    os.Getenv("JWT_ISSUER"),
+		},
+	}
+}
+
+// HasPermission checks a permission, cinemaID may be empty for actions that are not bound to a cinema.
+// A permission without scope is valid for every cinema.
+func (c *Claims) HasPermission(perm, cinemaID string) bool {
+	for _, p := range c.Permissions {
+		if p == perm {
+			return true
+		}
+		if cinemaID != "" && p == perm+"@"+cinemaID {
+			return true
+		}
+	}
+	return false
+}
+
+// GenToken signs the claims with the key set loaded at startup (see LoadKeySetFromEnv)
 func (c *Claims) GenToken() (string, error) {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return "", errors.New("no secret found")
+	ks, err := CurrentKeySet()
+	if err != nil {
+		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, c)
-	return token.SignedString([]byte(jwtSecret))
+	return ks.Sign(c)
 }
 
-func (c *Claims) VerifyToken(token string) error {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		return errors.New("no secret found")
+func (c *Claims) verify(token string) error {
+	ks, err := CurrentKeySet()
+	if err != nil {
+		return err
 	}
-	parsedToken, err := jwt.ParseWithClaims(token, c, func(t *jwt.Token) (any, error) { return []byte(jwtSecret), nil })
+	parsedToken, err := jwt.ParseWithClaims(token, c, ks.Keyfunc, jwt.WithValidMethods(ks.ValidMethods()))
 	if err != nil {
 		return err
 	}
@@ -117,3 +178,36 @@ func (c *Claims) VerifyToken(token string) error {
 	}
 	return nil
 }
+
+// VerifyToken only accepts access tokens, challenge tokens can't be used as a login
+func (c *Claims) VerifyToken(token string) error {
+	if err := c.verify(token); err != nil {
+		return err
+	}
+	if c.Purpose != "" {
+		return ErrTokenWrongPurpose
+	}
+	return nil
+}
+
+// VerifyChallengeToken only accepts 2FA challenge tokens
+func (c *Claims) VerifyChallengeToken(token string) error {
+	if err := c.verify(token); err != nil {
+		return err
+	}
+	if c.Purpose != PurposeTwoFactorChallenge {
+		return ErrTokenWrongPurpose
+	}
+	return nil
+}
+
+// VerifyPasswordResetToken only accepts password reset tokens
+func (c *Claims) VerifyPasswordResetToken(token string) error {
+	if err := c.verify(token); err != nil {
+		return err
+	}
+	if c.Purpose != PurposePasswordReset {
+		return ErrTokenWrongPurpose
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Notification is one message for a user, taken from the notifications outbox
+type Notification struct {
+	ID        int64           `json:"id"`
+	UserID    string          `json:"user_id"`
+	Email     string          `json:"email"`
+	Kind      string          `json:"kind"`
+	Title     string          `json:"title"`
+	Message   string          `json:"message"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Notifier delivers a notification (email, push, ...). An error means it is retried later.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// LogNotifier only writes the notification to the application log, useful in development
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, n Notification) error {
+	log.Printf("notify %s <%s>: %s - %s", n.UserID, n.Email, n.Title, n.Message)
+	return nil
+}
+
+// FileNotifier appends every notification as one json line to Path
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{Path: path}
+}
+
+func (f *FileNotifier) Notify(_ context.Context, n Notification) error {
+	line, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
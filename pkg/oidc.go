@@ -0,0 +1,307 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProvider is a generic OpenID Connect provider (Google, a local mock server, ...).
+// Endpoints are discovered from {Issuer}/.well-known/openid-configuration.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	HTTPClient   *http.Client
+
+	mu            sync.Mutex
+	discovery     *OIDCDiscovery
+	jwks          map[string]JWK
+	jwksFetchedAt time.Time
+}
+
+type OIDCDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type OIDCTokens struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// OIDCIdentity holds the claims of a verified ID token that we use
+type OIDCIdentity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Picture       string
+}
+
+type idTokenClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"` // bool, but some providers send "true"
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+	Picture       string `json:"picture"`
+	jwt.RegisteredClaims
+}
+
+func (p *OIDCProvider) client() *http.Client {
+	if p.HTTPClient != nil {
+		return p.HTTPClient
+	}
+	return &http.Client{Timeout: 10 * time.Second}
+}
+
+// Discover fetches (once) the provider metadata
+func (p *OIDCProvider) Discover(ctx context.Context) (*OIDCDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	var discovery OIDCDiscovery
+	if err := p.getJSON(ctx, wellKnown, &discovery); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	if discovery.Issuer != p.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q != %q", discovery.Issuer, p.Issuer)
+	}
+
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// AuthCodeURL builds the authorization request of the code flow with PKCE (S256)
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return discovery.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier string) (OIDCTokens, error) {
+	discovery, err := p.Discover(ctx)
+	if err != nil {
+		return OIDCTokens{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return OIDCTokens{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client().Do(req)
+	if err != nil {
+		return OIDCTokens{}, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return OIDCTokens{}, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return OIDCTokens{}, fmt.Errorf("oidc token endpoint returned %d: %s", res.StatusCode, body)
+	}
+
+	var tokens OIDCTokens
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return OIDCTokens{}, err
+	}
+	if tokens.IDToken == "" {
+		return OIDCTokens{}, errors.New("oidc token response has no id_token")
+	}
+	return tokens, nil
+}
+
+// VerifyIDToken checks signature (provider JWKS), issuer, audience, expiry and nonce
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (OIDCIdentity, error) {
+	if _, err := p.Discover(ctx); err != nil {
+		return OIDCIdentity{}, err
+	}
+
+	var claims idTokenClaims
+	_, err := jwt.ParseWithClaims(rawIDToken, &claims,
+		func(t *jwt.Token) (any, error) {
+			kid, _ := t.Header["kid"].(string)
+			jwk, err := p.findKey(ctx, kid)
+			if err != nil {
+				return nil, err
+			}
+			if jwk.Alg != "" && jwk.Alg != t.Method.Alg() {
+				return nil, fmt.Errorf("unexpected signing method %s", t.Method.Alg())
+			}
+			return jwk.PublicKey()
+		},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "EdDSA"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return OIDCIdentity{}, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return OIDCIdentity{}, errors.New("invalid id_token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return OIDCIdentity{}, errors.New("invalid id_token: missing sub")
+	}
+
+	verified := false
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+
+	return OIDCIdentity{
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(claims.Email),
+		EmailVerified: verified,
+		GivenName:     claims.GivenName,
+		FamilyName:    claims.FamilyName,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// findKey looks the kid up in the cached JWKS and refetches once when it is unknown (key rotation)
+func (p *OIDCProvider) findKey(ctx context.Context, kid string) (JWK, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	lookup := func() (JWK, bool) {
+		if kid == "" && len(p.jwks) == 1 {
+			for _, k := range p.jwks {
+				return k, true
+			}
+		}
+		k, ok := p.jwks[kid]
+		return k, ok
+	}
+
+	if key, ok := lookup(); ok && time.Since(p.jwksFetchedAt) < time.Hour {
+		return key, nil
+	}
+
+	// don't hammer the provider with unknown kids
+	if time.Since(p.jwksFetchedAt) < 10*time.Second && p.jwks != nil {
+		return JWK{}, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	var set JWKSet
+	if err := p.getJSON(ctx, p.discovery.JWKSURI, &set); err != nil {
+		return JWK{}, fmt.Errorf("oidc jwks: %w", err)
+	}
+	p.jwks = map[string]JWK{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		p.jwks[k.Kid] = k
+	}
+	p.jwksFetchedAt = time.Now()
+
+	if key, ok := lookup(); ok {
+		return key, nil
+	}
+	return JWK{}, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, endpoint string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := p.client().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", endpoint, res.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(dest)
+}
+
+// GenRandomString returns n random bytes encoded as base64url (used for state, nonce and PKCE)
+func GenRandomString(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// GenPKCE returns a code verifier and its S256 challenge (RFC 7636)
+func GenPKCE() (string, string, error) {
+	verifier, err := GenRandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
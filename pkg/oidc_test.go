@@ -0,0 +1,270 @@
+package pkg
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockOIDCServer is a minimal provider: discovery, JWKS and a token endpoint that checks the PKCE verifier
+// against the challenge of the authorization request
+type mockOIDCServer struct {
+	t   *testing.T
+	srv *httptest.Server
+	key *ecdsa.PrivateKey
+
+	discoveryIssuer string // issuer in the discovery document, default the server URL
+	challenge       string // code_challenge and nonce the "browser" brought to /authorize
+	nonce           string
+	idToken         func(jwt.MapClaims) // changes the claims of the next id_token
+}
+
+const (
+	mockClientID = "tickitz-test"
+	mockCode     = "auth-code-1"
+)
+
+func newMockOIDCServer(t *testing.T) *mockOIDCServer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	m := &mockOIDCServer{t: t, key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.discoveryIssuer
+		if issuer == "" {
+			issuer = m.srv.URL
+		}
+		json.NewEncoder(w).Encode(OIDCDiscovery{
+			Issuer:                issuer,
+			AuthorizationEndpoint: m.srv.URL + "/authorize",
+			TokenEndpoint:         m.srv.URL + "/token",
+			JWKSURI:               m.srv.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		jwk, err := NewJWK(&JWTKey{ID: "mock-1", Method: jwt.SigningMethodES256, Public: &m.key.PublicKey})
+		if err != nil {
+			t.Errorf("NewJWK: %v", err)
+		}
+		json.NewEncoder(w).Encode(JWKSet{Keys: []JWK{jwk}})
+	})
+	mux.HandleFunc("/token", m.token)
+
+	m.srv = httptest.NewServer(mux)
+	t.Cleanup(m.srv.Close)
+	return m
+}
+
+func (m *mockOIDCServer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad form", http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case r.PostForm.Get("grant_type") != "authorization_code",
+		r.PostForm.Get("code") != mockCode,
+		r.PostForm.Get("client_id") != mockClientID,
+		base64.RawURLEncoding.EncodeToString(sum[:]) != m.challenge:
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            m.srv.URL,
+		"aud":            mockClientID,
+		"sub":            "subject-1",
+		"exp":            time.Now().Add(5 * time.Minute).Unix(),
+		"iat":            time.Now().Unix(),
+		"email":          "Alice@Example.com",
+		"email_verified": true,
+		"given_name":     "Alice",
+		"nonce":          m.nonce,
+	}
+	if m.idToken != nil {
+		m.idToken(claims)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = "mock-1"
+	signed, err := token.SignedString(m.key)
+	if err != nil {
+		m.t.Errorf("sign id_token: %v", err)
+	}
+	json.NewEncoder(w).Encode(OIDCTokens{AccessToken: "at", IDToken: signed, TokenType: "Bearer"})
+}
+
+func (m *mockOIDCServer) provider() *OIDCProvider {
+	return &OIDCProvider{
+		Name:        "mock",
+		Issuer:      m.srv.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://localhost:3000/api/v1/auth/oidc/mock/callback",
+		HTTPClient:  m.srv.Client(),
+	}
+}
+
+// startLogin does what the Login handler and the browser do: build the authorization URL and hand the
+// challenge to the provider. It returns the verifier and nonce the callback gets from the saved state.
+func (m *mockOIDCServer) startLogin(t *testing.T, p *OIDCProvider) (verifier, nonce string) {
+	t.Helper()
+	verifier, challenge, err := GenPKCE()
+	if err != nil {
+		t.Fatalf("GenPKCE: %v", err)
+	}
+	nonce, _ = GenRandomString(16)
+
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", nonce, challenge)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse %s: %v", authURL, err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("nonce") != nonce || q.Get("state") != "state-1" {
+		t.Fatalf("authorization URL misses PKCE, nonce or state: %s", authURL)
+	}
+	m.challenge, m.nonce = q.Get("code_challenge"), q.Get("nonce")
+	return verifier, nonce
+}
+
+func TestOIDCLoginFlow(t *testing.T) {
+	m := newMockOIDCServer(t)
+	p := m.provider()
+	ctx := context.Background()
+
+	verifier, nonce := m.startLogin(t, p)
+	tokens, err := p.Exchange(ctx, mockCode, verifier)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	identity, err := p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+	if err != nil {
+		t.Fatalf("VerifyIDToken: %v", err)
+	}
+
+	want := OIDCIdentity{Subject: "subject-1", Email: "alice@example.com", EmailVerified: true, GivenName: "Alice"}
+	if identity != want {
+		t.Errorf("identity = %+v, want %+v", identity, want)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	m := newMockOIDCServer(t)
+	p := m.provider()
+
+	m.startLogin(t, p)
+	otherVerifier, _, _ := GenPKCE()
+	if _, err := p.Exchange(context.Background(), mockCode, otherVerifier); err == nil {
+		t.Fatal("Exchange with a verifier of another login must fail")
+	}
+}
+
+func TestOIDCVerifyIDTokenRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims func(jwt.MapClaims)
+		nonce  func(nonce string) string
+		errHas string
+	}{
+		{name: "nonce of another login", nonce: func(string) string { return "other-nonce" }, errHas: "nonce"},
+		{name: "no nonce in token", claims: func(c jwt.MapClaims) { delete(c, "nonce") }, errHas: "nonce"},
+		{name: "other issuer", claims: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, errHas: "issuer"},
+		{name: "other audience", claims: func(c jwt.MapClaims) { c["aud"] = "another-client" }, errHas: "audience"},
+		{name: "expired", claims: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }, errHas: "expired"},
+		{name: "no subject", claims: func(c jwt.MapClaims) { delete(c, "sub") }, errHas: "sub"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockOIDCServer(t)
+			p := m.provider()
+			ctx := context.Background()
+
+			verifier, nonce := m.startLogin(t, p)
+			m.idToken = tt.claims
+			tokens, err := p.Exchange(ctx, mockCode, verifier)
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+
+			expectedNonce := nonce
+			if tt.nonce != nil {
+				expectedNonce = tt.nonce(nonce)
+			}
+			_, err = p.VerifyIDToken(ctx, tokens.IDToken, expectedNonce)
+			if err == nil || !strings.Contains(err.Error(), tt.errHas) {
+				t.Fatalf("VerifyIDToken error = %v, want one about %q", err, tt.errHas)
+			}
+		})
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	m := newMockOIDCServer(t)
+	m.discoveryIssuer = "https://accounts.other.example.com"
+
+	if _, err := m.provider().AuthCodeURL(context.Background(), "s", "n", "c"); err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Fatalf("AuthCodeURL error = %v, want issuer mismatch", err)
+	}
+}
+
+// Only a verified email may be linked to an existing account (see FindOrCreateUserByIdentity), so every
+// form of "not verified" has to end up as EmailVerified false
+func TestOIDCEmailVerified(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  bool
+	}{
+		{name: "bool true", value: true, want: true},
+		{name: "string true", value: "true", want: true},
+		{name: "bool false", value: false, want: false},
+		{name: "string false", value: "false", want: false},
+		{name: "missing", value: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockOIDCServer(t)
+			p := m.provider()
+			ctx := context.Background()
+
+			verifier, nonce := m.startLogin(t, p)
+			m.idToken = func(c jwt.MapClaims) {
+				if tt.value == nil {
+					delete(c, "email_verified")
+				} else {
+					c["email_verified"] = tt.value
+				}
+			}
+			tokens, err := p.Exchange(ctx, mockCode, verifier)
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			identity, err := p.VerifyIDToken(ctx, tokens.IDToken, nonce)
+			if err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if identity.EmailVerified != tt.want {
+				t.Errorf("EmailVerified = %v, want %v", identity.EmailVerified, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Package pagination reads page / per_page / cursor from the query string and builds the
+// {items, page, per_page, total, next_cursor} envelope that every list endpoint returns.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor points at the last row of the previous page (keyset pagination).
+// Sort is the ordering the cursor was made for, Value the sort key of the row as text, ID the tie-breaker.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Params is what the repositories need, with a Cursor the page number is ignored
+type Params struct {
+	Page    int
+	PerPage int
+	Cursor  *Cursor
+}
+
+// Page is the response envelope, Page is left out when the client paginates with a cursor
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	Page       int    `json:"page,omitempty"`
+	PerPage    int    `json:"per_page"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Parse reads page, per_page and cursor. per_page falls back to defaultPerPage and is capped at maxPerPage.
+func Parse(query url.Values, defaultPerPage, maxPerPage int) (Params, error) {
+	p := Params{Page: 1, PerPage: defaultPerPage}
+
+	if v := query.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page <= 0 {
+			return p, errors.New("page must be a positive number")
+		}
+		p.Page = page
+	}
+
+	if v := query.Get("per_page"); v != "" {
+		perPage, err := strconv.Atoi(v)
+		if err != nil || perPage <= 0 {
+			return p, errors.New("per_page must be a positive number")
+		}
+		p.PerPage = min(perPage, maxPerPage)
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := DecodeCursor(v)
+		if err != nil {
+			return p, err
+		}
+		p.Cursor = &cursor
+	}
+
+	return p, nil
+}
+
+// Offset is 0 in cursor mode, the cursor condition already skips the previous pages
+func (p Params) Offset() int {
+	if p.Cursor != nil {
+		return 0
+	}
+	return (p.Page - 1) * p.PerPage
+}
+
+// Limit fetches one row more than the page, that extra row tells us there is a next page
+func (p Params) Limit() int {
+	return p.PerPage + 1
+}
+
+// CursorFor returns the cursor of the request if it was made for this sort, a cursor from another
+// ordering would skip or repeat rows
+func (p Params) CursorFor(sort string) (*Cursor, error) {
+	if p.Cursor == nil {
+		return nil, nil
+	}
+	if p.Cursor.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return p.Cursor, nil
+}
+
+// NewPage cuts off the extra row fetched by Limit and builds next_cursor from the last item,
+// cursorOf gets the index of that item. Without cursorOf (orderings that can't be continued
+// with a cursor) there is no next_cursor.
+func NewPage[T any](items []T, p Params, total int, cursorOf func(i int) Cursor) Page[T] {
+	page := Page[T]{
+		Items:   items,
+		PerPage: p.PerPage,
+		Total:   total,
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if p.Cursor == nil {
+		page.Page = p.Page
+	}
+
+	if len(page.Items) > p.PerPage {
+		page.Items = page.Items[:p.PerPage]
+		if cursorOf != nil {
+			page.NextCursor = EncodeCursor(cursorOf(p.PerPage - 1))
+		}
+	}
+	return page
+}
+
+func EncodeCursor(c Cursor) string {
+	bt, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(bt)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var c Cursor
+	bt, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(bt, &c); err != nil || c.ID == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -0,0 +1,177 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	validCursor := EncodeCursor(Cursor{Sort: "title:asc", Value: "Dune", ID: "12"})
+	tests := []struct {
+		name    string
+		query   string
+		want    Params
+		wantErr bool
+	}{
+		{name: "defaults", query: "", want: Params{Page: 1, PerPage: 20}},
+		{name: "page and per_page", query: "page=3&per_page=10", want: Params{Page: 3, PerPage: 10}},
+		{name: "per_page above max is capped", query: "per_page=500", want: Params{Page: 1, PerPage: 50}},
+		{name: "per_page exactly max", query: "per_page=50", want: Params{Page: 1, PerPage: 50}},
+		{name: "cursor", query: "cursor=" + validCursor, want: Params{Page: 1, PerPage: 20, Cursor: &Cursor{Sort: "title:asc", Value: "Dune", ID: "12"}}},
+		{name: "negative page", query: "page=-1", wantErr: true},
+		{name: "page zero", query: "page=0", wantErr: true},
+		{name: "page not a number", query: "page=two", wantErr: true},
+		{name: "negative per_page", query: "per_page=-5", wantErr: true},
+		{name: "per_page zero", query: "per_page=0", wantErr: true},
+		{name: "bad cursor", query: "cursor=not-a-cursor", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			got, err := Parse(query, 20, 50)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %+v, want an error", tt.query, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.query, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParamsOffsetAndLimit(t *testing.T) {
+	p := Params{Page: 3, PerPage: 10}
+	if p.Offset() != 20 || p.Limit() != 11 {
+		t.Errorf("page 3: offset %d limit %d, want 20 and 11", p.Offset(), p.Limit())
+	}
+	p.Cursor = &Cursor{Sort: "title:asc", ID: "1"}
+	if p.Offset() != 0 {
+		t.Errorf("offset with cursor = %d, want 0", p.Offset())
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{Sort: "updated_at:desc", Value: "2025-01-02 10:00:00.123456+00", ID: "42"},
+		{Sort: "created_at:desc", Value: "-infinity", ID: "0b8f6c3e-1b7a-4d5e-9a3f-2c1d0e9f8a7b"},
+		{Sort: "title:asc", Value: "Spider-Man: \"No Way Home\" & más", ID: "7"},
+	}
+	for _, c := range cursors {
+		encoded := EncodeCursor(c)
+		if _, err := url.ParseQuery("cursor=" + encoded); err != nil {
+			t.Errorf("cursor %q is not query safe: %v", encoded, err)
+		}
+		got, err := DecodeCursor(encoded)
+		if err != nil {
+			t.Fatalf("DecodeCursor(%q): %v", encoded, err)
+		}
+		if got != c {
+			t.Errorf("round trip = %+v, want %+v", got, c)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	valid := EncodeCursor(Cursor{Sort: "title:asc", Value: "Dune", ID: "12"})
+	flipped := []byte(valid)
+	flipped[0] ^= 0x20
+
+	tests := map[string]string{
+		"empty":             "",
+		"not base64":        "***",
+		"padded base64":     base64.URLEncoding.EncodeToString([]byte(`{"s":"title:asc","v":"Dune","id":"12"}`)) + "=",
+		"tampered":          string(flipped),
+		"cut off":           valid[:len(valid)-4],
+		"not json":          base64.RawURLEncoding.EncodeToString([]byte("title:asc|Dune|12")),
+		"empty id":          EncodeCursor(Cursor{Sort: "title:asc", Value: "Dune"}),
+		"id of wrong type":  base64.RawURLEncoding.EncodeToString([]byte(`{"s":"title:asc","v":"Dune","id":12}`)),
+		"json array":        base64.RawURLEncoding.EncodeToString([]byte(`["title:asc","Dune","12"]`)),
+		"json null":         base64.RawURLEncoding.EncodeToString([]byte(`null`)),
+		"trailing garbage":  valid + "!!",
+		"standard alphabet": base64.RawStdEncoding.EncodeToString([]byte(`{"s":"a?","v":">>>","id":"1"}`)),
+	}
+	for name, s := range tests {
+		t.Run(name, func(t *testing.T) {
+			if c, err := DecodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+				t.Fatalf("DecodeCursor(%q) = %+v, %v, want ErrInvalidCursor", s, c, err)
+			}
+		})
+	}
+}
+
+func TestCursorFor(t *testing.T) {
+	none := Params{Page: 1, PerPage: 20}
+	if c, err := none.CursorFor("title:asc"); c != nil || err != nil {
+		t.Fatalf("without cursor = %v, %v, want nil, nil", c, err)
+	}
+
+	p := Params{PerPage: 20, Cursor: &Cursor{Sort: "title:asc", Value: "Dune", ID: "12"}}
+	if c, err := p.CursorFor("title:asc"); err != nil || c != p.Cursor {
+		t.Fatalf("same sort = %v, %v, want the cursor", c, err)
+	}
+	for _, other := range []string{"title:desc", "release_date:asc", ""} {
+		if _, err := p.CursorFor(other); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("CursorFor(%q) error = %v, want ErrInvalidCursor", other, err)
+		}
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	cursorOf := func(items []string) func(int) Cursor {
+		return func(i int) Cursor { return Cursor{Sort: "title:asc", Value: items[i], ID: items[i]} }
+	}
+
+	t.Run("extra row is cut off and gives next_cursor", func(t *testing.T) {
+		items := []string{"a", "b", "c", "d"}
+		page := NewPage(items, Params{Page: 2, PerPage: 3}, 10, cursorOf(items))
+		if !reflect.DeepEqual(page.Items, []string{"a", "b", "c"}) {
+			t.Errorf("items = %v, want the first 3", page.Items)
+		}
+		if page.Page != 2 || page.PerPage != 3 || page.Total != 10 {
+			t.Errorf("envelope = %+v", page)
+		}
+		next, err := DecodeCursor(page.NextCursor)
+		if err != nil || next.ID != "c" {
+			t.Errorf("next_cursor = %+v, %v, want the last item of the page", next, err)
+		}
+	})
+
+	t.Run("last page has no next_cursor", func(t *testing.T) {
+		items := []string{"a", "b", "c"}
+		page := NewPage(items, Params{Page: 1, PerPage: 3}, 3, cursorOf(items))
+		if len(page.Items) != 3 || page.NextCursor != "" {
+			t.Errorf("page = %+v, want 3 items and no next_cursor", page)
+		}
+	})
+
+	t.Run("no next_cursor without cursorOf", func(t *testing.T) {
+		page := NewPage([]string{"a", "b", "c", "d"}, Params{Page: 1, PerPage: 3}, 10, nil)
+		if len(page.Items) != 3 || page.NextCursor != "" {
+			t.Errorf("page = %+v, want 3 items and no next_cursor", page)
+		}
+	})
+
+	t.Run("cursor mode leaves page out", func(t *testing.T) {
+		items := []string{"d", "e"}
+		page := NewPage(items, Params{Page: 1, PerPage: 3, Cursor: &Cursor{Sort: "title:asc", ID: "c"}}, 5, cursorOf(items))
+		if page.Page != 0 {
+			t.Errorf("page = %d, want 0 (omitted)", page.Page)
+		}
+	})
+
+	t.Run("nil items become an empty list", func(t *testing.T) {
+		page := NewPage[string](nil, Params{Page: 1, PerPage: 3}, 0, nil)
+		if page.Items == nil || len(page.Items) != 0 {
+			t.Errorf("items = %#v, want []", page.Items)
+		}
+	})
+}
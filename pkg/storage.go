@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Storage keeps uploaded files (posters, backdrops, profile pictures, ...). A key looks like
+// "posters/1727000000_images_.png", the database only stores the part after the folder.
+type Storage interface {
+	// Put writes the object, an existing object with the same key is replaced
+	Put(ctx context.Context, key string, body io.Reader, contentType string) error
+	// Delete removes the object, a key that does not exist is not an error
+	Delete(ctx context.Context, key string) error
+	// URL is where a client can download the object, signed and temporary when the storage is private
+	URL(ctx context.Context, key string) (string, error)
+}
+
+var ErrInvalidStorageKey = errors.New("invalid storage key")
+
+// cleanKey removes "..", leading slashes and the like, so a key can't point outside of the storage
+func cleanKey(key string) (string, error) {
+	cleaned := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(key, "\\", "/")), "/")
+	if cleaned == "" {
+		return "", ErrInvalidStorageKey
+	}
+	return cleaned, nil
+}
+
+// LocalStorage keeps the files in a folder on disk, BaseURL is where that folder is served
+// (/api/v1/img for public). Only usable with a single replica or a shared volume.
+type LocalStorage struct {
+	Root    string
+	BaseURL string
+}
+
+func NewLocalStorage(root, baseURL string) *LocalStorage {
+	return &LocalStorage{Root: root, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (l *LocalStorage) Put(_ context.Context, key string, body io.Reader, _ string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	location := filepath.Join(l.Root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(location), 0o755); err != nil {
+		return err
+	}
+
+	// write next to the target and rename, a reader never sees half a file
+	tmp, err := os.CreateTemp(filepath.Dir(location), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), location)
+}
+
+func (l *LocalStorage) Delete(_ context.Context, key string) error {
+	key, err := cleanKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(l.Root, filepath.FromSlash(key))); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *LocalStorage) URL(_ context.Context, key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return l.BaseURL + "/" + key, nil
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{key: "posters/1727000000_poster.png", want: "posters/1727000000_poster.png"},
+		{key: "/posters/a.png", want: "posters/a.png"},
+		{key: "../../etc/passwd", want: "etc/passwd"},
+		{key: "posters/../../../etc/passwd", want: "etc/passwd"},
+		{key: `..\..\windows\win.ini`, want: "windows/win.ini"},
+		{key: "posters//./a.png", want: "posters/a.png"},
+		{key: "", wantErr: true},
+		{key: "/", wantErr: true},
+		{key: "..", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := cleanKey(tt.key)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidStorageKey) {
+				t.Errorf("cleanKey(%q) error = %v, want ErrInvalidStorageKey", tt.key, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("cleanKey(%q) = %q, %v, want %q", tt.key, got, err, tt.want)
+		}
+	}
+}
+
+// tempFiles lists the .upload-* files Put left behind in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, ".upload-*"))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	return matches
+}
+
+func TestLocalStoragePut(t *testing.T) {
+	l := NewLocalStorage(t.TempDir(), "/api/v1/img/")
+	ctx := context.Background()
+
+	if err := l.Put(ctx, "posters/a.png", strings.NewReader("first"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := l.Put(ctx, "posters/a.png", strings.NewReader("second"), "image/png"); err != nil {
+		t.Fatalf("Put replace: %v", err)
+	}
+
+	location := filepath.Join(l.Root, "posters", "a.png")
+	content, err := os.ReadFile(location)
+	if err != nil || string(content) != "second" {
+		t.Fatalf("file = %q, %v, want the replaced content", content, err)
+	}
+	info, _ := os.Stat(location)
+	if perm := info.Mode().Perm(); perm != 0o644 {
+		t.Errorf("mode = %o, want 644", perm)
+	}
+	if left := tempFiles(t, filepath.Dir(location)); len(left) != 0 {
+		t.Errorf("temp files left behind: %v", left)
+	}
+
+	if got, _ := l.URL(ctx, "posters/a.png"); got != "/api/v1/img/posters/a.png" {
+		t.Errorf("URL = %s, want /api/v1/img/posters/a.png", got)
+	}
+}
+
+type failingReader struct{ sent bool }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if !r.sent {
+		r.sent = true
+		return copy(p, "half a fi"), nil
+	}
+	return 0, errors.New("connection reset")
+}
+
+// an upload that breaks off must leave the old file as it was, not half of the new one
+func TestLocalStoragePutIsAtomic(t *testing.T) {
+	l := NewLocalStorage(t.TempDir(), "/img")
+	ctx := context.Background()
+
+	if err := l.Put(ctx, "posters/a.png", strings.NewReader("old poster"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := l.Put(ctx, "posters/a.png", &failingReader{}, "image/png"); err == nil {
+		t.Fatal("Put with a failing body must return the error")
+	}
+
+	content, err := os.ReadFile(filepath.Join(l.Root, "posters", "a.png"))
+	if err != nil || string(content) != "old poster" {
+		t.Fatalf("file = %q, %v, want the old content", content, err)
+	}
+	if left := tempFiles(t, filepath.Join(l.Root, "posters")); len(left) != 0 {
+		t.Errorf("temp files left behind: %v", left)
+	}
+}
+
+func TestLocalStorageKeysStayInRoot(t *testing.T) {
+	parent := t.TempDir()
+	l := NewLocalStorage(filepath.Join(parent, "uploads"), "/img")
+	ctx := context.Background()
+
+	if err := l.Put(ctx, "../escaped.txt", strings.NewReader("x"), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escaped.txt")); !os.IsNotExist(err) {
+		t.Fatalf("Put wrote outside of the root (stat err = %v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(l.Root, "escaped.txt")); err != nil {
+		t.Fatalf("file not inside the root: %v", err)
+	}
+
+	// Delete is cleaned the same way, it can't remove a file next to the root
+	outside := filepath.Join(parent, "keep.txt")
+	if err := os.WriteFile(outside, []byte("keep"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Delete(ctx, "../keep.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("Delete removed a file outside of the root: %v", err)
+	}
+
+	if err := l.Put(ctx, "..", strings.NewReader("x"), ""); !errors.Is(err, ErrInvalidStorageKey) {
+		t.Fatalf("Put(\"..\") error = %v, want ErrInvalidStorageKey", err)
+	}
+}
+
+func TestLocalStorageDelete(t *testing.T) {
+	l := NewLocalStorage(t.TempDir(), "/img")
+	ctx := context.Background()
+
+	if err := l.Put(ctx, "stills/b.png", strings.NewReader("b"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := l.Delete(ctx, "stills/b.png"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(l.Root, "stills", "b.png")); !os.IsNotExist(err) {
+		t.Fatalf("file still exists: %v", err)
+	}
+	if err := l.Delete(ctx, "stills/b.png"); err != nil {
+		t.Fatalf("Delete of a missing file: %v", err)
+	}
+}
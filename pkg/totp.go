@@ -0,0 +1,149 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TOTPConfig follows RFC 6238 (HMAC-SHA1), which is what authenticator apps expect
+type TOTPConfig struct {
+	Issuer    string
+	Digits    int
+	Period    uint64
+	Skew      uint64
+	SecretLen int
+}
+
+func NewTOTPConfig(issuer string) *TOTPConfig {
+	return &TOTPConfig{
+		Issuer:    issuer,
+		Digits:    6,
+		Period:    30,
+		Skew:      1,
+		SecretLen: 20,
+	}
+}
+
+var b32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenSecret creates a random base32 secret for a new enrolment
+func (t *TOTPConfig) GenSecret() (string, error) {
+	secret := make([]byte, t.SecretLen)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return b32NoPadding.EncodeToString(secret), nil
+}
+
+// URI builds the otpauth:// URI that is rendered as QR code by authenticator apps
+func (t *TOTPConfig) URI(account, secret string) string {
+	label := url.PathEscape(t.Issuer) + ":" + url.PathEscape(account)
+
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", t.Issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(t.Digits))
+	q.Set("period", fmt.Sprint(t.Period))
+
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// Step returns the time step counter for the given time
+func (t *TOTPConfig) Step(now time.Time) uint64 {
+	return uint64(now.Unix()) / t.Period
+}
+
+// GenCode generates the code of a specific time step
+func (t *TOTPConfig) GenCode(secret string, step uint64) (string, error) {
+	key, err := b32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", errors.New("invalid totp secret")
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, step)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < t.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", t.Digits, value%mod), nil
+}
+
+// Validate checks the code against the current step and the allowed skew.
+// It returns the matched step so the caller can reject replays of the same code.
+func (t *TOTPConfig) Validate(code, secret string, now time.Time) (uint64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != t.Digits {
+		return 0, false
+	}
+
+	current := t.Step(now)
+	for i := uint64(0); i <= 2*t.Skew; i++ {
+		step := current - t.Skew + i
+		expected, err := t.GenCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+const (
+	recoveryAlphabet  = "abcdefghjkmnpqrstuvwxyz23456789"
+	recoveryByteLimit = 256 - 256%len(recoveryAlphabet) // random bytes below it map evenly to the alphabet
+)
+
+// GenRecoveryCodes creates one-time codes formatted as xxxxx-xxxxx
+func GenRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		code := make([]byte, 0, 10)
+		for len(code) < 10 {
+			raw := make([]byte, 16)
+			if _, err := rand.Read(raw); err != nil {
+				return nil, err
+			}
+			for _, b := range raw {
+				// 256 is not a multiple of 31, bytes from 248 on would make the first letters more likely
+				if int(b) >= recoveryByteLimit || len(code) == 10 {
+					continue
+				}
+				code = append(code, recoveryAlphabet[int(b)%len(recoveryAlphabet)])
+			}
+		}
+		codes = append(codes, string(code[:5])+"-"+string(code[5:]))
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode hashes a recovery code before it is stored.
+// The codes are random so a plain SHA-256 is enough (no salt/argon2 needed).
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
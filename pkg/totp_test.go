@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6238 Appendix B, SHA1 rows. The seed is the ASCII string "12345678901234567890".
+func TestTOTPGenCodeRFC6238(t *testing.T) {
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	cfg := NewTOTPConfig("Tickitz")
+	cfg.Digits = 8
+
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := cfg.GenCode(secret, cfg.Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatalf("GenCode(%d): %v", tt.unix, err)
+		}
+		if got != tt.want {
+			t.Errorf("GenCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPValidate(t *testing.T) {
+	cfg := NewTOTPConfig("Tickitz")
+	secret, err := cfg.GenSecret()
+	if err != nil {
+		t.Fatalf("GenSecret: %v", err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	current := cfg.Step(now)
+
+	codeAt := func(step uint64) string {
+		code, err := cfg.GenCode(secret, step)
+		if err != nil {
+			t.Fatalf("GenCode: %v", err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep uint64
+		wantOK   bool
+	}{
+		{name: "current step", code: codeAt(current), wantStep: current, wantOK: true},
+		{name: "previous step within skew", code: codeAt(current - 1), wantStep: current - 1, wantOK: true},
+		{name: "next step within skew", code: codeAt(current + 1), wantStep: current + 1, wantOK: true},
+		{name: "outside skew", code: codeAt(current - 2), wantOK: false},
+		{name: "wrong length", code: "12345", wantOK: false},
+		{name: "surrounding spaces", code: " " + codeAt(current) + " ", wantStep: current, wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := cfg.Validate(tt.code, secret, now)
+			if ok != tt.wantOK || (ok && step != tt.wantStep) {
+				t.Errorf("Validate = (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+// Validate accepts the same code again within its window, rejecting the replay is the job of
+// TwoFactorRepository.MarkStepUsed with the returned step
+func TestTOTPValidateReturnsStepForReplayCheck(t *testing.T) {
+	cfg := NewTOTPConfig("Tickitz")
+	secret, _ := cfg.GenSecret()
+	now := time.Now()
+	code, _ := cfg.GenCode(secret, cfg.Step(now))
+
+	first, ok1 := cfg.Validate(code, secret, now)
+	second, ok2 := cfg.Validate(code, secret, now.Add(20*time.Second))
+	if !ok1 || !ok2 || first != second {
+		t.Fatalf("Validate twice = (%d, %v), (%d, %v), want the same step both times", first, ok1, second, ok2)
+	}
+}
+
+func TestGenRecoveryCodes(t *testing.T) {
+	format := regexp.MustCompile(`^[` + recoveryAlphabet + `]{5}-[` + recoveryAlphabet + `]{5}$`)
+
+	codes, err := GenRecoveryCodes(500)
+	if err != nil {
+		t.Fatalf("GenRecoveryCodes: %v", err)
+	}
+	if len(codes) != 500 {
+		t.Fatalf("got %d codes, want 500", len(codes))
+	}
+
+	seen := map[string]bool{}
+	counts := map[rune]int{}
+	for _, code := range codes {
+		if !format.MatchString(code) {
+			t.Fatalf("code %q has the wrong format", code)
+		}
+		if seen[code] {
+			t.Fatalf("code %q generated twice", code)
+		}
+		seen[code] = true
+		for _, r := range strings.ReplaceAll(code, "-", "") {
+			counts[r]++
+		}
+	}
+
+	// 5000 letters over 31 symbols, about 161 each. This only catches gross mistakes like symbols that never
+	// appear, the modulo bias (9 instead of 8 chances in 256 for the first 8 symbols) is too small to see here.
+	for _, r := range recoveryAlphabet {
+		if counts[r] < 80 || counts[r] > 260 {
+			t.Errorf("symbol %q appeared %d times, expected about 161", r, counts[r])
+		}
+	}
+}
+
+func TestHashRecoveryCodeNormalizes(t *testing.T) {
+	if HashRecoveryCode(" ABCDE-fghjk ") != HashRecoveryCode("abcde-fghjk") {
+		t.Error("HashRecoveryCode must ignore case and surrounding spaces")
+	}
+}